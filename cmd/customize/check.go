@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// skipCheckDirs aren't searched for remaining names, since they're removed or rebuilt after customization.
+var skipCheckDirs = []string{".git", "build", filepath.Join("cmd", "customize")}
+
+// inSkippedDir reports whether path is in one of the skipCheckDirs.
+func inSkippedDir(path string) bool {
+	for _, dir := range skipCheckDirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+type occurrence struct {
+	path string
+	line int
+	text string
+}
+
+func (o occurrence) String() string {
+	return fmt.Sprintf("%s:%d: %s", filepath.ToSlash(o.path), o.line, o.text)
+}
+
+// findRemaining lists each line under root that will still contain name, ignoring case, once the plan is applied.
+// These are places customize doesn't change safely, like Go identifiers, so they need to be changed by hand.
+func findRemaining(p *plan, root, name string) ([]occurrence, error) {
+	name = strings.ToLower(name)
+	var found []occurrence
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p.isRemoved(path) || d.IsDir() && slices.Contains(skipCheckDirs, path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		content, err := p.content(path)
+		if err != nil {
+			return err
+		}
+		if isBinary(content) {
+			return nil
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(nil, len(content)+1)
+		for n := 1; scanner.Scan(); n++ {
+			if strings.Contains(strings.ToLower(scanner.Text()), name) {
+				found = append(found, occurrence{path: p.finalPath(path), line: n, text: strings.TrimSpace(scanner.Text())})
+			}
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for remaining uses of '%s': %w", name, err)
+	}
+	return found, nil
+}
+
+func printRemaining(out io.Writer, name string, found []occurrence) {
+	if len(found) == 0 {
+		_, _ = fmt.Fprintf(out, "No uses of '%s' remain.\n", name)
+		return
+	}
+	_, _ = fmt.Fprintf(out, "%d uses of '%s' remain, which need to be changed by hand:\n", len(found), name)
+	for _, o := range found {
+		_, _ = fmt.Fprintln(out, " ", o)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := map[string]struct {
+		old, new string
+		expected string
+	}{
+		"Same": {
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			expected: "",
+		},
+		"Changed line": {
+			old: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new: "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			expected: "--- a/old.txt\n+++ b/new.txt\n" +
+				"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		"Separate hunks": {
+			old: "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			new: "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n",
+			expected: "--- a/old.txt\n+++ b/new.txt\n" +
+				"@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n" +
+				"@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n",
+		},
+		"Added to empty": {
+			old:      "",
+			new:      "a\n",
+			expected: "--- a/old.txt\n+++ b/new.txt\n@@ -0,0 +1 @@\n+a\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, unifiedDiff("old.txt", "new.txt", []byte(tc.old), []byte(tc.new)))
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// manifestPath is where the template's optional features are declared.
+var manifestPath = filepath.Join("cmd", "customize", "features.json")
+
+// feature is an optional part of the template, which can be excluded when customizing.
+//
+// Excluding a feature removes its Paths, and the regions of other files that are marked for it.
+// Markers are comments, so they work in Go, templ, SQL, YAML, Dockerfiles, and Markdown:
+//   - A line ending with "// feature:NAME" is removed when NAME is excluded.
+//   - Lines between "// feature:NAME begin" and "// feature:NAME end" are removed when NAME is excluded.
+//   - A line of "// feature:!NAME: CODE" is replaced with CODE when NAME is excluded, and removed otherwise.
+//   - Lines between "// feature:!NAME begin" and "// feature:!NAME end" are uncommented when NAME is excluded, and removed otherwise.
+//
+// Any of "//", "--", or "#" can start a marker, as can "<!--" for begin and end markers that aren't negated.
+// Markers themselves are always removed.
+type feature struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Paths       []string `json:"paths"`
+	// Requires lists features that must be included for this one to be included.
+	Requires []string `json:"requires"`
+}
+
+type manifest []feature
+
+func loadManifest(path string) (manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read feature manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid feature manifest '%s': %w", path, err)
+	}
+	names := map[string]bool{}
+	for _, f := range m {
+		if !featureNamePattern.MatchString(f.Name) {
+			return nil, fmt.Errorf("invalid feature name '%s' in manifest", f.Name)
+		}
+		if names[f.Name] {
+			return nil, fmt.Errorf("feature '%s' is declared more than once in manifest", f.Name)
+		}
+		names[f.Name] = true
+	}
+	for _, f := range m {
+		for _, req := range f.Requires {
+			if !names[req] {
+				return nil, fmt.Errorf("feature '%s' requires unknown feature '%s'", f.Name, req)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m manifest) get(name string) (feature, bool) {
+	for _, f := range m {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return feature{}, false
+}
+
+// validate makes sure each excluded feature exists, and that no included feature requires an excluded one.
+func (m manifest) validate(excluded []string) error {
+	for _, name := range excluded {
+		if _, ok := m.get(name); !ok {
+			return fmt.Errorf("unknown feature '%s'", name)
+		}
+	}
+	for _, f := range m {
+		if slices.Contains(excluded, f.Name) {
+			continue
+		}
+		for _, req := range f.Requires {
+			if slices.Contains(excluded, req) {
+				return fmt.Errorf("feature '%s' requires '%s', so it must be excluded too", f.Name, req)
+			}
+		}
+	}
+	return nil
+}
+
+// applyFeatures removes the paths of excluded features, and applies the markers of all features in the files that git tracks.
+func applyFeatures(p *plan, m manifest, excluded []string) error {
+	included := map[string]bool{}
+	for _, f := range m {
+		included[f.Name] = !slices.Contains(excluded, f.Name)
+	}
+	for _, name := range excluded {
+		f, _ := m.get(name)
+		for _, path := range f.Paths {
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("unable to remove path '%s' of feature '%s': %w", path, name, err)
+			}
+			p.remove(path)
+			// Generated code isn't tracked, but it's already been generated when customize runs.
+			if generated := strings.TrimSuffix(path, ".templ") + "_templ.go"; strings.HasSuffix(path, ".templ") {
+				if _, err := os.Stat(generated); err == nil {
+					p.remove(generated)
+				}
+			}
+		}
+	}
+	files, err := trackedFiles()
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if p.isRemoved(path) || inSkippedDir(path) {
+			continue
+		}
+		// Generated templ code is left alone, since it's generated again after changes are applied.
+		if strings.HasSuffix(path, "_templ.go") {
+			continue
+		}
+		fi, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted, but the deletion isn't committed yet.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		err = p.edit(path, func(content []byte) ([]byte, error) {
+			if isBinary(content) || !bytes.Contains(content, []byte("feature:")) {
+				return content, nil
+			}
+			updated, err := applyMarkers(content, included)
+			if err != nil {
+				return nil, err
+			}
+			// Removed lines can leave trailing comments misaligned, so formatted Go files are formatted again.
+			if strings.HasSuffix(path, ".go") {
+				if formatted, err := format.Source(content); err == nil && bytes.Equal(formatted, content) {
+					if updated, err = format.Source(updated); err != nil {
+						return nil, fmt.Errorf("unable to format after applying feature markers: %w", err)
+					}
+				}
+			}
+			return updated, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	featureNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	markerPattern      = regexp.MustCompile(`(//|--|#|<!--)\s*feature:(!?)([a-z][a-z0-9-]*)(:| begin\b| end\b)?(.*)$`)
+)
+
+type markedBlock struct {
+	name    string
+	negated bool
+	prefix  string
+	line    int
+}
+
+// applyMarkers keeps or removes the regions of content marked for each feature, given whether it's included.
+func applyMarkers(content []byte, included map[string]bool) ([]byte, error) {
+	var (
+		out     bytes.Buffer
+		blocks  []markedBlock
+		scanner = bufio.NewScanner(bytes.NewReader(content))
+	)
+	scanner.Buffer(nil, len(content)+1)
+	// keep reports whether lines in the current blocks are kept, and uncomment whether they're uncommented.
+	state := func() (keep bool, uncomment *markedBlock) {
+		for i := range blocks {
+			if included[blocks[i].name] == blocks[i].negated {
+				return false, nil
+			}
+			if blocks[i].negated {
+				uncomment = &blocks[i]
+			}
+		}
+		return true, uncomment
+	}
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		keep, uncomment := state()
+		groups := markerPattern.FindStringSubmatchIndex(line)
+		if groups == nil {
+			switch {
+			case !keep:
+			case uncomment != nil:
+				out.WriteString(uncommentLine(line, uncomment.prefix))
+				out.WriteByte('\n')
+			default:
+				out.WriteString(line)
+				out.WriteByte('\n')
+			}
+			continue
+		}
+
+		var (
+			code    = line[:groups[0]]
+			prefix  = line[groups[2]:groups[3]]
+			negated = groups[5] > groups[4]
+			name    = line[groups[6]:groups[7]]
+			kind    = strings.TrimSpace(line[max(groups[8], 0):max(groups[9], 0)])
+			rest    = line[groups[10]:groups[11]]
+			alone   = len(strings.TrimSpace(code)) == 0
+		)
+		if _, ok := included[name]; !ok {
+			return nil, fmt.Errorf("line %d: marker for unknown feature '%s'", n, name)
+		}
+		if prefix == "<!--" {
+			rest = strings.TrimSuffix(strings.TrimSpace(rest), "-->")
+			if negated || kind != "begin" && kind != "end" {
+				return nil, fmt.Errorf("line %d: HTML comment markers must begin or end a block, and can't be negated", n)
+			}
+		}
+		if kind != ":" && len(strings.TrimSpace(rest)) > 0 {
+			return nil, fmt.Errorf("line %d: unexpected text after marker for feature '%s'", n, name)
+		}
+		switch {
+		case kind == "begin" && alone:
+			blocks = append(blocks, markedBlock{name: name, negated: negated, prefix: prefix, line: n})
+		case kind == "end" && alone:
+			if len(blocks) == 0 {
+				return nil, fmt.Errorf("line %d: end marker for feature '%s' without a begin marker", n, name)
+			}
+			top := blocks[len(blocks)-1]
+			if top.name != name || top.negated != negated {
+				return nil, fmt.Errorf("line %d: end marker for feature '%s' doesn't match begin marker on line %d", n, name, top.line)
+			}
+			blocks = blocks[:len(blocks)-1]
+		case kind == ":" && alone && negated:
+			if keep && !included[name] {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				out.WriteString(indent + strings.TrimPrefix(rest, " "))
+				out.WriteByte('\n')
+			}
+		case kind == "" && !alone && !negated:
+			if keep && included[name] {
+				out.WriteString(strings.TrimRight(code, " \t"))
+				out.WriteByte('\n')
+			}
+		default:
+			return nil, fmt.Errorf("line %d: invalid marker for feature '%s'", n, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(blocks) > 0 {
+		top := blocks[len(blocks)-1]
+		return nil, fmt.Errorf("line %d: begin marker for feature '%s' has no end marker", top.line, top.name)
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+	}
+	return out.Bytes(), nil
+}
+
+// uncommentLine removes the comment prefix from a line in a negated block, keeping its indentation.
+func uncommentLine(line, prefix string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+	if !strings.HasPrefix(trimmed, prefix) {
+		return line
+	}
+	trimmed = strings.TrimPrefix(trimmed, prefix)
+	return indent + strings.TrimPrefix(trimmed, " ")
+}
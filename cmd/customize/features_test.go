@@ -0,0 +1,218 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestApplyMarkers(t *testing.T) {
+	tests := map[string]struct {
+		input       string
+		excluded    bool
+		expected    string
+		expectedErr string
+	}{
+		"Trailing included": {
+			input:    "a\nb // feature:x\nc\n",
+			expected: "a\nb\nc\n",
+		},
+		"Trailing excluded": {
+			input:    "a\nb // feature:x\nc\n",
+			excluded: true,
+			expected: "a\nc\n",
+		},
+		"Block included": {
+			input:    "a\n\t// feature:x begin\n\tb\n\t// feature:x end\nc\n",
+			expected: "a\n\tb\nc\n",
+		},
+		"Block excluded": {
+			input:    "a\n-- feature:x begin\nb\n-- feature:x end\nc\n",
+			excluded: true,
+			expected: "a\nc\n",
+		},
+		"Nested blocks": {
+			input:    "# feature:x begin\na\n# feature:y begin\nb\n# feature:y end\n# feature:x end\n",
+			expected: "a\nb\n",
+		},
+		"HTML block": {
+			input:    "a\n<!-- feature:x begin -->\nb\n<!-- feature:x end -->\n",
+			excluded: true,
+			expected: "a\n",
+		},
+		"Negated line included": {
+			input:    "a\n\t// feature:!x: return nil\n",
+			expected: "a\n",
+		},
+		"Negated line excluded": {
+			input:    "a\n\t// feature:!x: return nil\n",
+			excluded: true,
+			expected: "a\n\treturn nil\n",
+		},
+		"Negated block excluded": {
+			input:    "\t// feature:!x begin\n\t// if a {\n\t// \treturn nil\n\t// }\n\t// feature:!x end\n",
+			excluded: true,
+			expected: "\tif a {\n\t\treturn nil\n\t}\n",
+		},
+		"Negated block included": {
+			input:    "a\n\t// feature:!x begin\n\t// return nil\n\t// feature:!x end\n",
+			expected: "a\n",
+		},
+		"No trailing newline": {
+			input:    "a // feature:x\nb",
+			expected: "a\nb",
+		},
+		"Unknown feature": {
+			input:       "a // feature:z\n",
+			expectedErr: "line 1: marker for unknown feature 'z'",
+		},
+		"Unbalanced begin": {
+			input:       "// feature:x begin\na\n",
+			expectedErr: "line 1: begin marker for feature 'x' has no end marker",
+		},
+		"Unbalanced end": {
+			input:       "a\n// feature:x end\n",
+			expectedErr: "line 2: end marker for feature 'x' without a begin marker",
+		},
+		"Mismatched end": {
+			input:       "// feature:x begin\n// feature:y begin\n// feature:x end\n",
+			expectedErr: "line 3: end marker for feature 'x' doesn't match begin marker on line 2",
+		},
+		"Marker alone": {
+			input:       "// feature:x\n",
+			expectedErr: "line 1: invalid marker for feature 'x'",
+		},
+		"Negated trailing": {
+			input:       "a // feature:!x\n",
+			expectedErr: "line 1: invalid marker for feature 'x'",
+		},
+		"Negated HTML": {
+			input:       "<!-- feature:!x begin -->\n",
+			expectedErr: "line 1: HTML comment markers must begin or end a block",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := applyMarkers([]byte(tc.input), map[string]bool{"x": !tc.excluded, "y": true})
+			if len(tc.expectedErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, string(result))
+			}
+		})
+	}
+}
+
+func TestOptions_selectFeatures(t *testing.T) {
+	m := manifest{
+		{Name: "a", Description: "Feature A."},
+		{Name: "b", Description: "Feature B.", Requires: []string{"a"}},
+		{Name: "c", Description: "Feature C."},
+	}
+	tests := map[string]struct {
+		include     []string
+		exclude     []string
+		yes         bool
+		stdin       string
+		expected    []string
+		expectedErr string
+	}{
+		"All included": {
+			yes: true,
+		},
+		"Excluded by flag": {
+			exclude:  []string{"c"},
+			yes:      true,
+			expected: []string{"c"},
+		},
+		"Requirement excluded": {
+			exclude:  []string{"a"},
+			yes:      true,
+			expected: []string{"a", "b"},
+		},
+		"Prompted": {
+			stdin:    "y\nn\n",
+			include:  []string{"a"},
+			expected: []string{"c"},
+		},
+		"Prompted default": {
+			stdin: "\n\n\n",
+		},
+		"Prompted requirement": {
+			stdin:    "n\n\n",
+			expected: []string{"a", "b"},
+		},
+		"Unknown": {
+			exclude:     []string{"d"},
+			expectedErr: "unknown feature 'd'",
+		},
+		"Both": {
+			include:     []string{"a"},
+			exclude:     []string{"a"},
+			expectedErr: "feature 'a' is both included and excluded",
+		},
+		"Required by included": {
+			include:     []string{"b"},
+			exclude:     []string{"a"},
+			expectedErr: "feature 'b' requires 'a', so it must be excluded too",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts, err := parseOptions([]string{"--module", "example.com/m", "--app", "widgets", "--display-name", "Widgets"}, strings.NewReader(tc.stdin), io.Discard)
+			require.NoError(t, err)
+			opts.Include, opts.Exclude, opts.Yes = tc.include, tc.exclude, tc.yes
+			err = opts.selectFeatures(m)
+			if len(tc.expectedErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, opts.Exclude)
+			}
+		})
+	}
+}
+
+// TestManifest_Repo checks that each of the template's features can be excluded on its own, which catches markers
+// that are invalid or name a feature missing from the manifest.
+// Whether the result builds is checked when customize runs.
+func TestManifest_Repo(t *testing.T) {
+	chdir(t, "../..")
+	m, err := loadManifest(manifestPath)
+	require.NoError(t, err)
+	opts := &options{Module: "example.com/m", App: "widgets", DisplayName: "Widgets"}
+	_, err = planTransform(opts, m)
+	require.NoError(t, err)
+	for _, f := range m {
+		t.Run(f.Name, func(t *testing.T) {
+			opts := *opts
+			opts.Exclude = []string{f.Name}
+			opts.Yes = true
+			opts.out = io.Discard
+			// Features that require this one are excluded too.
+			require.NoError(t, opts.selectFeatures(m))
+			_, err := planTransform(&opts, m)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestApplyFeatures_untracked(t *testing.T) {
+	chdir(t, t.TempDir())
+	require.NoError(t, os.WriteFile("greet.txt", []byte("hello // feature:greet\nbye\n"), 0644))
+	gitInit(t)
+	// A marker that isn't on its own line is invalid, but untracked files aren't part of the template.
+	require.NoError(t, os.WriteFile("notes.patch", []byte("+<!-- feature:greet begin -->\n"), 0644))
+
+	p := newPlan()
+	require.NoError(t, applyFeatures(p, manifest{{Name: "greet"}}, []string{"greet"}))
+	content, err := p.content("greet.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "bye\n", string(content))
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
 func gitRollback(git string) {
@@ -10,3 +13,19 @@ func gitRollback(git string) {
 	_ = exec.Command(git, "clean", "-fd").Run()
 	_ = exec.Command(git, "clean", "-fX").Run()
 }
+
+// trackedFiles lists the files that git tracks under the working directory.
+// Untracked files, like notes or patches lying around, aren't part of the template, so customize leaves them alone.
+func trackedFiles() ([]string, error) {
+	out, err := exec.Command("git", "ls-files", "-z").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to run 'git ls-files': %w", err)
+	}
+	var files []string
+	for _, file := range strings.Split(string(out), "\x00") {
+		if len(file) > 0 {
+			files = append(files, filepath.FromSlash(file))
+		}
+	}
+	return files, nil
+}
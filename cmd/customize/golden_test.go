@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata from the current output")
+
+// TestCustomize_Golden customizes copies of testdata/fixture, and compares the dry run output and the resulting tree with
+// dry-run.golden and the tree directory in testdata/golden/<test case>.
+// Run with -update to rewrite them after an intended change, and review the difference.
+func TestCustomize_Golden(t *testing.T) {
+	testdata, err := filepath.Abs("testdata")
+	require.NoError(t, err)
+	tests := map[string]options{
+		"default": {Module: "github.com/acme/widgets", App: "widgets", DisplayName: "Acme Widgets"},
+		"excluded": {Module: "github.com/acme/widgets", App: "widgets", DisplayName: "Acme Widgets",
+			Exclude: []string{"greet", "shout", "compose"}},
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.CopyFS(dir, os.DirFS(filepath.Join(testdata, "fixture"))))
+			chdir(t, dir)
+			gitInit(t)
+
+			m, err := loadManifest(manifestPath)
+			require.NoError(t, err)
+			p, err := planTransform(&opts, m)
+			require.NoError(t, err)
+			var out bytes.Buffer
+			p.Print(&out)
+			out.WriteString(p.Summary() + "\n")
+			require.NoError(t, checkRemaining(p, &out))
+			require.NoError(t, p.Apply())
+			require.NoError(t, os.RemoveAll(".git"))
+
+			golden := filepath.Join(testdata, "golden", name)
+			if *update {
+				require.NoError(t, os.RemoveAll(golden))
+				require.NoError(t, os.CopyFS(filepath.Join(golden, "tree"), os.DirFS(dir)))
+				require.NoError(t, os.WriteFile(filepath.Join(golden, "dry-run.golden"), out.Bytes(), 0644))
+			}
+			expected, err := os.ReadFile(filepath.Join(golden, "dry-run.golden"))
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), out.String())
+			assert.Equal(t, readTree(t, filepath.Join(golden, "tree")), readTree(t, dir))
+		})
+	}
+}
+
+// gitInit tracks every file in the working directory with a new git repository, since customize only changes tracked files.
+func gitInit(t *testing.T) {
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+}
+
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		require.NoError(t, os.Chdir(wd))
+	})
+}
+
+// readTree reads every file under root, keyed by its slash separated path.
+func readTree(t *testing.T, root string) map[string]string {
+	tree := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		tree[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	require.NoError(t, err)
+	return tree
+}
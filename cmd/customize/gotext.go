@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+)
+
+// replaceGoText replaces names in the string literals and comments of Go code, leaving identifiers alone.
+// Import paths are skipped, since they're changed by replaceFileImports.
+// The code doesn't need to be a whole file, so this is used for the Go code in templ files too.
+func replaceGoText(src []byte, replacer *nameReplacer) []byte {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	// Errors are ignored, since templ expressions aren't always valid Go on their own.
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	var (
+		buf       bytes.Buffer
+		last      int
+		importing bool
+		grouped   bool
+	)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if importing {
+			switch {
+			case tok == token.LPAREN:
+				grouped = true
+			case tok == token.RPAREN, tok == token.SEMICOLON && !grouped:
+				importing, grouped = false, false
+			case tok == token.STRING:
+				importing = grouped
+				continue
+			}
+		}
+		if tok == token.IMPORT {
+			importing = true
+			continue
+		}
+		if tok != token.STRING && tok != token.COMMENT {
+			continue
+		}
+		start := file.Offset(pos)
+		end := start + len(lit)
+		// The scanner drops carriage returns from raw strings, so those are skipped rather than risk a bad offset.
+		if end > len(src) || string(src[start:end]) != lit {
+			continue
+		}
+		replaced := replacer.Replace(lit)
+		if replaced == lit {
+			continue
+		}
+		buf.Write(src[last:start])
+		buf.WriteString(replaced)
+		last = end
+	}
+	if last == 0 {
+		return src
+	}
+	buf.Write(src[last:])
+	return buf.Bytes()
+}
@@ -1,26 +1,24 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
 	"io/fs"
-	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 var (
-	singleLineImport     = regexp.MustCompile(`^\s*import\s+([^"\s]+\s*)?"([^"]+)"$`)
-	multilineImportStart = regexp.MustCompile(`^\s*import\s*\(\s*$`)
-	multilineImport      = regexp.MustCompile(`^(\s*)([^"\s]+\s*)?"([^"]+)"`)
-	multilineImportEnd   = regexp.MustCompile(`^\s*\)\s*$`)
-	replaceFileGlobs     = []string{"*.go", "*.templ"}
-	ignoreFileGlobs      []string
+	replaceFileGlobs = []string{"*.go", "*.templ"}
+	ignoreFileGlobs  []string
 )
 
-func replaceDirImports(dir, target, replacement string) error {
+func replaceDirImports(p *plan, dir string, replacements ...string) error {
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -46,7 +44,10 @@ func replaceDirImports(dir, target, replacement string) error {
 				return err
 			}
 			if match {
-				if err := replaceFileImports(path, target, replacement); err != nil {
+				err := p.edit(path, func(content []byte) ([]byte, error) {
+					return replaceFileImports(content, replacements...)
+				})
+				if err != nil {
 					return err
 				}
 			}
@@ -56,51 +57,70 @@ func replaceDirImports(dir, target, replacement string) error {
 	return err
 }
 
-func replaceFileImports(file, target, replacement string) error {
-	var buf bytes.Buffer
-	err := func() error {
-		input, err := os.Open(file)
+// replaceFileImports changes import path prefixes, given as pairs of old and new prefixes.
+// Only the first matching prefix is replaced, so more specific prefixes should be given first.
+// Imports are found with go/parser, which stops after the import declarations, so this works for templ files too.
+// Only the path literals are rewritten, and gofmt'd Go files have their imports sorted again afterward.
+func replaceFileImports(content []byte, replacements ...string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse imports: %w", err)
+	}
+	type edit struct {
+		start, end int
+		path       string
+	}
+	var edits []edit
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("invalid import path %s: %w", spec.Path.Value, err)
 		}
-		defer func() {
-			_ = input.Close()
-		}()
-		scanner := bufio.NewScanner(input)
-		inMultilineImport := false
-		writeLine := func(line []byte) {
-			buf.Write(line)
-			buf.WriteString("\n")
+		newPath, ok := replaceImportPrefix(path, replacements)
+		if !ok {
+			continue
 		}
-		for scanner.Scan() {
-			line := scanner.Bytes()
-			switch {
-			case singleLineImport.Match(line):
-				groups := singleLineImport.FindSubmatch(line)
-				buf.WriteString(fmt.Sprintf("import %s\"%s\"\n", groups[1], []byte(strings.Replace(string(groups[2]), target, replacement, 1))))
-			case multilineImportStart.Match(line):
-				inMultilineImport = true
-				writeLine(line)
-			case inMultilineImport && multilineImport.Match(line):
-				groups := multilineImport.FindSubmatch(line)
-				buf.WriteString(fmt.Sprintf("%s%s\"%s\"\n", groups[1], groups[2], []byte(strings.Replace(string(groups[3]), target, replacement, 1))))
-			case multilineImportEnd.Match(line):
-				inMultilineImport = false
-				writeLine(line)
-			default:
-				writeLine(line)
-			}
-		}
-		if err := scanner.Err(); err != nil {
-			return err
+		start := fset.Position(spec.Path.Pos()).Offset
+		edits = append(edits, edit{
+			start: start,
+			end:   start + len(spec.Path.Value),
+			path:  newPath,
+		})
+	}
+	if len(edits) == 0 {
+		return content, nil
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	var buf bytes.Buffer
+	var last int
+	for _, e := range edits {
+		buf.Write(content[last:e.start])
+		buf.WriteString(strconv.Quote(e.path))
+		last = e.end
+	}
+	buf.Write(content[last:])
+
+	// Renamed imports may be out of order, but only files that were already formatted are reformatted,
+	// so nothing else in the file changes. This never succeeds for templ files.
+	if formatted, err := format.Source(content); err == nil && bytes.Equal(formatted, content) {
+		if sorted, err := format.Source(buf.Bytes()); err == nil {
+			return sorted, nil
 		}
-		return nil
-	}()
-	if err != nil {
-		return fmt.Errorf("error processing file '%s': %w", file, err)
 	}
-	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to rewrite file '%s': %w", file, err)
+	return buf.Bytes(), nil
+}
+
+// replaceImportPrefix replaces the first old prefix that matches whole path elements.
+func replaceImportPrefix(path string, replacements []string) (string, bool) {
+	for i := 0; i+1 < len(replacements); i += 2 {
+		target, replacement := replacements[i], replacements[i+1]
+		if path == target || strings.HasPrefix(path, target+"/") {
+			return replacement + strings.TrimPrefix(path, target), true
+		}
 	}
-	return nil
+	return "", false
 }
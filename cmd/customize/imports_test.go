@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReplaceFileImports(t *testing.T) {
+	tests := map[string]struct {
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		"Single import": {
+			input:    "package a\n\nimport \"yourapp/feature/model\"\n",
+			expected: "package a\n\nimport \"example.com/app/feature/model\"\n",
+		},
+		"Module root": {
+			input:    "package a\n\nimport \"yourapp\"\n",
+			expected: "package a\n\nimport \"example.com/app\"\n",
+		},
+		"Specific prefix first": {
+			input:    "package a\n\nimport \"yourapp/cmd/yourapp/internal/routes\"\n",
+			expected: "package a\n\nimport \"example.com/app/cmd/app/internal/routes\"\n",
+		},
+		"Sorted again": {
+			input:    "package a\n\nimport (\n\t\"fmt\"\n\tm \"yourapp/feature/model\"\n)\n",
+			expected: "package a\n\nimport (\n\tm \"example.com/app/feature/model\"\n\t\"fmt\"\n)\n",
+		},
+		"Not reformatted": {
+			input:    "package a\nimport (\n\"fmt\"\n\"yourapp/feature/model\"\n)\n",
+			expected: "package a\nimport (\n\"fmt\"\n\"example.com/app/feature/model\"\n)\n",
+		},
+		"Other modules": {
+			input:    "package a\n\nimport (\n\t\"github.com/you/yourapp\"\n\t\"yourappkit\"\n)\n\nvar s = \"yourapp/feature\"\n",
+			expected: "package a\n\nimport (\n\t\"github.com/you/yourapp\"\n\t\"yourappkit\"\n)\n\nvar s = \"yourapp/feature\"\n",
+		},
+		"Templ": {
+			input:    "package a\n\nimport \"yourapp/feature/model\"\n\ntempl A(u model.User) {\n\t<p>{ u.Name }</p>\n}\n",
+			expected: "package a\n\nimport \"example.com/app/feature/model\"\n\ntempl A(u model.User) {\n\t<p>{ u.Name }</p>\n}\n",
+		},
+		"Invalid": {
+			input:       "import \"yourapp\"\n",
+			expectedErr: "failed to parse imports",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := replaceFileImports([]byte(tc.input),
+				"yourapp/cmd/yourapp", "example.com/app/cmd/app",
+				"yourapp", "example.com/app",
+			)
+			if len(tc.expectedErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, string(result))
+			}
+		})
+	}
+}
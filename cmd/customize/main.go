@@ -1,94 +1,183 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	flag "github.com/spf13/pflag"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"unicode"
 )
 
 const (
-	curAppName = "yourapp"
+	curAppName     = "yourapp"
+	curDisplayName = "Your App"
+	// curTenantRole is the database role that queries for an organization run as, see infra/pg/sql/06_tenants.sql.
+	curTenantRole = curAppName + "_tenant"
+)
+
+// Exit codes are part of the command's interface, so the template can be instantiated from scripts.
+const (
+	exitOK = iota
+	// exitFailed means customization failed, and the repo was rolled back.
+	exitFailed
+	// exitUsage means the flags or input were invalid.
+	exitUsage
+	// exitNotReady means customize wasn't run from a clean checkout of the repo root, or git or go is missing.
+	exitNotReady
+	// exitDeclined means the changes weren't confirmed.
+	exitDeclined
+	// exitFinalize means customization was verified, but the git repository couldn't be re-initialized.
+	exitFinalize
 )
 
 var (
 	waypointDirs = []string{".git", "cmd", "feature", "foundation", "infra", "modmake"}
-	importDirs   = []string{"cmd/yourapp", "feature", "foundation"}
-	pathDirs     = []string{"modmake", "cmd/yourapp", "README.md", "docker-compose.yaml", "infra"}
+	importDirs   = []string{"cmd/yourapp", "feature", "foundation", "modmake"}
+	pathDirs     = []string{"modmake", "cmd/yourapp", "feature", "foundation", "README.md", "docker-compose.yaml", "infra"}
 	explicitAdd  = []string{"cmd/yourapp/internal/templates/util.go"}
 )
 
 func main() {
-	gitExec, goExec := checkReadiness()
-	var (
-		scanner = bufio.NewScanner(os.Stdin)
-	)
-	fmt.Print("Enter new Go module name: ")
-	scanner.Scan()
-	newModuleName, err := validateModuleName(scanner.Text())
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout))
+}
+
+func run(args []string, in io.Reader, out io.Writer) int {
+	opts, err := parseOptions(args, in, out)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
 	if err != nil {
-		fmt.Println("Invalid module name:", err)
-		os.Exit(1)
+		_, _ = fmt.Fprintln(out, "Invalid options:", err)
+		return exitUsage
 	}
-	fmt.Println("Using", newModuleName, "as module name")
-	fmt.Print("Enter new app name: ")
-	scanner.Scan()
-	newAppName, err := validateAppName(scanner.Text())
+	gitExec, goExec, err := checkReadiness(opts.DryRun)
 	if err != nil {
-		fmt.Println("Invalid app name:", err)
-		os.Exit(1)
+		_, _ = fmt.Fprintln(out, err)
+		return exitNotReady
 	}
-	fmt.Println("Using", newAppName, "as app name")
-	fmt.Println("Applying customization changes to repo, please wait...")
+	m, err := loadManifest(manifestPath)
+	if err != nil {
+		_, _ = fmt.Fprintln(out, err)
+		return exitNotReady
+	}
+	if err := opts.selectFeatures(m); err != nil {
+		_, _ = fmt.Fprintln(out, "Invalid options:", err)
+		return exitUsage
+	}
+
+	if opts.DryRun {
+		p, err := planTransform(opts, m)
+		if err != nil {
+			_, _ = fmt.Fprintln(out, "Error planning customization:", err)
+			return exitFailed
+		}
+		p.Print(out)
+		_, _ = fmt.Fprintln(out, "Dry run:", p.Summary())
+		if err := checkRemaining(p, out); err != nil {
+			_, _ = fmt.Fprintln(out, err)
+			return exitFailed
+		}
+		_, _ = fmt.Fprintln(out, "Generated code is not included, since it's created by modmake when customizations are applied.")
+		return exitOK
+	}
+
+	if err := opts.confirm(); err != nil {
+		_, _ = fmt.Fprintln(out, "Not applying changes:", err)
+		return exitDeclined
+	}
+	_, _ = fmt.Fprintln(out, "Applying customization changes to repo, please wait...")
 	generate := exec.Command(goExec, "run", "./modmake", "generate")
-	generate.Stdout = os.Stdout
-	generate.Stderr = os.Stderr
+	generate.Stdout = out
+	generate.Stderr = out
 	if err := generate.Run(); err != nil {
-		fmt.Println("Failed to run initial generation")
-		os.Exit(1)
+		_, _ = fmt.Fprintln(out, "Failed to run initial generation")
+		gitRollback(gitExec)
+		return exitFailed
 	}
-	if err := doTransform(newModuleName, newAppName); err != nil {
-		fmt.Println("Error running customization logic:", err)
-		fmt.Println("Rolling back changes, please wait...")
+	p, err := planTransform(opts, m)
+	if err == nil {
+		_, _ = fmt.Fprintln(out, p.Summary())
+		err = checkRemaining(p, out)
+	}
+	if err == nil {
+		err = p.Apply()
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(out, "Error running customization logic:", err)
+		_, _ = fmt.Fprintln(out, "Rolling back changes, please wait...")
 		gitRollback(gitExec)
-		os.Exit(1)
+		return exitFailed
 	}
-	fmt.Println("Customizations complete, validating Go code...")
-	if err := validateChanges(goExec); err != nil {
-		fmt.Println("Detected validation errors, rolling back changes...")
+	_, _ = fmt.Fprintln(out, "Customizations complete, validating Go code...")
+	if err := validateChanges(goExec, out); err != nil {
+		_, _ = fmt.Fprintln(out, "Detected validation errors, rolling back changes...")
 		gitRollback(gitExec)
-		os.Exit(1)
+		return exitFailed
 	}
 	if err := os.RemoveAll(filepath.Join("cmd", "customize")); err != nil {
-		fmt.Println("Unable to remove customize. Changes are verified, so proceeding anyway.")
+		_, _ = fmt.Fprintln(out, "Unable to remove customize. Changes are verified, so proceeding anyway.")
 	}
-	fmt.Println("Re-initializing git repository...")
-	if err := doFinalize(gitExec, "yourapp", newAppName); err != nil {
-		fmt.Println("Failed to re-initialize git repo:", err)
-		fmt.Println("This will need to be done manually")
+	if opts.KeepGit {
+		_, _ = fmt.Fprintln(out, "Customization complete. The changes are uncommitted, so review them with 'git status'.")
+		return exitOK
 	}
-	fmt.Println("Customization complete. Enjoy!")
+	_, _ = fmt.Fprintln(out, "Re-initializing git repository...")
+	if err := doFinalize(gitExec, "yourapp", opts.App); err != nil {
+		_, _ = fmt.Fprintln(out, "Failed to re-initialize git repo:", err)
+		_, _ = fmt.Fprintln(out, "This will need to be done manually")
+		return exitFinalize
+	}
+	_, _ = fmt.Fprintln(out, "Customization complete. Enjoy!")
+	return exitOK
 }
 
-func doTransform(newModuleName, newAppName string) error {
+// planTransform plans every change without writing anything.
+// Excluded features are removed first, so nothing else is changed in their files.
+func planTransform(opts *options, m manifest) (*plan, error) {
+	p := newPlan()
+	if err := applyFeatures(p, m, opts.Exclude); err != nil {
+		return nil, err
+	}
 	for _, dir := range importDirs {
-		if err := replaceDirImports(dir, curAppName, newModuleName); err != nil {
-			return err
+		// Packages under cmd/yourapp move when the directory is renamed.
+		err := replaceDirImports(p, dir,
+			curAppName+"/cmd/"+curAppName, opts.Module+"/cmd/"+opts.App,
+			curAppName, opts.Module,
+		)
+		if err != nil {
+			return nil, err
 		}
 	}
-	if err := changeModuleName(newModuleName); err != nil {
-		return err
+	if err := changeModuleName(p, opts.Module); err != nil {
+		return nil, err
 	}
 	for _, dir := range pathDirs {
-		if err := replaceAppName(dir, curAppName, newAppName); err != nil {
-			return err
+		// The brand in the title bar and page titles is "Your App!", which becomes just the display name.
+		// The tenant role isn't a whole word, so it's renamed on its own.
+		err := replaceAppName(p, dir,
+			curAppName, opts.App,
+			curDisplayName+"!", opts.DisplayName,
+			curDisplayName, opts.DisplayName,
+			curTenantRole, sqlName(opts.App)+"_tenant",
+		)
+		if err != nil {
+			return nil, err
 		}
 	}
+	return p, nil
+}
 
+// checkRemaining reports uses of the old app name that the plan leaves behind.
+func checkRemaining(p *plan, out io.Writer) error {
+	found, err := findRemaining(p, ".", curAppName)
+	if err != nil {
+		return err
+	}
+	printRemaining(out, curAppName, found)
 	return nil
 }
 
@@ -99,94 +188,85 @@ func doFinalize(gitExec, oldAppName, newAppName string) error {
 	if err := exec.Command(gitExec, "init").Run(); err != nil {
 		return fmt.Errorf("unable to initialize fresh git repository: %w", err)
 	}
-	_ = exec.Command(gitExec, "add", ".").Run()
+	if err := exec.Command(gitExec, "add", ".").Run(); err != nil {
+		return fmt.Errorf("unable to stage files: %w", err)
+	}
 	for _, explicit := range explicitAdd {
 		explicit = strings.ReplaceAll(explicit, oldAppName, newAppName)
 		_ = exec.Command(gitExec, "add", "-f", explicit).Run()
 	}
-	_ = exec.Command(gitExec, "commit", "-m", "Customized template from github.com/saylorsolutions/goth-stack").Run()
+	if err := exec.Command(gitExec, "commit", "-m", "Customized template from github.com/saylorsolutions/goth-stack").Run(); err != nil {
+		return fmt.Errorf("unable to create the initial commit: %w", err)
+	}
 	return nil
 }
 
-func checkReadiness() (gitExecutable string, goExecutable string) {
-	// Make sure that we're in the root dir
+// checkReadiness makes sure customize is run from the repo root, with git and go available.
+// Unless this is a dry run, the working tree must be clean too, since a rollback discards any uncommitted changes.
+func checkReadiness(dryRun bool) (gitExecutable string, goExecutable string, err error) {
 	for _, dir := range waypointDirs {
 		fi, err := os.Stat(dir)
 		if err != nil || !fi.IsDir() {
-			fmt.Println("Must be run from the root of the repository: missing directory", dir)
-			os.Exit(1)
+			return "", "", fmt.Errorf("must be run from the root of the repository: missing directory %s", dir)
 		}
 	}
 
 	git, err := exec.LookPath("git")
 	if err != nil {
-		fmt.Println("git is required to finalize/rollback setup")
-		os.Exit(1)
+		return "", "", errors.New("git is required to finalize/rollback setup")
 	}
 	if err := exec.Command(git, "--version").Run(); err != nil {
-		fmt.Println("Unable to run 'git --version'")
-		os.Exit(1)
+		return "", "", errors.New("unable to run 'git --version'")
 	}
 
 	goExec, err := exec.LookPath("go")
 	if err != nil {
-		fmt.Println("go is required to validate setup")
-		os.Exit(1)
+		return "", "", errors.New("go is required to validate setup")
 	}
 	if err := exec.Command(goExec, "version").Run(); err != nil {
-		fmt.Println("Unable to run 'go version'")
-		os.Exit(1)
+		return "", "", errors.New("unable to run 'go version'")
 	}
-	return git, goExec
-}
-
-func validateModuleName(modName string) (string, error) {
-	modName = strings.TrimSpace(modName)
-	if len(modName) == 0 {
-		return "", errors.New("module name is empty")
-	}
-	return modName, nil
-}
 
-func validateAppName(appName string) (string, error) {
-	appName = strings.TrimSpace(appName)
-	if len(appName) == 0 {
-		return "", errors.New("app name is empty")
-	}
-	var (
-		nameRunes    = []rune(appName)
-		numWritten   int
-		writePos     int
-		writtenRunes = make([]rune, len(nameRunes))
-	)
-	for _, r := range nameRunes {
-		if numWritten > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
-			writtenRunes[writePos] = r
-			writePos++
-			numWritten++
-		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
-			writtenRunes[writePos] = r
-			writePos++
-			numWritten++
+	if !dryRun {
+		status, err := exec.Command(git, "status", "--porcelain").Output()
+		if err != nil {
+			return "", "", fmt.Errorf("unable to run 'git status': %w", err)
+		}
+		if len(bytes.TrimSpace(status)) > 0 {
+			return "", "", errors.New("the working tree has uncommitted changes, which would be lost if customization is rolled back")
 		}
 	}
-	if numWritten == 0 {
-		return "", fmt.Errorf("no valid characters in name '%s'", appName)
-	}
-	appName = string(writtenRunes[:numWritten])
-	return appName, nil
+	return git, goExec, nil
 }
 
-func validateChanges(goExec string) error {
+func validateChanges(goExec string, out io.Writer) error {
+	// Code is generated again, since feature markers may have changed the templ files it's generated from.
+	generate := exec.Command(goExec, "run", "./modmake", "generate")
+	generate.Stdout = out
+	generate.Stderr = out
+	if err := generate.Run(); err != nil {
+		return err
+	}
 	vet := exec.Command(goExec, "vet", "./...")
-	vet.Stdout = os.Stdout
-	vet.Stderr = os.Stdout
+	vet.Stdout = out
+	vet.Stderr = out
 	if err := vet.Run(); err != nil {
 		return err
 	}
-	test := exec.Command(goExec, "test", "-v", "./...")
-	test.Stdout = os.Stdout
-	test.Stderr = os.Stdout
+	// Customize is removed once changes are verified, and its own tests expect the template as it was.
+	list, err := exec.Command(goExec, "list", "./...").Output()
+	if err != nil {
+		return fmt.Errorf("unable to list packages: %w", err)
+	}
+	args := []string{"test", "-v"}
+	for _, pkg := range strings.Fields(string(list)) {
+		if !strings.HasSuffix(pkg, "/cmd/customize") {
+			args = append(args, pkg)
+		}
+	}
+	test := exec.Command(goExec, args...)
+	test.Stdout = out
+	test.Stderr = out
 	if err := test.Run(); err != nil {
 		return err
 	}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var errDeclined = errors.New("changes were not confirmed")
+
+type options struct {
+	Module      string
+	App         string
+	DisplayName string
+	KeepGit     bool
+	DryRun      bool
+	Yes         bool
+	// Include and Exclude list optional features from the manifest, and any not listed are prompted for.
+	Include []string
+	Exclude []string
+
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+// parseOptions reads options from flags, prompting on in for any that weren't given.
+// With --yes nothing is prompted for, so missing values are an error instead.
+func parseOptions(args []string, in io.Reader, out io.Writer) (*options, error) {
+	opts := &options{scanner: bufio.NewScanner(in), out: out}
+	flags := flag.NewFlagSet("customize", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(out, "Usage: go run ./cmd/customize [flags]")
+		_, _ = fmt.Fprintln(out)
+		_, _ = fmt.Fprintln(out, "Values that aren't given as flags are prompted for, unless --yes is set.")
+		_, _ = fmt.Fprintln(out)
+		flags.PrintDefaults()
+		if m, err := loadManifest(manifestPath); err == nil {
+			_, _ = fmt.Fprintln(out)
+			_, _ = fmt.Fprintln(out, "Optional features, which are included unless excluded:")
+			for _, f := range m {
+				_, _ = fmt.Fprintf(out, "  %-10s %s\n", f.Name, f.Description)
+			}
+		}
+		_, _ = fmt.Fprintln(out)
+		_, _ = fmt.Fprintln(out, "Exit codes:")
+		_, _ = fmt.Fprintln(out, "  0  customization is complete, or the dry run succeeded")
+		_, _ = fmt.Fprintln(out, "  1  customization failed, and the repo was rolled back")
+		_, _ = fmt.Fprintln(out, "  2  invalid flags or input")
+		_, _ = fmt.Fprintln(out, "  3  not run from a clean checkout of the repo root, or git or go is missing")
+		_, _ = fmt.Fprintln(out, "  4  the changes were not confirmed")
+		_, _ = fmt.Fprintln(out, "  5  customization is verified, but the git repository couldn't be re-initialized")
+	}
+	flags.StringVar(&opts.Module, "module", "", "New Go module name, like github.com/you/app")
+	flags.StringVar(&opts.App, "app", "", "New app name, which replaces \"yourapp\" in paths, binaries, and images")
+	flags.StringVar(&opts.DisplayName, "display-name", "", "Human readable app name shown in the UI, derived from the app name by default")
+	flags.BoolVar(&opts.KeepGit, "keep-git", false, "Keep the existing git history, and leave the changes uncommitted")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "Print every rename and content change as a diff, without changing anything")
+	flags.StringSliceVar(&opts.Include, "include", nil, "Optional features to include, without prompting")
+	flags.StringSliceVar(&opts.Exclude, "exclude", nil, "Optional features to exclude, without prompting")
+	flags.BoolVarP(&opts.Yes, "yes", "y", false, "Don't prompt for anything, including confirmation")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	var err error
+	if opts.Module, err = opts.value("module", "Enter new Go module name: ", opts.Module, validateModuleName); err != nil {
+		return nil, err
+	}
+	if opts.App, err = opts.value("app", "Enter new app name: ", opts.App, validateAppName); err != nil {
+		return nil, err
+	}
+	if len(opts.DisplayName) == 0 {
+		defaultName := defaultDisplayName(opts.App)
+		if opts.Yes {
+			opts.DisplayName = defaultName
+		} else {
+			answer, _ := opts.prompt(fmt.Sprintf("Enter display name [%s]: ", defaultName))
+			if opts.DisplayName = answer; len(strings.TrimSpace(answer)) == 0 {
+				opts.DisplayName = defaultName
+			}
+		}
+	}
+	if opts.DisplayName, err = validateDisplayName(opts.DisplayName); err != nil {
+		return nil, fmt.Errorf("invalid display name: %w", err)
+	}
+	return opts, nil
+}
+
+// value validates a flag value, prompting for it first if it wasn't given.
+func (opts *options) value(name, label, given string, validate func(string) (string, error)) (string, error) {
+	if len(given) == 0 {
+		if opts.Yes {
+			return "", fmt.Errorf("--%s is required with --yes", name)
+		}
+		var err error
+		if given, err = opts.prompt(label); err != nil {
+			return "", fmt.Errorf("no %s given: %w", name, err)
+		}
+	}
+	val, err := validate(given)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return val, nil
+}
+
+func (opts *options) prompt(label string) (string, error) {
+	_, _ = fmt.Fprint(opts.out, label)
+	if !opts.scanner.Scan() {
+		_, _ = fmt.Fprintln(opts.out)
+		if err := opts.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return opts.scanner.Text(), nil
+}
+
+// selectFeatures decides which optional features are excluded, prompting for any not given with --include or --exclude.
+// With --yes, features that weren't given are included.
+func (opts *options) selectFeatures(m manifest) error {
+	for _, name := range slices.Concat(opts.Include, opts.Exclude) {
+		if _, ok := m.get(name); !ok {
+			return fmt.Errorf("unknown feature '%s'", name)
+		}
+	}
+	for _, name := range opts.Include {
+		if slices.Contains(opts.Exclude, name) {
+			return fmt.Errorf("feature '%s' is both included and excluded", name)
+		}
+	}
+	for _, f := range m {
+		if slices.Contains(opts.Include, f.Name) || slices.Contains(opts.Exclude, f.Name) {
+			continue
+		}
+		if i := slices.IndexFunc(f.Requires, func(req string) bool { return slices.Contains(opts.Exclude, req) }); i >= 0 {
+			_, _ = fmt.Fprintf(opts.out, "Excluding %s, since it requires %s.\n", f.Name, f.Requires[i])
+			opts.Exclude = append(opts.Exclude, f.Name)
+			continue
+		}
+		if opts.Yes {
+			continue
+		}
+		answer, _ := opts.prompt(fmt.Sprintf("Include %s? %s [Y/n]: ", f.Name, f.Description))
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "n", "no":
+			opts.Exclude = append(opts.Exclude, f.Name)
+		}
+	}
+	return m.validate(opts.Exclude)
+}
+
+// confirm asks before making changes, unless --yes was given.
+func (opts *options) confirm() error {
+	if opts.Yes {
+		return nil
+	}
+	_, _ = fmt.Fprintln(opts.out, "Module name: ", opts.Module)
+	_, _ = fmt.Fprintln(opts.out, "App name:    ", opts.App)
+	_, _ = fmt.Fprintln(opts.out, "Display name:", opts.DisplayName)
+	if len(opts.Exclude) > 0 {
+		_, _ = fmt.Fprintln(opts.out, "Excluded:    ", strings.Join(opts.Exclude, ", "))
+	}
+	if opts.KeepGit {
+		_, _ = fmt.Fprintln(opts.out, "The git history will be kept, and the changes left uncommitted.")
+	} else {
+		_, _ = fmt.Fprintln(opts.out, "The git history will be replaced with a single commit of the customized repo.")
+	}
+	answer, _ := opts.prompt("Apply these changes? [y/N]: ")
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errDeclined
+	}
+}
+
+func validateModuleName(modName string) (string, error) {
+	modName = strings.TrimSpace(modName)
+	if len(modName) == 0 {
+		return "", errors.New("module name is empty")
+	}
+	return modName, nil
+}
+
+func validateAppName(appName string) (string, error) {
+	appName = strings.TrimSpace(appName)
+	if len(appName) == 0 {
+		return "", errors.New("app name is empty")
+	}
+	var (
+		nameRunes    = []rune(appName)
+		numWritten   int
+		writePos     int
+		writtenRunes = make([]rune, len(nameRunes))
+	)
+	for _, r := range nameRunes {
+		if numWritten > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			writtenRunes[writePos] = r
+			writePos++
+			numWritten++
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			writtenRunes[writePos] = r
+			writePos++
+			numWritten++
+		}
+	}
+	if numWritten == 0 {
+		return "", fmt.Errorf("no valid characters in name '%s'", appName)
+	}
+	appName = string(writtenRunes[:numWritten])
+	return appName, nil
+}
+
+// validateDisplayName rejects characters that would need escaping in the Go, templ, and YAML files it's written to.
+func validateDisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return "", errors.New("display name is empty")
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) || strings.ContainsRune("\"'`\\<>{}", r) {
+			return "", fmt.Errorf("display name may not contain %q", r)
+		}
+	}
+	return name, nil
+}
+
+// defaultDisplayName turns an app name like "my-app" into "My App".
+func defaultDisplayName(appName string) string {
+	words := strings.FieldsFunc(appName, func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
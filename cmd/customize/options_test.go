@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := map[string]struct {
+		args        []string
+		stdin       string
+		expected    options
+		expectedErr string
+	}{
+		"All flags": {
+			args:     []string{"--module", "github.com/acme/widgets", "--app", "widgets", "--display-name", "Acme Widgets", "--keep-git", "--yes"},
+			expected: options{Module: "github.com/acme/widgets", App: "widgets", DisplayName: "Acme Widgets", KeepGit: true, Yes: true},
+		},
+		"Default display name": {
+			args:     []string{"--module", "example.com/m", "--app", "my-app", "-y"},
+			expected: options{Module: "example.com/m", App: "my-app", DisplayName: "My App", Yes: true},
+		},
+		"Prompted": {
+			stdin:    "example.com/m\nwidgets\n\n",
+			expected: options{Module: "example.com/m", App: "widgets", DisplayName: "Widgets"},
+		},
+		"Prompted display name": {
+			args:     []string{"--dry-run", "--module", "example.com/m", "--app", "widgets"},
+			stdin:    "Acme Widgets\n",
+			expected: options{Module: "example.com/m", App: "widgets", DisplayName: "Acme Widgets", DryRun: true},
+		},
+		"Missing with yes": {
+			args:        []string{"--app", "widgets", "--yes"},
+			expectedErr: "--module is required with --yes",
+		},
+		"Missing on stdin": {
+			expectedErr: "no module given",
+		},
+		"Invalid app name": {
+			args:        []string{"--module", "example.com/m", "--app", "!!!", "--yes"},
+			expectedErr: "invalid app: no valid characters",
+		},
+		"Invalid display name": {
+			args:        []string{"--module", "example.com/m", "--app", "widgets", "--display-name", "<b>Widgets</b>", "--yes"},
+			expectedErr: "invalid display name",
+		},
+		"Unknown flag": {
+			args:        []string{"--color"},
+			expectedErr: "unknown flag",
+		},
+		"Extra arguments": {
+			args:        []string{"--yes", "widgets"},
+			expectedErr: "unexpected arguments: widgets",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts, err := parseOptions(tc.args, strings.NewReader(tc.stdin), io.Discard)
+			if len(tc.expectedErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				opts.scanner, opts.out = nil, nil
+				assert.Equal(t, tc.expected, *opts)
+			}
+		})
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	tests := map[string]struct {
+		args     []string
+		expected int
+	}{
+		"Help":          {args: []string{"--help"}, expected: exitOK},
+		"Invalid flags": {args: []string{"--color"}, expected: exitUsage},
+		"Missing value": {args: []string{"--yes"}, expected: exitUsage},
+		// Tests run in the package directory, which isn't the repo root.
+		"Not ready": {args: []string{"--module", "example.com/m", "--app", "widgets", "--yes"}, expected: exitNotReady},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.Equal(t, tc.expected, run(tc.args, strings.NewReader(""), &out), out.String())
+		})
+	}
+}
+
+func TestOptions_confirm(t *testing.T) {
+	tests := map[string]struct {
+		stdin     string
+		yes       bool
+		expectErr bool
+	}{
+		"Confirmed":     {stdin: "y\n"},
+		"Confirmed yes": {stdin: "YES\n"},
+		"Declined":      {stdin: "n\n", expectErr: true},
+		"Default":       {stdin: "\n", expectErr: true},
+		"No input":      {expectErr: true},
+		"Skipped":       {yes: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts, err := parseOptions([]string{"--module", "example.com/m", "--app", "widgets", "--display-name", "Widgets"}, strings.NewReader(tc.stdin), io.Discard)
+			if !assert.NoError(t, err) {
+				return
+			}
+			opts.Yes = tc.yes
+			err = opts.confirm()
+			if tc.expectErr {
+				assert.ErrorIs(t, err, errDeclined)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
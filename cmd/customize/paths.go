@@ -1,92 +1,125 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 )
 
-func replaceAppName(root, oldAppName, newAppName string) error {
+func replaceAppName(p *plan, root string, replacements ...string) error {
 	if fi, err := os.Stat(root); err != nil {
 		return fmt.Errorf("unable to stat path '%s': %w", root, err)
 	} else {
 		if !fi.IsDir() {
-			return replaceFileAppName(root, oldAppName, newAppName)
+			return replaceFileAppName(p, root, replacements...)
 		}
 	}
 
-	fileRepl := map[string]string{}
-	dirRepl := map[string]string{}
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	oldAppName, newAppName := replacements[0], replacements[1]
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 		if strings.Contains(filepath.Base(path), oldAppName) {
+			newName := strings.ReplaceAll(filepath.Base(path), oldAppName, newAppName)
 			if d.IsDir() {
-				dirRepl[path] = filepath.Join(filepath.Dir(path), strings.ReplaceAll(filepath.Base(path), oldAppName, newAppName))
-			} else {
-				fileRepl[path] = filepath.Join(filepath.Dir(path), strings.ReplaceAll(filepath.Base(path), oldAppName, newAppName))
+				p.renameDir(path, newName)
+			} else if err := p.renameFile(path, newName); err != nil {
+				return err
 			}
 		}
 		if d.IsDir() {
 			return nil
 		}
-		return replaceFileAppName(path, oldAppName, newAppName)
+		return replaceFileAppName(p, path, replacements...)
 	})
-	if err != nil {
-		return err
-	}
-	for curName, newName := range fileRepl {
-		if err := os.Rename(curName, newName); err != nil {
-			return err
-		}
-	}
-	for curName, newName := range dirRepl {
-		if err := os.Rename(curName, newName); err != nil {
-			return err
+}
+
+// sqlName turns an app name into one that can be used in an unquoted SQL identifier, like "my_app" for "My-App".
+// Unquoted identifiers are folded to lower case, and can't include hyphens.
+func sqlName(appName string) string {
+	return strings.ToLower(strings.ReplaceAll(appName, "-", "_"))
+}
+
+// replaceFileAppName replaces each old string with its new string, given as pairs.
+// Only string literals and comments are changed in Go code, including the Go code in templ files, so identifiers are never corrupted.
+// Binary files, like pre-compressed static assets, are left alone since a replacement would corrupt them.
+func replaceFileAppName(p *plan, file string, replacements ...string) error {
+	replacer := newNameReplacer(replacements...)
+	return p.edit(file, func(content []byte) ([]byte, error) {
+		switch {
+		case isBinary(content):
+			return content, nil
+		case strings.HasSuffix(file, ".go"):
+			return replaceGoText(content, replacer), nil
+		case strings.HasSuffix(file, ".templ"):
+			return replaceTemplText(content, replacer), nil
+		default:
+			return []byte(replacer.Replace(string(content))), nil
 		}
-	}
+	})
+}
+
+// nameReplacer replaces names only where they're whole words, so a name that's part of a longer word or identifier,
+// like yourappVersion, is left alone. Earlier pairs take precedence where more than one matches.
+type nameReplacer struct {
+	pairs []string
+}
 
-	return nil
+func newNameReplacer(pairs ...string) *nameReplacer {
+	return &nameReplacer{pairs: pairs}
 }
 
-func replaceFileAppName(file, oldAppName, newAppName string) error {
+func (r *nameReplacer) Replace(s string) string {
 	var (
-		buf bytes.Buffer
+		buf  strings.Builder
+		last int
 	)
-	err := func() error {
-		input, err := os.Open(file)
-		if err != nil {
-			return err
+	for i := 0; i < len(s); {
+		old, replacement, ok := r.match(s, i)
+		if !ok {
+			i++
+			continue
 		}
-		defer func() {
-			_ = input.Close()
-		}()
-		scanner := bufio.NewScanner(input)
-		writeLine := func(line []byte) {
-			if bytes.Contains(line, []byte(oldAppName)) {
-				line = bytes.ReplaceAll(line, []byte(oldAppName), []byte(newAppName))
-			}
-			buf.Write(line)
-			buf.WriteString("\n")
+		buf.WriteString(s[last:i])
+		buf.WriteString(replacement)
+		i += len(old)
+		last = i
+	}
+	if last == 0 {
+		return s
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+func (r *nameReplacer) match(s string, i int) (old, replacement string, ok bool) {
+	for p := 0; p+1 < len(r.pairs); p += 2 {
+		old = r.pairs[p]
+		if len(old) == 0 || !strings.HasPrefix(s[i:], old) {
+			continue
 		}
-		for scanner.Scan() {
-			writeLine(scanner.Bytes())
+		end := i + len(old)
+		if isWordByte(old[0]) && i > 0 && isWordByte(s[i-1]) {
+			continue
 		}
-		if err := scanner.Err(); err != nil {
-			return err
+		if isWordByte(old[len(old)-1]) && end < len(s) && isWordByte(s[end]) {
+			continue
 		}
-		return nil
-	}()
-	if err != nil {
-		return fmt.Errorf("error processing file '%s': %w", file, err)
-	}
-	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to rewrite file '%s': %w", file, err)
+		return old, r.pairs[p+1], true
 	}
-	return nil
+	return "", "", false
+}
+
+// isWordByte reports whether c can be part of a word, treating any non-ASCII byte as a letter.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf
+}
+
+func isBinary(content []byte) bool {
+	return !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0
 }
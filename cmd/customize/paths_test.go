@@ -0,0 +1,111 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func testReplacer() *nameReplacer {
+	return newNameReplacer("yourapp", "widgets", "Your App!", "Acme Widgets", "Your App", "Acme Widgets")
+}
+
+func TestNameReplacer(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"Unchanged":       {input: "nothing here", expected: "nothing here"},
+		"Whole word":      {input: "run yourapp serve", expected: "run widgets serve"},
+		"Path":            {input: "./build/yourapp/yourapp", expected: "./build/widgets/widgets"},
+		"Punctuation":     {input: "yourapp-db:5432", expected: "widgets-db:5432"},
+		"Identifier":      {input: "yourappVersion, myyourapp, yourapp_db", expected: "yourappVersion, myyourapp, yourapp_db"},
+		"Display name":    {input: "About Your App", expected: "About Acme Widgets"},
+		"Brand":           {input: "<title>Your App! - {title}</title>", expected: "<title>Acme Widgets - {title}</title>"},
+		"Non-ASCII word":  {input: "éyourapp", expected: "éyourapp"},
+		"Adjacent names":  {input: "yourapp/yourapp", expected: "widgets/widgets"},
+		"Part of display": {input: "Your Apps", expected: "Your Apps"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, testReplacer().Replace(tc.input))
+		})
+	}
+}
+
+func TestSQLName(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"Unchanged":  {input: "widgets", expected: "widgets"},
+		"Hyphen":     {input: "my-app", expected: "my_app"},
+		"Upper case": {input: "MyApp", expected: "myapp"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sqlName(tc.input))
+		})
+	}
+}
+
+func TestReplaceGoText(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"Strings and comments": {
+			input:    "// yourapp does things\nvar yourapp = \"yourapp\" + `yourapp` /* yourapp */\n",
+			expected: "// widgets does things\nvar yourapp = \"widgets\" + `widgets` /* widgets */\n",
+		},
+		"Import paths": {
+			input:    "import \"yourapp\"\nimport (\n\ty \"yourapp/x\"\n)\n\nvar s = \"yourapp\"\n",
+			expected: "import \"yourapp\"\nimport (\n\ty \"yourapp/x\"\n)\n\nvar s = \"widgets\"\n",
+		},
+		"Fragment": {
+			input:    "{ templ.URL(\"/yourapp\") }",
+			expected: "{ templ.URL(\"/widgets\") }",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, string(replaceGoText([]byte(tc.input), testReplacer())))
+		})
+	}
+}
+
+func TestReplaceTemplText(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"Text and attributes": {
+			input:    "templ A() {\n\t<a class=\"yourapp\" title='Your App'>Your App!</a>\n}\n",
+			expected: "templ A() {\n\t<a class=\"widgets\" title='Acme Widgets'>Acme Widgets</a>\n}\n",
+		},
+		"Go expressions": {
+			input:    "templ A(yourapp string) {\n\t<p data-x={ yourapp + \"yourapp\" }>{ yourapp } yourapp</p>\n}\n",
+			expected: "templ A(yourapp string) {\n\t<p data-x={ yourapp + \"widgets\" }>{ yourapp } widgets</p>\n}\n",
+		},
+		"Go lines": {
+			input:    "templ A(yourapp string) {\n\tif yourapp == \"yourapp\" {\n\t\t@B(yourapp)\n\t} else {\n\t\tyourapp\n\t}\n}\n",
+			expected: "templ A(yourapp string) {\n\tif yourapp == \"widgets\" {\n\t\t@B(yourapp)\n\t} else {\n\t\twidgets\n\t}\n}\n",
+		},
+		"Top level Go": {
+			input:    "package t\n\n// yourapp\nfunc yourapp() string { return \"yourapp\" }\n\ntempl A() {\n\t<p>{ yourapp() }</p>\n}\n",
+			expected: "package t\n\n// widgets\nfunc yourapp() string { return \"widgets\" }\n\ntempl A() {\n\t<p>{ yourapp() }</p>\n}\n",
+		},
+		"Braces in strings": {
+			input:    "templ A() {\n\t<p>{ fmt.Sprint(\"}\", yourapp) } yourapp</p>\n}\n",
+			expected: "templ A() {\n\t<p>{ fmt.Sprint(\"}\", yourapp) } widgets</p>\n}\n",
+		},
+		"HTML comments": {
+			input:    "templ A() {\n\t<!-- <yourapp> -->\n}\n",
+			expected: "templ A() {\n\t<!-- <widgets> -->\n}\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, string(replaceTemplText([]byte(tc.input), testReplacer())))
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileChange is the planned state of a file.
+type fileChange struct {
+	path string
+	mode fs.FileMode
+	old  []byte
+	new  []byte
+	// newName is the file's new base name, if it's renamed.
+	newName string
+}
+
+// plan collects every change customize will make, so they can be shown with --dry-run before anything is written.
+type plan struct {
+	files      map[string]*fileChange
+	dirRenames map[string]string
+	removed    map[string]bool
+}
+
+func newPlan() *plan {
+	return &plan{
+		files:      map[string]*fileChange{},
+		dirRenames: map[string]string{},
+		removed:    map[string]bool{},
+	}
+}
+
+// remove deletes a file or directory, after which it's ignored by the rest of the plan.
+func (p *plan) remove(path string) {
+	p.removed[filepath.Clean(path)] = true
+}
+
+// isRemoved reports whether a path, or any directory containing it, is removed.
+func (p *plan) isRemoved(path string) bool {
+	for path = filepath.Clean(path); ; path = filepath.Dir(path) {
+		if p.removed[path] {
+			return true
+		}
+		if parent := filepath.Dir(path); parent == path {
+			return false
+		}
+	}
+}
+
+// edit changes the planned content of a file, which is read from disk the first time it's edited.
+// Removed files are left alone.
+func (p *plan) edit(path string, fn func(content []byte) ([]byte, error)) error {
+	path = filepath.Clean(path)
+	if p.isRemoved(path) {
+		return nil
+	}
+	change, ok := p.files[path]
+	if !ok {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("unable to stat file '%s': %w", path, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read file '%s': %w", path, err)
+		}
+		change = &fileChange{path: path, mode: fi.Mode().Perm(), old: data, new: data}
+		p.files[path] = change
+	}
+	updated, err := fn(change.new)
+	if err != nil {
+		return fmt.Errorf("error processing file '%s': %w", path, err)
+	}
+	change.new = updated
+	return nil
+}
+
+// content returns the planned content of a file, which is what's on disk if it isn't changed.
+func (p *plan) content(path string) ([]byte, error) {
+	if change, ok := p.files[filepath.Clean(path)]; ok {
+		return change.new, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file '%s': %w", path, err)
+	}
+	return data, nil
+}
+
+func (p *plan) renameFile(path, newName string) error {
+	return p.edit(path, func(content []byte) ([]byte, error) {
+		p.files[filepath.Clean(path)].newName = newName
+		return content, nil
+	})
+}
+
+func (p *plan) renameDir(path, newName string) {
+	if p.isRemoved(path) {
+		return
+	}
+	p.dirRenames[filepath.Clean(path)] = newName
+}
+
+// finalPath returns where a file or directory ends up after all renames.
+func (p *plan) finalPath(path string) string {
+	parts := strings.Split(filepath.Clean(path), string(filepath.Separator))
+	for i := range parts {
+		dir := filepath.Join(parts[:i+1]...)
+		if newName, ok := p.dirRenames[dir]; ok {
+			parts[i] = newName
+		}
+	}
+	if change, ok := p.files[filepath.Clean(path)]; ok && len(change.newName) > 0 {
+		parts[len(parts)-1] = change.newName
+	}
+	return filepath.Join(parts...)
+}
+
+func (p *plan) sortedFiles() []*fileChange {
+	changes := make([]*fileChange, 0, len(p.files))
+	for _, change := range p.files {
+		if p.isRemoved(change.path) {
+			continue
+		}
+		if !bytes.Equal(change.old, change.new) || len(change.newName) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].path < changes[j].path
+	})
+	return changes
+}
+
+// sortedDirs returns renamed directories, deepest first, so each can be renamed before its parent.
+func (p *plan) sortedDirs() []string {
+	dirs := make([]string, 0, len(p.dirRenames))
+	for dir := range p.dirRenames {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		di, dj := strings.Count(dirs[i], string(filepath.Separator)), strings.Count(dirs[j], string(filepath.Separator))
+		if di != dj {
+			return di > dj
+		}
+		return dirs[i] < dirs[j]
+	})
+	return dirs
+}
+
+func (p *plan) sortedRemoved() []string {
+	paths := make([]string, 0, len(p.removed))
+	for path := range p.removed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Print writes each removal and rename, and a unified diff of each content change.
+func (p *plan) Print(out io.Writer) {
+	for _, path := range p.sortedRemoved() {
+		_, _ = fmt.Fprintf(out, "remove %s\n", filepath.ToSlash(path))
+	}
+	for _, dir := range p.sortedDirs() {
+		_, _ = fmt.Fprintf(out, "rename %s/ => %s/\n", filepath.ToSlash(dir), filepath.ToSlash(filepath.Join(filepath.Dir(dir), p.dirRenames[dir])))
+	}
+	for _, change := range p.sortedFiles() {
+		oldPath := filepath.ToSlash(change.path)
+		newPath := filepath.ToSlash(p.finalPath(change.path))
+		if len(change.newName) > 0 {
+			_, _ = fmt.Fprintf(out, "rename %s => %s\n", oldPath, newPath)
+		}
+		_, _ = fmt.Fprint(out, unifiedDiff(oldPath, newPath, change.old, change.new))
+	}
+}
+
+// Summary describes the size of the plan in one line.
+func (p *plan) Summary() string {
+	var edited, renamed int
+	for _, change := range p.sortedFiles() {
+		if !bytes.Equal(change.old, change.new) {
+			edited++
+		}
+		if len(change.newName) > 0 {
+			renamed++
+		}
+	}
+	return fmt.Sprintf("%d files changed, %d files renamed, %d directories renamed, %d paths removed", edited, renamed, len(p.dirRenames), len(p.removed))
+}
+
+// Apply removes paths, then writes the planned content, then renames files, then renames directories from the deepest up.
+func (p *plan) Apply() error {
+	for _, path := range p.sortedRemoved() {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", path, err)
+		}
+	}
+	changes := p.sortedFiles()
+	for _, change := range changes {
+		if bytes.Equal(change.old, change.new) {
+			continue
+		}
+		if err := os.WriteFile(change.path, change.new, change.mode); err != nil {
+			return fmt.Errorf("failed to rewrite file '%s': %w", change.path, err)
+		}
+	}
+	for _, change := range changes {
+		if len(change.newName) == 0 {
+			continue
+		}
+		if err := os.Rename(change.path, filepath.Join(filepath.Dir(change.path), change.newName)); err != nil {
+			return err
+		}
+	}
+	for _, dir := range p.sortedDirs() {
+		if err := os.Rename(dir, filepath.Join(filepath.Dir(dir), p.dirRenames[dir])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+)
+
+// templGoLines start lines of Go code within a templ component, rather than markup.
+var templGoLines = []string{"if ", "else", "for ", "switch ", "case ", "default:", "}", "@"}
+
+// templRewriter replaces names in a templ file without parsing it fully.
+// Markup text and quoted attribute values are plain text, and Go code is changed like it would be in a Go file,
+// so tag names, attribute names, and Go identifiers are never changed.
+type templRewriter struct {
+	src      []byte
+	replacer *nameReplacer
+	out      bytes.Buffer
+	// pos is the start of the source not yet written to out.
+	pos int
+}
+
+func replaceTemplText(src []byte, replacer *nameReplacer) []byte {
+	t := &templRewriter{src: src, replacer: replacer}
+	for lineStart := 0; lineStart < len(src); {
+		line := t.line(lineStart)
+		lineEnd := lineStart + len(line)
+		if bytes.HasPrefix(line, []byte("templ ")) && bytes.HasSuffix(bytes.TrimSpace(line), []byte("{")) {
+			// Everything up to and including the component signature is Go.
+			t.code(lineEnd)
+			lineEnd = t.body(lineEnd)
+		}
+		lineStart = lineEnd
+	}
+	t.code(len(src))
+	return t.out.Bytes()
+}
+
+// line returns the line starting at i, including its newline.
+func (t *templRewriter) line(i int) []byte {
+	if end := bytes.IndexByte(t.src[i:], '\n'); end >= 0 {
+		return t.src[i : i+end+1]
+	}
+	return t.src[i:]
+}
+
+func (t *templRewriter) text(to int) {
+	t.out.WriteString(t.replacer.Replace(string(t.src[t.pos:to])))
+	t.pos = to
+}
+
+func (t *templRewriter) code(to int) {
+	t.out.Write(replaceGoText(t.src[t.pos:to], t.replacer))
+	t.pos = to
+}
+
+func (t *templRewriter) raw(to int) {
+	t.out.Write(t.src[t.pos:to])
+	t.pos = to
+}
+
+// body rewrites a component body starting at i, and returns the start of its closing brace, which is at the start of a line.
+func (t *templRewriter) body(i int) int {
+	lineStart := true
+	for i < len(t.src) {
+		if lineStart {
+			line := t.line(i)
+			if bytes.Equal(bytes.TrimRight(line, "\r\n"), []byte("}")) {
+				t.text(i)
+				return i
+			}
+			trimmed := bytes.TrimLeft(line, " \t")
+			if isTemplGoLine(trimmed) {
+				t.text(i + len(line) - len(trimmed))
+				i += len(line)
+				t.code(i)
+				continue
+			}
+		}
+		c := t.src[i]
+		switch {
+		case c == '\n':
+			lineStart = true
+			i++
+			continue
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case bytes.HasPrefix(t.src[i:], []byte("<!--")):
+			if end := bytes.Index(t.src[i:], []byte("-->")); end >= 0 {
+				i += end + len("-->")
+			} else {
+				i = len(t.src)
+			}
+		case c == '<' && i+1 < len(t.src) && (t.src[i+1] == '/' || isLetter(t.src[i+1])):
+			t.text(i)
+			i = t.tag(i)
+		case c == '{':
+			t.text(i)
+			i = matchBrace(t.src, i)
+			t.code(i)
+		default:
+			i++
+		}
+		lineStart = false
+	}
+	t.text(len(t.src))
+	return len(t.src)
+}
+
+// tag rewrites the quoted attribute values and Go expressions of the tag starting at i, and returns the end of the tag.
+func (t *templRewriter) tag(i int) int {
+	for i++; i < len(t.src); {
+		switch c := t.src[i]; c {
+		case '>':
+			i++
+			t.raw(i)
+			return i
+		case '"', '\'':
+			t.raw(i + 1)
+			end := bytes.IndexByte(t.src[i+1:], c)
+			if end < 0 {
+				t.text(len(t.src))
+				return len(t.src)
+			}
+			i += end + 1
+			t.text(i)
+			i++
+		case '{':
+			t.raw(i)
+			i = matchBrace(t.src, i)
+			t.code(i)
+		default:
+			i++
+		}
+	}
+	t.raw(len(t.src))
+	return len(t.src)
+}
+
+func isTemplGoLine(line []byte) bool {
+	for _, prefix := range templGoLines {
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// matchBrace returns the index after the brace that closes the one at i, skipping braces in Go strings.
+func matchBrace(src []byte, i int) int {
+	var depth int
+	for ; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'', '`':
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		}
+	}
+	return len(src)
+}
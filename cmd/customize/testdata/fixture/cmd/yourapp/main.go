@@ -0,0 +1,26 @@
+// Command yourapp serves the app.
+package main
+
+import (
+	"fmt"
+	"github.com/example/yourapp-extras/banner"
+	"os"
+	"yourapp/cmd/yourapp/internal/templates"
+	greeting "yourapp/feature/greet" // feature:greet
+	"yourapp/foundation/brand"
+	"yourappkit/util"
+)
+
+// yourappVersion is an identifier, so it's left alone and reported as remaining.
+var yourappVersion = "1.0.0"
+
+func main() {
+	fmt.Println("Starting yourapp", yourappVersion, brand.Name, banner.Text, util.Version)
+	// feature:greet begin
+	fmt.Println(greeting.Hello("you"))
+	fmt.Println(greeting.Shout("you")) // feature:shout
+	// feature:greet end
+	// feature:!greet: fmt.Println("Hello from", brand.Name)
+	_, _ = fmt.Fprintln(os.Stderr, `yourapp shut down`)
+	_ = templates.HeadSection("")
+}
@@ -0,0 +1,16 @@
+// Package greet says hello from yourapp.
+package greet
+
+import (
+	"fmt"
+	"strings"
+	"yourapp/foundation/brand"
+)
+
+func Hello(name string) string {
+	return fmt.Sprintf("Hello %s, from %s!", name, brand.Name)
+}
+
+func Shout(name string) string { // feature:shout
+	return strings.ToUpper(Hello(name)) // feature:shout
+} // feature:shout
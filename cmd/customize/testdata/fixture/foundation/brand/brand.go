@@ -0,0 +1,8 @@
+// Package brand names the app.
+package brand
+
+const (
+	Name = "Your App"
+	// Binary is the name of the yourapp executable.
+	Binary = "yourapp"
+)
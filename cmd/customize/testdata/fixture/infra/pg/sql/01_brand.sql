@@ -0,0 +1,9 @@
+create table brand
+(
+    name text not null default 'Your App'
+);
+
+-- feature:greet begin
+-- Greetings reference the yourapp brand.
+alter table brand add column greeting text null;
+-- feature:greet end
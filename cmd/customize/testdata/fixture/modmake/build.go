@@ -0,0 +1,8 @@
+package main
+
+import "yourapp/foundation/brand"
+
+const (
+	appMainPath = "./cmd/yourapp"
+	appName     = brand.Binary
+)
@@ -0,0 +1,23 @@
+// Command widgets serves the app.
+package main
+
+import (
+	"fmt"
+	"github.com/acme/widgets/cmd/widgets/internal/templates"
+	greeting "github.com/acme/widgets/feature/greet"
+	"github.com/acme/widgets/foundation/brand"
+	"github.com/example/yourapp-extras/banner"
+	"os"
+	"yourappkit/util"
+)
+
+// yourappVersion is an identifier, so it's left alone and reported as remaining.
+var yourappVersion = "1.0.0"
+
+func main() {
+	fmt.Println("Starting widgets", yourappVersion, brand.Name, banner.Text, util.Version)
+	fmt.Println(greeting.Hello("you"))
+	fmt.Println(greeting.Shout("you"))
+	_, _ = fmt.Fprintln(os.Stderr, `widgets shut down`)
+	_ = templates.HeadSection("")
+}
@@ -0,0 +1,16 @@
+// Package greet says hello from widgets.
+package greet
+
+import (
+	"fmt"
+	"github.com/acme/widgets/foundation/brand"
+	"strings"
+)
+
+func Hello(name string) string {
+	return fmt.Sprintf("Hello %s, from %s!", name, brand.Name)
+}
+
+func Shout(name string) string {
+	return strings.ToUpper(Hello(name))
+}
@@ -0,0 +1,8 @@
+// Package brand names the app.
+package brand
+
+const (
+	Name = "Acme Widgets"
+	// Binary is the name of the widgets executable.
+	Binary = "widgets"
+)
@@ -0,0 +1,7 @@
+create table brand
+(
+    name text not null default 'Acme Widgets'
+);
+
+-- Greetings reference the widgets brand.
+alter table brand add column greeting text null;
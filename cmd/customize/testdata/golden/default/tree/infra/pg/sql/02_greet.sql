@@ -0,0 +1,4 @@
+create table greeting
+(
+    name text not null
+);
@@ -0,0 +1,5 @@
+create table brand
+(
+    name text not null default 'Acme Widgets'
+);
+
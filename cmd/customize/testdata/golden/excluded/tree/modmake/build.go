@@ -0,0 +1,8 @@
+package main
+
+import "github.com/acme/widgets/foundation/brand"
+
+const (
+	appMainPath = "./cmd/widgets"
+	appName     = brand.Binary
+)
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	namePattern = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+	// reservedFields are columns every entity has.
+	reservedFields = []string{"id", "created_at", "updated_at"}
+)
+
+// Field kinds, which decide the Go type, column type, form input, and parsing of a field.
+const (
+	kindString = "string"
+	kindText   = "text"
+	kindInt    = "int"
+	kindFloat  = "float"
+	kindBool   = "bool"
+	kindDate   = "date"
+)
+
+var kinds = []string{kindString, kindText, kindInt, kindFloat, kindBool, kindDate}
+
+// entity is what the templates are rendered with.
+// Names are snake case, and methods derive the other forms used in code, SQL, and URLs.
+type entity struct {
+	Module    string
+	App       string
+	Name      string
+	Plural    string
+	Fields    []field
+	Auth      string
+	Migration string
+}
+
+type field struct {
+	Name string
+	Kind string
+}
+
+// parseEntity validates the entity name and field list given on the command line.
+// The plural is derived from the name if it's empty.
+func parseEntity(name, plural string, specs []string) (*entity, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid entity name '%s', it should be lower snake case like 'line_item'", name)
+	}
+	if len(plural) == 0 {
+		plural = pluralize(name)
+	}
+	if !namePattern.MatchString(plural) || plural == name {
+		return nil, fmt.Errorf("invalid plural name '%s', it should be lower snake case and differ from '%s'", plural, name)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("at least one field is required")
+	}
+	e := &entity{Name: name, Plural: plural, Auth: strings.ReplaceAll(plural, "_", "-")}
+	for _, spec := range specs {
+		f, err := parseField(spec)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(e.Fields, func(other field) bool { return other.Name == f.Name }) {
+			return nil, fmt.Errorf("field '%s' is given more than once", f.Name)
+		}
+		e.Fields = append(e.Fields, f)
+	}
+	return e, nil
+}
+
+// parseField parses a field given as NAME:KIND.
+func parseField(spec string) (field, error) {
+	name, kind, ok := strings.Cut(spec, ":")
+	if !ok {
+		return field{}, fmt.Errorf("invalid field '%s', it should be NAME:KIND", spec)
+	}
+	if !namePattern.MatchString(name) {
+		return field{}, fmt.Errorf("invalid field name '%s', it should be lower snake case", name)
+	}
+	if slices.Contains(reservedFields, name) {
+		return field{}, fmt.Errorf("field '%s' is added to every entity, so it can't be given", name)
+	}
+	if !slices.Contains(kinds, kind) {
+		return field{}, fmt.Errorf("invalid kind '%s' for field '%s', it should be one of %s", kind, name, strings.Join(kinds, ", "))
+	}
+	return field{Name: name, Kind: kind}, nil
+}
+
+// pluralize handles the common English plurals, and anything else can be given with --plural.
+func pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsRune("aeiou", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "z"),
+		strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	default:
+		return name + "s"
+	}
+}
+
+// Type is the exported Go name, like LineItem.
+func (e *entity) Type() string {
+	return camel(e.Name, true)
+}
+
+// Types is the exported Go name of the plural, like LineItems.
+func (e *entity) Types() string {
+	return camel(e.Plural, true)
+}
+
+// Var is the unexported Go name, like lineItem.
+func (e *entity) Var() string {
+	return camel(e.Name, false)
+}
+
+// Package is the feature package name, like lineitems.
+func (e *entity) Package() string {
+	return strings.ReplaceAll(e.Plural, "_", "")
+}
+
+// Path is the URL path of the list page, like /line-items.
+func (e *entity) Path() string {
+	return "/" + strings.ReplaceAll(e.Plural, "_", "-")
+}
+
+// Title is the name shown in page headings, like Line Item.
+func (e *entity) Title() string {
+	return title(e.Name)
+}
+
+// Titles is the plural shown in page headings, like Line Items.
+func (e *entity) Titles() string {
+	return title(e.Plural)
+}
+
+// Label is the name used in sentences, like line item.
+func (e *entity) Label() string {
+	return strings.ReplaceAll(e.Name, "_", " ")
+}
+
+// Labels is the plural used in sentences, like line items.
+func (e *entity) Labels() string {
+	return strings.ReplaceAll(e.Plural, "_", " ")
+}
+
+// Has reports whether any field is one of the given kinds, which decides the imports a file needs.
+func (e *entity) Has(kinds ...string) bool {
+	return slices.ContainsFunc(e.Fields, func(f field) bool { return slices.Contains(kinds, f.Kind) })
+}
+
+// Columns lists the field columns, separated by commas.
+func (e *entity) Columns() string {
+	var cols []string
+	for _, f := range e.Fields {
+		cols = append(cols, f.Name)
+	}
+	return strings.Join(cols, ", ")
+}
+
+// Placeholders lists a query parameter for each field, numbered from first.
+func (e *entity) Placeholders(first int) string {
+	var params []string
+	for i := range e.Fields {
+		params = append(params, fmt.Sprintf("$%d", first+i))
+	}
+	return strings.Join(params, ", ")
+}
+
+// Assignments sets each field's column to a query parameter, numbered from first.
+func (e *entity) Assignments(first int) string {
+	var sets []string
+	for i, f := range e.Fields {
+		sets = append(sets, fmt.Sprintf("%s = $%d", f.Name, first+i))
+	}
+	return strings.Join(sets, ", ")
+}
+
+// ParamList lists the params struct's fields as query arguments.
+func (e *entity) ParamList() string {
+	var args []string
+	for _, f := range e.Fields {
+		args = append(args, "params."+f.GoName())
+	}
+	return strings.Join(args, ", ")
+}
+
+// ScanList lists pointers to every column of a result variable, in the order they're selected.
+func (e *entity) ScanList(result string) string {
+	args := []string{fmt.Sprintf("&%s.%sID", result, e.Type())}
+	for _, f := range e.Fields {
+		args = append(args, fmt.Sprintf("&%s.%s", result, f.GoName()))
+	}
+	args = append(args, "&"+result+".CreatedAt", "&"+result+".UpdatedAt")
+	return strings.Join(args, ", ")
+}
+
+// GoName is the exported Go name, like UnitPrice.
+func (f field) GoName() string {
+	return camel(f.Name, true)
+}
+
+// JSON is the JSON name, like unitPrice.
+func (f field) JSON() string {
+	return camel(f.Name, false)
+}
+
+// Title is the name shown as a form label, like Unit Price.
+func (f field) Title() string {
+	return title(f.Name)
+}
+
+// Label is the name used in sentences, like unit price.
+func (f field) Label() string {
+	return strings.ReplaceAll(f.Name, "_", " ")
+}
+
+// Required reports whether a value must be given in the form.
+func (f field) Required() bool {
+	return f.Kind != kindText && f.Kind != kindBool
+}
+
+// Display is the templ expression that shows the field of a result variable as text.
+func (f field) Display(result string) string {
+	value := result + "." + f.GoName()
+	switch f.Kind {
+	case kindInt:
+		return fmt.Sprintf("sprintf(\"%%d\", %s)", value)
+	case kindFloat:
+		return fmt.Sprintf("sprintf(\"%%g\", %s)", value)
+	case kindBool:
+		return fmt.Sprintf("yesNo(%s)", value)
+	case kindDate:
+		return value + ".Format(\"2006-01-02\")"
+	default:
+		return value
+	}
+}
+
+func (f field) GoType() string {
+	switch f.Kind {
+	case kindInt:
+		return "int64"
+	case kindFloat:
+		return "float64"
+	case kindBool:
+		return "bool"
+	case kindDate:
+		return "time.Time"
+	default:
+		return "string"
+	}
+}
+
+func (f field) SQLType() string {
+	switch f.Kind {
+	case kindText:
+		return "text not null default ''"
+	case kindInt:
+		return "bigint not null"
+	case kindFloat:
+		return "double precision not null"
+	case kindBool:
+		return "boolean not null default false"
+	case kindDate:
+		return "date not null"
+	default:
+		return "text not null"
+	}
+}
+
+// Sample is a valid form value for tests, and SampleGo is the Go value it parses to.
+func (f field) Sample() string {
+	switch f.Kind {
+	case kindText:
+		return "Some longer text"
+	case kindInt:
+		return "42"
+	case kindFloat:
+		return "1.5"
+	case kindBool:
+		return "true"
+	case kindDate:
+		return "2024-01-02"
+	default:
+		return "Example"
+	}
+}
+
+func (f field) SampleGo() string {
+	switch f.Kind {
+	case kindInt:
+		return "42"
+	case kindFloat:
+		return "1.5"
+	case kindBool:
+		return "true"
+	case kindDate:
+		return "time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)"
+	default:
+		return fmt.Sprintf("%q", f.Sample())
+	}
+}
+
+// Invalid is a form value that fails to parse or validate, or empty if there isn't one.
+func (f field) Invalid() string {
+	switch f.Kind {
+	case kindInt, kindFloat:
+		return "many"
+	case kindDate:
+		return "tomorrow"
+	default:
+		return ""
+	}
+}
+
+func camel(name string, exported bool) string {
+	var buf strings.Builder
+	for i, part := range strings.Split(name, "_") {
+		if len(part) == 0 {
+			continue
+		}
+		switch {
+		case part == "id" || part == "url" || part == "api" || part == "http":
+			if i > 0 || exported {
+				part = strings.ToUpper(part)
+			}
+		case i > 0 || exported:
+			part = strings.ToUpper(part[:1]) + part[1:]
+		}
+		buf.WriteString(part)
+	}
+	return buf.String()
+}
+
+func title(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseEntity(t *testing.T) {
+	tests := map[string]struct {
+		name        string
+		plural      string
+		fields      []string
+		expected    *entity
+		expectedErr string
+	}{
+		"Simple": {
+			name:     "widget",
+			fields:   []string{"name:string", "price:float"},
+			expected: &entity{Name: "widget", Plural: "widgets", Auth: "widgets", Fields: []field{{"name", kindString}, {"price", kindFloat}}},
+		},
+		"Snake case": {
+			name:     "line_item",
+			fields:   []string{"due_on:date"},
+			expected: &entity{Name: "line_item", Plural: "line_items", Auth: "line-items", Fields: []field{{"due_on", kindDate}}},
+		},
+		"Given plural": {
+			name:     "person",
+			plural:   "people",
+			fields:   []string{"name:string"},
+			expected: &entity{Name: "person", Plural: "people", Auth: "people", Fields: []field{{"name", kindString}}},
+		},
+		"Invalid name": {
+			name:        "Widget",
+			fields:      []string{"name:string"},
+			expectedErr: "invalid entity name 'Widget'",
+		},
+		"Plural same as name": {
+			name:        "sheep",
+			plural:      "sheep",
+			fields:      []string{"name:string"},
+			expectedErr: "invalid plural name 'sheep'",
+		},
+		"No fields": {
+			name:        "widget",
+			expectedErr: "at least one field is required",
+		},
+		"Missing kind": {
+			name:        "widget",
+			fields:      []string{"name"},
+			expectedErr: "invalid field 'name'",
+		},
+		"Unknown kind": {
+			name:        "widget",
+			fields:      []string{"name:varchar"},
+			expectedErr: "invalid kind 'varchar'",
+		},
+		"Reserved field": {
+			name:        "widget",
+			fields:      []string{"created_at:date"},
+			expectedErr: "field 'created_at' is added to every entity",
+		},
+		"Duplicate field": {
+			name:        "widget",
+			fields:      []string{"name:string", "name:text"},
+			expectedErr: "field 'name' is given more than once",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := parseEntity(tc.name, tc.plural, tc.fields)
+			if len(tc.expectedErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, e)
+		})
+	}
+}
+
+func TestEntity_names(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		expected []string
+	}{
+		"Single word": {
+			name:     "widget",
+			expected: []string{"Widget", "Widgets", "widget", "widgets", "/widgets", "Widget", "widget"},
+		},
+		"Snake case": {
+			name:     "line_item",
+			expected: []string{"LineItem", "LineItems", "lineItem", "lineitems", "/line-items", "Line Item", "line item"},
+		},
+		"Consonant y": {
+			name:     "category",
+			expected: []string{"Category", "Categories", "category", "categories", "/categories", "Category", "category"},
+		},
+		"Vowel y": {
+			name:     "survey_key",
+			expected: []string{"SurveyKey", "SurveyKeys", "surveyKey", "surveykeys", "/survey-keys", "Survey Key", "survey key"},
+		},
+		"Sibilant": {
+			name:     "box",
+			expected: []string{"Box", "Boxes", "box", "boxes", "/boxes", "Box", "box"},
+		},
+		"Initialism": {
+			name:     "api_client",
+			expected: []string{"APIClient", "APIClients", "apiClient", "apiclients", "/api-clients", "Api Client", "api client"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := parseEntity(tc.name, "", []string{"name:string"})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, []string{e.Type(), e.Types(), e.Var(), e.Package(), e.Path(), e.Title(), e.Label()})
+		})
+	}
+}
+
+func TestField_GoName(t *testing.T) {
+	tests := map[string]string{
+		"name":       "Name",
+		"unit_price": "UnitPrice",
+		"owner_id":   "OwnerID",
+		"home_url":   "HomeURL",
+	}
+	for name, expected := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, expected, field{Name: name}.GoName())
+		})
+	}
+}
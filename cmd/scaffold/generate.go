@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var (
+	//go:embed templates
+	templateFS embed.FS
+	templates  = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+	migrationsDir     = filepath.Join("infra", "pg", "sql")
+	migrationsGo      = filepath.Join("feature", "model", "migrations.go")
+	migrationPattern  = regexp.MustCompile(`^(\d+)_.+\.sql$`)
+	modulePattern     = regexp.MustCompile(`^module\s+(\S+)`)
+	requiredMigration = "var RequiredMigrations = []string{"
+	// routesAnchor is the catch-all route, which generated routes are registered before.
+	routesAnchor = `mux.Handle("/", ro.handle(ro.notFound()))`
+)
+
+// output is a generated file, or an edit to an existing one.
+type output struct {
+	path    string
+	content []byte
+	edit    bool
+}
+
+// generate renders every file for the entity, and the edits that wire it into the app.
+// Nothing is written, so a failure leaves the tree as it was.
+func generate(e *entity) ([]output, error) {
+	outputs, err := render(e)
+	if err != nil {
+		return nil, err
+	}
+	edits := []struct {
+		path string
+		edit func([]byte) ([]byte, error)
+	}{
+		{migrationsGo, func(content []byte) ([]byte, error) { return addMigration(content, e.Migration) }},
+		{filepath.Join("cmd", e.App, "internal", "routes", "routes.go"), func(content []byte) ([]byte, error) {
+			return addRoutes(content, fmt.Sprintf("ro.%sRoutes(mux)", e.Var()))
+		}},
+	}
+	for _, edit := range edits {
+		content, err := os.ReadFile(edit.path)
+		if err != nil {
+			return nil, err
+		}
+		if content, err = edit.edit(content); err != nil {
+			return nil, fmt.Errorf("unable to update '%s': %w", edit.path, err)
+		}
+		outputs = append(outputs, output{path: edit.path, content: content, edit: true})
+	}
+	return outputs, nil
+}
+
+// render renders the entity's new files, which must not exist yet.
+func render(e *entity) ([]output, error) {
+	files := []struct {
+		template string
+		path     string
+	}{
+		{"migration.sql.tmpl", filepath.Join(migrationsDir, e.Migration+".sql")},
+		{"model.go.tmpl", filepath.Join("feature", "model", e.Plural+".go")},
+		{"feature.go.tmpl", filepath.Join("feature", e.Package(), e.Package()+".go")},
+		{"feature_test.go.tmpl", filepath.Join("feature", e.Package(), e.Package()+"_test.go")},
+		{"routes.go.tmpl", filepath.Join("cmd", e.App, "internal", "routes", e.Plural+".go")},
+		{"pages.templ.tmpl", filepath.Join("cmd", e.App, "internal", "templates", e.Plural+".templ")},
+	}
+	var outputs []output
+	for _, f := range files {
+		if _, err := os.Stat(f.path); err == nil {
+			return nil, fmt.Errorf("'%s' already exists", f.path)
+		}
+		var buf bytes.Buffer
+		if err := templates.ExecuteTemplate(&buf, f.template, e); err != nil {
+			return nil, fmt.Errorf("failed to render '%s': %w", f.path, err)
+		}
+		content := buf.Bytes()
+		if strings.HasSuffix(f.path, ".go") {
+			formatted, err := format.Source(content)
+			if err != nil {
+				return nil, fmt.Errorf("generated invalid Go code for '%s': %w", f.path, err)
+			}
+			content = formatted
+		}
+		outputs = append(outputs, output{path: f.path, content: content})
+	}
+	return outputs, nil
+}
+
+// write writes outputs in order, creating directories as needed.
+func write(outputs []output) error {
+	for _, out := range outputs {
+		if err := os.MkdirAll(filepath.Dir(out.path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out.path, out.content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMigration adds a migration to the end of model.RequiredMigrations.
+func addMigration(content []byte, migration string) ([]byte, error) {
+	start := bytes.Index(content, []byte(requiredMigration))
+	if start < 0 {
+		return nil, fmt.Errorf("unable to find RequiredMigrations, add '%s' to it by hand", migration)
+	}
+	end := bytes.Index(content[start:], []byte("\n}"))
+	if end < 0 {
+		return nil, errors.New("unable to find the end of RequiredMigrations")
+	}
+	end += start + 1
+	var buf bytes.Buffer
+	buf.Write(content[:end])
+	buf.WriteString(strconv.Quote(migration) + ",\n")
+	buf.Write(content[end:])
+	return format.Source(buf.Bytes())
+}
+
+// addRoutes adds a line that registers routes just before the catch-all route.
+func addRoutes(content []byte, line string) ([]byte, error) {
+	i := bytes.Index(content, []byte(routesAnchor))
+	if i < 0 {
+		return nil, fmt.Errorf("unable to find the catch-all route, add '%s' before it by hand", line)
+	}
+	i = bytes.LastIndexByte(content[:i], '\n') + 1
+	var buf bytes.Buffer
+	buf.Write(content[:i])
+	buf.WriteString("\t" + line + "\n")
+	buf.Write(content[i:])
+	return buf.Bytes(), nil
+}
+
+// readModule returns the module path from go.mod in the current directory.
+func readModule() (string, error) {
+	f, err := os.Open("go.mod")
+	if err != nil {
+		return "", fmt.Errorf("scaffold must be run from the root of the repo: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if match := modulePattern.FindStringSubmatch(scanner.Text()); match != nil {
+			return match[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("no module declared in go.mod")
+}
+
+// findApp returns the name of the app's directory in cmd, which is the only one with routes.
+func findApp() (string, error) {
+	matches, err := filepath.Glob(filepath.Join("cmd", "*", "internal", "routes", "routes.go"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) != 1 {
+		return "", fmt.Errorf("expected one app with routes in cmd, found %d", len(matches))
+	}
+	return filepath.Base(filepath.Dir(filepath.Dir(filepath.Dir(matches[0])))), nil
+}
+
+// nextMigration names the script after the highest numbered one in infra/pg/sql.
+func nextMigration(plural string) (string, error) {
+	entries, err := os.ReadDir(migrationsDir)
+	if err != nil {
+		return "", err
+	}
+	var highest int
+	for _, entry := range entries {
+		if match := migrationPattern.FindStringSubmatch(entry.Name()); match != nil {
+			n, _ := strconv.Atoi(match[1])
+			highest = max(highest, n)
+		}
+	}
+	return fmt.Sprintf("%02d_%s", highest+1, plural), nil
+}
+
+// relative returns the slash separated path, for messages that should look the same on every OS.
+func relative(p string) string {
+	return path.Clean(filepath.ToSlash(p))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := map[string][]string{
+		"Every kind": {"name:string", "notes:text", "quantity:int", "unit_price:float", "active:bool", "due_on:date"},
+		"Only text":  {"notes:text"},
+		"Only bool":  {"active:bool"},
+		"Only date":  {"due_on:date"},
+	}
+	for name, fields := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := parseEntity("line_item", "", fields)
+			require.NoError(t, err)
+			e.Module, e.App, e.Migration = "example.com/shop", "shop", "07_line_items"
+			outputs, err := render(e)
+			require.NoError(t, err)
+			require.Len(t, outputs, 6)
+			for _, out := range outputs {
+				assert.False(t, out.edit)
+				assert.NotContains(t, string(out.content), "<no value>", out.path)
+				if strings.HasSuffix(out.path, ".go") {
+					assert.Empty(t, unusedImports(t, out.content), out.path)
+				}
+			}
+			assert.Contains(t, string(outputs[0].content), "create table line_item")
+			assert.Contains(t, string(outputs[0].content), "values ('07_line_items')")
+			assert.Contains(t, string(outputs[4].content), `"example.com/shop/cmd/shop/internal/templates"`)
+			assert.Contains(t, string(outputs[5].content), "templ LineItemEdit(form LineItemForm)")
+		})
+	}
+}
+
+// unusedImports returns the imports of a Go file that aren't referred to, since a compiler isn't available to check generated code.
+func unusedImports(t *testing.T, src []byte) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	require.NoError(t, err)
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+	var unused []string
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		require.NoError(t, err)
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if !used[name] {
+			unused = append(unused, importPath)
+		}
+	}
+	return unused
+}
+
+func TestAddMigration(t *testing.T) {
+	tests := map[string]struct {
+		content     string
+		expected    string
+		expectedErr string
+	}{
+		"One per line": {
+			content:  "package model\n\nvar RequiredMigrations = []string{\n\t\"01_auth\",\n}\n",
+			expected: "package model\n\nvar RequiredMigrations = []string{\n\t\"01_auth\",\n\t\"02_widgets\",\n}\n",
+		},
+		"Several on a line": {
+			content:  "package model\n\nvar RequiredMigrations = []string{\n\t\"01_auth\", \"01_more\",\n}\n\nvar other = []string{\n}\n",
+			expected: "package model\n\nvar RequiredMigrations = []string{\n\t\"01_auth\", \"01_more\",\n\t\"02_widgets\",\n}\n\nvar other = []string{}\n",
+		},
+		"Missing": {
+			content:     "package model\n",
+			expectedErr: "unable to find RequiredMigrations",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			updated, err := addMigration([]byte(tc.content), "02_widgets")
+			if len(tc.expectedErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(updated))
+		})
+	}
+}
+
+func TestAddRoutes(t *testing.T) {
+	content := "func (ro *Router) ServeMux() *http.ServeMux {\n\tmux := http.NewServeMux()\n\tmux.Handle(\"/\", ro.handle(ro.notFound()))\n\treturn mux\n}\n"
+	updated, err := addRoutes([]byte(content), "ro.widgetRoutes(mux)")
+	assert.NoError(t, err)
+	assert.Equal(t, "func (ro *Router) ServeMux() *http.ServeMux {\n\tmux := http.NewServeMux()\n\tro.widgetRoutes(mux)\n\tmux.Handle(\"/\", ro.handle(ro.notFound()))\n\treturn mux\n}\n", string(updated))
+
+	_, err = addRoutes([]byte("package routes\n"), "ro.widgetRoutes(mux)")
+	assert.ErrorContains(t, err, "unable to find the catch-all route")
+}
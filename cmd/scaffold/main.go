@@ -0,0 +1,105 @@
+// Scaffold generates a CRUD feature for a new entity, following the patterns of the rest of the app.
+//
+// It writes the migration, model code with Redirect* hooks for tests, a feature service with table-driven tests,
+// routes, and list, detail, and edit pages, then registers the routes and the migration.
+package main
+
+import (
+	"errors"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	exitOK = iota
+	// exitFailed means the files couldn't be generated or written.
+	exitFailed
+	// exitUsage means the flags or arguments were invalid.
+	exitUsage
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+func run(args []string, out io.Writer) int {
+	var (
+		plural string
+		dryRun bool
+	)
+	flags := flag.NewFlagSet("scaffold", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(out, "Usage: go run ./cmd/scaffold [flags] ENTITY NAME:KIND...")
+		_, _ = fmt.Fprintln(out)
+		_, _ = fmt.Fprintln(out, "ENTITY and field names are lower snake case, like line_item or unit_price.")
+		_, _ = fmt.Fprintln(out, "KIND is one of string, text, int, float, bool, or date.")
+		_, _ = fmt.Fprintln(out)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&plural, "plural", "", "Plural of the entity name, if adding an 's' isn't right")
+	flags.BoolVar(&dryRun, "dry-run", false, "Print the files that would be written, without writing them")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return exitUsage
+	}
+	e, err := parseEntity(flags.Arg(0), plural, flags.Args()[1:])
+	if err != nil {
+		_, _ = fmt.Fprintln(out, "Invalid arguments:", err)
+		return exitUsage
+	}
+
+	if err := resolve(e); err != nil {
+		_, _ = fmt.Fprintln(out, err)
+		return exitFailed
+	}
+	outputs, err := generate(e)
+	if err != nil {
+		_, _ = fmt.Fprintln(out, "Unable to scaffold:", err)
+		return exitFailed
+	}
+	for _, o := range outputs {
+		verb := "Create"
+		if o.edit {
+			verb = "Update"
+		}
+		_, _ = fmt.Fprintf(out, "%s %s\n", verb, relative(o.path))
+	}
+	if dryRun {
+		return exitOK
+	}
+	if err := write(outputs); err != nil {
+		_, _ = fmt.Fprintln(out, "Unable to write files:", err)
+		return exitFailed
+	}
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintln(out, "Next steps:")
+	_, _ = fmt.Fprintln(out, "  - Generate the templ code with 'go run ./modmake generate', and run the tests.")
+	_, _ = fmt.Fprintf(out, "  - Apply %s to the database.\n", relative(filepath.Join(migrationsDir, e.Migration+".sql")))
+	_, _ = fmt.Fprintf(out, "  - Grant access with '%s auth grant USERNAME %s'.\n", e.App, e.Auth) // feature:authz
+	_, _ = fmt.Fprintf(out, "  - Link to %s from the pages that need it.\n", e.Path())
+	return exitOK
+}
+
+// resolve fills in what the entity needs from the repo it's generated in.
+func resolve(e *entity) (err error) {
+	if e.Module, err = readModule(); err != nil {
+		return err
+	}
+	if e.App, err = findApp(); err != nil {
+		return err
+	}
+	if e.Migration, err = nextMigration(e.Plural); err != nil {
+		return err
+	}
+	return nil
+}
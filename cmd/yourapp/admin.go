@@ -0,0 +1,685 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/cli"
+	"github.com/saylorsolutions/x/signalx"
+	flag "github.com/spf13/pflag"
+	"golang.org/x/term"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"yourapp/feature/audit"
+	"yourapp/feature/backup"
+	"yourapp/feature/model"
+	"yourapp/feature/tenant"
+)
+
+var (
+	errNoSuchUser = errors.New("no such user")
+	errNoSuchOrg  = errors.New("no such organization")
+)
+
+// withDB opens the database the same way the server does, and records audit entries as [audit.CLIUser].
+func withDB(fn func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error) error {
+	ctx := signalx.SignalCtx(context.Background(), os.Interrupt, syscall.SIGTERM)
+	db, err := initData()
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return fn(ctx, db, audit.NewLogger(db, audit.StdDelegate(log.New(os.Stderr, "", log.LstdFlags), false)))
+}
+
+// readPassword prompts for a password without echo if in is a terminal, asking twice to confirm it.
+// Otherwise, the first line of in is used, so passwords can be piped in from a secret store.
+func readPassword(in *os.File, out io.Writer) (string, error) {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if len(password) == 0 {
+			return "", errors.New("no password given on stdin")
+		}
+		return password, nil
+	}
+	prompt := func(msg string) (string, error) {
+		_, _ = fmt.Fprint(out, msg)
+		val, err := term.ReadPassword(fd)
+		_, _ = fmt.Fprintln(out)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return string(val), nil
+	}
+	password, err := prompt("Password: ")
+	if err != nil {
+		return "", err
+	}
+	if len(password) == 0 {
+		return "", errors.New("password must not be empty")
+	}
+	confirm, err := prompt("Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirm {
+		return "", errors.New("passwords don't match")
+	}
+	return password, nil
+}
+
+// requireRow returns errNoSuchUser if result didn't affect any rows.
+func requireRow(result sql.Result, username string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", errNoSuchUser, username)
+	}
+	return nil
+}
+
+func mapUsername(flags *flag.FlagSet) (string, error) {
+	var username string
+	if err := cli.MapArgs(flags.Args(), 1, &username); err != nil || len(username) == 0 {
+		return "", cli.NewUsageError("a username is required")
+	}
+	return username, nil
+}
+
+func addUserCommands(cmds *cli.CommandSet) {
+	user := cmds.AddCommand("user", "Manages user accounts")
+
+	add := user.AddCommand("add", "Adds a user, reading their password from a prompt or stdin")
+	add.Usage("add [FLAGS] USERNAME")
+	add.Flags().Bool("admin", false, "Makes the new user an admin")
+	add.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		username, err := mapUsername(flags)
+		if err != nil {
+			return err
+		}
+		password, err := readPassword(os.Stdin, os.Stderr)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			if _, err := model.CreateUser(ctx, db, username, password); err != nil {
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Added user %s", username)
+			if cli.MustGet(flags.GetBool("admin")) {
+				if _, err := model.ElevateToAdmin(ctx, db, username); err != nil {
+					return err
+				}
+				auditLog.Postf(ctx, audit.CLIUser, "Promoted user %s to admin", username)
+			}
+			p.Printf("Added user %s\n", username)
+			return nil
+		})
+	})
+
+	passwd := user.AddCommand("passwd", "Changes a user's password, reading it from a prompt or stdin")
+	passwd.Usage("passwd USERNAME")
+	passwd.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		username, err := mapUsername(flags)
+		if err != nil {
+			return err
+		}
+		password, err := readPassword(os.Stdin, os.Stderr)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			result, err := model.UpdatePassword(ctx, db, username, password)
+			if err != nil {
+				return err
+			}
+			if err := requireRow(result, username); err != nil {
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Changed password for user %s", username)
+			p.Printf("Changed password for user %s\n", username)
+			return nil
+		})
+	})
+
+	lock := user.AddCommand("lock", "Locks a user out, ending their sessions and revoking their tokens")
+	lock.Usage("lock USERNAME")
+	lock.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		username, err := mapUsername(flags)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			result, err := model.LockUser(ctx, db, username)
+			if err != nil {
+				return err
+			}
+			if err := requireRow(result, username); err != nil {
+				return err
+			}
+			if _, err := model.InvalidateUserSessions(ctx, db, username); err != nil {
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Locked user %s", username)
+			p.Printf("Locked user %s, use 'passwd' to unlock\n", username)
+			return nil
+		})
+	})
+
+	del := user.AddCommand("delete", "Deletes a user, along with their sessions and grants")
+	del.Usage("delete --yes USERNAME")
+	del.Flags().Bool("yes", false, "Confirms that the user should be deleted")
+	del.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		username, err := mapUsername(flags)
+		if err != nil {
+			return err
+		}
+		if !cli.MustGet(flags.GetBool("yes")) {
+			return cli.NewUsageError("deleting a user can't be undone, pass --yes to confirm")
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			result, err := model.DeleteUser(ctx, db, username)
+			if err != nil {
+				return err
+			}
+			if err := requireRow(result, username); err != nil {
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Deleted user %s", username)
+			p.Printf("Deleted user %s\n", username)
+			return nil
+		})
+	})
+
+	promote := user.AddCommand("promote", "Makes a user an admin")
+	promote.Usage("promote USERNAME")
+	promote.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		username, err := mapUsername(flags)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			result, err := model.ElevateToAdmin(ctx, db, username)
+			if err != nil {
+				return err
+			}
+			if err := requireRow(result, username); err != nil {
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Promoted user %s to admin", username)
+			p.Printf("Promoted user %s to admin\n", username)
+			return nil
+		})
+	})
+
+	list := user.AddCommand("list", "Lists all users")
+	list.Does(func(flags *flag.FlagSet, _ *cli.Printer) error {
+		return withDB(func(ctx context.Context, db *sql.DB, _ *audit.Logger) error {
+			users, err := model.GetAllUsers(ctx, db)
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "ID\tUSERNAME\tADMIN")
+			for _, u := range users {
+				_, _ = fmt.Fprintf(tw, "%d\t%s\t%t\n", u.UserID, u.Username, u.Admin)
+			}
+			return tw.Flush()
+		})
+	})
+}
+
+// feature:authz begin
+
+func addAuthCommands(cmds *cli.CommandSet) {
+	authCmd := cmds.AddCommand("auth", "Manages authorizations granted to users")
+
+	// resolve looks up the IDs needed to grant or revoke an authorization.
+	resolve := func(ctx context.Context, db *sql.DB, username, authName string) (userID string, authID string, err error) {
+		user, err := model.GetUser(ctx, db, username)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return "", "", fmt.Errorf("%w: %s", errNoSuchUser, username)
+			}
+			return "", "", err
+		}
+		auths, err := model.GetAuthorizations(ctx, db)
+		if err != nil {
+			return "", "", err
+		}
+		for _, a := range auths {
+			if strings.EqualFold(a.Name, authName) {
+				return fmt.Sprintf("%d", user.UserID), fmt.Sprintf("%d", a.AuthID), nil
+			}
+		}
+		return "", "", fmt.Errorf("no authorization named '%s' exists, see 'auth list'", authName)
+	}
+	mapGrant := func(flags *flag.FlagSet) (string, string, error) {
+		var username, authName string
+		if err := cli.MapArgs(flags.Args(), 2, &username, &authName); err != nil {
+			return "", "", cli.NewUsageError("a username and authorization are required")
+		}
+		return username, authName, nil
+	}
+
+	grant := authCmd.AddCommand("grant", "Grants an authorization to a user")
+	grant.Usage("grant USERNAME AUTH")
+	grant.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		username, authName, err := mapGrant(flags)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			userID, authID, err := resolve(ctx, db, username, authName)
+			if err != nil {
+				return err
+			}
+			if _, err := model.GrantAuth(ctx, db, userID, authID); err != nil {
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Granted auth '%s' to user %s", authName, username)
+			p.Printf("Granted '%s' to %s\n", authName, username)
+			return nil
+		})
+	})
+
+	revoke := authCmd.AddCommand("revoke", "Revokes an authorization from a user")
+	revoke.Usage("revoke USERNAME AUTH")
+	revoke.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		username, authName, err := mapGrant(flags)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			userID, authID, err := resolve(ctx, db, username, authName)
+			if err != nil {
+				return err
+			}
+			result, err := model.RevokeAuth(ctx, db, userID, authID)
+			if err != nil {
+				return err
+			}
+			if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+				return fmt.Errorf("user %s was not granted '%s'", username, authName)
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Revoked auth '%s' from user %s", authName, username)
+			p.Printf("Revoked '%s' from %s\n", authName, username)
+			return nil
+		})
+	})
+
+	list := authCmd.AddCommand("list", "Lists all authorizations, or those granted to a user")
+	list.Usage("list [USERNAME]")
+	list.Does(func(flags *flag.FlagSet, _ *cli.Printer) error {
+		var username string
+		_ = cli.MapArgs(flags.Args(), 0, &username)
+		return withDB(func(ctx context.Context, db *sql.DB, _ *audit.Logger) error {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			if len(username) == 0 {
+				auths, err := model.GetAuthorizations(ctx, db)
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(tw, "ID\tAUTH")
+				for _, a := range auths {
+					_, _ = fmt.Fprintf(tw, "%d\t%s\n", a.AuthID, a.Name)
+				}
+				return tw.Flush()
+			}
+			user, err := model.GetUser(ctx, db, username)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("%w: %s", errNoSuchUser, username)
+				}
+				return err
+			}
+			grants, err := model.UserAuth(ctx, db, user.UserID)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(tw, "ID\tAUTH\tGRANTED")
+			for _, g := range grants {
+				_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\n", g.Id, g.Auth, g.Granted.Format("2006-01-02 15:04:05"))
+			}
+			return tw.Flush()
+		})
+	})
+}
+
+// feature:authz end
+
+func addOrgCommands(cmds *cli.CommandSet) {
+	orgCmd := cmds.AddCommand("org", "Manages organizations and their members")
+
+	// resolve looks up the organization and user that a membership command is for.
+	// Audit entries posted with the returned context are recorded in the organization.
+	resolve := func(ctx context.Context, db *sql.DB, slug, username string) (context.Context, *model.GetOrganizationResult, *model.GetUserResult, error) {
+		org, err := model.GetOrganization(ctx, db, slug)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ctx, nil, nil, fmt.Errorf("%w: %s", errNoSuchOrg, slug)
+			}
+			return ctx, nil, nil, err
+		}
+		user, err := model.GetUser(ctx, db, username)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ctx, nil, nil, fmt.Errorf("%w: %s", errNoSuchUser, username)
+			}
+			return ctx, nil, nil, err
+		}
+		return model.WithTenant(ctx, org.OrgID), org, user, nil
+	}
+	mapMember := func(flags *flag.FlagSet) (string, string, error) {
+		var slug, username string
+		if err := cli.MapArgs(flags.Args(), 2, &slug, &username); err != nil {
+			return "", "", cli.NewUsageError("an organization and username are required")
+		}
+		return slug, username, nil
+	}
+
+	add := orgCmd.AddCommand("add", "Adds an organization, identified by a slug used in its URLs")
+	add.Usage("add SLUG NAME")
+	add.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		var slug, name string
+		if err := cli.MapArgs(flags.Args(), 2, &slug, &name); err != nil {
+			return cli.NewUsageError("a slug and name are required")
+		}
+		if !tenant.ValidSlug(slug) {
+			return cli.NewUsageError("the slug must be lower case letters, digits, and hyphens, and may not be 'www'")
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			orgID, err := model.CreateOrganization(ctx, db, slug, name)
+			if err != nil {
+				return err
+			}
+			auditLog.Postf(model.WithTenant(ctx, orgID), audit.CLIUser, "Added organization %s", slug)
+			p.Printf("Added organization %s\n", slug)
+			return nil
+		})
+	})
+
+	list := orgCmd.AddCommand("list", "Lists all organizations")
+	list.Does(func(flags *flag.FlagSet, _ *cli.Printer) error {
+		return withDB(func(ctx context.Context, db *sql.DB, _ *audit.Logger) error {
+			orgs, err := model.GetAllOrganizations(ctx, db)
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "ID\tSLUG\tNAME\tMEMBERS")
+			for _, o := range orgs {
+				_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%d\n", o.OrgID, o.Slug, o.Name, o.Members)
+			}
+			return tw.Flush()
+		})
+	})
+
+	members := orgCmd.AddCommand("members", "Lists an organization's members")
+	members.Usage("members SLUG")
+	members.Does(func(flags *flag.FlagSet, _ *cli.Printer) error {
+		var slug string
+		if err := cli.MapArgs(flags.Args(), 1, &slug); err != nil || len(slug) == 0 {
+			return cli.NewUsageError("an organization is required")
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, _ *audit.Logger) error {
+			org, err := model.GetOrganization(ctx, db, slug)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("%w: %s", errNoSuchOrg, slug)
+				}
+				return err
+			}
+			results, err := model.GetMembers(ctx, db, org.OrgID)
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "ID\tUSERNAME\tADMIN\tJOINED")
+			for _, m := range results {
+				_, _ = fmt.Fprintf(tw, "%d\t%s\t%t\t%s\n", m.UserID, m.Username, m.Admin, m.JoinedAt.Format("2006-01-02 15:04:05"))
+			}
+			return tw.Flush()
+		})
+	})
+
+	addMember := orgCmd.AddCommand("add-member", "Adds a user to an organization, or changes whether they're one of its admins")
+	addMember.Usage("add-member [FLAGS] SLUG USERNAME")
+	addMember.Flags().Bool("admin", false, "Makes the member an admin of the organization")
+	addMember.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		slug, username, err := mapMember(flags)
+		if err != nil {
+			return err
+		}
+		admin := cli.MustGet(flags.GetBool("admin"))
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			orgCtx, org, _, err := resolve(ctx, db, slug, username)
+			if err != nil {
+				return err
+			}
+			if _, err := model.AddMember(ctx, db, org.OrgID, username, admin); err != nil {
+				return err
+			}
+			auditLog.Postf(orgCtx, audit.CLIUser, "Added %s to organization %s (admin: %t)", username, slug, admin)
+			p.Printf("Added %s to %s\n", username, slug)
+			return nil
+		})
+	})
+
+	removeMember := orgCmd.AddCommand("remove-member", "Removes a user from an organization, along with their grants in it")
+	removeMember.Usage("remove-member SLUG USERNAME")
+	removeMember.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		slug, username, err := mapMember(flags)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			orgCtx, org, user, err := resolve(ctx, db, slug, username)
+			if err != nil {
+				return err
+			}
+			result, err := model.RemoveMember(ctx, db, org.OrgID, user.UserID)
+			if err != nil {
+				return err
+			}
+			if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+				return fmt.Errorf("user %s is not a member of %s", username, slug)
+			}
+			auditLog.Postf(orgCtx, audit.CLIUser, "Removed %s from organization %s", username, slug)
+			p.Printf("Removed %s from %s\n", username, slug)
+			return nil
+		})
+	})
+	// feature:authz begin
+
+	// resolveAuth looks up the authorization to grant or revoke by name.
+	resolveAuth := func(ctx context.Context, db *sql.DB, authName string) (uint64, error) {
+		auths, err := model.GetAuthorizations(ctx, db)
+		if err != nil {
+			return 0, err
+		}
+		for _, a := range auths {
+			if strings.EqualFold(a.Name, authName) {
+				return a.AuthID, nil
+			}
+		}
+		return 0, fmt.Errorf("no authorization named '%s' exists, see 'auth list'", authName)
+	}
+	mapGrant := func(flags *flag.FlagSet) (string, string, string, error) {
+		var slug, username, authName string
+		if err := cli.MapArgs(flags.Args(), 3, &slug, &username, &authName); err != nil {
+			return "", "", "", cli.NewUsageError("an organization, username, and authorization are required")
+		}
+		return slug, username, authName, nil
+	}
+
+	grant := orgCmd.AddCommand("grant", "Grants an authorization to a member, which only applies in their organization")
+	grant.Usage("grant SLUG USERNAME AUTH")
+	grant.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		slug, username, authName, err := mapGrant(flags)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			orgCtx, org, user, err := resolve(ctx, db, slug, username)
+			if err != nil {
+				return err
+			}
+			authID, err := resolveAuth(ctx, db, authName)
+			if err != nil {
+				return err
+			}
+			if _, err := model.GetMembership(ctx, db, org.OrgID, user.UserID); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("user %s is not a member of %s, see 'org add-member'", username, slug)
+				}
+				return err
+			}
+			if _, err := model.GrantMemberAuth(ctx, db, org.OrgID, user.UserID, authID); err != nil {
+				return err
+			}
+			auditLog.Postf(orgCtx, audit.CLIUser, "Granted auth '%s' to %s in organization %s", authName, username, slug)
+			p.Printf("Granted '%s' to %s in %s\n", authName, username, slug)
+			return nil
+		})
+	})
+
+	revoke := orgCmd.AddCommand("revoke", "Revokes an authorization from a member of an organization")
+	revoke.Usage("revoke SLUG USERNAME AUTH")
+	revoke.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		slug, username, authName, err := mapGrant(flags)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			orgCtx, org, user, err := resolve(ctx, db, slug, username)
+			if err != nil {
+				return err
+			}
+			authID, err := resolveAuth(ctx, db, authName)
+			if err != nil {
+				return err
+			}
+			result, err := model.RevokeMemberAuth(ctx, db, org.OrgID, user.UserID, authID)
+			if err != nil {
+				return err
+			}
+			if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+				return fmt.Errorf("user %s was not granted '%s' in %s", username, authName, slug)
+			}
+			auditLog.Postf(orgCtx, audit.CLIUser, "Revoked auth '%s' from %s in organization %s", authName, username, slug)
+			p.Printf("Revoked '%s' from %s in %s\n", authName, username, slug)
+			return nil
+		})
+	})
+	// feature:authz end
+}
+
+func addDBCommands(cmds *cli.CommandSet) {
+	dbCmd := cmds.AddCommand("db", "Backs up, restores, and seeds the application's tables")
+
+	dump := dbCmd.AddCommand("dump", "Writes the application's tables as versioned NDJSON")
+	dump.Usage("dump [FLAGS]")
+	dump.Flags().String("out", "", "Writes the dump to this file instead of stdout")
+	dump.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		out := cli.MustGet(flags.GetString("out"))
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			var w io.Writer = os.Stdout
+			if len(out) > 0 {
+				// The dump has password hashes, so only the owner may read it.
+				f, err := os.OpenFile(out, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+				if err != nil {
+					return fmt.Errorf("failed to create dump file: %w", err)
+				}
+				defer func() {
+					_ = f.Close()
+				}()
+				w = f
+			}
+			stats, err := backup.Dump(ctx, db, w)
+			if err != nil {
+				if len(out) > 0 {
+					_ = os.Remove(out)
+				}
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Dumped the database: %s", stats)
+			p.Printf("Dumped %s\n", stats)
+			return nil
+		})
+	})
+
+	restore := dbCmd.AddCommand("restore", "Loads a dump written by 'db dump' in a single transaction")
+	restore.Usage("restore [FILE]")
+	restore.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		var file string
+		_ = cli.MapArgs(flags.Args(), 0, &file)
+		var r io.Reader = os.Stdin
+		if len(file) > 0 && file != "-" {
+			f, err := os.Open(file)
+			if err != nil {
+				return fmt.Errorf("failed to open dump file: %w", err)
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			r = f
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			stats, err := backup.Restore(ctx, db, r)
+			if err != nil {
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Restored the database: %s", stats)
+			p.Printf("Restored %s\n", stats)
+			return nil
+		})
+	})
+
+	seed := dbCmd.AddCommand("seed", "Loads development fixtures, like demo users, from a JSON file")
+	seed.Usage("seed FILE")
+	seed.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		var file string
+		if err := cli.MapArgs(flags.Args(), 1, &file); err != nil || len(file) == 0 {
+			return cli.NewUsageError("a fixtures file is required")
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("failed to open fixtures file: %w", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		fixtures, err := backup.ReadFixtures(f)
+		if err != nil {
+			return err
+		}
+		return withDB(func(ctx context.Context, db *sql.DB, auditLog *audit.Logger) error {
+			stats, err := backup.Seed(ctx, db, fixtures)
+			if err != nil {
+				return err
+			}
+			auditLog.Postf(ctx, audit.CLIUser, "Seeded the database from %s: %s", file, stats)
+			p.Printf("Seeded %s\n", stats)
+			return nil
+		})
+	})
+}
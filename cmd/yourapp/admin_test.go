@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestReadPassword_Stdin(t *testing.T) {
+	tests := map[string]struct {
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		"Line":       {input: "s3cret pass\n", expected: "s3cret pass"},
+		"CRLF":       {input: "s3cret\r\n", expected: "s3cret"},
+		"No newline": {input: "s3cret", expected: "s3cret"},
+		"First line": {input: "first\nsecond\n", expected: "first"},
+		"Empty":      {input: "", expectErr: true},
+		"Blank line": {input: "\n", expectErr: true},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			assert.NoError(t, err)
+			defer func() {
+				_ = r.Close()
+			}()
+			_, err = w.WriteString(tc.input)
+			assert.NoError(t, err)
+			assert.NoError(t, w.Close())
+
+			password, err := readPassword(r, io.Discard)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, password)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"log"
+	"net/http"
+	"yourapp/cmd/yourapp/internal/routes"
+	"yourapp/feature/audit"
+	"yourapp/feature/jobs"
+	"yourapp/feature/outbox"
+	"yourapp/feature/tenant"
+	"yourapp/foundation/health"
+	"yourapp/foundation/metrics"
+	"yourapp/foundation/requestid"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
+)
+
+// app is the server's handlers and the services behind them, built from the environment.
+type app struct {
+	log    *log.Logger
+	router *routes.Router
+	// audit is the logger shared by every service, which records to the user_audit table.
+	audit  *audit.Logger
+	probe  *health.Probe
+	sender *outbox.Sender
+	worker *jobs.Worker
+}
+
+// newApp loads the app's configuration from the environment, and builds its services on db.
+// Nothing is started, so tests can build the same app that run does.
+func newApp(logger *log.Logger, delegate audit.LogDelegate, db *sql.DB) (*app, error) {
+	assetSet, err := initAssets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load static assets: %w", err)
+	}
+	corsPolicy, err := initCORS()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CORS policy: %w", err)
+	}
+	auditLog := audit.NewLogger(db, delegate)
+	authSvc, err := initAuth(auditLog, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize auth store: %w", err)
+	}
+	sender, capturedMail, err := initMail(delegate, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize mail: %w", err)
+	}
+	worker, err := initJobs(delegate, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize background jobs: %w", err)
+	}
+	signupSvc, err := initSignup(auditLog, db, authSvc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize signup: %w", err)
+	}
+	signupSvc.OnEnqueue = sender.Wake
+	probe := initHealth(logger, db)
+	a := &app{
+		log: logger,
+		router: &routes.Router{
+			Log:          logger,
+			LogDelegate:  delegate,
+			AuthSvc:      authSvc,
+			Signup:       signupSvc,
+			Tenants:      tenant.NewResolver(db),
+			Pool:         db,
+			Assets:       assetSet,
+			Health:       probe,
+			Build:        health.BuildInfo{Version: version, GitHash: gitHash},
+			CORS:         corsPolicy,
+			CapturedMail: capturedMail,
+		},
+		audit:  auditLog,
+		probe:  probe,
+		sender: sender,
+		worker: worker,
+	}
+	initDev(a)
+	return a, nil
+}
+
+// httpMetrics is created once, since its metrics can only be registered once.
+var httpMetrics = metrics.Default.NewHTTPMetrics()
+
+// handler is the full handler stack: the routes wrapped in middleware, and grouped under the URL prefix.
+// The organization's prefix comes after the URL prefix in path mode, like /prefix/org/acme/tokens.
+func (a *app) handler() http.Handler {
+	handler := withDev(httpx.Wrap(a.router.ServeMux(),
+		requestid.Middleware(),
+		httpx.LoggingMiddleware(httpx.StdLogger(a.log)),
+		httpx.RecoveryMiddleware(panicHandlerFunc(func(cause any) {
+			a.log.Println("[ERR] Panic encountered:", cause)
+		})),
+		initSecurityHeaders().Middleware(),
+		// This comes before routing, since it removes the organization's prefix from the path in path mode.
+		a.router.Tenants.Middleware(),
+		// This must directly wrap the mux to see which route was matched.
+		httpMetrics.Middleware(),
+	))
+	return urlprefix.Group(handler) // feature:urlprefix
+	// feature:!urlprefix: return handler
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/saylorsolutions/x/cli"
+	flag "github.com/spf13/pflag"
+	"net/http"
+	"os"
+	"time"
+	"yourapp/cmd/yourapp/internal/routes"
+	"yourapp/feature/auth"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
+)
+
+func commands() *cli.CommandSet {
+	cmds := cli.NewCommandSet("yourapp")
+	keygen := cmds.AddCommand("keygen", "Prints a new hex encoded cookie hash and block key pair")
+	keygen.Usage("keygen [FLAGS]")
+	keygen.Flags().Bool("pair", false, "Prints the key pair in the form used by "+auth.EnvKeys+" and "+auth.EnvKeysFile)
+	keygen.Does(func(flags *flag.FlagSet, p *cli.Printer) error {
+		kp := auth.GenerateKeyPair()
+		if cli.MustGet(flags.GetBool("pair")) {
+			p.Println(kp.String())
+			return nil
+		}
+		p.Printf("%s=%x\n%s=%x\n", auth.EnvHashKey, kp.Hash, auth.EnvBlockKey, kp.Block)
+		return nil
+	})
+
+	healthcheck := cmds.AddCommand("healthcheck", "Exits with an error if the local yourapp server is not ready, for use in container healthchecks")
+	healthcheck.Usage("healthcheck [FLAGS]")
+	healthcheck.Flags().String("url", "http://127.0.0.1"+listenAddr+urlprefix.Apply("/readyz"), "The readiness URL to check") // feature:urlprefix
+	// feature:!urlprefix: healthcheck.Flags().String("url", "http://127.0.0.1"+listenAddr+"/readyz", "The readiness URL to check")
+	healthcheck.Flags().Duration("timeout", 3*time.Second, "How long to wait for a response")
+	healthcheck.Does(func(flags *flag.FlagSet, _ *cli.Printer) error {
+		client := &http.Client{Timeout: cli.MustGet(flags.GetDuration("timeout"))}
+		resp, err := client.Get(cli.MustGet(flags.GetString("url")))
+		if err != nil {
+			return fmt.Errorf("failed to check readiness: %w", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("server is not ready: %s", resp.Status)
+		}
+		return nil
+	})
+
+	openapi := cmds.AddCommand("openapi", "Prints the OpenAPI document for the JSON API, for generating clients without running the server")
+	openapi.Usage("openapi")
+	openapi.Does(func(_ *flag.FlagSet, _ *cli.Printer) error {
+		policy, err := auth.LoadCookiePolicy()
+		if err != nil {
+			return err
+		}
+		authSvc := new(auth.Service)
+		if err := authSvc.SetCookiePolicy(policy); err != nil {
+			return err
+		}
+		ro := &routes.Router{AuthSvc: authSvc}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ro.OpenAPI(ro.APIv1()))
+	})
+
+	addUserCommands(cmds)
+	addAuthCommands(cmds) // feature:authz
+	addOrgCommands(cmds)
+	addDBCommands(cmds)
+	return cmds
+}
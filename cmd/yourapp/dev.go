@@ -0,0 +1,30 @@
+//go:build dev
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"yourapp/cmd/yourapp/internal/templates"
+	"yourapp/foundation/assets"
+	"yourapp/foundation/livereload"
+)
+
+// devStaticDir is where dev builds serve static assets from, relative to the root of the repo that local:dev runs in.
+const devStaticDir = "cmd/yourapp/static"
+
+// initDev serves static assets from disk instead of the embedded copies, so edits show up without a rebuild,
+// and makes pages reload when local:dev restarts the app.
+func initDev(a *app) {
+	a.log.Println("[WRN] !!! This is a dev build, static assets are served from", devStaticDir, "!!!")
+	a.router.Assets = assets.LoadLive(os.DirFS(devStaticDir))
+	templates.UseLiveReload(livereload.Path)
+}
+
+// withDev serves live reload events outside the middleware, which would keep them from being flushed.
+func withDev(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+livereload.Path, livereload.Handler())
+	mux.Handle("/", next)
+	return mux
+}
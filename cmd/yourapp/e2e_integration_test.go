@@ -0,0 +1,76 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"yourapp/feature/auth"
+	"yourapp/feature/model"
+	"yourapp/foundation/pgtest"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m, filepath.Join("..", "..", "infra", "pg", "sql")))
+}
+
+func TestLoginFlow_db(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	_, err := model.CreateUser(ctx, db, "alice", "correct horse")
+	require.NoError(t, err)
+	a, auditLog := newTestApp(t, db)
+	srv := httptest.NewTLSServer(a.handler())
+	defer srv.Close()
+
+	testLoginFlow(t, a, srv, auditLog)
+	count, err := model.CountActiveSessions(ctx, db)
+	require.NoError(t, err)
+	assert.Zero(t, count, "Logging out should invalidate the session")
+	// feature:audit begin
+	entries, err := model.GetLatestLogEntries(ctx, db, 100)
+	require.NoError(t, err)
+	var recorded bool
+	for _, entry := range entries {
+		if entry.Username == "alice" && entry.Action == "Authenticated" {
+			recorded = true
+		}
+	}
+	assert.True(t, recorded, "The login should be recorded in user_audit")
+	// feature:audit end
+}
+
+func TestLockUser_bearer(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	_, err := model.CreateUser(ctx, db, "alice", "correct horse")
+	require.NoError(t, err)
+	a, _ := newTestApp(t, db)
+	srv := httptest.NewTLSServer(a.handler())
+	defer srv.Close()
+	minted, err := a.router.AuthSvc.MintToken(ctx, "test", auth.TokenRequest{Username: "alice", Name: "ci"})
+	require.NoError(t, err)
+
+	getMe := func() int {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+urlprefix.Apply("/api/v1/me"), nil) // feature:urlprefix
+		// feature:!urlprefix: req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/me", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+minted.Token)
+		resp, err := srv.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, getMe())
+
+	_, err = model.LockUser(ctx, db, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, getMe(), "Locking a user should revoke their tokens")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"yourapp/feature/auth"
+	"yourapp/feature/model"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
+	"yourapp/foundation/webtest"
+)
+
+func TestLoginFlow(t *testing.T) {
+	a, auditLog := newTestApp(t, nil)
+	users := &fakeUsers{passwords: map[string]string{"alice": "correct horse"}, sessions: map[string]string{}}
+	users.redirect(a.router.AuthSvc.UsersRepo())
+	// feature:audit begin
+	a.audit.UserRepo.RedirectInsertAuditLog(func(context.Context, *sql.DB, string, string) (sql.Result, error) {
+		return nil, nil
+	})
+	// feature:audit end
+	srv := httptest.NewTLSServer(a.handler())
+	defer srv.Close()
+
+	testLoginFlow(t, a, srv, auditLog)
+	assert.Empty(t, users.sessions, "Logging out should invalidate the session")
+}
+
+// newTestApp builds the app on db like run does, with its logs recorded so audit events can be checked.
+func newTestApp(t *testing.T, db *sql.DB) (*app, *webtest.Log) {
+	t.Setenv(auth.EnvHashKey, hex.EncodeToString(securecookie.GenerateRandomKey(32)))
+	t.Setenv(auth.EnvBlockKey, hex.EncodeToString(securecookie.GenerateRandomKey(32)))
+	auditLog := new(webtest.Log)
+	a, err := newApp(log.New(io.Discard, "", 0), auditLog, db)
+	require.NoError(t, err)
+	return a, auditLog
+}
+
+// testLoginFlow logs in, views the home page and the API, and logs out, checking the audit events along the way.
+// The server is served over TLS, since session cookies are only sent over HTTPS.
+func testLoginFlow(t *testing.T, a *app, srv *httptest.Server, auditLog *webtest.Log) {
+	client := webtest.NewClient(t, srv, urlprefix.Get()) // feature:urlprefix
+	// feature:!urlprefix: client := webtest.NewClient(t, srv, "")
+	page := client.Get("/")
+	require.Equal(t, http.StatusOK, page.StatusCode)
+	assert.Equal(t, "/login", page.Path, "Home should redirect to login without a session")
+
+	page = client.Submit(page, "/login", url.Values{"username": {"alice"}, "password": {"battery staple"}})
+	require.Equal(t, http.StatusOK, page.StatusCode)
+	assert.Equal(t, "/login", page.Path)
+	assert.True(t, auditLog.Contains("failed authentication"))
+	assert.False(t, auditLog.Contains("Authenticated"))
+
+	page = client.Submit(page, "/login", url.Values{"username": {"alice"}, "password": {"correct horse"}})
+	require.Equal(t, http.StatusOK, page.StatusCode)
+	assert.Equal(t, "/", page.Path)
+	assert.Contains(t, page.Body, "alice")
+	assert.True(t, auditLog.Contains("Authenticated"))
+
+	readableCSRF := a.router.AuthSvc.CookiePolicy().Name(auth.CSRFReadableCookieKey)
+	require.Equal(t, http.StatusOK, client.Get("/api/v1/me").StatusCode)
+	require.NotNil(t, client.Cookie(readableCSRF), "The API should expose the CSRF token to scripts")
+
+	page = client.Get("/logout")
+	require.Equal(t, http.StatusOK, page.StatusCode)
+	assert.Equal(t, "/login", page.Path)
+	assert.Equal(t, "/login", client.Get("/").Path, "The session should end with logout")
+	assert.Nil(t, client.Cookie(readableCSRF), "Logout should clear the readable CSRF token")
+}
+
+// fakeUsers stands in for the users and session tables, through the UsersRepo redirects.
+type fakeUsers struct {
+	mu        sync.Mutex
+	passwords map[string]string
+	sessions  map[string]string
+	created   int
+}
+
+func (f *fakeUsers) redirect(repo *model.UsersRepo) {
+	repo.RedirectCheckPassword(func(_ context.Context, _ *sql.DB, username string, password string) (*model.CheckPasswordResult, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		expected, ok := f.passwords[username]
+		return &model.CheckPasswordResult{Matches: ok && expected == password}, nil
+	})
+	repo.RedirectCreateSession(func(_ context.Context, _ *sql.DB, username string) (*model.CreateSessionResult, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.created++
+		key := fmt.Sprintf("session-%d", f.created)
+		f.sessions[key] = username
+		return &model.CreateSessionResult{SessionKey: key}, nil
+	})
+	repo.RedirectGetSessionUser(func(_ context.Context, _ *sql.DB, sessionKey string) (*model.GetSessionUserResult, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		username, ok := f.sessions[sessionKey]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		return &model.GetSessionUserResult{UserID: 1, Username: username}, nil
+	})
+	repo.RedirectUpdateSessionLiveness(func(context.Context, *sql.DB, string) (sql.Result, error) {
+		return nil, nil
+	})
+	repo.RedirectInvalidateSession(func(_ context.Context, _ *sql.DB, sessionKey string) (sql.Result, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.sessions, sessionKey)
+		return nil, nil
+	})
+	// feature:authz begin
+	repo.RedirectUserAuth(func(context.Context, *sql.DB, uint64) ([]*model.UserAuthResult, error) {
+		return nil, nil
+	})
+	// feature:authz end
+}
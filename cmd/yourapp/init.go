@@ -1,12 +1,32 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/saylorsolutions/x/env"
+	"io/fs"
 	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"yourapp/cmd/yourapp/internal/templates"
 	"yourapp/feature/audit"
 	"yourapp/feature/auth"
+	"yourapp/feature/jobs"
+	"yourapp/feature/model"
+	"yourapp/feature/outbox"
+	"yourapp/feature/signup"
+	"yourapp/foundation/assets"
+	"yourapp/foundation/cors"
+	"yourapp/foundation/cron"
+	"yourapp/foundation/health"
+	"yourapp/foundation/mail"
+	"yourapp/foundation/metrics"
+	"yourapp/foundation/secheaders"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
 )
 
 func initData() (*sql.DB, error) {
@@ -17,7 +37,134 @@ func initData() (*sql.DB, error) {
 	return sql.Open("pgx", connectionURL)
 }
 
-func initAuth(logger *log.Logger, db *sql.DB) (*auth.Service, error) {
-	auditLog := audit.NewLogger(db, audit.StdDelegate(logger, true))
+func initHealth(logger *log.Logger, db *sql.DB) *health.Probe {
+	probe := &health.Probe{ErrorLog: logger}
+	probe.AddCheck("db", db.PingContext)
+	probe.AddCheck("migrations", func(ctx context.Context) error {
+		pending, err := model.PendingMigrations(ctx, db)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+		}
+		return nil
+	})
+	return probe
+}
+
+// initMetrics registers DB metrics, and returns the admin server that exposes them.
+// The server is nil if METRICS_ADDR isn't set, since metrics should not be served on the public listener.
+func initMetrics(logger *log.Logger, db *sql.DB) *http.Server {
+	dbGauge := func(name, help string, stat func(sql.DBStats) float64) {
+		metrics.NewGaugeFunc(name, help, func() float64 {
+			return stat(db.Stats())
+		})
+	}
+	dbGauge("db_pool_max_open_connections", "Maximum number of open connections to the database", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	dbGauge("db_pool_open_connections", "Number of established connections, both in use and idle", func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	dbGauge("db_pool_in_use_connections", "Number of connections currently in use", func(s sql.DBStats) float64 { return float64(s.InUse) })
+	dbGauge("db_pool_idle_connections", "Number of idle connections", func(s sql.DBStats) float64 { return float64(s.Idle) })
+	metrics.NewCounterFunc("db_pool_wait_count_total", "Total number of connections waited for", func() float64 {
+		return float64(db.Stats().WaitCount)
+	})
+	metrics.NewCounterFunc("db_pool_wait_duration_seconds_total", "Total time blocked waiting for a new connection", func() float64 {
+		return db.Stats().WaitDuration.Seconds()
+	})
+	metrics.NewGaugeFunc("auth_sessions_active", "Number of unexpired sessions", func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		count, err := model.CountActiveSessions(ctx, db)
+		if err != nil {
+			logger.Println("[ERR] Failed to count active sessions for metrics:", err)
+			return math.NaN()
+		}
+		return float64(count)
+	})
+
+	addr := env.Val("METRICS_ADDR", "")
+	if len(addr) == 0 {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Default.Handler())
+	return &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+}
+
+func initAuth(auditLog *audit.Logger, db *sql.DB) (*auth.Service, error) {
 	return auth.NewAuthService(auditLog, db)
 }
+
+// initMail loads the mailer, see [mail.Load], and returns the outbox sender that delivers with it.
+// If mail is captured, then the captured mailer is returned too, so it can be browsed at /dev/mail.
+func initMail(delegate audit.LogDelegate, db *sql.DB) (*outbox.Sender, *mail.CaptureMailer, error) {
+	mailer, err := mail.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	captured, _ := mailer.(*mail.CaptureMailer)
+	if captured != nil {
+		captured.OnCapture = func(msg mail.Captured) {
+			delegate.Info("Captured email '%s' to %s, see %s", msg.Subject, strings.Join(msg.To, ", "), urlprefix.Apply(fmt.Sprintf("/dev/mail/%d", msg.ID))) // feature:urlprefix
+			// feature:!urlprefix: delegate.Info("Captured email '%s' to %s, see /dev/mail/%d", msg.Subject, strings.Join(msg.To, ", "), msg.ID)
+		}
+	}
+	return outbox.NewSender(db, mailer, delegate), captured, nil
+}
+
+// purgeSessions deletes expired sessions, which are otherwise only removed when they're next used.
+var purgeSessions = jobs.NewKind[struct{}]("purge-sessions")
+
+// initJobs creates the background job worker, and registers the app's handlers and recurring jobs.
+func initJobs(delegate audit.LogDelegate, db *sql.DB) (*jobs.Worker, error) {
+	worker := jobs.NewWorker(db, delegate)
+	jobs.Handle(worker, purgeSessions, func(ctx context.Context, _ struct{}) error {
+		result, err := model.PurgeExpiredSessions(ctx, db)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			delegate.Info("Purged %d expired sessions", n)
+		}
+		return nil
+	})
+	if err := jobs.Schedule(worker, "purge-sessions", cron.MustParse("@hourly"), purgeSessions, struct{}{}); err != nil {
+		return nil, err
+	}
+	return worker, nil
+}
+
+// initSignup loads the signup settings, see [signup.LoadConfig].
+func initSignup(auditLog *audit.Logger, db *sql.DB, authSvc *auth.Service) (*signup.Service, error) {
+	cfg, err := signup.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return signup.NewService(cfg, auditLog, db, templates.SignupEmails{}, authSvc.LinkSigner()), nil
+}
+
+func initSecurityHeaders() secheaders.Policy {
+	policy := secheaders.DefaultPolicy()
+	policy.ReportOnly = env.Bool("CSP_REPORT_ONLY", false)
+	policy.ReportURI = urlprefix.Apply("/csp-report") // feature:urlprefix
+	// feature:!urlprefix: policy.ReportURI = "/csp-report"
+	policy.HSTSMaxAge = env.Duration("HSTS_MAX_AGE", policy.HSTSMaxAge)
+	return policy
+}
+
+// initCORS loads the per-origin CORS policy for the API from CORS_POLICY, see [cors.Parse].
+// No cross-origin requests are allowed by default.
+func initCORS() (cors.Policy, error) {
+	return cors.Parse(env.Val("CORS_POLICY", ""))
+}
+
+func initAssets() (*assets.Set, error) {
+	staticFS, err := fs.Sub(staticAssets, "static")
+	if err != nil {
+		return nil, err
+	}
+	return assets.Load(staticFS)
+}
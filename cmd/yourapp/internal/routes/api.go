@@ -0,0 +1,233 @@
+package routes
+
+import (
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"strings"
+	"yourapp/feature/auth"
+	"yourapp/feature/tenant"
+	"yourapp/foundation/api"
+	"yourapp/foundation/httperr"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
+)
+
+const apiPrefix = "/api/v1"
+
+// isAPIRequest returns true for requests to the JSON API, which should never get an HTML response.
+func isAPIRequest(r *http.Request) bool {
+	return r.URL.Path == apiPrefix || strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+// SessionInfo is what a single page app needs to know about the current user when it starts.
+type SessionInfo struct {
+	Authenticated  bool     `json:"authenticated"`
+	Username       string   `json:"username,omitempty"`
+	Admin          bool     `json:"admin"`
+	Authorizations []string `json:"authorizations"` // feature:authz
+	// Organization is set when the request is for an organization that the user is a member of.
+	Organization *OrgInfo `json:"organization,omitempty"`
+	// CSRFToken must be sent in the CSRFHeader with requests that change state.
+	// It's also set in a readable cookie, but cross-origin apps can't read that.
+	CSRFToken  string `json:"csrfToken,omitempty"`
+	CSRFHeader string `json:"csrfHeader"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// APIUser describes the caller of the API.
+type APIUser struct {
+	Username string `json:"username"`
+	Admin    bool   `json:"admin"`
+	// TokenID is set if the caller authenticated with an API token.
+	TokenID uint64   `json:"tokenID,omitempty"`
+	Scopes  []string `json:"scopes"` // feature:authz
+	// Organization is set when the request is for an organization that the caller is a member of.
+	Organization *OrgInfo `json:"organization,omitempty"`
+}
+
+// OrgInfo describes the organization that a request is for, and the user's membership in it.
+type OrgInfo struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Admin bool   `json:"admin"`
+}
+
+// orgInfo returns the request's organization, or nil if the user isn't a member of it.
+func orgInfo(r *http.Request, details auth.Details) *OrgInfo {
+	org, ok := tenant.Get(r.Context())
+	if !ok || details.TenantID != org.ID {
+		return nil
+	}
+	return &OrgInfo{Slug: org.Slug, Name: org.Name, Admin: details.TenantAdmin}
+}
+
+// APIv1 defines the versioned JSON API.
+// Routes only need to be added here to be served and described in the OpenAPI document.
+func (ro *Router) APIv1() *api.Group {
+	requireCSRF := ro.AuthSvc.RequireCSRF()
+	group := api.NewGroup(apiPrefix, "Your App API", "1")
+	group.ServerURL = urlprefix.Apply(apiPrefix) // feature:urlprefix
+	// feature:!urlprefix: group.ServerURL = apiPrefix
+	group.Add(
+		api.Operation{
+			Method:      http.MethodGet,
+			Path:        "/session",
+			Summary:     "Bootstrap a single page app session",
+			Description: "Returns the current user, if any, and the CSRF token for requests that change state.",
+			Tags:        []string{"session"},
+			Response:    SessionInfo{},
+			Handler:     ro.apiSession(),
+		},
+		api.Operation{
+			Method:     http.MethodPost,
+			Path:       "/session",
+			Summary:    "Log in",
+			Tags:       []string{"session"},
+			Request:    LoginRequest{},
+			Response:   SessionInfo{},
+			Handler:    ro.apiLogin(),
+			Middleware: []httpx.Middleware{requireCSRF},
+		},
+		api.Operation{
+			Method:     http.MethodDelete,
+			Path:       "/session",
+			Summary:    "Log out",
+			Tags:       []string{"session"},
+			Auth:       true,
+			Status:     http.StatusNoContent,
+			Handler:    ro.apiLogout(),
+			Middleware: []httpx.Middleware{requireCSRF},
+		},
+		api.Operation{
+			Method:   http.MethodGet,
+			Path:     "/me",
+			Summary:  "Describe the authenticated caller",
+			Tags:     []string{"session"},
+			Auth:     true,
+			Response: APIUser{},
+			Handler:  ro.apiMe(),
+		},
+	)
+	group.Add(api.Operation{
+		Method:  http.MethodGet,
+		Path:    "/openapi.json",
+		Summary: "This OpenAPI document",
+		Tags:    []string{"meta"},
+		Handler: func(w http.ResponseWriter, r *http.Request) error {
+			return api.Write(w, http.StatusOK, ro.OpenAPI(group))
+		},
+	})
+	return group
+}
+
+// OpenAPI describes the API group, including how to authenticate.
+func (ro *Router) OpenAPI(group *api.Group) *api.Document {
+	return group.OpenAPI(map[string]api.SecurityScheme{
+		"session": {Type: "apiKey", In: "cookie", Name: ro.AuthSvc.CookiePolicy().Name(auth.SessionCookieName)},
+		"token":   {Type: "http", Scheme: "bearer"},
+	})
+}
+
+// apiDetails returns the authenticated caller, or a 401 error.
+func apiDetails(r *http.Request) (auth.Details, error) {
+	details, ok := auth.GetSessionUser(r)
+	if !ok {
+		return auth.Details{}, httperr.Unauthorized("Authentication is required")
+	}
+	return details, nil
+}
+
+func sessionInfo(r *http.Request) SessionInfo {
+	info := SessionInfo{
+		Authorizations: []string{}, // feature:authz
+		CSRFHeader:     auth.CSRFHeaderKey,
+	}
+	info.CSRFToken, _ = auth.GetCSRF(r)
+	if details, ok := auth.GetSessionUser(r); ok {
+		info.Authenticated = true
+		info.Username = details.Username
+		info.Admin = details.Admin
+		info.Organization = orgInfo(r, details)
+		// feature:authz begin
+		for _, granted := range details.Authz {
+			info.Authorizations = append(info.Authorizations, granted.Auth)
+		}
+		// feature:authz end
+	}
+	return info
+}
+
+func (ro *Router) apiSession() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return api.Write(w, http.StatusOK, sessionInfo(r))
+	}
+}
+
+func (ro *Router) apiLogin() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var req LoginRequest
+		if err := api.Decode(r, &req); err != nil {
+			return err
+		}
+		matches, err := ro.AuthSvc.CheckPassword(r.Context(), req.Username, req.Password)
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to check password: %w", err))
+		}
+		if !matches {
+			ro.AuthSvc.RecordLogin(r.Context(), req.Username, false)
+			return httperr.Unauthorized("Invalid username or password")
+		}
+		r, err = ro.AuthSvc.SetAuthenticatedSession(w, r, req.Username)
+		if err != nil {
+			return httperr.From(fmt.Errorf("failed to set authenticated session: %w", err))
+		}
+		ro.AuthSvc.RecordLogin(r.Context(), req.Username, true)
+		ro.AuthSvc.SetReadableCSRF(w, r)
+		return api.Write(w, http.StatusOK, sessionInfo(r))
+	}
+}
+
+func (ro *Router) apiLogout() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, err := apiDetails(r)
+		if err != nil {
+			return err
+		}
+		if details.TokenID != 0 {
+			return httperr.BadRequest("Tokens can't log out, revoke the token instead")
+		}
+		if err := ro.AuthSvc.InvalidateSession(r); err != nil {
+			return httperr.Internal(fmt.Errorf("failed to invalidate session: %w", err))
+		}
+		ro.AuthSvc.ClearCookie(w, auth.SessionCookieName)
+		ro.AuthSvc.ClearReadableCSRF(w)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+}
+
+func (ro *Router) apiMe() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, err := apiDetails(r)
+		if err != nil {
+			return err
+		}
+		user := APIUser{
+			Username:     details.Username,
+			Admin:        details.Admin,
+			TokenID:      details.TokenID,
+			Scopes:       []string{}, // feature:authz
+			Organization: orgInfo(r, details),
+		}
+		// feature:authz begin
+		for _, granted := range details.Authz {
+			user.Scopes = append(user.Scopes, granted.Auth)
+		}
+		// feature:authz end
+		return api.Write(w, http.StatusOK, user)
+	}
+}
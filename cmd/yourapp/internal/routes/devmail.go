@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"strconv"
+	"yourapp/cmd/yourapp/internal/templates"
+	"yourapp/foundation/httperr"
+	"yourapp/foundation/mail"
+	"yourapp/foundation/secheaders"
+)
+
+// capturedHTMLPolicy replaces the app's CSP when showing a captured HTML body.
+// Email HTML isn't trusted like the app's own pages, so scripts, forms and frames are blocked, and the page is sandboxed.
+const capturedHTMLPolicy = "default-src 'none'; style-src 'unsafe-inline'; img-src * data:; sandbox"
+
+func (ro *Router) devMailPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return ro.renderComponent(w, r, "Captured Mail", templates.DevMail(ro.CapturedMail.Messages()))
+	}
+}
+
+func (ro *Router) devMailMessage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		msg, err := ro.capturedMessage(r)
+		if err != nil {
+			return err
+		}
+		return ro.renderComponent(w, r, msg.Subject, templates.DevMailMessage(msg))
+	}
+}
+
+func (ro *Router) devMailHTML() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		msg, err := ro.capturedMessage(r)
+		if err != nil {
+			return err
+		}
+		h := w.Header()
+		h.Del(secheaders.HeaderCSPReportOnly)
+		h.Set(secheaders.HeaderCSP, capturedHTMLPolicy)
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		_, err = w.Write([]byte(msg.HTML))
+		return err
+	}
+}
+
+func (ro *Router) devMailClear() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ro.CapturedMail.Clear()
+		return ro.renderComponent(w, r, "Captured Mail", templates.DevMail(nil))
+	}
+}
+
+func (ro *Router) capturedMessage(r *http.Request) (mail.Captured, error) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return mail.Captured{}, httperr.BadRequest("Invalid message ID")
+	}
+	msg, ok := ro.CapturedMail.Message(id)
+	if !ok {
+		return mail.Captured{}, httperr.NotFound("The message does not exist or is no longer kept")
+	}
+	return msg, nil
+}
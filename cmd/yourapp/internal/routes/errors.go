@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"yourapp/cmd/yourapp/internal/templates"
+	"yourapp/foundation/htmx"
+	"yourapp/foundation/httperr"
+	"yourapp/foundation/requestid"
+)
+
+// guardWriter tracks whether a response has been started, and discards anything written after a redirect.
+type guardWriter struct {
+	http.ResponseWriter
+	started    bool
+	redirected bool
+}
+
+func (g *guardWriter) WriteHeader(status int) {
+	if g.started {
+		return
+	}
+	g.started = true
+	h := g.Header()
+	if (status >= 300 && status < 400) || len(h.Get(htmx.HeaderRedirect)) > 0 || len(h.Get(htmx.HeaderLocation)) > 0 {
+		g.redirected = true
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *guardWriter) Write(data []byte) (int, error) {
+	if !g.started {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.redirected {
+		return len(data), nil
+	}
+	return g.ResponseWriter.Write(data)
+}
+
+func (g *guardWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// handle adapts an error returning handler to a [http.HandlerFunc].
+// A returned error is rendered with handleError, unless the handler has already started writing its response.
+func (ro *Router) handle(handler httpx.ErrHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gw := &guardWriter{ResponseWriter: w}
+		if err := handler(gw, r); err != nil {
+			ro.handleError(gw, r, err)
+		}
+	}
+}
+
+// handleAPI is like handle, except that errors are always written as JSON.
+func (ro *Router) handleAPI(handler httpx.ErrHandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gw := &guardWriter{ResponseWriter: w}
+		if err := handler(gw, r); err != nil {
+			ro.writeError(gw, r, err, true)
+		}
+	})
+}
+
+// handleError responds with an error page, or a JSON error body if the client prefers JSON.
+// Server errors are logged with the request ID, and their cause is never shown to the client.
+func (ro *Router) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	ro.writeError(w, r, err, httperr.WantsJSON(r) || isAPIRequest(r))
+}
+
+func (ro *Router) writeError(w http.ResponseWriter, r *http.Request, err error, asJSON bool) {
+	httpErr := httperr.From(err)
+	reqID := requestid.Get(r.Context())
+	if httpErr.Status >= http.StatusInternalServerError {
+		ro.Log.Printf("[ERR] %s %s failed (request %s): %v\n", r.Method, r.URL.Path, reqID, err)
+	}
+	if gw, ok := w.(*guardWriter); ok && gw.started {
+		ro.Log.Printf("[ERR] Response for %s %s already started, unable to report error (request %s): %v\n", r.Method, r.URL.Path, reqID, err)
+		return
+	}
+	if asJSON {
+		httperr.WriteJSON(w, httpErr, reqID)
+		return
+	}
+
+	if htmx.IsFragmentRequest(r) {
+		// The request's target may be a small part of the page, so the error is shown in the content area instead.
+		w.Header().Set(htmx.HeaderRetarget, appContentTarget)
+		w.Header().Set(htmx.HeaderReswap, "innerHTML")
+	}
+	content := templates.Error(httpErr.Status, httpErr.PublicMessage(), reqID)
+	if err := ro.renderPage(w, r, httpErr.Status, http.StatusText(httpErr.Status), content); err != nil {
+		ro.Log.Printf("[ERR] Failed to render error page (request %s): %v\n", reqID, err)
+		http.Error(w, http.StatusText(httpErr.Status), httpErr.Status)
+	}
+}
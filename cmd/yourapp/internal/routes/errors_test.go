@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"errors"
+	"github.com/saylorsolutions/x/httpx"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"yourapp/foundation/htmx"
+	"yourapp/foundation/httperr"
+	"yourapp/foundation/requestid"
+)
+
+func TestRouter_handle(t *testing.T) {
+	ro := &Router{Log: log.Default()}
+	tests := map[string]struct {
+		handler        httpx.ErrHandlerFunc
+		headers        map[string]string
+		path           string
+		expectedStatus int
+		expectedType   string
+		contains       []string
+		notContains    []string
+		check          func(t *testing.T, rec *httptest.ResponseRecorder)
+	}{
+		"Error page": {
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return httperr.Internal(errors.New("db password is hunter2"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedType:   "text/html",
+			contains:       []string{"<html", "500 Internal Server Error", "req-1"},
+			notContains:    []string{"hunter2"},
+		},
+		"JSON client": {
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return httperr.NotFound("No such widget")
+			},
+			headers:        map[string]string{"Accept": "application/json"},
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/json",
+			contains:       []string{`"message":"No such widget"`, `"requestID":"req-1"`},
+		},
+		"API path": {
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return httperr.Unauthorized("Authentication is required")
+			},
+			path:           "/api/v1/me",
+			expectedStatus: http.StatusUnauthorized,
+			expectedType:   "application/json",
+			contains:       []string{`"message":"Authentication is required"`},
+			notContains:    []string{"<html"},
+		},
+		"htmx fragment": {
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return httperr.BadRequest("Name is required")
+			},
+			headers:        map[string]string{htmx.HeaderRequest: "true"},
+			expectedStatus: http.StatusBadRequest,
+			expectedType:   "text/html",
+			contains:       []string{"Name is required"},
+			notContains:    []string{"<html"},
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, appContentTarget, rec.Header().Get(htmx.HeaderRetarget))
+			},
+		},
+		"Nothing after redirect": {
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				ro.Redirect(w, r, "/login", http.StatusFound)
+				_, _ = w.Write([]byte("leaked content"))
+				w.WriteHeader(http.StatusOK)
+				return errors.New("failed after redirect")
+			},
+			expectedStatus: http.StatusFound,
+			notContains:    []string{"leaked content", "Internal Server Error"},
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				assert.Equal(t, "/login", rec.Header().Get("Location"))
+			},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			handler := requestid.Middleware()(ro.handle(tc.handler))
+			path := tc.path
+			if len(path) == 0 {
+				path = "/thing"
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set(requestid.Header, "req-1")
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if len(tc.expectedType) > 0 {
+				assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), tc.expectedType))
+			}
+			body := rec.Body.String()
+			for _, s := range tc.contains {
+				assert.Contains(t, body, s)
+			}
+			for _, s := range tc.notContains {
+				assert.NotContains(t, body, s)
+			}
+			if tc.check != nil {
+				tc.check(t, rec)
+			}
+		})
+	}
+}
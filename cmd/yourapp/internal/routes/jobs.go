@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"strconv"
+	"yourapp/cmd/yourapp/internal/templates"
+	"yourapp/feature/model"
+	"yourapp/foundation/httperr"
+)
+
+// jobListLimit caps each table on the jobs page, since a backed up queue could hold many thousands of jobs.
+const jobListLimit = 100
+
+func (ro *Router) adminJobsPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return ro.renderAdminJobs(w, r, "")
+	}
+}
+
+func (ro *Router) adminRetryJob() httpx.ErrHandlerFunc {
+	return ro.adminJobAction("retry", "Retried", "The job does not exist or is not dead", model.RetryJob)
+}
+
+func (ro *Router) adminDeleteJob() httpx.ErrHandlerFunc {
+	return ro.adminJobAction("delete", "Deleted", "The job does not exist or is running", model.DeleteJob)
+}
+
+// adminJobAction applies an action to the job in the request path, which fails with notFound if no job was changed.
+func (ro *Router) adminJobAction(verb, done, notFound string, action func(context.Context, *sql.DB, uint64) (sql.Result, error)) httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, ok := ro.getDetailsOrRedirect(w, r)
+		if !ok {
+			return nil
+		}
+		jobID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			return httperr.BadRequest("Invalid job ID")
+		}
+		result, err := action(r.Context(), ro.Pool, jobID)
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to %s job: %w", verb, err))
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return httperr.NotFound(notFound)
+		}
+		ro.AuthSvc.AuditEventf(r.Context(), details.Username, "%s job %d", done, jobID)
+		return ro.renderAdminJobs(w, r, fmt.Sprintf("%s job %d.", done, jobID))
+	}
+}
+
+func (ro *Router) renderAdminJobs(w http.ResponseWriter, r *http.Request, notice string) error {
+	var pending []*model.ListJobsResult
+	for _, status := range []string{model.JobRunning, model.JobQueued} {
+		jobs, err := model.ListJobs(r.Context(), ro.Pool, status, jobListLimit)
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to list %s jobs: %w", status, err))
+		}
+		pending = append(pending, jobs...)
+	}
+	dead, err := model.ListJobs(r.Context(), ro.Pool, model.JobDead, jobListLimit)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to list dead jobs: %w", err))
+	}
+	return ro.renderComponent(w, r, "Background Jobs", templates.AdminJobs(pending, dead, notice))
+}
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"strconv"
+	"strings"
+	"yourapp/cmd/yourapp/internal/templates"
+	"yourapp/feature/auth"
+	"yourapp/feature/model"
+	"yourapp/feature/tenant"
+	"yourapp/foundation/httperr"
+)
+
+func (ro *Router) membersPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return ro.renderMembers(w, r, "")
+	}
+}
+
+func (ro *Router) addMember() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, org, err := memberDetails(r)
+		if err != nil {
+			return err
+		}
+		username := strings.TrimSpace(r.FormValue("username"))
+		if len(username) == 0 {
+			return httperr.BadRequest("Username is required")
+		}
+		result, err := model.AddMember(r.Context(), ro.Pool, org.ID, username, r.FormValue("admin") == "true")
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to add member: %w", err))
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return httperr.NotFound("There is no user with that username")
+		}
+		ro.AuthSvc.AuditEventf(r.Context(), details.Username, "Added %s to organization %s", username, org.Slug)
+		return ro.renderMembers(w, r, "Added "+username)
+	}
+}
+
+func (ro *Router) removeMember() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, org, err := memberDetails(r)
+		if err != nil {
+			return err
+		}
+		userID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			return httperr.BadRequest("Invalid user ID")
+		}
+		if userID == details.UserID {
+			return httperr.BadRequest("You can't remove yourself from the organization")
+		}
+		result, err := model.RemoveMember(r.Context(), ro.Pool, org.ID, userID)
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to remove member: %w", err))
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return httperr.NotFound("The user is not a member of the organization")
+		}
+		ro.AuthSvc.AuditEventf(r.Context(), details.Username, "Removed user %d from organization %s", userID, org.Slug)
+		return ro.renderMembers(w, r, "")
+	}
+}
+
+func (ro *Router) renderMembers(w http.ResponseWriter, r *http.Request, notice string) error {
+	details, org, err := memberDetails(r)
+	if err != nil {
+		return err
+	}
+	members, err := model.GetMembers(r.Context(), ro.Pool, org.ID)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to get members: %w", err))
+	}
+	return ro.renderComponent(w, r, "Members", templates.Members(org, members, details.TenantAdmin, notice))
+}
+
+// memberDetails returns the details of a member of the request's organization, which [Router.requireTenant] ensures.
+func memberDetails(r *http.Request) (auth.Details, tenant.Org, error) {
+	details, ok := auth.GetSessionUser(r)
+	org, found := tenant.Get(r.Context())
+	if !ok || !found || details.TenantID != org.ID {
+		return auth.Details{}, tenant.Org{}, httperr.Internal(errors.New("missing organization membership"))
+	}
+	return details, org, nil
+}
@@ -2,21 +2,39 @@ package routes
 
 import (
 	"database/sql"
-	"embed"
+	"errors"
+	"fmt"
 	"github.com/saylorsolutions/x/httpx"
 	"log"
 	"net/http"
 	"yourapp/cmd/yourapp/internal/templates"
+	"yourapp/feature/audit"
 	"yourapp/feature/auth"
-	"yourapp/feature/model"
-	"yourapp/foundation/urlprefix"
+	"yourapp/feature/signup"
+	"yourapp/feature/tenant"
+	"yourapp/foundation/assets"
+	"yourapp/foundation/cors"
+	"yourapp/foundation/health"
+	"yourapp/foundation/htmx"
+	"yourapp/foundation/httperr"
+	"yourapp/foundation/mail"
+	"yourapp/foundation/secheaders"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
 )
 
 type Router struct {
-	Log          *log.Logger
-	AuthSvc      *auth.Service
-	Pool         *sql.DB
-	StaticAssets embed.FS
+	Log         *log.Logger
+	LogDelegate audit.LogDelegate
+	AuthSvc     *auth.Service
+	Signup      *signup.Service
+	Tenants     *tenant.Resolver
+	Pool        *sql.DB
+	Assets      *assets.Set
+	Health      *health.Probe
+	Build       health.BuildInfo
+	CORS        cors.Policy
+	// CapturedMail enables the captured mail pages under /dev/mail when set.
+	CapturedMail *mail.CaptureMailer
 	mux          *http.ServeMux
 }
 
@@ -24,101 +42,168 @@ func (ro *Router) ServeMux() *http.ServeMux {
 	mux := http.NewServeMux()
 	requireSession := ro.AuthSvc.RequireSession()
 	requireAdmin := ro.requireAdmin()
+	requireTenant := ro.requireTenant()
+	requireTenantAdmin := ro.requireTenantAdmin()
 	setCSRF := ro.AuthSvc.SetCSRF()
 	requireCSRF := ro.AuthSvc.RequireCSRF()
-	staticHandler := httpx.EmbeddedHandler(ro.StaticAssets, "", "")
+	ro.AuthSvc.SetCSRFFailureHandler(ro.handle(ro.csrfFailure()))
+	ro.AuthSvc.SetErrorHandler(ro.handleError)
+	ro.Tenants.SetErrorHandler(ro.handleError)
+	templates.UseAssets(ro.Assets)
+	staticHandler := http.StripPrefix("/static/", ro.Assets.Handler())
 	mux.HandleFunc("/blank", func(w http.ResponseWriter, r *http.Request) {})
 	mux.Handle("GET /static/", staticHandler)
-	mux.Handle("GET /{$}", requireSession(ro.homePage()))
-	mux.Handle("GET /unauthorized", requireSession(ro.unauthorized()))
-	mux.Handle("GET /pool", requireAdmin(ro.poolStats()))
-	mux.Handle("GET /login", setCSRF(ro.loginPage()))
-	mux.Handle("POST /login", requireCSRF(ro.loginHandling()))
-	mux.Handle("/logout", requireSession(ro.logoutHandling()))
+	mux.Handle("POST /csp-report", ro.cspReport())
+	mux.Handle("GET /healthz", ro.Health.Liveness())
+	mux.Handle("GET /readyz", ro.Health.Readiness())
+	mux.Handle("GET /version", health.VersionHandler(ro.Build))
+	mux.Handle("GET /{$}", requireSession(ro.handle(ro.homePage())))
+	mux.Handle("GET /unauthorized", requireSession(ro.handle(ro.unauthorized())))
+	mux.Handle("GET /pool", requireAdmin(ro.handle(ro.poolStats()))) // feature:pool-stats
+	mux.Handle("GET /login", setCSRF(ro.handle(ro.loginPage())))
+	mux.Handle("POST /login", requireCSRF(ro.handle(ro.loginHandling())))
+	mux.Handle("/logout", requireSession(ro.handle(ro.logoutHandling())))
+	mux.Handle("GET /signup", setCSRF(ro.handle(ro.signupPage())))
+	mux.Handle("POST /signup", requireCSRF(ro.handle(ro.signupHandling())))
+	mux.Handle("GET /signup/verify", setCSRF(ro.handle(ro.verifySignupPage())))
+	mux.Handle("POST /signup/verify", requireCSRF(ro.handle(ro.verifySignup())))
+	mux.Handle("GET /invite", setCSRF(ro.handle(ro.invitePage())))
+	mux.Handle("POST /invite", requireCSRF(ro.handle(ro.inviteHandling())))
+	mux.Handle("GET /tokens", requireSession(ro.handle(ro.tokensPage())))
+	mux.Handle("POST /tokens", requireSession(requireCSRF(ro.handle(ro.createToken()))))
+	mux.Handle("POST /tokens/{id}/revoke", requireSession(requireCSRF(ro.handle(ro.revokeToken()))))
+	mux.Handle("GET /members", requireTenant(ro.handle(ro.membersPage())))
+	mux.Handle("POST /members", requireTenantAdmin(requireCSRF(ro.handle(ro.addMember()))))
+	mux.Handle("POST /members/{id}/remove", requireTenantAdmin(requireCSRF(ro.handle(ro.removeMember()))))
+	mux.Handle("GET /admin/tokens", requireAdmin(ro.handle(ro.adminTokensPage())))
+	mux.Handle("POST /admin/tokens", requireAdmin(requireCSRF(ro.handle(ro.adminCreateToken()))))
+	mux.Handle("POST /admin/tokens/{id}/revoke", requireAdmin(requireCSRF(ro.handle(ro.adminRevokeToken()))))
+	mux.Handle("POST /admin/service-accounts", requireAdmin(requireCSRF(ro.handle(ro.createServiceAccount()))))
+	mux.Handle("GET /admin/invitations", requireAdmin(ro.handle(ro.adminInvitationsPage())))
+	mux.Handle("POST /admin/invitations", requireAdmin(requireCSRF(ro.handle(ro.adminInvite()))))
+	mux.Handle("POST /admin/invitations/{id}/revoke", requireAdmin(requireCSRF(ro.handle(ro.adminRevokeInvitation()))))
+	mux.Handle("GET /admin/jobs", requireAdmin(ro.handle(ro.adminJobsPage())))
+	mux.Handle("POST /admin/jobs/{id}/retry", requireAdmin(requireCSRF(ro.handle(ro.adminRetryJob()))))
+	mux.Handle("POST /admin/jobs/{id}/delete", requireAdmin(requireCSRF(ro.handle(ro.adminDeleteJob()))))
+	if ro.CapturedMail != nil {
+		mux.Handle("GET /dev/mail", requireAdmin(ro.handle(ro.devMailPage())))
+		mux.Handle("POST /dev/mail/clear", requireAdmin(requireCSRF(ro.handle(ro.devMailClear()))))
+		mux.Handle("GET /dev/mail/{id}", requireAdmin(ro.handle(ro.devMailMessage())))
+		mux.Handle("GET /dev/mail/{id}/html", requireAdmin(ro.handle(ro.devMailHTML())))
+	}
+	ro.APIv1().Mount(mux, ro.handleAPI, ro.CORS.Middleware(), ro.AuthSvc.APIAuth(), setCSRF, ro.AuthSvc.ExposeCSRF())
+	mux.Handle("/", ro.handle(ro.notFound()))
 	return mux
 }
 
-func (ro *Router) homePage() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func (ro *Router) homePage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
 		details, ok := ro.getDetailsOrRedirect(w, r)
 		if !ok {
-			return
+			return nil
 		}
 		ro.Log.Println("Found auth details in request, username:", details.Username)
-		ro.renderComponent(w, r, templates.Frame("", details.Username))
+		return ro.renderComponent(w, r, "", templates.Home())
 	}
 }
 
-func (ro *Router) loginPage() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func (ro *Router) loginPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
 		csrfVal, ok := auth.GetCSRF(r)
 		if !ok {
-			http.Error(w, "Missing CSRF token", 500)
-			return
+			return httperr.Internal(errors.New("missing CSRF token for login page"))
 		}
-		ro.renderComponent(w, r, templates.LoginPage(csrfVal))
+		return ro.renderFullPage(w, r, http.StatusOK, templates.LoginPage(csrfVal, ro.Signup.Enabled))
 	}
 }
 
-func (ro *Router) loginHandling() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func (ro *Router) loginHandling() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
 		username := r.FormValue("username")
 		password := r.FormValue("password")
-		result, err := model.CheckPassword(r.Context(), ro.Pool, username, password)
+		matches, err := ro.AuthSvc.CheckPassword(r.Context(), username, password)
 		if err != nil {
-			ro.Log.Println("Failed to check password:", err)
-			w.WriteHeader(500)
-			return
+			return httperr.Internal(fmt.Errorf("failed to check password: %w", err))
 		}
-		if !result.Matches {
-			ro.AuthSvc.AuditEvent(r.Context(), username, "failed authentication")
+		if !matches {
+			ro.AuthSvc.RecordLogin(r.Context(), username, false)
 			ro.Redirect(w, r, "/login", http.StatusFound)
-			return
+			return nil
 		}
 		if _, err = ro.AuthSvc.SetAuthenticatedSession(w, r, username); err != nil {
-			ro.Log.Println("Failed to set authenticated auth:", err)
-			w.WriteHeader(500)
-			return
+			return httperr.From(fmt.Errorf("failed to set authenticated session: %w", err))
 		}
-		ro.AuthSvc.AuditEvent(r.Context(), username, "Authenticated")
+		ro.AuthSvc.RecordLogin(r.Context(), username, true)
 		ro.Redirect(w, r, "/", http.StatusFound)
-		return
+		return nil
 	}
 }
 
-func (ro *Router) logoutHandling() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		details, ok := auth.GetSessionUser(r)
-		if !ok {
+func (ro *Router) logoutHandling() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if _, ok := auth.GetSessionUser(r); !ok {
 			ro.Redirect(w, r, "/login", http.StatusFound)
-			return
+			return nil
 		}
 
 		ro.AuthSvc.ClearCookie(w, auth.SessionCookieName)
-		if _, err := model.InvalidateSession(r.Context(), ro.Pool, details.SessionKey); err != nil {
+		ro.AuthSvc.ClearReadableCSRF(w)
+		if err := ro.AuthSvc.InvalidateSession(r); err != nil {
 			ro.Log.Println("[ERR] Failed to invalidate auth:", err)
 		}
 		ro.Redirect(w, r, "/login", http.StatusFound)
+		return nil
 	}
 }
 
-func (ro *Router) poolStats() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		details, ok := ro.getDetailsOrRedirect(w, r)
-		if !ok {
-			return
-		}
+// feature:pool-stats begin
+
+func (ro *Router) poolStats() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
 		stats := ro.Pool.Stats()
-		ro.renderComponent(w, r, templates.StatsPage(details.Username, stats))
+		return ro.renderComponent(w, r, "DB stats", templates.Stats(stats))
+	}
+}
+
+// feature:pool-stats end
+
+func (ro *Router) csrfFailure() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if httperr.WantsJSON(r) || isAPIRequest(r) {
+			return httperr.Forbidden("Invalid CSRF token")
+		}
+		return ro.renderFullPage(w, r, http.StatusForbidden, templates.CSRFFailurePage(r.URL.Path))
+	}
+}
+
+func (ro *Router) unauthorized() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return ro.renderPage(w, r, http.StatusForbidden, "Unauthorized", templates.Unauthorized())
 	}
 }
 
-func (ro *Router) unauthorized() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ro.renderComponent(w, r, templates.UnauthorizedPage())
+func (ro *Router) notFound() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return httperr.NotFound("The page you requested does not exist")
 	}
 }
 
+func (ro *Router) cspReport() http.Handler {
+	return secheaders.ReportHandler(func(r *http.Request, report secheaders.Report) {
+		ro.LogDelegate.Warn("CSP violation on %s: %s blocked %s (%s:%d) %s",
+			report.DocumentURL, report.EffectiveDirective, report.BlockedURL, report.SourceFile, report.LineNumber, report.Sample)
+	})
+}
+
+// Redirect redirects the client to the prefixed location, within the request's organization if there is one.
+// htmx requests are redirected with HX-Redirect, so the browser loads the full page rather than swapping it into the request's target.
 func (ro *Router) Redirect(w http.ResponseWriter, r *http.Request, location string, status int) {
-	http.Redirect(w, r, urlprefix.Apply(location), status)
+	htmx.Redirect(w, r, urlprefix.Apply(tenant.Path(r.Context(), location)), status) // feature:urlprefix
+	// feature:!urlprefix: htmx.Redirect(w, r, tenant.Path(r.Context(), location), status)
+}
+
+// Relocate is like Redirect, except that htmx requests swap the location into the app content area instead of loading a full page.
+func (ro *Router) Relocate(w http.ResponseWriter, r *http.Request, location string, status int) {
+	htmx.Location(w, r, urlprefix.Apply(tenant.Path(r.Context(), location)), appContentTarget, status) // feature:urlprefix
+	// feature:!urlprefix: htmx.Location(w, r, tenant.Path(r.Context(), location), appContentTarget, status)
 }
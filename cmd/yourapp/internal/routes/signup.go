@@ -0,0 +1,234 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"strconv"
+	"strings"
+	"yourapp/cmd/yourapp/internal/templates"
+	"yourapp/feature/auth"
+	"yourapp/feature/model"
+	"yourapp/feature/signup"
+	"yourapp/foundation/httperr"
+)
+
+func (ro *Router) signupPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !ro.Signup.Enabled {
+			return httperr.NotFound("The page you requested does not exist")
+		}
+		csrfVal, ok := auth.GetCSRF(r)
+		if !ok {
+			return httperr.Internal(errors.New("missing CSRF token for signup page"))
+		}
+		return ro.renderFullPage(w, r, http.StatusOK, templates.SignupPage(templates.SignupForm{CSRF: csrfVal}))
+	}
+}
+
+func (ro *Router) signupHandling() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !ro.Signup.Enabled {
+			return httperr.NotFound("The page you requested does not exist")
+		}
+		csrfVal, _ := auth.GetCSRF(r)
+		reg := signup.Registration{
+			Username: strings.TrimSpace(r.FormValue("username")),
+			Email:    strings.TrimSpace(r.FormValue("email")),
+			Password: r.FormValue("password"),
+		}
+		err := ro.Signup.Register(r.Context(), reg)
+		if msg, ok := signupProblem(err); ok {
+			form := templates.SignupForm{CSRF: csrfVal, Username: reg.Username, Email: reg.Email, Error: msg}
+			return ro.renderFullPage(w, r, http.StatusBadRequest, templates.SignupPage(form))
+		}
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to register: %w", err))
+		}
+		return ro.renderFullPage(w, r, http.StatusOK, templates.SignupMessage("Check Your Email",
+			"We sent a link to "+reg.Email+". Open it within 24 hours to activate your account.", false))
+	}
+}
+
+// verifySignupPage asks for confirmation before activating the account, since mail scanners may follow the link.
+func (ro *Router) verifySignupPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		token := r.URL.Query().Get("token")
+		if msg, ok := signupProblem(ro.Signup.CheckVerification(token)); ok {
+			return ro.renderVerifyFailed(w, r, msg)
+		}
+		csrfVal, ok := auth.GetCSRF(r)
+		if !ok {
+			return httperr.Internal(errors.New("missing CSRF token for verification page"))
+		}
+		return ro.renderFullPage(w, r, http.StatusOK, templates.VerifyPage(csrfVal, token))
+	}
+}
+
+func (ro *Router) verifySignup() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		_, err := ro.Signup.Verify(r.Context(), r.FormValue("token"))
+		if msg, ok := signupProblem(err); ok {
+			return ro.renderVerifyFailed(w, r, msg)
+		}
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to verify signup: %w", err))
+		}
+		return ro.renderFullPage(w, r, http.StatusOK, templates.SignupMessage("Account Activated",
+			"Your email address is verified, and your account is ready.", true))
+	}
+}
+
+func (ro *Router) renderVerifyFailed(w http.ResponseWriter, r *http.Request, problem string) error {
+	return ro.renderFullPage(w, r, http.StatusBadRequest, templates.SignupMessage("Verification Failed",
+		problem+". You can sign up again to get a new link.", false))
+}
+
+func (ro *Router) invitePage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return ro.renderInvite(w, r, r.URL.Query().Get("token"), "", "")
+	}
+}
+
+func (ro *Router) inviteHandling() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		token := r.FormValue("token")
+		username := strings.TrimSpace(r.FormValue("username"))
+		_, err := ro.Signup.Accept(r.Context(), token, username, r.FormValue("password"))
+		if msg, ok := signupProblem(err); ok {
+			return ro.renderInvite(w, r, token, username, msg)
+		}
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to accept invitation: %w", err))
+		}
+		return ro.renderFullPage(w, r, http.StatusOK, templates.SignupMessage("Account Created",
+			"Your account is ready.", true))
+	}
+}
+
+// renderInvite shows the form for accepting an invitation, or a message if the invitation link is no longer valid.
+func (ro *Router) renderInvite(w http.ResponseWriter, r *http.Request, token, username, problem string) error {
+	invitation, err := ro.Signup.Invitation(r.Context(), token)
+	if msg, ok := signupProblem(err); ok {
+		return ro.renderFullPage(w, r, http.StatusBadRequest, templates.SignupMessage("Invitation Unavailable",
+			msg+". Ask whoever invited you to send a new invitation.", false))
+	}
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to get invitation: %w", err))
+	}
+	csrfVal, ok := auth.GetCSRF(r)
+	if !ok {
+		return httperr.Internal(errors.New("missing CSRF token for invitation page"))
+	}
+	status := http.StatusOK
+	if len(problem) > 0 {
+		status = http.StatusBadRequest
+	}
+	form := templates.InviteForm{
+		CSRF:       csrfVal,
+		Token:      token,
+		Invitation: invitation,
+		Username:   username,
+		Error:      problem,
+	}
+	return ro.renderFullPage(w, r, status, templates.InvitePage(form))
+}
+
+// signupProblem returns a message for errors that the person signing up can fix.
+func signupProblem(err error) (string, bool) {
+	for _, problem := range []error{
+		signup.ErrInvalidUser,
+		signup.ErrInvalidEmail,
+		signup.ErrWeakPassword,
+		signup.ErrUsernameTaken,
+		signup.ErrInvalidLink,
+		signup.ErrExpiredLink,
+	} {
+		if errors.Is(err, problem) {
+			msg := problem.Error()
+			return strings.ToUpper(msg[:1]) + msg[1:], true
+		}
+	}
+	return "", false
+}
+
+func (ro *Router) adminInvitationsPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return ro.renderAdminInvitations(w, r, "")
+	}
+}
+
+func (ro *Router) adminInvite() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, ok := ro.getDetailsOrRedirect(w, r)
+		if !ok {
+			return nil
+		}
+		if err := r.ParseForm(); err != nil {
+			return httperr.BadRequest("Invalid form")
+		}
+		params := model.CreateInvitationParams{
+			Email:     strings.TrimSpace(r.FormValue("email")),
+			InvitedBy: details.UserID,
+			Admin:     r.FormValue("admin") == "true",
+		}
+		// feature:authz begin
+		for _, val := range r.Form["auth"] {
+			authID, err := strconv.ParseUint(val, 10, 64)
+			if err != nil {
+				return httperr.BadRequest("Invalid authorization")
+			}
+			params.AuthIDs = append(params.AuthIDs, authID)
+		}
+		// feature:authz end
+		_, err := ro.Signup.Invite(r.Context(), details.Username, params)
+		switch {
+		case err == nil:
+			return ro.renderAdminInvitations(w, r, "Sent an invitation to "+params.Email)
+		case errors.Is(err, signup.ErrInvalidEmail):
+			return httperr.BadRequest("Invalid email address")
+		case errors.Is(err, signup.ErrEmailTaken):
+			return httperr.Wrap(http.StatusConflict, "That email address already has an account", err)
+		default:
+			return httperr.Internal(fmt.Errorf("failed to send invitation: %w", err))
+		}
+	}
+}
+
+func (ro *Router) adminRevokeInvitation() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, ok := ro.getDetailsOrRedirect(w, r)
+		if !ok {
+			return nil
+		}
+		invitationID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			return httperr.BadRequest("Invalid invitation ID")
+		}
+		result, err := model.RevokeInvitation(r.Context(), ro.Pool, invitationID)
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to revoke invitation: %w", err))
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return httperr.NotFound("The invitation does not exist or is already closed")
+		}
+		ro.AuthSvc.AuditEventf(r.Context(), details.Username, "Revoked invitation %d", invitationID)
+		return ro.renderAdminInvitations(w, r, "")
+	}
+}
+
+func (ro *Router) renderAdminInvitations(w http.ResponseWriter, r *http.Request, notice string) error {
+	invitations, err := model.ListInvitations(r.Context(), ro.Pool)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to list invitations: %w", err))
+	}
+	// feature:authz begin
+	auths, err := model.GetAuthorizations(r.Context(), ro.Pool)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to get authorizations: %w", err))
+	}
+	return ro.renderComponent(w, r, "Invitations", templates.AdminInvitations(invitations, auths, notice))
+	// feature:authz end
+	// feature:!authz: return ro.renderComponent(w, r, "Invitations", templates.AdminInvitations(invitations, notice))
+}
@@ -0,0 +1,218 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"yourapp/cmd/yourapp/internal/templates"
+	"yourapp/feature/auth"
+	"yourapp/feature/model"
+	"yourapp/foundation/httperr"
+)
+
+func (ro *Router) tokensPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return ro.renderTokens(w, r, nil)
+	}
+}
+
+func (ro *Router) createToken() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, ok := ro.getDetailsOrRedirect(w, r)
+		if !ok {
+			return nil
+		}
+		req, err := tokenRequest(r, details.Username)
+		if err != nil {
+			return err
+		}
+		req.Scopes = r.Form["scope"] // feature:authz
+		minted, err := ro.mintToken(r, details.Username, req)
+		if err != nil {
+			return err
+		}
+		return ro.renderTokens(w, r, minted)
+	}
+}
+
+func (ro *Router) revokeToken() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, ok := ro.getDetailsOrRedirect(w, r)
+		if !ok {
+			return nil
+		}
+		if err := ro.revoke(r, details, details.UserID); err != nil {
+			return err
+		}
+		return ro.renderTokens(w, r, nil)
+	}
+}
+
+func (ro *Router) renderTokens(w http.ResponseWriter, r *http.Request, minted *auth.MintedToken) error {
+	details, ok := ro.getDetailsOrRedirect(w, r)
+	if !ok {
+		return nil
+	}
+	tokens, err := model.ListTokens(r.Context(), ro.Pool, details.UserID)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to list tokens: %w", err))
+	}
+	form := templates.TokenForm{
+		Action:     "/tokens",
+		Scopes:     details.Authz, // feature:authz
+		AllowAdmin: details.Admin,
+	}
+	return ro.renderComponent(w, r, "API Tokens", templates.Tokens(tokens, form, minted, details.Admin))
+}
+
+func (ro *Router) adminTokensPage() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return ro.renderAdminTokens(w, r, nil)
+	}
+}
+
+func (ro *Router) adminCreateToken() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, ok := ro.getDetailsOrRedirect(w, r)
+		if !ok {
+			return nil
+		}
+		req, err := tokenRequest(r, r.FormValue("username"))
+		if err != nil {
+			return err
+		}
+		accounts, err := model.GetServiceAccounts(r.Context(), ro.Pool)
+		if err != nil {
+			return httperr.Internal(fmt.Errorf("failed to get service accounts: %w", err))
+		}
+		var found bool
+		for _, account := range accounts {
+			if account.Username == req.Username {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return httperr.BadRequest("Tokens can only be minted for service accounts here")
+		}
+		// feature:authz begin
+		for _, scope := range strings.Split(r.FormValue("scopes"), ",") {
+			if scope = strings.TrimSpace(scope); len(scope) > 0 {
+				req.Scopes = append(req.Scopes, scope)
+			}
+		}
+		// feature:authz end
+		minted, err := ro.mintToken(r, details.Username, req)
+		if err != nil {
+			return err
+		}
+		return ro.renderAdminTokens(w, r, minted)
+	}
+}
+
+func (ro *Router) adminRevokeToken() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, ok := ro.getDetailsOrRedirect(w, r)
+		if !ok {
+			return nil
+		}
+		if err := ro.revoke(r, details, 0); err != nil {
+			return err
+		}
+		return ro.renderAdminTokens(w, r, nil)
+	}
+}
+
+func (ro *Router) createServiceAccount() httpx.ErrHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		details, ok := ro.getDetailsOrRedirect(w, r)
+		if !ok {
+			return nil
+		}
+		username := strings.TrimSpace(r.FormValue("username"))
+		if len(username) == 0 {
+			return httperr.BadRequest("A username is required")
+		}
+		if _, err := model.CreateServiceAccount(r.Context(), ro.Pool, username); err != nil {
+			return httperr.Internal(fmt.Errorf("failed to create service account: %w", err))
+		}
+		ro.AuthSvc.AuditEventf(r.Context(), details.Username, "Created service account '%s'", username)
+		return ro.renderAdminTokens(w, r, nil)
+	}
+}
+
+func (ro *Router) renderAdminTokens(w http.ResponseWriter, r *http.Request, minted *auth.MintedToken) error {
+	tokens, err := model.ListTokens(r.Context(), ro.Pool, 0)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to list tokens: %w", err))
+	}
+	accounts, err := model.GetServiceAccounts(r.Context(), ro.Pool)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to get service accounts: %w", err))
+	}
+	form := templates.TokenForm{
+		Action:     "/admin/tokens",
+		Accounts:   accounts,
+		AllowAdmin: true,
+	}
+	return ro.renderComponent(w, r, "All API Tokens", templates.AdminTokens(tokens, form, minted))
+}
+
+// tokenRequest reads the fields shared by the user and admin token forms.
+func tokenRequest(r *http.Request, username string) (auth.TokenRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return auth.TokenRequest{}, httperr.BadRequest("Invalid form")
+	}
+	req := auth.TokenRequest{
+		Username: username,
+		Name:     strings.TrimSpace(r.FormValue("name")),
+		Admin:    r.FormValue("admin") == "true",
+	}
+	if days := r.FormValue("ttl_days"); len(days) > 0 {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return auth.TokenRequest{}, httperr.BadRequest("Invalid expiry")
+		}
+		req.TTL = time.Duration(n) * 24 * time.Hour
+	}
+	return req, nil
+}
+
+func (ro *Router) mintToken(r *http.Request, by string, req auth.TokenRequest) (*auth.MintedToken, error) {
+	minted, err := ro.AuthSvc.MintToken(r.Context(), by, req)
+	switch {
+	case err == nil:
+		return minted, nil
+	case errors.Is(err, auth.ErrTokenName):
+		return nil, httperr.BadRequest("A token name is required")
+	// feature:authz begin
+	case errors.Is(err, auth.ErrScopeNotGranted):
+		return nil, httperr.Wrap(http.StatusBadRequest, "Tokens can only be scoped to granted authorizations", err)
+	// feature:authz end
+	case errors.Is(err, auth.ErrAdminNotGranted):
+		return nil, httperr.Wrap(http.StatusForbidden, "Only admins can create admin tokens", err)
+	default:
+		return nil, httperr.Internal(fmt.Errorf("failed to mint token: %w", err))
+	}
+}
+
+// revoke revokes the token in the request path, which must belong to the given user unless userID is 0.
+func (ro *Router) revoke(r *http.Request, details auth.Details, userID uint64) error {
+	tokenID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return httperr.BadRequest("Invalid token ID")
+	}
+	result, err := model.RevokeToken(r.Context(), ro.Pool, tokenID, userID)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to revoke token: %w", err))
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return httperr.NotFound("The token does not exist or was already revoked")
+	}
+	ro.AuthSvc.AuditEventf(r.Context(), details.Username, "Revoked token %d", tokenID)
+	return nil
+}
@@ -2,11 +2,18 @@ package routes
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"github.com/a-h/templ"
 	"github.com/saylorsolutions/x/httpx"
 	"io"
 	"net/http"
+	"yourapp/cmd/yourapp/internal/templates"
 	"yourapp/feature/auth"
+	"yourapp/feature/model"
+	"yourapp/feature/tenant"
+	"yourapp/foundation/htmx"
+	"yourapp/foundation/httperr"
 )
 
 func (ro *Router) fallbackHandler() http.HandlerFunc {
@@ -15,13 +22,39 @@ func (ro *Router) fallbackHandler() http.HandlerFunc {
 	}
 }
 
-func (ro *Router) renderComponent(w http.ResponseWriter, r *http.Request, comp templ.Component) {
+const appContentTarget = "#app-content"
+
+// renderComponent renders page content within the app Frame.
+// Fragment requests from htmx get just the content, since it will be swapped into an element that is already on the page.
+func (ro *Router) renderComponent(w http.ResponseWriter, r *http.Request, title string, content templ.Component) error {
+	return ro.renderPage(w, r, http.StatusOK, title, content)
+}
+
+// renderPage is like renderComponent, but responds with the given status.
+func (ro *Router) renderPage(w http.ResponseWriter, r *http.Request, status int, title string, content templ.Component) error {
+	w.Header().Add("Vary", htmx.HeaderRequest)
+	if htmx.IsFragmentRequest(r) {
+		return ro.renderFullPage(w, r, status, content)
+	}
+	var username string
+	if details, ok := auth.GetSessionUser(r); ok {
+		username = details.Username
+	}
+	ctx := templ.WithChildren(r.Context(), content)
+	return ro.renderFullPage(w, r.WithContext(ctx), status, templates.Frame(title, username))
+}
+
+// renderFullPage renders a component as-is, regardless of the type of request.
+// The component is rendered fully before anything is written, so a failure can still be reported to the client.
+func (ro *Router) renderFullPage(w http.ResponseWriter, r *http.Request, status int, comp templ.Component) error {
 	var buf bytes.Buffer
 	if err := comp.Render(r.Context(), &buf); err != nil {
-		ro.Log.Println("Failed to render component for path:", r.URL.Path)
-		http.Error(w, err.Error(), 500)
+		return httperr.Internal(fmt.Errorf("failed to render component for path %s: %w", r.URL.Path, err))
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
 	_, _ = io.Copy(w, &buf)
+	return nil
 }
 
 func (ro *Router) getDetailsOrRedirect(w http.ResponseWriter, r *http.Request) (auth.Details, bool) {
@@ -43,13 +76,56 @@ func (ro *Router) requireAdmin() httpx.Middleware {
 			}
 			if !details.Admin {
 				ro.AuthSvc.AuditEventf(r.Context(), details.Username, "Attempted to access %s %s, not admin", r.Method, r.URL.Path)
-				ro.Redirect(w, r, "/unauthorized", http.StatusFound)
+				ro.Relocate(w, r, "/unauthorized", http.StatusFound)
+				return
 			}
 			next.ServeHTTP(w, r)
 		}))
 	}
 }
 
+// requireTenant requires a session for a member of the request's organization, which the session middleware already ensures.
+// Requests that aren't for an organization get a 404, since the routes behind it only exist within one.
+// The request's context is scoped to the organization with [model.WithTenant], so row level security limits its queries to the organization's rows.
+// Other routes aren't scoped, since they're the same in every organization.
+func (ro *Router) requireTenant() httpx.Middleware {
+	return ro.requireMembership(false)
+}
+
+// requireTenantAdmin is like requireTenant, but the member must also be an admin of the organization.
+func (ro *Router) requireTenantAdmin() httpx.Middleware {
+	return ro.requireMembership(true)
+}
+
+func (ro *Router) requireMembership(admin bool) httpx.Middleware {
+	return func(next http.Handler) http.Handler {
+		session := ro.AuthSvc.RequireSession()
+		return session(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			details, ok := ro.getDetailsOrRedirect(w, r)
+			if !ok {
+				return
+			}
+			org, ok := tenant.Get(r.Context())
+			if !ok {
+				ro.handleError(w, r, httperr.NotFound("The page you requested does not exist"))
+				return
+			}
+			if details.TenantID != org.ID {
+				ro.handleError(w, r, httperr.Internal(errors.New("missing organization membership")))
+				return
+			}
+			if admin && !details.TenantAdmin {
+				ro.AuthSvc.AuditEventf(r.Context(), details.Username, "Attempted to access %s %s, not admin of %s", r.Method, r.URL.Path, org.Slug)
+				ro.Relocate(w, r, "/unauthorized", http.StatusFound)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(model.WithTenant(r.Context(), org.ID)))
+		}))
+	}
+}
+
+// feature:authz begin
+
 func (ro *Router) requireAuth(auth string) httpx.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -59,9 +135,12 @@ func (ro *Router) requireAuth(auth string) httpx.Middleware {
 			}
 			if !details.HasAuth(auth) {
 				ro.AuthSvc.AuditEventf(r.Context(), details.Username, "Attempted to access %s %s, not granted auth %s", r.Method, r.URL.Path, auth)
-				ro.Redirect(w, r, "/unauthorized", http.StatusFound)
+				ro.Relocate(w, r, "/unauthorized", http.StatusFound)
+				return
 			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// feature:authz end
@@ -0,0 +1,110 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/a-h/templ"
+	"net/http"
+	"yourapp/feature/auth"
+	"yourapp/feature/tenant"
+	"yourapp/foundation/assets"
+	"yourapp/foundation/secheaders"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
+)
+
+var (
+	csrfFormKey = auth.CSRFFormKey
+	assetSet    *assets.Set
+	// liveReloadPath is where pages listen for the dev server restarting, if it's set.
+	liveReloadPath string
+)
+
+// UseAssets sets the asset set used to resolve logical asset names to fingerprinted URLs.
+func UseAssets(set *assets.Set) {
+	assetSet = set
+}
+
+// UseLiveReload makes every page reload when the server at path says it has restarted, which only dev builds do.
+func UseLiveReload(path string) {
+	liveReloadPath = path
+}
+
+// asset resolves a logical asset name, like "main.css", to its fingerprinted URL under /static.
+// Assets are shared by every organization, so their URLs aren't within the request's organization.
+func asset(logical string) string {
+	path := "/static/" + logical
+	if assetSet != nil {
+		path = "/static/" + assetSet.Path(logical)
+	}
+	return urlprefix.Apply(path) // feature:urlprefix
+	// feature:!urlprefix: return path
+}
+
+// liveReloadURL applies the URL prefix to liveReloadPath.
+// Like assets, it's served outside of the organization's routes, so it isn't within the request's organization.
+func liveReloadURL() string {
+	return urlprefix.Apply(liveReloadPath) // feature:urlprefix
+	// feature:!urlprefix: return liveReloadPath
+}
+
+func sprintf(format string, args ...any) string {
+	return fmt.Sprintf(format, args...)
+}
+
+// yesNo shows a flag in a table, like the pages generated by cmd/scaffold do.
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+// prefix applies the URL prefix to an app path, keeping it within the request's organization, see [tenant.Path].
+func prefix(ctx context.Context, url string) templ.SafeURL {
+	return templ.SafeURL(prefixString(ctx, url))
+}
+
+func prefixString(ctx context.Context, url string) string {
+	return urlprefix.Apply(tenant.Path(ctx, url)) // feature:urlprefix
+	// feature:!urlprefix: return tenant.Path(ctx, url)
+}
+
+// csrfHeaders returns an hx-headers value that includes the request's CSRF token in all htmx requests.
+func csrfHeaders(ctx context.Context) string {
+	token, ok := auth.GetCSRFCtx(ctx)
+	if !ok {
+		return "{}"
+	}
+	headers, err := json.Marshal(map[string]string{auth.CSRFHeaderKey: token})
+	if err != nil {
+		return "{}"
+	}
+	return string(headers)
+}
+
+// cspNonce returns the request's CSP nonce, which must be set on inline scripts.
+func cspNonce(ctx context.Context) string {
+	return secheaders.Nonce(ctx)
+}
+
+// htmxConfig returns the htmx-config meta value, which allows inline scripts in swapped content to run under the CSP.
+func htmxConfig(ctx context.Context) string {
+	config, err := json.Marshal(map[string]any{
+		"inlineScriptNonce": secheaders.Nonce(ctx),
+		// Error responses are swapped too, so themed error pages are shown instead of being silently dropped.
+		"responseHandling": []map[string]any{
+			{"code": "204", "swap": false},
+			{"code": "[23]..", "swap": true},
+			{"code": "[45]..", "swap": true, "error": true},
+		},
+	})
+	if err != nil {
+		return "{}"
+	}
+	return string(config)
+}
+
+func statusText(status int) string {
+	return http.StatusText(status)
+}
@@ -5,6 +5,7 @@ import (
 	"embed"
 	"errors"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/saylorsolutions/x/env"
 	"github.com/saylorsolutions/x/httpx"
 	"github.com/saylorsolutions/x/signalx"
 	"log"
@@ -12,8 +13,11 @@ import (
 	"os"
 	"syscall"
 	"time"
-	"yourapp/cmd/yourapp/internal/routes"
-	"yourapp/foundation/urlprefix"
+	"yourapp/feature/audit"
+)
+
+const (
+	listenAddr = ":8080"
 )
 
 var (
@@ -24,6 +28,16 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		cmds := commands()
+		if cmds.RespondUsage("") {
+			return
+		}
+		if err := cmds.Exec(os.Args[1:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
 	logger := log.Default()
 	logger.Printf("Starting yourapp v%s: %s\n", version, gitHash)
 	ctx := signalx.SignalCtx(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -43,31 +57,53 @@ func run(ctx context.Context, logger *log.Logger) error {
 		_ = db.Close()
 	}()
 
-	authSvc, err := initAuth(log.Default(), db)
+	a, err := newApp(logger, audit.StdDelegate(logger, true), db)
 	if err != nil {
-		logger.Println("[ERR] Failed to initialize auth store:", err)
+		logger.Println("[ERR] Failed to initialize yourapp:", err)
 		return err
 	}
-	ro := &routes.Router{
-		Log:          logger,
-		AuthSvc:      authSvc,
-		Pool:         db,
-		StaticAssets: staticAssets,
+	pingCtx, cancelPing := context.WithTimeout(ctx, 5*time.Second)
+	if err := db.PingContext(pingCtx); err != nil {
+		logger.Println("[WRN] Database is not reachable yet, /readyz will fail until it is:", err)
 	}
-	handler := httpx.Wrap(ro.ServeMux(),
-		httpx.LoggingMiddleware(httpx.StdLogger(logger)),
-		httpx.RecoveryMiddleware(panicHandlerFunc(func(cause any) {
-			logger.Println("[ERR] Panic encountered:", cause)
-		})),
-	)
+	cancelPing()
+	adminSrv := initMetrics(logger, db)
 
 	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: urlprefix.Group(handler),
+		Addr:    listenAddr,
+		Handler: a.handler(),
 	}
 
+	// Readiness fails as soon as a shutdown signal is received, and the server keeps serving for DRAIN_DELAY so load balancers can react.
+	serveCtx := a.probe.DrainCtx(ctx, env.Duration("DRAIN_DELAY", 0))
+	if adminSrv != nil {
+		logger.Println("Serving metrics on", adminSrv.Addr)
+		go func() {
+			if err := httpx.ListenAndServeCtx(serveCtx, adminSrv, 5*time.Second); err != nil {
+				logger.Println("[ERR] Error running admin server:", err)
+			}
+		}()
+	}
+	// The sender stops with the server, and is waited for so a send in progress can finish and be recorded before the DB is closed.
+	senderDone := make(chan struct{})
+	go func() {
+		defer close(senderDone)
+		a.sender.Run(serveCtx)
+	}()
+	defer func() {
+		<-senderDone
+	}()
+	// Jobs start draining as soon as a shutdown signal is received, and are waited for before the DB is closed.
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		a.worker.Run(ctx)
+	}()
+	defer func() {
+		<-workerDone
+	}()
 	log.Println("Starting yourapp server...")
-	if err := httpx.ListenAndServeCtx(ctx, srv, 5*time.Second); err != nil {
+	if err := httpx.ListenAndServeCtx(serveCtx, srv, 5*time.Second); err != nil {
 		if errors.Is(err, http.ErrServerClosed) {
 			return err
 		}
@@ -0,0 +1,12 @@
+//go:build !dev
+
+package main
+
+import "net/http"
+
+// initDev does nothing outside of dev builds, see dev.go.
+func initDev(*app) {}
+
+func withDev(next http.Handler) http.Handler {
+	return next
+}
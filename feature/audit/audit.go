@@ -4,17 +4,29 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"yourapp/feature/model"
+	"yourapp/feature/model"      // feature:audit
+	"yourapp/foundation/metrics" // feature:audit
 )
 
 const (
 	AnonymousUser = "<anonymous>"
+	// CLIUser is the actor recorded for changes made with the yourapp admin subcommands.
+	CLIUser = "cli"
 )
 
+// feature:audit begin
+
+var (
+	writeFailures  = metrics.NewCounter("audit_write_failures_total", "Audit log entries that failed to be written")
+	writesInFlight = metrics.NewGauge("audit_writes_in_flight", "Audit log entries being written right now")
+)
+
+// feature:audit end
+
 type Logger struct {
 	delegate LogDelegate
 	pool     *sql.DB
-	UserRepo model.UsersRepo
+	UserRepo model.UsersRepo // feature:audit
 }
 
 func NewLogger(pool *sql.DB, delegate LogDelegate) *Logger {
@@ -22,11 +34,22 @@ func NewLogger(pool *sql.DB, delegate LogDelegate) *Logger {
 }
 
 func (l *Logger) Post(ctx context.Context, username, action string) {
+	// feature:audit begin
 	l.delegate.Debug(action)
+	writesInFlight.Add(1)
 	_, err := l.UserRepo.InsertAuditLog(ctx, l.pool, username, action)
+	writesInFlight.Add(-1)
 	if err != nil {
+		writeFailures.Inc()
 		l.delegate.Error("Failed to insert into audit log: %w", err)
 	}
+	// feature:audit end
+	// feature:!audit: l.delegate.Info("%s: %s", username, action)
+}
+
+// Warn logs a message that operators should notice, without recording it in the audit log.
+func (l *Logger) Warn(msg string, args ...any) {
+	l.delegate.Warn(msg, args...)
 }
 
 func (l *Logger) Postf(ctx context.Context, username, msg string, args ...any) {
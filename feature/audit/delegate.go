@@ -4,6 +4,7 @@ import "log"
 
 type LogDelegate interface {
 	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
 	Error(msg string, args ...any)
 	Debug(msg string, args ...any)
 }
@@ -24,6 +25,10 @@ func (s *stdLogDelegate) Info(msg string, args ...any) {
 	s.logger.Printf("[INF] "+msg+"\n", args...)
 }
 
+func (s *stdLogDelegate) Warn(msg string, args ...any) {
+	s.logger.Printf("[WRN] "+msg+"\n", args...)
+}
+
 func (s *stdLogDelegate) Error(msg string, args ...any) {
 	s.logger.Printf("[ERR] "+msg+"\n", args...)
 }
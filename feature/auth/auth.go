@@ -4,19 +4,26 @@ package auth
 import (
 	"context"
 	"database/sql"
-	"encoding/hex"
-	"fmt"
-	"github.com/gorilla/securecookie"
-	"github.com/saylorsolutions/x/env"
-	"github.com/saylorsolutions/x/httpx"
+	"github.com/saylorsolutions/x/httpx" // feature:authz
 	"net/http"
-	"strings"
+	"strings" // feature:authz
 	"yourapp/feature/audit"
 	"yourapp/feature/model"
+	"yourapp/foundation/htmx" // feature:authz
+	"yourapp/foundation/httperr"
+	"yourapp/foundation/metrics"
+	"yourapp/foundation/requestid" // feature:authz
+	"yourapp/foundation/signed"
 )
 
 const (
-	expectedHashLen = 32
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+)
+
+var (
+	loginAttempts  = metrics.NewCounter("auth_logins_total", "Login attempts by result", "result")
+	csrfRejections = metrics.NewCounter("auth_csrf_rejections_total", "Requests rejected because of a missing or invalid CSRF token")
 )
 
 type Details struct {
@@ -24,9 +31,19 @@ type Details struct {
 	Username   string
 	Admin      bool
 	SessionKey string
-	Authz      []*model.UserAuthResult
+	// TokenID is set when the request was authenticated with an API token rather than a session.
+	TokenID uint64
+	// TenantID is the organization that the request is for, which the user must be a member of.
+	// Handlers for the organization's own pages scope their queries to it with [model.WithTenant].
+	TenantID uint64
+	// TenantAdmin is set if the user is an admin of the organization that the request is for.
+	TenantAdmin bool
+	// Authz are the user's authorizations, or their member authorizations when TenantID is set.
+	Authz []*model.UserAuthResult // feature:authz
 }
 
+// feature:authz begin
+
 func (d Details) HasAuth(auth string) bool {
 	auth = strings.ToLower(auth)
 	for _, granted := range d.Authz {
@@ -37,51 +54,62 @@ func (d Details) HasAuth(auth string) bool {
 	return false
 }
 
-type Service struct {
-	log      *audit.Logger
-	sc       *securecookie.SecureCookie
-	pool     *sql.DB
-	userRepo model.UsersRepo
-}
+// feature:authz end
 
-func initSecureCookie() (*securecookie.SecureCookie, error) {
-	var hashKey []byte
-	envHashKey := env.Val("SESSION_HASHKEY", "")
-	if len(envHashKey) == 0 {
-		hashKey = securecookie.GenerateRandomKey(expectedHashLen)
-	} else {
-		hashKey = make([]byte, expectedHashLen)
-		size, err := hex.Decode(hashKey, []byte(envHashKey))
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse environment provided hash key as hex: %w", err)
-		}
-		if size < expectedHashLen {
-			return nil, fmt.Errorf("hash key must be a hex string representing at least %d bytes", expectedHashLen)
-		}
-	}
-	return securecookie.New(hashKey, nil), nil
+type Service struct {
+	log         *audit.Logger
+	sc          keyRing
+	csrf        csrfKeys
+	links       *signed.Signer
+	csrfFailure http.Handler
+	failure     func(w http.ResponseWriter, r *http.Request, err error)
+	cookies     CookiePolicy
+	pool        *sql.DB
+	userRepo    model.UsersRepo
+	tokenRepo   model.TokensRepo
+	orgRepo     model.OrgsRepo
 }
 
 func NewAuthService(log *audit.Logger, pool *sql.DB) (*Service, error) {
-	sc, err := initSecureCookie()
+	pairs, err := LoadKeyPairs()
+	if err != nil {
+		return nil, err
+	}
+	cookies, err := LoadCookiePolicy()
 	if err != nil {
 		return nil, err
 	}
-	return &Service{log: log, sc: sc, pool: pool}, nil
+	if cookies.Insecure {
+		log.Warn("!!! %s is set, cookies will be sent over plain HTTP. NEVER use this outside of local development !!!", EnvCookieInsecure)
+	}
+	return &Service{
+		log:     log,
+		sc:      newKeyRing(pairs...),
+		csrf:    newCSRFKeys(pairs...),
+		links:   newLinkSigner(pairs...),
+		cookies: cookies,
+		pool:    pool,
+	}, nil
 }
 
+// feature:authz begin
+
 func (s *Service) RequireAuth(auth string) httpx.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			details, ok := GetSessionUser(r)
 			if !ok {
 				s.log.Postf(r.Context(), audit.AnonymousUser, "User is not granted auth '%s'", auth)
-				http.Redirect(w, r, NoSessionRedirect, http.StatusFound)
+				htmx.Redirect(w, r, NoSessionRedirect, http.StatusFound)
 				return
 			}
 			if !details.HasAuth(auth) {
 				s.log.Postf(r.Context(), details.Username, "User is not granted auth '%s'", auth)
-				http.Redirect(w, r, NoSessionRedirect, http.StatusFound)
+				if details.TokenID != 0 {
+					httperr.WriteJSON(w, httperr.Forbidden("token is not granted auth '"+auth+"'"), requestid.Get(r.Context()))
+					return
+				}
+				htmx.Redirect(w, r, NoSessionRedirect, http.StatusFound)
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -89,6 +117,50 @@ func (s *Service) RequireAuth(auth string) httpx.Middleware {
 	}
 }
 
+// feature:authz end
+
+// SetErrorHandler sets the function used to respond when the auth middleware fails unexpectedly,
+// or rejects a request for an organization that the user isn't a member of.
+// The error given to the handler is an [*httperr.Error].
+// The default handler responds with a bare status.
+func (s *Service) SetErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) {
+	s.failure = handler
+}
+
+func (s *Service) fail(w http.ResponseWriter, r *http.Request, err error) {
+	httpErr := httperr.From(err)
+	if s.failure != nil {
+		s.failure(w, r, httpErr)
+		return
+	}
+	http.Error(w, http.StatusText(httpErr.Status), httpErr.Status)
+}
+
+// CheckPassword reports whether the password is the user's, which is false for a user that doesn't exist.
+func (s *Service) CheckPassword(ctx context.Context, username, password string) (bool, error) {
+	result, err := s.userRepo.CheckPassword(ctx, s.pool, username, password)
+	if err != nil {
+		return false, err
+	}
+	return result.Matches, nil
+}
+
+// UsersRepo returns the repo that users and sessions are read with, so tests can redirect its queries.
+func (s *Service) UsersRepo() *model.UsersRepo {
+	return &s.userRepo
+}
+
+// RecordLogin records a login attempt in the audit log and login metrics.
+func (s *Service) RecordLogin(ctx context.Context, username string, success bool) {
+	if success {
+		loginAttempts.Inc(LoginSuccess)
+		s.log.Post(ctx, username, "Authenticated")
+		return
+	}
+	loginAttempts.Inc(LoginFailure)
+	s.log.Post(ctx, username, "failed authentication")
+}
+
 func (s *Service) AuditEvent(ctx context.Context, username string, message string) {
 	s.log.Post(ctx, username, message)
 }
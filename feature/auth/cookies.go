@@ -1,34 +1,164 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/env"
 	"net/http"
+	"strings"
 	"time"
-	"yourapp/foundation/urlprefix"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
+)
+
+const (
+	EnvCookieSameSite = "COOKIE_SAMESITE" // EnvCookieSameSite may be one of "lax", "strict", or "none".
+	EnvCookiePrefix   = "COOKIE_PREFIX"   // EnvCookiePrefix may be one of "auto", "host", "secure", or "none".
+	EnvCookieDomain   = "COOKIE_DOMAIN"   // EnvCookieDomain sets the cookie Domain attribute, which is not allowed with the "host" prefix.
+	EnvCookieInsecure = "COOKIE_INSECURE" // EnvCookieInsecure disables the Secure attribute for local development over plain HTTP.
+)
+
+// CookiePrefix is a cookie name prefix that tells browsers to enforce additional constraints on the cookie.
+// See https://datatracker.ietf.org/doc/html/draft-ietf-httpbis-rfc6265bis#name-cookie-name-prefixes for details.
+type CookiePrefix string
+
+const (
+	NoPrefix     CookiePrefix = ""
+	HostPrefix   CookiePrefix = "__Host-"
+	SecurePrefix CookiePrefix = "__Secure-"
 )
 
 var (
-	CookieBasePath = urlprefix.Apply("/")
+	ErrInvalidCookiePolicy = errors.New("invalid cookie policy")
 )
 
+// CookiePolicy determines the attributes set on every cookie issued by the [Service].
+// The zero value is a secure policy without a name prefix, scoped to the configured [urlprefix].
+type CookiePolicy struct {
+	SameSite http.SameSite
+	Prefix   CookiePrefix
+	Domain   string
+	// Insecure drops the Secure attribute and any name prefix so cookies work over plain HTTP.
+	// This should only ever be used for local development.
+	Insecure bool
+}
+
+// LoadCookiePolicy loads a CookiePolicy from the environment.
+//
+// By default, cookies are SameSite=Lax and use the __Host- prefix.
+// When a [urlprefix] is configured, the __Secure- prefix is used instead, since __Host- cookies must have a path of "/".
+func LoadCookiePolicy() (CookiePolicy, error) {
+	policy := CookiePolicy{
+		Domain:   strings.TrimSpace(env.Val(EnvCookieDomain, "")),
+		Insecure: env.Bool(EnvCookieInsecure, false),
+	}
+	switch strings.ToLower(strings.TrimSpace(env.Val(EnvCookieSameSite, "lax"))) {
+	case "lax":
+		policy.SameSite = http.SameSiteLaxMode
+	case "strict":
+		policy.SameSite = http.SameSiteStrictMode
+	case "none":
+		policy.SameSite = http.SameSiteNoneMode
+	default:
+		return CookiePolicy{}, fmt.Errorf("%w: unknown %s value '%s'", ErrInvalidCookiePolicy, EnvCookieSameSite, env.Val(EnvCookieSameSite, ""))
+	}
+	switch strings.ToLower(strings.TrimSpace(env.Val(EnvCookiePrefix, "auto"))) {
+	case "auto":
+		policy.Prefix = HostPrefix
+		// feature:!urlprefix: if len(policy.Domain) > 0 {
+		if len(urlprefix.Get()) > 0 || len(policy.Domain) > 0 { // feature:urlprefix
+			policy.Prefix = SecurePrefix
+		}
+	case "host":
+		policy.Prefix = HostPrefix
+	case "secure":
+		policy.Prefix = SecurePrefix
+	case "none":
+		policy.Prefix = NoPrefix
+	default:
+		return CookiePolicy{}, fmt.Errorf("%w: unknown %s value '%s'", ErrInvalidCookiePolicy, EnvCookiePrefix, env.Val(EnvCookiePrefix, ""))
+	}
+	if policy.Insecure {
+		policy.Prefix = NoPrefix
+	}
+	if err := policy.Validate(); err != nil {
+		return CookiePolicy{}, err
+	}
+	return policy, nil
+}
+
+// Validate checks that browsers will accept cookies issued with this policy.
+func (p CookiePolicy) Validate() error {
+	if p.Insecure {
+		if p.Prefix != NoPrefix {
+			return fmt.Errorf("%w: the %s prefix requires secure cookies", ErrInvalidCookiePolicy, p.Prefix)
+		}
+		if p.SameSite == http.SameSiteNoneMode {
+			return fmt.Errorf("%w: SameSite=None requires secure cookies", ErrInvalidCookiePolicy)
+		}
+	}
+	if p.Prefix == HostPrefix && len(p.Domain) > 0 {
+		return fmt.Errorf("%w: the %s prefix doesn't allow setting a domain", ErrInvalidCookiePolicy, HostPrefix)
+	}
+	return nil
+}
+
+// Name returns the cookie name that will be used for the given key.
+func (p CookiePolicy) Name(key string) string {
+	return string(p.Prefix) + key
+}
+
+// Path returns the cookie path, derived from the configured [urlprefix].
+func (p CookiePolicy) Path() string {
+	if p.Prefix == HostPrefix {
+		return "/"
+	}
+	return urlprefix.Apply("/") // feature:urlprefix
+	// feature:!urlprefix: return "/"
+}
+
+func (p CookiePolicy) cookie(key, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     p.Name(key),
+		Value:    value,
+		Path:     p.Path(),
+		Domain:   p.Domain,
+		HttpOnly: true,
+		Secure:   !p.Insecure,
+		SameSite: p.SameSite,
+	}
+}
+
+// CookiePolicy returns the policy used for cookies issued by this Service.
+func (s *Service) CookiePolicy() CookiePolicy {
+	return s.cookies
+}
+
+// SetCookiePolicy changes the policy used for cookies issued by this Service.
+func (s *Service) SetCookiePolicy(policy CookiePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	s.cookies = policy
+	return nil
+}
+
+// SetSecureCookie signs and encrypts the value, and sets it as a cookie according to the [CookiePolicy].
+// A cookieTTL of zero creates a browser session cookie, which is removed when the browser is closed.
 func (s *Service) SetSecureCookie(w http.ResponseWriter, key string, value string, cookieTTL time.Duration) error {
 	val, err := s.sc.Encode(key, value)
 	if err != nil {
 		return err
 	}
-	cookie := http.Cookie{
-		Name:     key,
-		Value:    val,
-		Path:     CookieBasePath,
-		Expires:  time.Now().Add(cookieTTL),
-		HttpOnly: true,
-		Secure:   true,
+	cookie := s.cookies.cookie(key, val)
+	if cookieTTL > 0 {
+		cookie.Expires = time.Now().Add(cookieTTL)
 	}
-	http.SetCookie(w, &cookie)
+	http.SetCookie(w, cookie)
 	return nil
 }
 
 func (s *Service) GetCookieValue(r *http.Request, key string) (string, error) {
-	cookie, err := r.Cookie(key)
+	cookie, err := r.Cookie(s.cookies.Name(key))
 	if err != nil {
 		return "", err
 	}
@@ -40,13 +170,7 @@ func (s *Service) GetCookieValue(r *http.Request, key string) (string, error) {
 }
 
 func (s *Service) ClearCookie(w http.ResponseWriter, key string) {
-	cookie := http.Cookie{
-		Name:     key,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   true,
-	}
-	http.SetCookie(w, &cookie)
+	cookie := s.cookies.cookie(key, "")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
 }
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCookiePolicy_Validate(t *testing.T) {
+	tests := map[string]struct {
+		policy CookiePolicy
+		isErr  bool
+	}{
+		"Zero value": {},
+		"Host prefix": {
+			policy: CookiePolicy{Prefix: HostPrefix, SameSite: http.SameSiteStrictMode},
+		},
+		"Host prefix with domain": {
+			policy: CookiePolicy{Prefix: HostPrefix, Domain: "example.com"},
+			isErr:  true,
+		},
+		"Insecure with prefix": {
+			policy: CookiePolicy{Prefix: SecurePrefix, Insecure: true},
+			isErr:  true,
+		},
+		"Insecure with SameSite=None": {
+			policy: CookiePolicy{SameSite: http.SameSiteNoneMode, Insecure: true},
+			isErr:  true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			err := tc.policy.Validate()
+			if tc.isErr {
+				assert.ErrorIs(t, err, ErrInvalidCookiePolicy)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestService_ClearCookie(t *testing.T) {
+	svc := &Service{sc: keyRing{securecookie.New([]byte("abc"), nil)}}
+	assert.NoError(t, svc.SetCookiePolicy(CookiePolicy{Prefix: HostPrefix, SameSite: http.SameSiteLaxMode}))
+
+	rec := httptest.NewRecorder()
+	assert.NoError(t, svc.SetSecureCookie(rec, SessionCookieName, "abc", time.Minute))
+	svc.ClearCookie(rec, SessionCookieName)
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 2)
+	set, cleared := cookies[0], cookies[1]
+	assert.Equal(t, "__Host-"+SessionCookieName, set.Name)
+	assert.Equal(t, set.Name, cleared.Name)
+	assert.Equal(t, set.Path, cleared.Path, "Cookie must be cleared with the same path it was set with")
+	assert.Equal(t, set.Domain, cleared.Domain)
+	assert.Equal(t, http.SameSiteLaxMode, set.SameSite)
+	assert.True(t, set.Secure)
+	assert.Equal(t, -1, cleared.MaxAge)
+}
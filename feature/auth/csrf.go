@@ -2,25 +2,37 @@ package auth
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"github.com/saylorsolutions/x/httpx"
 	"net/http"
-	"time"
 	"yourapp/feature/audit"
 )
 
 const (
 	CSRFFormKey   = "csrf_token"
+	CSRFHeaderKey = "X-CSRF-Token"
 	CSRFCookieKey = "_csrf"
+	// CSRFReadableCookieKey names a cookie holding the CSRF token that scripts can read, see [Service.ExposeCSRF].
+	CSRFReadableCookieKey = "XSRF-TOKEN"
 )
 
 type csrfKeyType string
 
 var csrfKey csrfKeyType = CSRFCookieKey
 
+// GetCSRF returns the CSRF token that should be submitted with forms or htmx requests from this request's page.
+// The token is set by [Service.SetCSRF] for anonymous pages, and [Service.RequireSession] for authenticated pages.
 func GetCSRF(r *http.Request) (string, bool) {
-	value := r.Context().Value(csrfKey)
+	return GetCSRFCtx(r.Context())
+}
+
+// GetCSRFCtx is the same as [GetCSRF], but reads from a context, which is useful in templ components.
+func GetCSRFCtx(ctx context.Context) (string, bool) {
+	value := ctx.Value(csrfKey)
 	if value == nil {
 		return "", false
 	}
@@ -30,51 +42,190 @@ func GetCSRF(r *http.Request) (string, bool) {
 	return "", false
 }
 
+func setCSRF(r *http.Request, token string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), csrfKey, token))
+}
+
+// csrfKeys holds HMAC keys for deriving CSRF tokens, newest first.
+type csrfKeys [][]byte
+
+func newCSRFKeys(pairs ...KeyPair) csrfKeys {
+	keys := make(csrfKeys, len(pairs))
+	for i, kp := range pairs {
+		mac := hmac.New(sha256.New, kp.Hash)
+		mac.Write([]byte("csrf-key"))
+		keys[i] = mac.Sum(nil)
+	}
+	return keys
+}
+
+func (k csrfKeys) token(key []byte, scope, binding string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write([]byte(binding))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Token derives a CSRF token bound to the given value with the newest key.
+func (k csrfKeys) Token(scope, binding string) string {
+	var key []byte
+	if len(k) > 0 {
+		key = k[0]
+	}
+	return k.token(key, scope, binding)
+}
+
+// Verify checks the given token against tokens derived with any key.
+func (k csrfKeys) Verify(scope, binding, given string) bool {
+	if len(given) == 0 {
+		return false
+	}
+	for _, key := range k {
+		if hmac.Equal([]byte(k.token(key, scope, binding)), []byte(given)) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	csrfSessionScope   = "session"
+	csrfAnonymousScope = "anonymous"
+)
+
+// sessionCSRFToken derives the synchronizer token for an authenticated session.
+func (s *Service) sessionCSRFToken(sessionKey string) string {
+	return s.csrf.Token(csrfSessionScope, sessionKey)
+}
+
+// SetCSRF makes a CSRF token available to anonymous pages, like the login page.
+// Since there's no session yet, the token is bound to a random value stored in a browser session cookie.
+// The cookie is reused if it's already set, so opening a form in several tabs doesn't invalidate the others.
 func (s *Service) SetCSRF() httpx.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			key := make([]byte, 16)
-			_, err := rand.Read(key)
-			if err != nil {
-				s.log.Postf(r.Context(), audit.AnonymousUser, "failed to read random key: %v", err)
-				http.Error(w, "Failed to set CSRF token", 500)
+			if details, ok := GetSessionUser(r); ok {
+				if details.TokenID != 0 {
+					next.ServeHTTP(w, r)
+					return
+				}
+				next.ServeHTTP(w, setCSRF(r, s.sessionCSRFToken(details.SessionKey)))
 				return
 			}
-			var csrfHex = make([]byte, len(key)*2)
-			hex.Encode(csrfHex, key)
-			if err := s.SetSecureCookie(w, CSRFCookieKey, string(csrfHex), 5*time.Minute); err != nil {
-				http.Error(w, "Failed to set CSRF cookie", 500)
+			binding, err := s.GetCookieValue(r, CSRFCookieKey)
+			if err != nil || len(binding) == 0 {
+				key := make([]byte, 16)
+				if _, err := rand.Read(key); err != nil {
+					s.log.Postf(r.Context(), audit.AnonymousUser, "failed to read random key: %v", err)
+					s.fail(w, r, fmt.Errorf("failed to generate CSRF binding: %w", err))
+					return
+				}
+				binding = hex.EncodeToString(key)
+			}
+			if err := s.SetSecureCookie(w, CSRFCookieKey, binding, 0); err != nil {
+				s.fail(w, r, fmt.Errorf("failed to set CSRF cookie: %w", err))
 				return
 			}
-			r = r.WithContext(context.WithValue(r.Context(), csrfKey, string(csrfHex)))
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, setCSRF(r, s.csrf.Token(csrfAnonymousScope, binding)))
 		})
 	}
 }
 
+// SetCSRFFailureHandler sets the handler used to respond when a request fails CSRF validation.
+// This is useful for showing a friendly page when a form has gone stale.
+// The default handler responds with a bare 403.
+func (s *Service) SetCSRFFailureHandler(handler http.Handler) {
+	s.csrfFailure = handler
+}
+
+func (s *Service) failCSRF(w http.ResponseWriter, r *http.Request) {
+	csrfRejections.Inc()
+	if s.csrfFailure != nil {
+		s.csrfFailure.ServeHTTP(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusForbidden)
+}
+
+// RequireCSRF validates the CSRF token given in the [CSRFHeaderKey] header, or the [CSRFFormKey] form field.
+// If [Service.RequireSession] has already run, then the token must be bound to the session.
+// Otherwise, the token must be bound to the cookie set by [Service.SetCSRF].
+// Requests authenticated by [Service.RequireBearer] are skipped, since browsers don't send bearer tokens on their own.
 func (s *Service) RequireCSRF() httpx.Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			username := audit.AnonymousUser
-			details, ok := GetSessionUser(r)
-			if ok {
-				username = details.Username
+			if details, ok := GetSessionUser(r); ok && details.TokenID != 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			givenCSRF := r.Header.Get(CSRFHeaderKey)
+			if len(givenCSRF) == 0 {
+				givenCSRF = r.FormValue(CSRFFormKey)
+			}
+			if details, ok := GetSessionUser(r); ok {
+				if !s.csrf.Verify(csrfSessionScope, details.SessionKey, givenCSRF) {
+					s.log.Post(r.Context(), details.Username, "mismatched CSRF token")
+					s.failCSRF(w, r)
+					return
+				}
+				next.ServeHTTP(w, setCSRF(r, givenCSRF))
+				return
 			}
-			csrfValue, err := s.GetCookieValue(r, string(csrfKey))
+			binding, err := s.GetCookieValue(r, CSRFCookieKey)
 			if err != nil {
-				s.log.Post(r.Context(), username, "missing CSRF token")
-				w.WriteHeader(http.StatusUnauthorized)
+				s.log.Post(r.Context(), audit.AnonymousUser, "missing CSRF token")
+				s.failCSRF(w, r)
 				return
 			}
-			givenCSRF := r.FormValue(CSRFFormKey)
-			if csrfValue != givenCSRF {
-				s.log.Post(r.Context(), username, "mismatched CSRF token")
-				w.WriteHeader(http.StatusUnauthorized)
+			if !s.csrf.Verify(csrfAnonymousScope, binding, givenCSRF) {
+				s.log.Post(r.Context(), audit.AnonymousUser, "mismatched CSRF token")
+				s.failCSRF(w, r)
 				return
 			}
-			s.log.Post(r.Context(), username, "Valid CSRF token")
-			s.ClearCookie(w, string(csrfKey))
+			s.log.Post(r.Context(), audit.AnonymousUser, "Valid CSRF token")
+			next.ServeHTTP(w, setCSRF(r, givenCSRF))
+		})
+	}
+}
+
+// ExposeCSRF copies the request's CSRF token into a cookie that scripts can read, which is how single page apps get it.
+// The app sends the cookie value back in the [CSRFHeaderKey] header, like a double-submit cookie.
+// Unlike a plain double-submit cookie, the token is bound to the session or [Service.SetCSRF] cookie with an HMAC,
+// so a cookie planted by a sibling subdomain won't pass [Service.RequireCSRF], and the session cookie stays HttpOnly.
+// This must run after [Service.RequireSession] or [Service.SetCSRF].
+func (s *Service) ExposeCSRF() httpx.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.SetReadableCSRF(w, r)
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// SetReadableCSRF sets the cookie described in [Service.ExposeCSRF], if the request has a CSRF token that the client doesn't already have.
+func (s *Service) SetReadableCSRF(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetCSRF(r)
+	if !ok {
+		return
+	}
+	if cookie, err := r.Cookie(s.cookies.Name(CSRFReadableCookieKey)); err == nil && cookie.Value == token {
+		return
+	}
+	cookie := s.cookies.cookie(CSRFReadableCookieKey, token)
+	cookie.HttpOnly = false
+	http.SetCookie(w, cookie)
+}
+
+// ClearCSRF removes the anonymous CSRF cookie, which is no longer needed once a session is established.
+func (s *Service) ClearCSRF(w http.ResponseWriter) {
+	s.ClearCookie(w, CSRFCookieKey)
+}
+
+// ClearReadableCSRF removes the cookie set by [Service.SetReadableCSRF], which should be done on logout.
+func (s *Service) ClearReadableCSRF(w http.ResponseWriter) {
+	cookie := s.cookies.cookie(CSRFReadableCookieKey, "")
+	cookie.HttpOnly = false
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
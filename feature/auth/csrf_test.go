@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestService_RequireCSRF(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authSvc := testAuthService(t, ctx)
+	var (
+		issued   string
+		accepted int
+		failed   int
+	)
+	authSvc.SetCSRFFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failed++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	setCSRF := authSvc.SetCSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issued, _ = GetCSRF(r)
+	}))
+	requireCSRF := authSvc.RequireCSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted++
+	}))
+
+	rec := httptest.NewRecorder()
+	setCSRF.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.NotEmpty(t, issued)
+
+	post := func(token string, useHeader bool, cookies ...*http.Cookie) int {
+		form := url.Values{}
+		if !useHeader {
+			form.Set(CSRFFormKey, token)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if useHeader {
+			req.Header.Set(CSRFHeaderKey, token)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		requireCSRF.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("Form field", func(t *testing.T) {
+		accepted, failed = 0, 0
+		assert.Equal(t, http.StatusOK, post(issued, false, cookies...))
+		assert.Equal(t, 1, accepted)
+		assert.Equal(t, 0, failed)
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		accepted, failed = 0, 0
+		assert.Equal(t, http.StatusOK, post(issued, true, cookies...))
+		assert.Equal(t, 1, accepted)
+		assert.Equal(t, 0, failed)
+	})
+
+	t.Run("Missing cookie", func(t *testing.T) {
+		accepted, failed = 0, 0
+		assert.Equal(t, http.StatusForbidden, post(issued, false))
+		assert.Equal(t, 0, accepted)
+		assert.Equal(t, 1, failed)
+	})
+
+	t.Run("Mismatched token", func(t *testing.T) {
+		accepted, failed = 0, 0
+		assert.Equal(t, http.StatusForbidden, post("abc", true, cookies...))
+		assert.Equal(t, 0, accepted)
+		assert.Equal(t, 1, failed)
+	})
+
+	t.Run("Session bound", func(t *testing.T) {
+		accepted, failed = 0, 0
+		sessionToken := authSvc.sessionCSRFToken("abc")
+		for _, token := range []string{sessionToken, issued} {
+			req := httptest.NewRequest(http.MethodPost, "/protected", nil)
+			req.Header.Set(CSRFHeaderKey, token)
+			req = setSessionDetails(req, Details{Username: "Bob", SessionKey: "abc"})
+			requireCSRF.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		assert.Equal(t, 1, accepted)
+		assert.Equal(t, 1, failed, "Anonymous tokens should not be accepted for a session")
+	})
+}
+
+func TestCSRFKeys_Rotation(t *testing.T) {
+	oldPair, newPair := GenerateKeyPair(), GenerateKeyPair()
+	oldToken := newCSRFKeys(oldPair).Token(csrfSessionScope, "abc")
+	rotated := newCSRFKeys(newPair, oldPair)
+	assert.True(t, rotated.Verify(csrfSessionScope, "abc", oldToken))
+	assert.False(t, rotated.Verify(csrfSessionScope, "def", oldToken))
+	assert.False(t, rotated.Verify(csrfAnonymousScope, "abc", oldToken))
+	assert.NotEqual(t, oldToken, rotated.Token(csrfSessionScope, "abc"))
+}
+
+func TestService_ExposeCSRF(t *testing.T) {
+	authSvc := testAuthService(t, context.Background())
+	var accepted int
+	expose := authSvc.SetCSRF()(authSvc.ExposeCSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	requireCSRF := authSvc.SetCSRF()(authSvc.RequireCSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted++
+	})))
+
+	rec := httptest.NewRecorder()
+	expose.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/session", nil))
+	var binding, readable *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		switch cookie.Name {
+		case authSvc.cookies.Name(CSRFCookieKey):
+			binding = cookie
+		case authSvc.cookies.Name(CSRFReadableCookieKey):
+			readable = cookie
+		}
+	}
+	if !assert.NotNil(t, binding) || !assert.NotNil(t, readable) {
+		return
+	}
+	assert.True(t, binding.HttpOnly)
+	assert.False(t, readable.HttpOnly, "Scripts must be able to read the token")
+
+	t.Run("Echoed token", func(t *testing.T) {
+		accepted = 0
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/session", nil)
+		req.AddCookie(binding)
+		req.AddCookie(readable)
+		req.Header.Set(CSRFHeaderKey, readable.Value)
+		rec := httptest.NewRecorder()
+		requireCSRF.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, accepted)
+	})
+
+	t.Run("Planted cookie", func(t *testing.T) {
+		accepted = 0
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/session", nil)
+		req.AddCookie(binding)
+		req.AddCookie(&http.Cookie{Name: readable.Name, Value: "planted"})
+		req.Header.Set(CSRFHeaderKey, "planted")
+		rec := httptest.NewRecorder()
+		requireCSRF.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "A matching cookie and header isn't enough without the HMAC binding")
+		assert.Equal(t, 0, accepted)
+	})
+}
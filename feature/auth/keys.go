@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gorilla/securecookie"
+	"github.com/saylorsolutions/x/env"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	expectedHashLen  = 32
+	expectedBlockLen = 32
+
+	EnvHashKey  = "SESSION_HASHKEY"   // EnvHashKey is the hex encoded hash key used to sign new cookie values.
+	EnvBlockKey = "SESSION_BLOCKKEY"  // EnvBlockKey is the hex encoded block key used to encrypt new cookie values.
+	EnvKeys     = "SESSION_KEYS"      // EnvKeys is a comma separated list of key pairs, newest first.
+	EnvKeysFile = "SESSION_KEYS_FILE" // EnvKeysFile is the path to a file with one key pair per line, newest first.
+)
+
+var (
+	ErrNoBlockKey = errors.New("the newest key pair must include a block key, so set " + EnvBlockKey + " or list a new pair first in " + EnvKeys)
+)
+
+// KeyPair is a hash key used to sign cookie values, and a block key used to encrypt them.
+// The block key may be empty for older key pairs, which allows decoding values that were only signed.
+type KeyPair struct {
+	Hash  []byte
+	Block []byte
+}
+
+// GenerateKeyPair creates a new random KeyPair suitable for use with [Service].
+func GenerateKeyPair() KeyPair {
+	return KeyPair{
+		Hash:  securecookie.GenerateRandomKey(expectedHashLen),
+		Block: securecookie.GenerateRandomKey(expectedBlockLen),
+	}
+}
+
+// ParseKeyPair parses a key pair in the form "HASH[:BLOCK]", where each key is hex encoded.
+func ParseKeyPair(pair string) (KeyPair, error) {
+	hashHex, blockHex, _ := strings.Cut(strings.TrimSpace(pair), ":")
+	return parseKeyPair(hashHex, blockHex)
+}
+
+func parseKeyPair(hashHex, blockHex string) (KeyPair, error) {
+	var (
+		kp  KeyPair
+		err error
+	)
+	kp.Hash, err = hex.DecodeString(strings.TrimSpace(hashHex))
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("failed to parse hash key as hex: %w", err)
+	}
+	if len(kp.Hash) < expectedHashLen {
+		return KeyPair{}, fmt.Errorf("hash key must be a hex string representing at least %d bytes", expectedHashLen)
+	}
+	blockHex = strings.TrimSpace(blockHex)
+	if len(blockHex) == 0 {
+		return kp, nil
+	}
+	kp.Block, err = hex.DecodeString(blockHex)
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("failed to parse block key as hex: %w", err)
+	}
+	switch len(kp.Block) {
+	case 16, 24, 32:
+	default:
+		return KeyPair{}, errors.New("block key must be a hex string representing 16, 24, or 32 bytes")
+	}
+	return kp, nil
+}
+
+// String returns the key pair in the same form accepted by [ParseKeyPair].
+func (p KeyPair) String() string {
+	if len(p.Block) == 0 {
+		return hex.EncodeToString(p.Hash)
+	}
+	return hex.EncodeToString(p.Hash) + ":" + hex.EncodeToString(p.Block)
+}
+
+// ReadKeyPairs reads key pairs from the reader, one per line and newest first.
+// Blank lines and lines starting with '#' are ignored.
+func ReadKeyPairs(r io.Reader) ([]KeyPair, error) {
+	var (
+		pairs   []KeyPair
+		scanner = bufio.NewScanner(r)
+		lineNum int
+	)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kp, err := ParseKeyPair(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key pair on line %d: %w", lineNum, err)
+		}
+		pairs = append(pairs, kp)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// LoadKeyPairs loads the configured cookie key pairs, newest first.
+//
+// Sources are checked in this order, and the first one set is used:
+//   - [EnvKeysFile] names a file read with [ReadKeyPairs].
+//   - [EnvKeys] holds comma separated pairs in the form accepted by [ParseKeyPair].
+//   - [EnvHashKey] and [EnvBlockKey] hold a single key pair.
+//
+// If nothing is configured, then a random key pair is generated, and cookies will not be recognized after a restart.
+func LoadKeyPairs() ([]KeyPair, error) {
+	var (
+		pairs []KeyPair
+		err   error
+	)
+	switch {
+	case len(env.Val(EnvKeysFile, "")) > 0:
+		pairs, err = readKeyFile(env.Val(EnvKeysFile, ""))
+	case len(env.Val(EnvKeys, "")) > 0:
+		for _, pair := range strings.Split(env.Val(EnvKeys, ""), ",") {
+			if len(strings.TrimSpace(pair)) == 0 {
+				continue
+			}
+			kp, perr := ParseKeyPair(pair)
+			if perr != nil {
+				err = fmt.Errorf("invalid key pair in %s: %w", EnvKeys, perr)
+				break
+			}
+			pairs = append(pairs, kp)
+		}
+	case len(env.Val(EnvHashKey, "")) > 0:
+		var kp KeyPair
+		kp, err = parseKeyPair(env.Val(EnvHashKey, ""), env.Val(EnvBlockKey, ""))
+		pairs = append(pairs, kp)
+	default:
+		return []KeyPair{GenerateKeyPair()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, errors.New("no cookie key pairs configured")
+	}
+	if len(pairs[0].Block) == 0 {
+		return nil, ErrNoBlockKey
+	}
+	return pairs, nil
+}
+
+func readKeyFile(path string) ([]KeyPair, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return ReadKeyPairs(f)
+}
+
+// keyRing encodes cookie values with the first (newest) codec, and decodes with any of them.
+type keyRing []securecookie.Codec
+
+func newKeyRing(pairs ...KeyPair) keyRing {
+	ring := make(keyRing, len(pairs))
+	for i, kp := range pairs {
+		ring[i] = securecookie.New(kp.Hash, kp.Block)
+	}
+	return ring
+}
+
+func (k keyRing) Encode(name string, value any) (string, error) {
+	return securecookie.EncodeMulti(name, value, k...)
+}
+
+func (k keyRing) Decode(name, value string, dst any) error {
+	return securecookie.DecodeMulti(name, value, dst, k...)
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyPair(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		hasBlock bool
+		isErr    bool
+	}{
+		"Hash only": {
+			input: strings.Repeat("ab", 32),
+		},
+		"Hash and block": {
+			input:    strings.Repeat("ab", 32) + ":" + strings.Repeat("cd", 32),
+			hasBlock: true,
+		},
+		"Short hash": {
+			input: strings.Repeat("ab", 16),
+			isErr: true,
+		},
+		"Invalid block size": {
+			input: strings.Repeat("ab", 32) + ":" + strings.Repeat("cd", 20),
+			isErr: true,
+		},
+		"Not hex": {
+			input: strings.Repeat("zz", 32),
+			isErr: true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			kp, err := ParseKeyPair(tc.input)
+			if tc.isErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.hasBlock, len(kp.Block) > 0)
+			assert.Equal(t, tc.input, kp.String())
+		})
+	}
+}
+
+func TestReadKeyPairs(t *testing.T) {
+	input := "# newest first\n" + GenerateKeyPair().String() + "\n\n" + GenerateKeyPair().String() + "\n"
+	pairs, err := ReadKeyPairs(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Len(t, pairs, 2)
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	oldPair, newPair := GenerateKeyPair(), GenerateKeyPair()
+	oldRing := newKeyRing(oldPair)
+	rotated := newKeyRing(newPair, oldPair)
+
+	oldVal, err := oldRing.Encode(SessionCookieName, "abc")
+	assert.NoError(t, err)
+	var decoded string
+	assert.NoError(t, rotated.Decode(SessionCookieName, oldVal, &decoded), "Older keys should still decode")
+	assert.Equal(t, "abc", decoded)
+
+	newVal, err := rotated.Encode(SessionCookieName, "def")
+	assert.NoError(t, err)
+	assert.Error(t, oldRing.Decode(SessionCookieName, newVal, &decoded), "Values should be encoded with the newest key")
+}
+
+func TestLoadKeyPairs(t *testing.T) {
+	oldPair, newPair := GenerateKeyPair(), GenerateKeyPair()
+	oldHash := hex.EncodeToString(oldPair.Hash)
+	tests := map[string]struct {
+		env         map[string]string
+		expectedLen int
+		expectedErr error
+	}{
+		"Nothing configured": {
+			expectedLen: 1,
+		},
+		"Hash and block": {
+			env:         map[string]string{EnvHashKey: hex.EncodeToString(newPair.Hash), EnvBlockKey: hex.EncodeToString(newPair.Block)},
+			expectedLen: 1,
+		},
+		"Hash only": {
+			env:         map[string]string{EnvHashKey: oldHash},
+			expectedErr: ErrNoBlockKey,
+		},
+		"Upgraded from hash only": {
+			env:         map[string]string{EnvKeys: newPair.String() + "," + oldHash},
+			expectedLen: 2,
+		},
+		"Newest without block": {
+			env:         map[string]string{EnvKeys: oldHash + "," + newPair.String()},
+			expectedErr: ErrNoBlockKey,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{EnvHashKey, EnvBlockKey, EnvKeys, EnvKeysFile} {
+				t.Setenv(key, tc.env[key])
+			}
+			pairs, err := LoadKeyPairs()
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, pairs, tc.expectedLen)
+			assert.NotEmpty(t, pairs[0].Block)
+		})
+	}
+}
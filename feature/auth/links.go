@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"yourapp/foundation/signed"
+)
+
+// newLinkSigner derives link signing keys from the session key pairs, so rotating session keys also rotates link keys.
+func newLinkSigner(pairs ...KeyPair) *signed.Signer {
+	keys := make([][]byte, len(pairs))
+	for i, kp := range pairs {
+		mac := hmac.New(sha256.New, kp.Hash)
+		mac.Write([]byte("link-key"))
+		keys[i] = mac.Sum(nil)
+	}
+	return signed.NewSigner(keys...)
+}
+
+// LinkSigner returns the signer used for links sent outside the app, like email verification and invitation links.
+func (s *Service) LinkSigner() *signed.Signer {
+	return s.links
+}
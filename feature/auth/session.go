@@ -2,11 +2,13 @@ package auth
 
 import (
 	"context"
+	"fmt"
 	"github.com/saylorsolutions/x/httpx"
 	"net/http"
 	"time"
 	"yourapp/feature/audit"
-	"yourapp/foundation/urlprefix"
+	"yourapp/foundation/htmx"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
 )
 
 const (
@@ -14,7 +16,8 @@ const (
 )
 
 var (
-	NoSessionRedirect = urlprefix.Apply("/login")
+	NoSessionRedirect = urlprefix.Apply("/login") // feature:urlprefix
+	// feature:!urlprefix: NoSessionRedirect = "/login"
 )
 
 type sessionDetails string
@@ -43,51 +46,95 @@ func (s *Service) RequireSession() httpx.Middleware {
 		return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			sessionKey, err := s.GetCookieValue(r, SessionCookieName)
 			if err != nil {
-				http.Redirect(w, r, NoSessionRedirect, http.StatusFound)
+				htmx.Redirect(w, r, NoSessionRedirect, http.StatusFound)
 				return
 			}
-			result, err := s.userRepo.GetSessionUser(r.Context(), s.pool, sessionKey)
-			if err != nil || result == nil {
-				s.log.Postf(r.Context(), audit.AnonymousUser, "Failed to get user details for auth %s: %v", sessionKey, err)
-				http.Redirect(w, r, NoSessionRedirect, http.StatusFound)
+			r, ok, err := s.loadSession(w, r, sessionKey)
+			if err != nil {
+				s.fail(w, r, err)
 				return
 			}
-			if len(result.Username) == 0 {
-				s.log.Postf(r.Context(), audit.AnonymousUser, "Failed to get user details for auth: %s", sessionKey)
-				http.Redirect(w, r, NoSessionRedirect, http.StatusFound)
+			if !ok {
+				htmx.Redirect(w, r, NoSessionRedirect, http.StatusFound)
 				return
 			}
-			details := Details{
-				UserID:     result.UserID,
-				Username:   result.Username,
-				Admin:      result.Admin,
-				SessionKey: sessionKey,
-			}
-			_, err = s.userRepo.UpdateSessionLiveness(r.Context(), s.pool, sessionKey)
-			if err != nil {
-				s.log.Postf(r.Context(), details.Username, "Failed to update session %s liveness: %v", sessionKey, err)
-				http.Error(w, "Session management error", 500)
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// APIAuth authenticates API requests with a bearer token if one is given, or with the session cookie if there is one.
+// Unlike [Service.RequireSession], requests without valid credentials continue anonymously, so handlers decide how to respond.
+// An invalid bearer token is still rejected, since the client clearly meant to authenticate.
+func (s *Service) APIAuth() httpx.Middleware {
+	bearer := s.RequireBearer()
+	return func(next http.Handler) http.Handler {
+		withBearer := bearer(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.Header.Get("Authorization")) > 0 {
+				withBearer.ServeHTTP(w, r)
 				return
 			}
-			if err := s.setSessionCookie(w, sessionKey); err != nil {
-				s.log.Postf(r.Context(), details.Username, "Failed to encode session key as cookie value: %v", err)
-				http.Error(w, "Session management error", 500)
+			sessionKey, err := s.GetCookieValue(r, SessionCookieName)
+			if err != nil {
+				next.ServeHTTP(w, r)
 				return
 			}
-			auths, err := s.userRepo.UserAuth(r.Context(), s.pool, result.UserID)
+			r, _, err = s.loadSession(w, r, sessionKey)
 			if err != nil {
-				s.log.Postf(r.Context(), audit.AnonymousUser, "Failed to retrieve authorizations for user %s: %v", result.Username, err)
-				http.Error(w, "Session management error", 500)
+				s.fail(w, r, err)
 				return
 			}
-			details.Authz = auths
-			r = setSessionDetails(r, details)
-			s.log.Postf(r.Context(), result.Username, "%s %s", r.Method, r.URL.Path)
 			next.ServeHTTP(w, r)
-		}))
+		})
 	}
 }
 
+// loadSession looks up and refreshes the session, and returns a request with its [Details].
+// If the session doesn't exist or has expired, then the request is returned unchanged with false.
+// An error means that the session couldn't be checked.
+func (s *Service) loadSession(w http.ResponseWriter, r *http.Request, sessionKey string) (*http.Request, bool, error) {
+	result, err := s.userRepo.GetSessionUser(r.Context(), s.pool, sessionKey)
+	if err != nil || result == nil {
+		s.log.Postf(r.Context(), audit.AnonymousUser, "Failed to get user details for auth %s: %v", sessionKey, err)
+		return r, false, nil
+	}
+	if len(result.Username) == 0 {
+		s.log.Postf(r.Context(), audit.AnonymousUser, "Failed to get user details for auth: %s", sessionKey)
+		return r, false, nil
+	}
+	details := Details{
+		UserID:     result.UserID,
+		Username:   result.Username,
+		Admin:      result.Admin,
+		SessionKey: sessionKey,
+	}
+	_, err = s.userRepo.UpdateSessionLiveness(r.Context(), s.pool, sessionKey)
+	if err != nil {
+		s.log.Postf(r.Context(), details.Username, "Failed to update session %s liveness: %v", sessionKey, err)
+		return r, false, fmt.Errorf("failed to update session liveness: %w", err)
+	}
+	if err := s.setSessionCookie(w, sessionKey); err != nil {
+		s.log.Postf(r.Context(), details.Username, "Failed to encode session key as cookie value: %v", err)
+		return r, false, fmt.Errorf("failed to set session cookie: %w", err)
+	}
+	// feature:authz begin
+	auths, err := s.userRepo.UserAuth(r.Context(), s.pool, result.UserID)
+	if err != nil {
+		s.log.Postf(r.Context(), audit.AnonymousUser, "Failed to retrieve authorizations for user %s: %v", result.Username, err)
+		return r, false, fmt.Errorf("failed to retrieve authorizations: %w", err)
+	}
+	details.Authz = auths
+	// feature:authz end
+	if err := s.setTenant(r, &details); err != nil {
+		return r, false, err
+	}
+	r = setSessionDetails(r, details)
+	r = setCSRF(r, s.sessionCSRFToken(sessionKey))
+	s.log.Postf(r.Context(), result.Username, "%s %s", r.Method, r.URL.Path)
+	return r, true, nil
+}
+
 func (s *Service) SetAuthenticatedSession(w http.ResponseWriter, r *http.Request, username string) (*http.Request, error) {
 	ses, err := s.userRepo.CreateSession(r.Context(), s.pool, username)
 	if err != nil {
@@ -98,20 +145,28 @@ func (s *Service) SetAuthenticatedSession(w http.ResponseWriter, r *http.Request
 		return r, err
 	}
 	userDetails := Details{
+		UserID:     details.UserID,
 		Username:   details.Username,
 		Admin:      details.Admin,
 		SessionKey: ses.SessionKey,
 	}
+	// feature:authz begin
 	authz, err := s.userRepo.UserAuth(r.Context(), s.pool, details.UserID)
 	if err != nil {
 		return r, err
 	}
 	userDetails.Authz = authz
+	// feature:authz end
+	if err := s.setTenant(r, &userDetails); err != nil {
+		return r, err
+	}
 	if err := s.setSessionCookie(w, ses.SessionKey); err != nil {
 		s.log.Postf(r.Context(), username, "Unable to encode session key as cookie value: %v", err)
 		return r, err
 	}
 	r = setSessionDetails(r, userDetails)
+	r = setCSRF(r, s.sessionCSRFToken(ses.SessionKey))
+	s.ClearCSRF(w)
 	return r, nil
 }
 
@@ -13,20 +13,21 @@ import (
 	"testing"
 	"yourapp/feature/audit"
 	"yourapp/feature/model"
+	"yourapp/foundation/htmx"
 )
 
 func TestService_RequireSession(t *testing.T) {
 	var (
 		loginRedirectCalls         int
 		getSessionCalls            int
-		getAuthCalls               int
+		getAuthCalls               int // feature:authz
 		authenticatedCalls         int
 		updateSessionLivenessCalls int
 	)
 	resetCounts := func() {
 		loginRedirectCalls = 0
 		getSessionCalls = 0
-		getAuthCalls = 0
+		getAuthCalls = 0 // feature:authz
 		authenticatedCalls = 0
 		updateSessionLivenessCalls = 0
 	}
@@ -48,10 +49,12 @@ func TestService_RequireSession(t *testing.T) {
 		updateSessionLivenessCalls++
 		return nil, nil
 	})
+	// feature:authz begin
 	authSvc.userRepo.RedirectUserAuth(func(_ context.Context, _ *sql.DB, user uint64) ([]*model.UserAuthResult, error) {
 		getAuthCalls++
 		return nil, nil
 	})
+	// feature:authz end
 	requireSession := authSvc.RequireSession()
 	mux := http.NewServeMux()
 
@@ -75,7 +78,7 @@ func TestService_RequireSession(t *testing.T) {
 		assert.Equal(t, 200, status)
 		assert.Equal(t, 0, loginRedirectCalls)
 		assert.Equal(t, 0, getSessionCalls)
-		assert.Equal(t, 0, getAuthCalls)
+		assert.Equal(t, 0, getAuthCalls) // feature:authz
 		assert.Equal(t, 0, updateSessionLivenessCalls)
 		assert.Equal(t, 0, authenticatedCalls)
 	})
@@ -87,11 +90,24 @@ func TestService_RequireSession(t *testing.T) {
 		assert.Equal(t, 200, status)
 		assert.Equal(t, 1, loginRedirectCalls)
 		assert.Equal(t, 0, getSessionCalls)
-		assert.Equal(t, 0, getAuthCalls)
+		assert.Equal(t, 0, getAuthCalls) // feature:authz
 		assert.Equal(t, 0, updateSessionLivenessCalls)
 		assert.Equal(t, 0, authenticatedCalls)
 	})
 
+	t.Run("Missing session cookie htmx", func(t *testing.T) {
+		resetCounts()
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set(htmx.HeaderRequest, "true")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, NoSessionRedirect, rec.Header().Get(htmx.HeaderRedirect))
+		assert.Empty(t, rec.Header().Get("Location"), "htmx requests should not follow a standard redirect")
+		assert.Equal(t, 0, loginRedirectCalls)
+		assert.Equal(t, 0, authenticatedCalls)
+	})
+
 	t.Run("Invalid session", func(t *testing.T) {
 		resetCounts()
 		_, status, err := httpx.GetRequest(fmt.Sprintf("%s/protected", srv.URL)).AddHeader("Set-Cookie", "abc").Send()
@@ -99,7 +115,7 @@ func TestService_RequireSession(t *testing.T) {
 		assert.Equal(t, 200, status)
 		assert.Equal(t, 1, loginRedirectCalls)
 		assert.Equal(t, 0, getSessionCalls)
-		assert.Equal(t, 0, getAuthCalls)
+		assert.Equal(t, 0, getAuthCalls) // feature:authz
 		assert.Equal(t, 0, updateSessionLivenessCalls)
 		assert.Equal(t, 0, authenticatedCalls)
 	})
@@ -122,7 +138,7 @@ func TestService_RequireSession(t *testing.T) {
 		assert.Equal(t, 200, status)
 		assert.Equal(t, 1, loginRedirectCalls)
 		assert.Equal(t, 1, getSessionCalls)
-		assert.Equal(t, 0, getAuthCalls)
+		assert.Equal(t, 0, getAuthCalls) // feature:authz
 		assert.Equal(t, 0, updateSessionLivenessCalls)
 		assert.Equal(t, 0, authenticatedCalls)
 	})
@@ -144,7 +160,7 @@ func TestService_RequireSession(t *testing.T) {
 		assert.Equal(t, 200, status)
 		assert.Equal(t, 0, loginRedirectCalls)
 		assert.Equal(t, 1, getSessionCalls)
-		assert.Equal(t, 1, getAuthCalls)
+		assert.Equal(t, 1, getAuthCalls) // feature:authz
 		assert.Equal(t, 1, updateSessionLivenessCalls)
 		assert.Equal(t, 1, authenticatedCalls)
 	})
@@ -152,13 +168,16 @@ func TestService_RequireSession(t *testing.T) {
 
 func testAuthService(t *testing.T, ctx context.Context) *Service {
 	auditLog := audit.NewLogger(nil, audit.StdDelegate(log.Default(), true))
+	// feature:audit begin
 	auditLog.UserRepo.RedirectInsertAuditLog(func(_ context.Context, _ *sql.DB, user string, msg string) (sql.Result, error) {
 		t.Log("[Audit Log]", user, msg)
 		return nil, nil
 	})
+	// feature:audit end
 	sc := securecookie.New([]byte("abc"), nil)
 	return &Service{
-		log: auditLog,
-		sc:  sc,
+		log:  auditLog,
+		sc:   keyRing{sc},
+		csrf: newCSRFKeys(GenerateKeyPair()),
 	}
 }
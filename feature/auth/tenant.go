@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"yourapp/feature/model"
+	"yourapp/feature/tenant"
+	"yourapp/foundation/httperr"
+)
+
+// OrgsRepo returns the repo that organization memberships are read with, so tests can redirect its queries.
+func (s *Service) OrgsRepo() *model.OrgsRepo {
+	return &s.orgRepo
+}
+
+// errNotMember rejects a request for an organization that the user isn't a member of.
+// It's the same 404 as for an organization that doesn't exist, so users can't find out which ones do.
+var errNotMember = httperr.NotFound("No such organization")
+
+// setTenant adds the user's membership in the request's organization to details, see [tenant.Get].
+// Users that aren't members get [errNotMember], so they can't use their own authorizations within an organization.
+// Nothing is changed if the request isn't for an organization.
+func (s *Service) setTenant(r *http.Request, details *Details) error {
+	org, ok := tenant.Get(r.Context())
+	if !ok {
+		return nil
+	}
+	ctx := model.WithTenant(r.Context(), org.ID)
+	member, err := s.orgRepo.GetMembership(ctx, s.pool, org.ID, details.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.log.Postf(r.Context(), details.Username, "Attempted to access %s %s, not a member of %s", r.Method, r.URL.Path, org.Slug)
+		return errNotMember
+	}
+	if err != nil {
+		s.log.Postf(r.Context(), details.Username, "Failed to retrieve membership in %s: %v", org.Slug, err)
+		return fmt.Errorf("failed to retrieve membership: %w", err)
+	}
+	details.TenantID = org.ID
+	details.TenantAdmin = member.Admin
+	// feature:authz begin
+	var auths []*model.UserAuthResult
+	if details.TokenID != 0 {
+		auths, err = s.orgRepo.MemberTokenAuth(ctx, s.pool, org.ID, details.TokenID, details.UserID)
+	} else {
+		auths, err = s.orgRepo.MemberAuth(ctx, s.pool, org.ID, details.UserID)
+	}
+	if err != nil {
+		s.log.Postf(r.Context(), details.Username, "Failed to retrieve authorizations in %s: %v", org.Slug, err)
+		return fmt.Errorf("failed to retrieve member authorizations: %w", err)
+	}
+	details.Authz = auths
+	// feature:authz end
+	return nil
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"yourapp/feature/model"
+	"yourapp/feature/tenant"
+)
+
+func TestService_setTenant(t *testing.T) {
+	token, hash, _, err := GenerateToken()
+	assert.NoError(t, err)
+
+	authSvc := testAuthService(t, context.Background())
+	authSvc.tokenRepo.RedirectGetTokenUser(func(_ context.Context, _ *sql.DB, tokenHash string) (*model.GetTokenUserResult, error) {
+		assert.Equal(t, hash, tokenHash)
+		return &model.GetTokenUserResult{TokenID: 5, UserID: 1, Username: "Bob"}, nil
+	})
+	// feature:authz begin
+	authSvc.tokenRepo.RedirectTokenAuth(func(_ context.Context, _ *sql.DB, tokenID uint64, userID uint64) ([]*model.UserAuthResult, error) {
+		return []*model.UserAuthResult{{Id: 1, Auth: "reports"}}, nil
+	})
+	authSvc.orgRepo.RedirectMemberTokenAuth(func(ctx context.Context, _ *sql.DB, orgID uint64, tokenID uint64, userID uint64) ([]*model.UserAuthResult, error) {
+		scoped, _ := model.TenantID(ctx)
+		assert.Equal(t, orgID, scoped, "Member authorizations should be read in the organization's scope")
+		return []*model.UserAuthResult{{Id: 2, Auth: "billing"}}, nil
+	})
+	// feature:authz end
+	authSvc.tokenRepo.RedirectTouchToken(func(_ context.Context, _ *sql.DB, tokenID uint64) (sql.Result, error) {
+		return nil, nil
+	})
+	authSvc.orgRepo.RedirectGetMembership(func(_ context.Context, _ *sql.DB, orgID uint64, userID uint64) (*model.GetMembershipResult, error) {
+		switch orgID {
+		case 3:
+			return &model.GetMembershipResult{Admin: true}, nil
+		case 4:
+			return &model.GetMembershipResult{}, nil
+		default:
+			return nil, sql.ErrNoRows
+		}
+	})
+	resolver := tenant.NewResolver(nil)
+	resolver.OrgsRepo().RedirectGetOrganization(func(_ context.Context, _ *sql.DB, slug string) (*model.GetOrganizationResult, error) {
+		ids := map[string]uint64{"acme": 3, "globex": 4, "initech": 5}
+		return &model.GetOrganizationResult{OrgID: ids[slug], Slug: slug}, nil
+	})
+
+	tests := map[string]struct {
+		path           string
+		expectedStatus int
+		expectedID     uint64
+		expectedAdmin  bool
+		expectedAuth   string // feature:authz
+	}{
+		"No organization": {
+			path:           "/api/v1/me",
+			expectedStatus: http.StatusOK,
+			expectedAuth:   "reports", // feature:authz
+		},
+		"Admin member": {
+			path:           "/org/acme/api/v1/me",
+			expectedStatus: http.StatusOK,
+			expectedID:     3,
+			expectedAdmin:  true,
+			expectedAuth:   "billing", // feature:authz
+		},
+		"Member": {
+			path:           "/org/globex/api/v1/me",
+			expectedStatus: http.StatusOK,
+			expectedID:     4,
+			expectedAuth:   "billing", // feature:authz
+		},
+		"Not a member": {
+			path:           "/org/initech/api/v1/me",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var called bool
+			handler := resolver.Middleware()(authSvc.RequireBearer()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				details, ok := GetSessionUser(r)
+				assert.True(t, ok)
+				assert.Equal(t, tc.expectedID, details.TenantID)
+				assert.Equal(t, tc.expectedAdmin, details.TenantAdmin)
+				assert.True(t, details.HasAuth(tc.expectedAuth)) // feature:authz
+				_, scoped := model.TenantID(r.Context())
+				assert.False(t, scoped, "Only the organization's own routes should be scoped to it")
+			})))
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus == http.StatusOK, called, "Requests from users that aren't members shouldn't continue")
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"strings"
+	"time"
+	"yourapp/feature/audit"
+	"yourapp/feature/model"
+	"yourapp/foundation/httperr"
+	"yourapp/foundation/metrics"
+	"yourapp/foundation/requestid"
+)
+
+const (
+	// TokenPrefix marks API tokens issued by this app, which makes them easy to spot in secret scanners.
+	TokenPrefix = "pat_"
+	// tokenHintLen is the number of characters after the prefix that are kept as a hint.
+	tokenHintLen = 6
+)
+
+var (
+	ErrTokenName       = errors.New("token name is required")
+	ErrScopeNotGranted = errors.New("token scope is not granted to the user") // feature:authz
+	ErrAdminNotGranted = errors.New("only admins can mint admin tokens")
+)
+
+var bearerRejections = metrics.NewCounter("auth_bearer_rejections_total", "Requests rejected because of a missing or invalid API token")
+
+// GenerateToken creates a new random API token, along with the hash that should be stored and a hint to show to the user.
+func GenerateToken() (token string, hash string, hint string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token = TokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), token[:len(TokenPrefix)+tokenHintLen], nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash of a token.
+// Tokens have enough entropy that a slow password hash isn't needed, and a fast hash allows lookup by hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenRequest describes a token to mint.
+type TokenRequest struct {
+	Username string
+	Name     string
+	// feature:authz begin
+
+	// Scopes are authorization names that the token may use, which must be granted to the user.
+	Scopes []string
+	// feature:authz end
+
+	// Admin allows the token to act as an admin, which requires the user to be an admin.
+	Admin bool
+	// TTL is how long the token is valid, or zero for a token that doesn't expire.
+	TTL time.Duration
+}
+
+// MintedToken is returned from [Service.MintToken].
+// The Token is not stored, so it must be shown to the user right away.
+type MintedToken struct {
+	TokenID uint64
+	Token   string
+	Hint    string
+}
+
+// MintToken creates a new API token for a user.
+func (s *Service) MintToken(ctx context.Context, by string, req TokenRequest) (*MintedToken, error) {
+	if len(strings.TrimSpace(req.Name)) == 0 {
+		return nil, ErrTokenName
+	}
+	user, err := s.userRepo.GetUser(ctx, s.pool, req.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user '%s': %w", req.Username, err)
+	}
+	if req.Admin && !user.Admin {
+		return nil, ErrAdminNotGranted
+	}
+	// feature:authz begin
+	granted, err := s.userRepo.UserAuth(ctx, s.pool, user.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authorizations for user '%s': %w", req.Username, err)
+	}
+	var authIDs []uint64
+	for _, scope := range req.Scopes {
+		var found bool
+		for _, auth := range granted {
+			if strings.EqualFold(auth.Auth, scope) {
+				authIDs = append(authIDs, auth.Id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrScopeNotGranted, scope)
+		}
+	}
+	// feature:authz end
+
+	token, hash, hint, err := GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	params := model.CreateTokenParams{
+		UserID:    user.UserID,
+		Name:      req.Name,
+		TokenHash: hash,
+		Hint:      hint,
+		Admin:     req.Admin,
+		AuthIDs:   authIDs, // feature:authz
+	}
+	if req.TTL > 0 {
+		expires := time.Now().Add(req.TTL)
+		params.ExpiresAt = &expires
+	}
+	result, err := s.tokenRepo.CreateToken(ctx, s.pool, params)
+	if err != nil {
+		return nil, err
+	}
+	s.log.Postf(ctx, by, "Minted token %d (%s) for user '%s' with scopes %v", result.TokenID, hint, req.Username, req.Scopes) // feature:authz
+	// feature:!authz: s.log.Postf(ctx, by, "Minted token %d (%s) for user '%s'", result.TokenID, hint, req.Username)
+	return &MintedToken{TokenID: result.TokenID, Token: token, Hint: hint}, nil
+}
+
+// RequireBearer authenticates requests with an API token given in the Authorization header.
+// The request gets the same [Details] as [Service.RequireSession], with the authorizations limited to the token's scopes.
+// Failed requests get a 401 JSON error, since these are meant for API clients rather than browsers.
+func (s *Service) RequireBearer() httpx.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				s.rejectBearer(w, r, `Bearer`, "missing bearer token")
+				return
+			}
+			result, err := s.tokenRepo.GetTokenUser(r.Context(), s.pool, HashToken(token))
+			if err != nil || result == nil {
+				s.log.Postf(r.Context(), audit.AnonymousUser, "Rejected API token: %v", err)
+				s.rejectBearer(w, r, `Bearer error="invalid_token"`, "invalid bearer token")
+				return
+			}
+			// feature:authz begin
+			auths, err := s.tokenRepo.TokenAuth(r.Context(), s.pool, result.TokenID, result.UserID)
+			if err != nil {
+				s.log.Postf(r.Context(), result.Username, "Failed to retrieve authorizations for token %d: %v", result.TokenID, err)
+				s.fail(w, r, fmt.Errorf("failed to retrieve token authorizations: %w", err))
+				return
+			}
+			// feature:authz end
+			if _, err := s.tokenRepo.TouchToken(r.Context(), s.pool, result.TokenID); err != nil {
+				s.log.Postf(r.Context(), result.Username, "Failed to update token %d last use: %v", result.TokenID, err)
+			}
+			details := Details{
+				UserID:   result.UserID,
+				Username: result.Username,
+				Admin:    result.Admin,
+				TokenID:  result.TokenID,
+				Authz:    auths, // feature:authz
+			}
+			if err := s.setTenant(r, &details); err != nil {
+				s.fail(w, r, err)
+				return
+			}
+			s.log.Postf(r.Context(), result.Username, "%s %s (token %d)", r.Method, r.URL.Path, result.TokenID)
+			next.ServeHTTP(w, setSessionDetails(r, details))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return "", false
+	}
+	return token, true
+}
+
+func (s *Service) rejectBearer(w http.ResponseWriter, r *http.Request, challenge, message string) {
+	bearerRejections.Inc()
+	w.Header().Set("WWW-Authenticate", challenge)
+	httperr.WriteJSON(w, httperr.Unauthorized(message), requestid.Get(r.Context()))
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"yourapp/feature/model"
+)
+
+func TestGenerateToken(t *testing.T) {
+	token, hash, hint, err := GenerateToken()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, TokenPrefix))
+	assert.True(t, strings.HasPrefix(token, hint))
+	assert.Len(t, hint, len(TokenPrefix)+tokenHintLen)
+	assert.Equal(t, HashToken(token), hash)
+	assert.NotContains(t, hash, token)
+
+	other, _, _, err := GenerateToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestService_RequireBearer(t *testing.T) {
+	token, hash, _, err := GenerateToken()
+	assert.NoError(t, err)
+
+	authSvc := testAuthService(t, context.Background())
+	var touched int
+	authSvc.tokenRepo.RedirectGetTokenUser(func(_ context.Context, _ *sql.DB, tokenHash string) (*model.GetTokenUserResult, error) {
+		if tokenHash != hash {
+			return nil, sql.ErrNoRows
+		}
+		return &model.GetTokenUserResult{TokenID: 5, UserID: 1, Username: "Bob"}, nil
+	})
+	// feature:authz begin
+	authSvc.tokenRepo.RedirectTokenAuth(func(_ context.Context, _ *sql.DB, tokenID uint64, userID uint64) ([]*model.UserAuthResult, error) {
+		assert.Equal(t, uint64(5), tokenID)
+		assert.Equal(t, uint64(1), userID)
+		return []*model.UserAuthResult{{Id: 1, Auth: "reports"}}, nil
+	})
+	// feature:authz end
+	authSvc.tokenRepo.RedirectTouchToken(func(_ context.Context, _ *sql.DB, tokenID uint64) (sql.Result, error) {
+		touched++
+		return nil, nil
+	})
+
+	tests := map[string]struct {
+		header         string
+		auth           string // feature:authz
+		expectedStatus int
+		expectedTouch  int
+	}{
+		"Missing header": {
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"Wrong scheme": {
+			header:         "Basic " + token,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"Unknown token": {
+			header:         "Bearer " + TokenPrefix + "unknown",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"Valid token": {
+			header:         "Bearer " + token,
+			expectedStatus: http.StatusOK,
+			expectedTouch:  1,
+		},
+		// feature:authz begin
+		"Valid token with scope": {
+			header:         "bearer " + token,
+			auth:           "reports",
+			expectedStatus: http.StatusOK,
+			expectedTouch:  1,
+		},
+		"Valid token out of scope": {
+			header:         "Bearer " + token,
+			auth:           "billing",
+			expectedStatus: http.StatusForbidden,
+			expectedTouch:  1,
+		},
+		// feature:authz end
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			touched = 0
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				details, ok := GetSessionUser(r)
+				assert.True(t, ok)
+				assert.Equal(t, "Bob", details.Username)
+				assert.Equal(t, uint64(5), details.TokenID)
+				assert.True(t, details.HasAuth("reports")) // feature:authz
+				assert.Empty(t, details.SessionKey)
+			})
+			// feature:authz begin
+			if len(tc.auth) > 0 {
+				handler = authSvc.RequireAuth(tc.auth)(handler)
+			}
+			// feature:authz end
+			handler = authSvc.RequireBearer()(handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			if len(tc.header) > 0 {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedTouch, touched)
+			if tc.expectedStatus == http.StatusUnauthorized {
+				assert.True(t, strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Bearer"))
+				assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+			}
+		})
+	}
+}
+
+func TestService_RequireCSRF_Bearer(t *testing.T) {
+	authSvc := testAuthService(t, context.Background())
+	var called bool
+	handler := authSvc.RequireCSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/things", nil)
+	req = setSessionDetails(req, Details{UserID: 1, Username: "Bob", TokenID: 5})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.True(t, called, "Bearer authenticated requests don't need a CSRF token")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestService_MintToken(t *testing.T) {
+	authSvc := testAuthService(t, context.Background())
+	authSvc.userRepo.RedirectGetUser(func(_ context.Context, _ *sql.DB, username string) (*model.GetUserResult, error) {
+		return &model.GetUserResult{UserID: 1, Username: username, Admin: username == "admin"}, nil
+	})
+	// feature:authz begin
+	authSvc.userRepo.RedirectUserAuth(func(_ context.Context, _ *sql.DB, userID uint64) ([]*model.UserAuthResult, error) {
+		return []*model.UserAuthResult{{Id: 3, Auth: "reports"}}, nil
+	})
+	// feature:authz end
+	var created model.CreateTokenParams
+	authSvc.tokenRepo.RedirectCreateToken(func(_ context.Context, _ *sql.DB, params model.CreateTokenParams) (*model.CreateTokenResult, error) {
+		created = params
+		return &model.CreateTokenResult{TokenID: 7}, nil
+	})
+
+	tests := map[string]struct {
+		req         TokenRequest
+		expectedErr error
+		expectedIDs []uint64 // feature:authz
+	}{
+		// feature:authz begin
+		"Granted scope": {
+			req:         TokenRequest{Username: "Bob", Name: "ci", Scopes: []string{"Reports"}},
+			expectedIDs: []uint64{3},
+		},
+		// feature:authz end
+		"No scopes": {
+			req: TokenRequest{Username: "Bob", Name: "ci"},
+		},
+		"Missing name": {
+			req:         TokenRequest{Username: "Bob"},
+			expectedErr: ErrTokenName,
+		},
+		// feature:authz begin
+		"Scope not granted": {
+			req:         TokenRequest{Username: "Bob", Name: "ci", Scopes: []string{"billing"}},
+			expectedErr: ErrScopeNotGranted,
+		},
+		// feature:authz end
+		"Admin not granted": {
+			req:         TokenRequest{Username: "Bob", Name: "ci", Admin: true},
+			expectedErr: ErrAdminNotGranted,
+		},
+		"Admin granted": {
+			req: TokenRequest{Username: "admin", Name: "ci", Admin: true},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			created = model.CreateTokenParams{}
+			minted, err := authSvc.MintToken(context.Background(), "admin", tc.req)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(7), minted.TokenID)
+			assert.Equal(t, HashToken(minted.Token), created.TokenHash, "Only the hash should be stored")
+			assert.Equal(t, tc.expectedIDs, created.AuthIDs) // feature:authz
+			assert.Equal(t, tc.req.Admin, created.Admin)
+		})
+	}
+}
@@ -0,0 +1,460 @@
+// Package backup dumps the application's tables to a portable NDJSON format, restores them into another database,
+// and seeds a database with fixtures for development.
+//
+// A dump starts with a [Header] record, and has a [Record] on each line after it.
+// Rows refer to each other by the IDs they had when dumped, and [Restore] maps those to the IDs they get when inserted,
+// so a dump can be restored into a database that already has data.
+//
+// Version 2 added organizations, their members and member grants, and the organization of audit entries.
+// Version 1 dumps can still be restored.
+package backup
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"yourapp/feature/model"
+)
+
+const (
+	Format = "yourapp-backup"
+	// Version is incremented when the format changes in a way that older versions of the app can't restore.
+	Version = 2
+
+	KindHeader        = "header"
+	KindUser          = "user"
+	KindAuthorization = "authorization" // feature:authz
+	KindGrant         = "grant"         // feature:authz
+	KindOrganization  = "organization"
+	KindMembership    = "membership"
+	KindMemberGrant   = "memberGrant" // feature:authz
+	KindAudit         = "audit"       // feature:audit
+)
+
+// Record is one line of a dump, with Data holding the type that matches its Kind.
+type Record struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type Header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+type User struct {
+	ID              uint64     `json:"id"`
+	Username        string     `json:"username"`
+	Admin           bool       `json:"admin"`
+	ServiceAccount  bool       `json:"serviceAccount"`
+	Email           *string    `json:"email,omitempty"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	PassHash        string     `json:"passHash"`
+}
+
+// feature:authz begin
+
+type Authorization struct {
+	ID          uint64 `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Grant struct {
+	UserID  uint64     `json:"userId"`
+	AuthID  uint64     `json:"authId"`
+	Granted time.Time  `json:"granted"`
+	Revoked *time.Time `json:"revoked,omitempty"`
+}
+
+// feature:authz end
+
+type Organization struct {
+	ID        uint64    `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Membership struct {
+	OrgID    uint64    `json:"orgId"`
+	UserID   uint64    `json:"userId"`
+	Admin    bool      `json:"admin"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// feature:authz begin
+
+type MemberGrant struct {
+	OrgID   uint64     `json:"orgId"`
+	UserID  uint64     `json:"userId"`
+	AuthID  uint64     `json:"authId"`
+	Granted time.Time  `json:"granted"`
+	Revoked *time.Time `json:"revoked,omitempty"`
+}
+
+// feature:authz end
+
+// feature:audit begin
+
+type AuditEntry struct {
+	Username  string    `json:"username"`
+	Action    string    `json:"action"`
+	EventTime time.Time `json:"eventTime"`
+	// OrgID is the organization that the entry was recorded in, if any.
+	OrgID *uint64 `json:"orgId,omitempty"`
+}
+
+// feature:audit end
+
+// Stats counts the rows that were dumped, restored, or seeded.
+type Stats struct {
+	Users          int
+	Authorizations int // feature:authz
+	Grants         int // feature:authz
+	Organizations  int
+	Memberships    int
+	MemberGrants   int // feature:authz
+	AuditEntries   int // feature:audit
+}
+
+func (s *Stats) String() string {
+	parts := []string{fmt.Sprintf("%d users", s.Users)}
+	parts = append(parts, fmt.Sprintf("%d authorizations", s.Authorizations), fmt.Sprintf("%d grants", s.Grants)) // feature:authz
+	parts = append(parts, fmt.Sprintf("%d organizations", s.Organizations), fmt.Sprintf("%d memberships", s.Memberships))
+	parts = append(parts, fmt.Sprintf("%d member grants", s.MemberGrants)) // feature:authz
+	parts = append(parts, fmt.Sprintf("%d audit entries", s.AuditEntries)) // feature:audit
+	return strings.Join(parts, ", ")
+}
+
+// Dump writes every user, authorization, grant, organization, membership, and audit entry to w, as they were at a single point in time.
+// Password hashes are included, so the dump should be kept as safe as the database itself.
+func Dump(ctx context.Context, db *sql.DB, w io.Writer) (*Stats, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in Dump: %w", err)
+	}
+	bw := bufio.NewWriter(w)
+	stats, err := dump(tx, bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	return stats, tx.Commit()
+}
+
+func dump(tx *sql.Tx, w io.Writer) (*Stats, error) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	write := func(kind string, data any) error {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s record: %w", kind, err)
+		}
+		if err := enc.Encode(Record{Kind: kind, Data: raw}); err != nil {
+			return fmt.Errorf("failed to write %s record: %w", kind, err)
+		}
+		return nil
+	}
+
+	var stats Stats
+	if err := write(KindHeader, Header{Format: Format, Version: Version, Created: time.Now().UTC()}); err != nil {
+		return nil, err
+	}
+	users, err := model.ExportUsersTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		err := write(KindUser, User{
+			ID:              u.UserID,
+			Username:        u.Username,
+			Admin:           u.Admin,
+			ServiceAccount:  u.ServiceAccount,
+			Email:           u.Email,
+			EmailVerifiedAt: u.EmailVerifiedAt,
+			PassHash:        u.PassHash,
+		})
+		if err != nil {
+			return nil, err
+		}
+		stats.Users++
+	}
+	// feature:authz begin
+	auths, err := model.ExportAuthorizationsTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range auths {
+		if err := write(KindAuthorization, Authorization{ID: a.AuthID, Name: a.Name, Description: a.Description}); err != nil {
+			return nil, err
+		}
+		stats.Authorizations++
+	}
+	grants, err := model.ExportGrantsTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range grants {
+		if err := write(KindGrant, Grant{UserID: g.UserID, AuthID: g.AuthID, Granted: g.Granted, Revoked: g.Revoked}); err != nil {
+			return nil, err
+		}
+		stats.Grants++
+	}
+	// feature:authz end
+	orgs, err := model.ExportOrganizationsTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orgs {
+		if err := write(KindOrganization, Organization{ID: o.OrgID, Slug: o.Slug, Name: o.Name, CreatedAt: o.CreatedAt}); err != nil {
+			return nil, err
+		}
+		stats.Organizations++
+	}
+	memberships, err := model.ExportMembershipsTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range memberships {
+		if err := write(KindMembership, Membership{OrgID: m.OrgID, UserID: m.UserID, Admin: m.Admin, JoinedAt: m.JoinedAt}); err != nil {
+			return nil, err
+		}
+		stats.Memberships++
+	}
+	// feature:authz begin
+	memberGrants, err := model.ExportMemberGrantsTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range memberGrants {
+		if err := write(KindMemberGrant, MemberGrant{OrgID: g.OrgID, UserID: g.UserID, AuthID: g.AuthID, Granted: g.Granted, Revoked: g.Revoked}); err != nil {
+			return nil, err
+		}
+		stats.MemberGrants++
+	}
+	// feature:authz end
+	// feature:audit begin
+	entries, err := model.ExportAuditLogTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if err := write(KindAudit, AuditEntry{Username: e.Username, Action: e.Action, EventTime: e.EventTime, OrgID: e.OrgID}); err != nil {
+			return nil, err
+		}
+		stats.AuditEntries++
+	}
+	// feature:audit end
+	return &stats, nil
+}
+
+// Restore loads a dump written by [Dump] in a single transaction, so nothing is changed if any record fails.
+// Users, authorizations, and organizations that already exist with the same name are updated to match the dump,
+// and audit entries that are already in the log aren't added again.
+func Restore(ctx context.Context, db *sql.DB, r io.Reader) (*Stats, error) {
+	dec := json.NewDecoder(r)
+	if err := readHeader(dec); err != nil {
+		return nil, err
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in Restore: %w", err)
+	}
+	stats, err := restore(tx, dec)
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	return stats, tx.Commit()
+}
+
+// readHeader makes sure that the dump is in a format and version that can be restored.
+func readHeader(dec *json.Decoder) error {
+	var (
+		rec    Record
+		header Header
+	)
+	if err := dec.Decode(&rec); err != nil {
+		return fmt.Errorf("failed to read backup header: %w", err)
+	}
+	if rec.Kind != KindHeader {
+		return fmt.Errorf("not a backup, the first record is '%s' instead of '%s'", rec.Kind, KindHeader)
+	}
+	if err := json.Unmarshal(rec.Data, &header); err != nil {
+		return fmt.Errorf("failed to read backup header: %w", err)
+	}
+	if header.Format != Format {
+		return fmt.Errorf("not a backup, the format is '%s' instead of '%s'", header.Format, Format)
+	}
+	if header.Version < 1 || header.Version > Version {
+		return fmt.Errorf("backup version %d is not supported, this version of the app restores up to version %d", header.Version, Version)
+	}
+	return nil
+}
+
+func restore(tx *sql.Tx, dec *json.Decoder) (*Stats, error) {
+	var (
+		stats Stats
+		// Each maps an ID in the dump to the ID of the same row in this database.
+		userIDs = map[uint64]uint64{}
+		authIDs = map[uint64]uint64{} // feature:authz
+		orgIDs  = map[uint64]uint64{}
+	)
+	// The header was record 1.
+	for n := 2; ; n++ {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return &stats, nil
+			}
+			return nil, fmt.Errorf("failed to read record %d: %w", n, err)
+		}
+		switch rec.Kind {
+		case KindUser:
+			var u User
+			if err := decodeRecord(n, rec, &u); err != nil {
+				return nil, err
+			}
+			if len(u.Username) == 0 {
+				return nil, fmt.Errorf("record %d: user %d has no username", n, u.ID)
+			}
+			id, err := model.ImportUserTx(tx, model.ImportUserParams{
+				Username:        u.Username,
+				Admin:           u.Admin,
+				ServiceAccount:  u.ServiceAccount,
+				Email:           u.Email,
+				EmailVerifiedAt: u.EmailVerifiedAt,
+				PassHash:        u.PassHash,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+			userIDs[u.ID] = id
+			stats.Users++
+		// feature:authz begin
+		case KindAuthorization:
+			var a Authorization
+			if err := decodeRecord(n, rec, &a); err != nil {
+				return nil, err
+			}
+			id, err := model.ImportAuthorizationTx(tx, a.Name, a.Description)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+			authIDs[a.ID] = id
+			stats.Authorizations++
+		case KindGrant:
+			var g Grant
+			if err := decodeRecord(n, rec, &g); err != nil {
+				return nil, err
+			}
+			userID, ok := userIDs[g.UserID]
+			if !ok {
+				return nil, fmt.Errorf("record %d: grant refers to user %d, which isn't in an earlier record", n, g.UserID)
+			}
+			authID, ok := authIDs[g.AuthID]
+			if !ok {
+				return nil, fmt.Errorf("record %d: grant refers to authorization %d, which isn't in an earlier record", n, g.AuthID)
+			}
+			if _, err := model.ImportGrantTx(tx, userID, authID, g.Granted, g.Revoked); err != nil {
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+			stats.Grants++
+		// feature:authz end
+		case KindOrganization:
+			var o Organization
+			if err := decodeRecord(n, rec, &o); err != nil {
+				return nil, err
+			}
+			id, err := model.ImportOrganizationTx(tx, o.Slug, o.Name)
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+			orgIDs[o.ID] = id
+			stats.Organizations++
+		case KindMembership:
+			var m Membership
+			if err := decodeRecord(n, rec, &m); err != nil {
+				return nil, err
+			}
+			orgID, ok := orgIDs[m.OrgID]
+			if !ok {
+				return nil, fmt.Errorf("record %d: membership refers to organization %d, which isn't in an earlier record", n, m.OrgID)
+			}
+			userID, ok := userIDs[m.UserID]
+			if !ok {
+				return nil, fmt.Errorf("record %d: membership refers to user %d, which isn't in an earlier record", n, m.UserID)
+			}
+			if _, err := model.ImportMembershipTx(tx, orgID, userID, m.Admin, m.JoinedAt); err != nil {
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+			stats.Memberships++
+		// feature:authz begin
+		case KindMemberGrant:
+			var g MemberGrant
+			if err := decodeRecord(n, rec, &g); err != nil {
+				return nil, err
+			}
+			orgID, ok := orgIDs[g.OrgID]
+			if !ok {
+				return nil, fmt.Errorf("record %d: member grant refers to organization %d, which isn't in an earlier record", n, g.OrgID)
+			}
+			userID, ok := userIDs[g.UserID]
+			if !ok {
+				return nil, fmt.Errorf("record %d: member grant refers to user %d, which isn't in an earlier record", n, g.UserID)
+			}
+			authID, ok := authIDs[g.AuthID]
+			if !ok {
+				return nil, fmt.Errorf("record %d: member grant refers to authorization %d, which isn't in an earlier record", n, g.AuthID)
+			}
+			if _, err := model.ImportMemberGrantTx(tx, orgID, userID, authID, g.Granted, g.Revoked); err != nil {
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+			stats.MemberGrants++
+		// feature:authz end
+		// feature:audit begin
+		case KindAudit:
+			var e AuditEntry
+			if err := decodeRecord(n, rec, &e); err != nil {
+				return nil, err
+			}
+			var orgID *uint64
+			if e.OrgID != nil {
+				id, ok := orgIDs[*e.OrgID]
+				if !ok {
+					return nil, fmt.Errorf("record %d: audit entry refers to organization %d, which isn't in an earlier record", n, *e.OrgID)
+				}
+				orgID = &id
+			}
+			if _, err := model.ImportAuditEntryTx(tx, e.Username, e.Action, e.EventTime, orgID); err != nil {
+				return nil, fmt.Errorf("record %d: %w", n, err)
+			}
+			stats.AuditEntries++
+		// feature:audit end
+		default:
+			return nil, fmt.Errorf("record %d has unknown kind '%s'", n, rec.Kind)
+		}
+	}
+}
+
+func decodeRecord(n int, rec Record, v any) error {
+	if err := json.Unmarshal(rec.Data, v); err != nil {
+		return fmt.Errorf("failed to read %s record %d: %w", rec.Kind, n, err)
+	}
+	return nil
+}
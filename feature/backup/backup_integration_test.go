@@ -0,0 +1,152 @@
+//go:build integration
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"yourapp/feature/model"
+	"yourapp/foundation/pgtest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m, filepath.Join("..", "..", "infra", "pg", "sql")))
+}
+
+func testFixtures() *Fixtures {
+	return &Fixtures{
+		Authorizations: []FixtureAuthorization{{Name: "reports", Description: "Views reports"}, {Name: "billing"}}, // feature:authz
+		Users: []FixtureUser{
+			{Username: "alice", Password: "correct horse", Admin: true, Authorizations: []string{"reports", "billing"}}, // feature:authz
+			// feature:!authz: {Username: "alice", Password: "correct horse", Admin: true},
+			{Username: "bob", Password: "battery staple"},
+		},
+		Organizations: []FixtureOrganization{
+			{Slug: "acme", Name: "Acme", Members: []FixtureMember{
+				{Username: "alice", Admin: true},
+				{Username: "bob", Authorizations: []string{"reports"}}, // feature:authz
+				// feature:!authz: {Username: "bob"},
+			}},
+		},
+	}
+}
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	stats, err := Seed(ctx, db, testFixtures())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Users)
+	assert.Equal(t, 2, stats.Memberships)
+
+	alice, err := model.GetUser(ctx, db, "alice")
+	require.NoError(t, err)
+	assert.True(t, alice.Admin)
+	org, err := model.GetOrganization(ctx, db, "acme")
+	require.NoError(t, err)
+	membership, err := model.GetMembership(ctx, db, org.OrgID, alice.UserID)
+	require.NoError(t, err)
+	assert.True(t, membership.Admin)
+	check, err := model.CheckPassword(ctx, db, "bob", "battery staple")
+	require.NoError(t, err)
+	assert.True(t, check.Matches)
+	// feature:authz begin
+	grants, err := model.UserAuth(ctx, db, alice.UserID)
+	require.NoError(t, err)
+	assert.Len(t, grants, 2)
+
+	fixtures := testFixtures()
+	fixtures.Users = append(fixtures.Users, FixtureUser{Username: "carol", Password: "secret", Authorizations: []string{"missing"}})
+	_, err = Seed(ctx, db, fixtures)
+	assert.ErrorContains(t, err, "user 'carol' is granted authorization 'missing', which doesn't exist")
+	_, err = model.GetUser(ctx, db, "carol")
+	assert.Error(t, err, "Nothing should be seeded if any fixture fails")
+	// feature:authz end
+}
+
+func TestDumpRestore(t *testing.T) {
+	ctx := context.Background()
+	src := pgtest.DB(t)
+	_, err := Seed(ctx, src, testFixtures())
+	require.NoError(t, err)
+	// feature:audit begin
+	_, err = model.InsertAuditLog(ctx, src, "alice", "Did something")
+	require.NoError(t, err)
+	srcOrg, err := model.GetOrganization(ctx, src, "acme")
+	require.NoError(t, err)
+	_, err = model.InsertAuditLog(model.WithTenant(ctx, srcOrg.OrgID), src, "alice", "Did something in acme")
+	require.NoError(t, err)
+	// feature:audit end
+
+	var dump bytes.Buffer
+	stats, err := Dump(ctx, src, &dump)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Users)
+
+	// The destination already has a user and organization, so the restored rows get different IDs.
+	dest := pgtest.DB(t)
+	_, err = model.CreateUser(ctx, dest, "zed", "secret")
+	require.NoError(t, err)
+	_, err = model.CreateOrganization(ctx, dest, "globex", "Globex")
+	require.NoError(t, err)
+	restored, err := Restore(ctx, dest, bytes.NewReader(dump.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, stats, restored)
+
+	srcAlice, err := model.GetUser(ctx, src, "alice")
+	require.NoError(t, err)
+	alice, err := model.GetUser(ctx, dest, "alice")
+	require.NoError(t, err)
+	assert.NotEqual(t, srcAlice.UserID, alice.UserID)
+	assert.True(t, alice.Admin)
+	check, err := model.CheckPassword(ctx, dest, "alice", "correct horse")
+	require.NoError(t, err)
+	assert.True(t, check.Matches, "The password hash should be restored as is")
+	// feature:authz begin
+	grants, err := model.UserAuth(ctx, dest, alice.UserID)
+	require.NoError(t, err)
+	assert.Len(t, grants, 2)
+	// feature:authz end
+	org, err := model.GetOrganization(ctx, dest, "acme")
+	require.NoError(t, err)
+	members, err := model.GetMembers(ctx, dest, org.OrgID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, alice.UserID, members[0].UserID)
+	assert.True(t, members[0].Admin)
+	// feature:authz begin
+	bob, err := model.GetUser(ctx, dest, "bob")
+	require.NoError(t, err)
+	memberGrants, err := model.MemberAuth(ctx, dest, org.OrgID, bob.UserID)
+	require.NoError(t, err)
+	require.Len(t, memberGrants, 1)
+	assert.Equal(t, "reports", memberGrants[0].Auth)
+	// feature:authz end
+
+	// Restoring again updates the same rows.
+	_, err = Restore(ctx, dest, bytes.NewReader(dump.Bytes()))
+	require.NoError(t, err)
+	users, err := model.GetAllUsers(ctx, dest)
+	require.NoError(t, err)
+	assert.Len(t, users, 3)
+	// feature:audit begin
+	entries, err := model.GetLatestLogEntries(ctx, dest, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	// Only the organization's entry is visible within it.
+	entries, err = model.GetLatestLogEntries(model.WithTenant(ctx, org.OrgID), dest, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Did something in acme", entries[0].Action)
+	// feature:audit end
+	// feature:authz begin
+
+	_, err = Restore(ctx, dest, bytes.NewReader(append(dump.Bytes(), `{"kind":"grant","data":{"userId":999,"authId":1}}`...)))
+	assert.ErrorContains(t, err, "grant refers to user 999")
+	// feature:authz end
+}
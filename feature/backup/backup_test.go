@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestRestore_header(t *testing.T) {
+	tests := map[string]struct {
+		dump      string
+		expectErr string
+	}{
+		"Empty": {
+			dump:      "",
+			expectErr: "failed to read backup header",
+		},
+		"Not NDJSON": {
+			dump:      "username,admin\nalice,true\n",
+			expectErr: "failed to read backup header",
+		},
+		"No header": {
+			dump:      `{"kind":"user","data":{"id":1,"username":"alice"}}`,
+			expectErr: "the first record is 'user' instead of 'header'",
+		},
+		"Other format": {
+			dump:      `{"kind":"header","data":{"format":"pg_dump","version":1}}`,
+			expectErr: "the format is 'pg_dump' instead of 'yourapp-backup'",
+		},
+		"Newer version": {
+			dump:      `{"kind":"header","data":{"format":"yourapp-backup","version":3}}`,
+			expectErr: "backup version 3 is not supported",
+		},
+		"No version": {
+			dump:      `{"kind":"header","data":{"format":"yourapp-backup"}}`,
+			expectErr: "backup version 0 is not supported",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The header is checked before the database is used.
+			_, err := Restore(context.Background(), nil, strings.NewReader(tc.dump))
+			assert.ErrorContains(t, err, tc.expectErr)
+		})
+	}
+}
+
+func TestReadFixtures(t *testing.T) {
+	tests := map[string]struct {
+		fixtures  string
+		expected  *Fixtures
+		expectErr string
+	}{
+		"Users": {
+			fixtures: `{"users":[{"username":"alice","password":"secret","admin":true},{"username":"bob","password":"secret"}]}`,
+			expected: &Fixtures{Users: []FixtureUser{
+				{Username: "alice", Password: "secret", Admin: true},
+				{Username: "bob", Password: "secret"},
+			}},
+		},
+		// feature:authz begin
+		"Authorizations": {
+			fixtures: `{"authorizations":[{"name":"reports","description":"Views reports"}],"users":[{"username":"alice","password":"secret","authorizations":["reports"]}]}`,
+			expected: &Fixtures{
+				Authorizations: []FixtureAuthorization{{Name: "reports", Description: "Views reports"}},
+				Users:          []FixtureUser{{Username: "alice", Password: "secret", Authorizations: []string{"reports"}}},
+			},
+		},
+		"Duplicate authorization": {
+			fixtures:  `{"authorizations":[{"name":"reports"},{"name":"reports"}]}`,
+			expectErr: "authorization 'reports' is declared more than once",
+		},
+		"Unnamed authorization": {
+			fixtures:  `{"authorizations":[{"description":"Views reports"}]}`,
+			expectErr: "authorization 1 has no name",
+		},
+		// feature:authz end
+		"Organizations": {
+			fixtures: `{"users":[{"username":"alice","password":"secret"}],"organizations":[{"slug":"acme","name":"Acme","members":[{"username":"alice","admin":true}]}]}`,
+			expected: &Fixtures{
+				Users:         []FixtureUser{{Username: "alice", Password: "secret"}},
+				Organizations: []FixtureOrganization{{Slug: "acme", Name: "Acme", Members: []FixtureMember{{Username: "alice", Admin: true}}}},
+			},
+		},
+		"Invalid slug": {
+			fixtures:  `{"organizations":[{"slug":"Acme Corp","name":"Acme"}]}`,
+			expectErr: "organization 1 has an invalid slug 'Acme Corp'",
+		},
+		"Duplicate organization": {
+			fixtures:  `{"organizations":[{"slug":"acme","name":"Acme"},{"slug":"acme","name":"Other"}]}`,
+			expectErr: "organization 'acme' is declared more than once",
+		},
+		"Undeclared member": {
+			fixtures:  `{"organizations":[{"slug":"acme","name":"Acme","members":[{"username":"alice"}]}]}`,
+			expectErr: "organization 'acme' has member 'alice', who isn't declared in the users",
+		},
+		"Unknown field": {
+			fixtures:  `{"users":[{"username":"alice","pasword":"secret"}]}`,
+			expectErr: `unknown field "pasword"`,
+		},
+		"Duplicate user": {
+			fixtures:  `{"users":[{"username":"alice","password":"secret"},{"username":"alice","password":"other"}]}`,
+			expectErr: "user 'alice' is declared more than once",
+		},
+		"No username": {
+			fixtures:  `{"users":[{"password":"secret"}]}`,
+			expectErr: "user 1 has no username",
+		},
+		"No password": {
+			fixtures:  `{"users":[{"username":"alice"}]}`,
+			expectErr: "user 'alice' has no password",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			fixtures, err := ReadFixtures(strings.NewReader(tc.fixtures))
+			if len(tc.expectErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, fixtures)
+		})
+	}
+}
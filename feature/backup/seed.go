@@ -0,0 +1,196 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv" // feature:authz
+	"yourapp/feature/model"
+	"yourapp/feature/tenant"
+)
+
+// Fixtures declares the users, authorizations, and organizations that [Seed] makes sure exist, for setting up a development database.
+type Fixtures struct {
+	Authorizations []FixtureAuthorization `json:"authorizations"` // feature:authz
+	Users          []FixtureUser          `json:"users"`
+	Organizations  []FixtureOrganization  `json:"organizations"`
+}
+
+// feature:authz begin
+
+type FixtureAuthorization struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// feature:authz end
+
+type FixtureUser struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Admin    bool   `json:"admin"`
+	// feature:authz begin
+
+	// Authorizations are granted to the user by name, and must either be in the fixtures or already exist.
+	Authorizations []string `json:"authorizations"`
+	// feature:authz end
+}
+
+type FixtureOrganization struct {
+	Slug    string          `json:"slug"`
+	Name    string          `json:"name"`
+	Members []FixtureMember `json:"members"`
+}
+
+// FixtureMember adds a user declared in the fixtures to an organization.
+type FixtureMember struct {
+	Username string `json:"username"`
+	Admin    bool   `json:"admin"`
+	// feature:authz begin
+
+	// Authorizations are granted to the member by name, and only apply in the organization.
+	Authorizations []string `json:"authorizations"`
+	// feature:authz end
+}
+
+// ReadFixtures decodes fixtures from JSON, rejecting fields it doesn't know so that typos aren't silently ignored.
+func ReadFixtures(r io.Reader) (*Fixtures, error) {
+	var fixtures Fixtures
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fixtures); err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	// feature:authz begin
+	authNames := map[string]bool{}
+	for i, a := range fixtures.Authorizations {
+		if len(a.Name) == 0 {
+			return nil, fmt.Errorf("authorization %d has no name", i+1)
+		}
+		if authNames[a.Name] {
+			return nil, fmt.Errorf("authorization '%s' is declared more than once", a.Name)
+		}
+		authNames[a.Name] = true
+	}
+	// feature:authz end
+	usernames := map[string]bool{}
+	for i, u := range fixtures.Users {
+		if len(u.Username) == 0 {
+			return nil, fmt.Errorf("user %d has no username", i+1)
+		}
+		if usernames[u.Username] {
+			return nil, fmt.Errorf("user '%s' is declared more than once", u.Username)
+		}
+		usernames[u.Username] = true
+		if len(u.Password) == 0 {
+			return nil, fmt.Errorf("user '%s' has no password", u.Username)
+		}
+	}
+	slugs := map[string]bool{}
+	for i, o := range fixtures.Organizations {
+		if !tenant.ValidSlug(o.Slug) {
+			return nil, fmt.Errorf("organization %d has an invalid slug '%s'", i+1, o.Slug)
+		}
+		if slugs[o.Slug] {
+			return nil, fmt.Errorf("organization '%s' is declared more than once", o.Slug)
+		}
+		slugs[o.Slug] = true
+		if len(o.Name) == 0 {
+			return nil, fmt.Errorf("organization '%s' has no name", o.Slug)
+		}
+		for _, m := range o.Members {
+			if !usernames[m.Username] {
+				return nil, fmt.Errorf("organization '%s' has member '%s', who isn't declared in the users", o.Slug, m.Username)
+			}
+		}
+	}
+	return &fixtures, nil
+}
+
+// Seed creates the fixtures in a single transaction.
+// Users that already exist get the password and admin flag from the fixtures, so seeding again resets them.
+func Seed(ctx context.Context, db *sql.DB, fixtures *Fixtures) (*Stats, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in Seed: %w", err)
+	}
+	stats, err := seed(tx, fixtures)
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	return stats, tx.Commit()
+}
+
+func seed(tx *sql.Tx, fixtures *Fixtures) (*Stats, error) {
+	var stats Stats
+	// feature:authz begin
+	for _, a := range fixtures.Authorizations {
+		if _, err := model.ImportAuthorizationTx(tx, a.Name, a.Description); err != nil {
+			return nil, fmt.Errorf("failed to seed authorization '%s': %w", a.Name, err)
+		}
+		stats.Authorizations++
+	}
+	auths, err := model.ExportAuthorizationsTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	authIDs := map[string]uint64{}
+	for _, a := range auths {
+		authIDs[a.Name] = a.AuthID
+	}
+	// feature:authz end
+	userIDs := map[string]uint64{}
+	for _, u := range fixtures.Users {
+		userID, err := model.SeedUserTx(tx, u.Username, u.Password, u.Admin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seed user '%s': %w", u.Username, err)
+		}
+		userIDs[u.Username] = userID
+		stats.Users++
+		// feature:authz begin
+		for _, name := range u.Authorizations {
+			authID, ok := authIDs[name]
+			if !ok {
+				return nil, fmt.Errorf("user '%s' is granted authorization '%s', which doesn't exist", u.Username, name)
+			}
+			if _, err := model.GrantAuthTx(tx, strconv.FormatUint(userID, 10), strconv.FormatUint(authID, 10)); err != nil {
+				return nil, fmt.Errorf("failed to grant '%s' to user '%s': %w", name, u.Username, err)
+			}
+			stats.Grants++
+		}
+		// feature:authz end
+	}
+	for _, o := range fixtures.Organizations {
+		orgID, err := model.ImportOrganizationTx(tx, o.Slug, o.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seed organization '%s': %w", o.Slug, err)
+		}
+		stats.Organizations++
+		for _, m := range o.Members {
+			userID := userIDs[m.Username]
+			if _, err := model.SeedMembershipTx(tx, orgID, userID, m.Admin); err != nil {
+				return nil, fmt.Errorf("failed to add user '%s' to organization '%s': %w", m.Username, o.Slug, err)
+			}
+			stats.Memberships++
+			// feature:authz begin
+			for _, name := range m.Authorizations {
+				authID, ok := authIDs[name]
+				if !ok {
+					return nil, fmt.Errorf("member '%s' of '%s' is granted authorization '%s', which doesn't exist", m.Username, o.Slug, name)
+				}
+				if _, err := model.SeedMemberGrantTx(tx, orgID, userID, authID); err != nil {
+					return nil, fmt.Errorf("failed to grant '%s' to member '%s' of '%s': %w", name, m.Username, o.Slug, err)
+				}
+				stats.MemberGrants++
+			}
+			// feature:authz end
+		}
+	}
+	return &stats, nil
+}
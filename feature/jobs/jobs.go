@@ -0,0 +1,335 @@
+// Package jobs runs background work from the job table, with typed handlers, retries with exponential backoff,
+// recurring jobs on cron schedules, and dead-lettering of jobs that keep failing.
+//
+// Declare a [Kind] for each type of job, register its handler with [Handle], and enqueue it with [Enqueue] or [EnqueueTx].
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"yourapp/feature/audit"
+	"yourapp/feature/model"
+	"yourapp/foundation/backoff"
+	"yourapp/foundation/cron"
+	"yourapp/foundation/metrics"
+)
+
+const (
+	ResultDone  = "done"
+	ResultRetry = "retry"
+	ResultDead  = "dead"
+
+	DefaultInterval     = 5 * time.Second
+	DefaultConcurrency  = 4
+	DefaultLease        = 5 * time.Minute
+	DefaultDrainTimeout = 30 * time.Second
+	DefaultRetention    = 7 * 24 * time.Hour
+	DefaultMaxAttempts  = 10
+
+	backoffBase = 10 * time.Second
+	backoffMax  = time.Hour
+	purgeEvery  = time.Hour
+	// recordTimeout bounds recording a job's result, which still happens after running jobs are cancelled on shutdown.
+	recordTimeout = 10 * time.Second
+)
+
+var (
+	jobsProcessed = metrics.NewCounter("jobs_processed_total", "Job attempts, by kind and result", "kind", "result")
+
+	ErrUnknownKind = errors.New("no handler is registered for job kind")
+)
+
+// Kind ties the name stored with a job to the type of its payload, so enqueuing and handling agree on it.
+type Kind[T any] struct {
+	Name string
+}
+
+func NewKind[T any](name string) Kind[T] {
+	return Kind[T]{Name: name}
+}
+
+// Option changes how a job is enqueued.
+type Option func(*model.EnqueueJobParams)
+
+// Delay makes the job wait before it runs.
+func Delay(d time.Duration) Option {
+	return func(params *model.EnqueueJobParams) {
+		params.Delay = d
+	}
+}
+
+// MaxAttempts sets how many times the job is tried before it's dead-lettered, which is [DefaultMaxAttempts] otherwise.
+func MaxAttempts(n int) Option {
+	return func(params *model.EnqueueJobParams) {
+		params.MaxAttempts = n
+	}
+}
+
+// UniqueKey makes enqueuing a no-op if a job with the same key exists, until that job is purged.
+func UniqueKey(key string) Option {
+	return func(params *model.EnqueueJobParams) {
+		params.UniqueKey = key
+	}
+}
+
+// Enqueue adds a job to the queue on its own.
+// Use [EnqueueTx] instead when the job is triggered by a change, so it only runs if the change is committed.
+func Enqueue[T any](ctx context.Context, conn *sql.DB, kind Kind[T], payload T, opts ...Option) (*model.EnqueueJobResult, error) {
+	params, err := enqueueParams(kind, payload, opts)
+	if err != nil {
+		return nil, err
+	}
+	return model.EnqueueJob(ctx, conn, params)
+}
+
+// EnqueueTx adds a job to the queue as part of a larger transaction.
+func EnqueueTx[T any](tx *sql.Tx, kind Kind[T], payload T, opts ...Option) (*model.EnqueueJobResult, error) {
+	params, err := enqueueParams(kind, payload, opts)
+	if err != nil {
+		return nil, err
+	}
+	return model.EnqueueJobTx(tx, params)
+}
+
+func enqueueParams[T any](kind Kind[T], payload T, opts []Option) (model.EnqueueJobParams, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return model.EnqueueJobParams{}, fmt.Errorf("failed to encode payload for job kind '%s': %w", kind.Name, err)
+	}
+	params := model.EnqueueJobParams{Kind: kind.Name, Payload: data, MaxAttempts: DefaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	return params, nil
+}
+
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// Permanent marks an error as one that retrying won't fix, so the job is dead-lettered right away.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+func IsPermanent(err error) bool {
+	var perm permanentError
+	return errors.As(err, &perm)
+}
+
+type handler func(ctx context.Context, payload json.RawMessage) error
+
+type schedule struct {
+	name   string
+	spec   cron.Schedule
+	params model.EnqueueJobParams
+	// next is the run that has already been enqueued by this worker.
+	next time.Time
+}
+
+// Worker claims due jobs and runs them with the registered handlers.
+// Several workers can run at once, e.g. one per app instance, since jobs are claimed with a lease.
+type Worker struct {
+	// Interval is how often the queue is polled when it's idle.
+	Interval time.Duration
+	// Concurrency is the most jobs run at once.
+	Concurrency int
+	// Lease is how long a claimed job is reserved for this worker, and how long its handler may run before its context is cancelled.
+	Lease time.Duration
+	// DrainTimeout is how long running jobs have to finish once shutdown starts, before their contexts are cancelled.
+	DrainTimeout time.Duration
+	// Retention is how long finished jobs are kept before they're purged.
+	Retention time.Duration
+
+	pool      *sql.DB
+	log       audit.LogDelegate
+	repo      model.JobRepo
+	handlers  map[string]handler
+	schedules []*schedule
+	wake      chan struct{}
+}
+
+func NewWorker(pool *sql.DB, log audit.LogDelegate) *Worker {
+	return &Worker{
+		Interval:     DefaultInterval,
+		Concurrency:  DefaultConcurrency,
+		Lease:        DefaultLease,
+		DrainTimeout: DefaultDrainTimeout,
+		Retention:    DefaultRetention,
+		pool:         pool,
+		log:          log,
+		handlers:     map[string]handler{},
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Handle registers the handler for a kind of job, which must be done before [Worker.Run].
+// Returning an error retries the job with backoff, unless it's wrapped with [Permanent].
+// Handlers may run more than once for the same job, e.g. if a worker dies before recording the result, so they should be idempotent.
+func Handle[T any](w *Worker, kind Kind[T], fn func(ctx context.Context, payload T) error) {
+	if _, ok := w.handlers[kind.Name]; ok {
+		panic(fmt.Sprintf("a handler is already registered for job kind '%s'", kind.Name))
+	}
+	w.handlers[kind.Name] = func(ctx context.Context, data json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return Permanent(fmt.Errorf("failed to decode payload: %w", err))
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// Schedule enqueues a job each time spec fires, in UTC, which must be done before [Worker.Run].
+// Each run is enqueued with a unique key, so it only runs once when several workers share the same schedule.
+func Schedule[T any](w *Worker, name string, spec cron.Schedule, kind Kind[T], payload T) error {
+	params, err := enqueueParams(kind, payload, nil)
+	if err != nil {
+		return err
+	}
+	w.schedules = append(w.schedules, &schedule{name: name, spec: spec, params: params})
+	return nil
+}
+
+// Wake makes the worker poll right away, rather than waiting for the next interval.
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run works on due jobs until the context is cancelled, then waits for running jobs to finish.
+// Jobs still running after [Worker.DrainTimeout] have their contexts cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	// Jobs outlive ctx while draining, and are only cancelled once the drain timeout passes.
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+	slots := make(chan struct{}, w.Concurrency)
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		w.enqueueScheduled(ctx)
+		if free := w.Concurrency - len(slots); free > 0 {
+			claimed, err := w.repo.ClaimJobs(ctx, w.pool, free, w.Lease)
+			if err != nil && ctx.Err() == nil {
+				w.log.Error("Failed to claim jobs: %v", err)
+			}
+			for _, job := range claimed {
+				slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					w.work(jobCtx, job)
+					<-slots
+					w.Wake()
+				}()
+			}
+		}
+		if time.Since(lastPurge) >= purgeEvery {
+			if _, err := w.repo.PurgeFinishedJobs(ctx, w.pool, w.Retention); err != nil && ctx.Err() == nil {
+				w.log.Error("Failed to purge finished jobs: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			w.drain(&wg, cancelJobs)
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+func (w *Worker) drain(wg *sync.WaitGroup, cancelJobs context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(w.DrainTimeout):
+		w.log.Warn("Cancelling jobs still running after %s", w.DrainTimeout)
+		cancelJobs()
+		<-done
+	}
+}
+
+// enqueueScheduled enqueues the next run of each schedule, if this worker hasn't already.
+func (w *Worker) enqueueScheduled(ctx context.Context) {
+	now := time.Now().UTC()
+	for _, s := range w.schedules {
+		next := s.spec.Next(now)
+		if next.IsZero() || next.Equal(s.next) {
+			continue
+		}
+		params := s.params
+		params.Delay = next.Sub(now)
+		params.UniqueKey = fmt.Sprintf("cron:%s@%s", s.name, next.Format(time.RFC3339))
+		if _, err := w.repo.EnqueueJob(ctx, w.pool, params); err != nil {
+			if ctx.Err() == nil {
+				w.log.Error("Failed to enqueue scheduled job '%s': %v", s.name, err)
+			}
+			continue
+		}
+		s.next = next
+	}
+}
+
+// work runs a claimed job, and records the result.
+// The result is recorded even if ctx was cancelled by the drain timeout, so a cancelled job is retried with backoff instead of waiting out its lease.
+func (w *Worker) work(ctx context.Context, job *model.ClaimJobsResult) {
+	err := w.call(ctx, job)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err == nil {
+		jobsProcessed.Inc(job.Kind, ResultDone)
+		if _, err := w.repo.CompleteJob(ctx, w.pool, job.JobID, job.Attempts); err != nil {
+			// The lease will expire and the job will run again, which is why handlers should be idempotent.
+			w.log.Error("Failed to mark job %d as done: %v", job.JobID, err)
+		}
+		return
+	}
+
+	var retryAfter time.Duration
+	if !IsPermanent(err) && job.Attempts < job.MaxAttempts {
+		retryAfter = backoff.Delay(job.Attempts, backoffBase, backoffMax)
+		jobsProcessed.Inc(job.Kind, ResultRetry)
+		w.log.Warn("Job %d '%s' failed on attempt %d, retrying in %s: %v", job.JobID, job.Kind, job.Attempts, retryAfter.Round(time.Second), err)
+	} else {
+		jobsProcessed.Inc(job.Kind, ResultDead)
+		w.log.Error("Job %d '%s' is dead after %d attempts: %v", job.JobID, job.Kind, job.Attempts, err)
+	}
+	if _, err := w.repo.FailJob(ctx, w.pool, job.JobID, job.Attempts, err.Error(), retryAfter); err != nil {
+		w.log.Error("Failed to record job %d failure: %v", job.JobID, err)
+	}
+}
+
+// call runs the job's handler, with a context that's cancelled when the lease passes.
+// Unknown kinds are retried rather than dead-lettered, since a newer app instance may know them during a rolling deploy.
+func (w *Worker) call(ctx context.Context, job *model.ClaimJobsResult) (err error) {
+	h, ok := w.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("%w '%s'", ErrUnknownKind, job.Kind)
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.Lease)
+	defer cancel()
+	defer func() {
+		if cause := recover(); cause != nil {
+			err = fmt.Errorf("panic: %v", cause)
+		}
+	}()
+	return h(ctx, job.Payload)
+}
@@ -0,0 +1,265 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"yourapp/feature/audit"
+	"yourapp/feature/model"
+	"yourapp/foundation/cron"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+var greet = NewKind[greeting]("greet")
+
+func testWorker() *Worker {
+	w := NewWorker(nil, audit.StdDelegate(log.Default(), true))
+	w.repo.RedirectPurgeFinishedJobs(func(_ context.Context, _ *sql.DB, _ time.Duration) (sql.Result, error) {
+		return nil, nil
+	})
+	return w
+}
+
+func TestEnqueueParams(t *testing.T) {
+	params, err := enqueueParams(greet, greeting{Name: "bob"}, []Option{Delay(time.Minute), MaxAttempts(3), UniqueKey("bob")})
+	assert.NoError(t, err)
+	assert.Equal(t, model.EnqueueJobParams{
+		Kind:        "greet",
+		Payload:     []byte(`{"name":"bob"}`),
+		Delay:       time.Minute,
+		MaxAttempts: 3,
+		UniqueKey:   "bob",
+	}, params)
+
+	params, err = enqueueParams(greet, greeting{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMaxAttempts, params.MaxAttempts)
+}
+
+func TestWorker_work(t *testing.T) {
+	tests := map[string]struct {
+		kind          string
+		payload       string
+		attempts      int
+		handlerErr    error
+		panics        bool
+		expectDone    bool
+		expectRetry   bool
+		expectDead    bool
+		expectedCause string
+	}{
+		"Done": {
+			kind:       "greet",
+			payload:    `{"name":"bob"}`,
+			attempts:   1,
+			expectDone: true,
+		},
+		"Retried": {
+			kind:          "greet",
+			payload:       `{"name":"bob"}`,
+			attempts:      1,
+			handlerErr:    errors.New("service unavailable"),
+			expectRetry:   true,
+			expectedCause: "service unavailable",
+		},
+		"Permanent failure": {
+			kind:          "greet",
+			payload:       `{"name":"bob"}`,
+			attempts:      1,
+			handlerErr:    Permanent(errors.New("no such user")),
+			expectDead:    true,
+			expectedCause: "no such user",
+		},
+		"Out of attempts": {
+			kind:          "greet",
+			payload:       `{"name":"bob"}`,
+			attempts:      DefaultMaxAttempts,
+			handlerErr:    errors.New("service unavailable"),
+			expectDead:    true,
+			expectedCause: "service unavailable",
+		},
+		"Panic": {
+			kind:          "greet",
+			payload:       `{"name":"bob"}`,
+			attempts:      1,
+			panics:        true,
+			expectRetry:   true,
+			expectedCause: "panic: oops",
+		},
+		"Invalid payload": {
+			kind:          "greet",
+			payload:       `{"name":1}`,
+			attempts:      1,
+			expectDead:    true,
+			expectedCause: "failed to decode payload",
+		},
+		"Unknown kind": {
+			kind:          "wave",
+			payload:       `{}`,
+			attempts:      1,
+			expectRetry:   true,
+			expectedCause: "no handler is registered for job kind 'wave'",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var done, retried, dead bool
+			w := testWorker()
+			Handle(w, greet, func(ctx context.Context, payload greeting) error {
+				assert.Equal(t, "bob", payload.Name)
+				_, hasDeadline := ctx.Deadline()
+				assert.True(t, hasDeadline, "Handlers should be cancelled when the lease passes")
+				if tc.panics {
+					panic("oops")
+				}
+				return tc.handlerErr
+			})
+			w.repo.RedirectCompleteJob(func(_ context.Context, _ *sql.DB, jobID uint64, attempt int) (sql.Result, error) {
+				assert.Equal(t, uint64(5), jobID)
+				assert.Equal(t, tc.attempts, attempt)
+				done = true
+				return nil, nil
+			})
+			w.repo.RedirectFailJob(func(_ context.Context, _ *sql.DB, jobID uint64, attempt int, reason string, retryAfter time.Duration) (sql.Result, error) {
+				assert.Equal(t, uint64(5), jobID)
+				assert.Equal(t, tc.attempts, attempt, "Only the claimed attempt should be updated")
+				assert.Contains(t, reason, tc.expectedCause)
+				if retryAfter > 0 {
+					retried = true
+				} else {
+					dead = true
+				}
+				return nil, nil
+			})
+			w.work(context.Background(), &model.ClaimJobsResult{
+				JobID:       5,
+				Kind:        tc.kind,
+				Payload:     []byte(tc.payload),
+				Attempts:    tc.attempts,
+				MaxAttempts: DefaultMaxAttempts,
+			})
+			assert.Equal(t, tc.expectDone, done)
+			assert.Equal(t, tc.expectRetry, retried)
+			assert.Equal(t, tc.expectDead, dead)
+		})
+	}
+}
+
+func TestWorker_Run(t *testing.T) {
+	w := testWorker()
+	w.Interval = time.Hour
+	started := make(chan struct{})
+	release := make(chan struct{})
+	Handle(w, greet, func(ctx context.Context, _ greeting) error {
+		close(started)
+		<-release
+		return ctx.Err()
+	})
+	var (
+		mux    sync.Mutex
+		claims int
+		done   []uint64
+	)
+	w.repo.RedirectClaimJobs(func(_ context.Context, _ *sql.DB, limit int, _ time.Duration) ([]*model.ClaimJobsResult, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		claims++
+		if claims > 1 {
+			return nil, nil
+		}
+		assert.Equal(t, DefaultConcurrency, limit)
+		return []*model.ClaimJobsResult{{JobID: 1, Kind: "greet", Payload: []byte(`{}`), Attempts: 1, MaxAttempts: 1}}, nil
+	})
+	w.repo.RedirectCompleteJob(func(_ context.Context, _ *sql.DB, jobID uint64, _ int) (sql.Result, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		done = append(done, jobID)
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		w.Run(ctx)
+	}()
+	<-started
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("Run should wait for running jobs to finish")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+	assert.Equal(t, []uint64{1}, done, "The job should finish with an uncancelled context while draining")
+}
+
+func TestWorker_Run_DrainTimeout(t *testing.T) {
+	w := testWorker()
+	w.Interval = time.Hour
+	w.DrainTimeout = 10 * time.Millisecond
+	started := make(chan struct{})
+	Handle(w, greet, func(ctx context.Context, _ greeting) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var claims int
+	w.repo.RedirectClaimJobs(func(_ context.Context, _ *sql.DB, _ int, _ time.Duration) ([]*model.ClaimJobsResult, error) {
+		claims++
+		if claims > 1 {
+			return nil, nil
+		}
+		return []*model.ClaimJobsResult{{JobID: 1, Kind: "greet", Payload: []byte(`{}`), Attempts: 1, MaxAttempts: 2}}, nil
+	})
+	var failed bool
+	w.repo.RedirectFailJob(func(ctx context.Context, _ *sql.DB, _ uint64, _ int, reason string, _ time.Duration) (sql.Result, error) {
+		assert.Contains(t, reason, context.Canceled.Error())
+		assert.NoError(t, ctx.Err(), "The failure should be recorded even though the job was cancelled")
+		failed = true
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		w.Run(ctx)
+	}()
+	<-started
+	cancel()
+	<-stopped
+	assert.True(t, failed, "Jobs should be cancelled once the drain timeout passes")
+}
+
+func TestWorker_enqueueScheduled(t *testing.T) {
+	w := testWorker()
+	assert.NoError(t, Schedule(w, "hourly-greeting", cron.MustParse("@hourly"), greet, greeting{Name: "everyone"}))
+	var enqueued []model.EnqueueJobParams
+	w.repo.RedirectEnqueueJob(func(_ context.Context, _ *sql.DB, params model.EnqueueJobParams) (*model.EnqueueJobResult, error) {
+		enqueued = append(enqueued, params)
+		return &model.EnqueueJobResult{JobID: uint64(len(enqueued))}, nil
+	})
+
+	w.enqueueScheduled(context.Background())
+	w.enqueueScheduled(context.Background())
+	if assert.Len(t, enqueued, 1, "Each run should only be enqueued once") {
+		params := enqueued[0]
+		assert.Equal(t, "greet", params.Kind)
+		assert.JSONEq(t, `{"name":"everyone"}`, string(params.Payload))
+		assert.True(t, strings.HasPrefix(params.UniqueKey, "cron:hourly-greeting@"), params.UniqueKey)
+		assert.True(t, strings.HasSuffix(params.UniqueKey, ":00:00Z"), "The key should name the run's time, which is on the hour")
+		assert.Greater(t, params.Delay, time.Duration(0))
+		assert.LessOrEqual(t, params.Delay, time.Hour)
+	}
+}
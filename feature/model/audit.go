@@ -0,0 +1,155 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (repo *UsersRepo) RedirectInsertAuditLog(delegate func(context.Context, *sql.DB, string, string) (sql.Result, error)) {
+	repo.insertAuditLog = delegate
+}
+
+func (repo *UsersRepo) InsertAuditLog(ctx context.Context, conn *sql.DB, username string, message string) (sql.Result, error) {
+	if repo.insertAuditLog != nil {
+		return repo.insertAuditLog(ctx, conn, username, message)
+	}
+	return InsertAuditLog(ctx, conn, username, message)
+}
+
+func (repo *UsersRepo) RedirectGetLatestLogEntries(delegate func(context.Context, *sql.DB, int) ([]*GetLatestLogEntriesResult, error)) {
+	repo.getLatestLogEntries = delegate
+}
+
+func (repo *UsersRepo) GetLatestLogEntries(ctx context.Context, conn *sql.DB, limit int) ([]*GetLatestLogEntriesResult, error) {
+	if repo.getLatestLogEntries != nil {
+		return repo.getLatestLogEntries(ctx, conn, limit)
+	}
+	return GetLatestLogEntries(ctx, conn, limit)
+}
+
+// InsertAuditLog records an action, which belongs to the organization that ctx is scoped to if there is one.
+func InsertAuditLog(ctx context.Context, conn *sql.DB, username string, message string) (sql.Result, error) {
+	const query = `
+insert into user_audit (username, action, org_id) values ($1, $2, current_tenant());
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in InsertAuditLog: %w", err)
+	}
+
+	result, err := tx.Exec(query, username, message)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run InsertAuditLog: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+type GetLatestLogEntriesResult struct {
+	Username  string    `json:"username"`
+	EventTime time.Time `json:"eventTime"`
+	Action    string    `json:"action"`
+}
+
+func GetLatestLogEntries(ctx context.Context, conn *sql.DB, limit int) ([]*GetLatestLogEntriesResult, error) {
+	const query = `
+select
+    user_id,
+    username,
+    event_time,
+    action
+from (
+    select
+        u.id "user_id",
+        u.username,
+        event_time,
+        action
+    from user_audit ua
+    left join users u on ua.username = u.username
+    order by event_time desc
+    limit $1
+) segment
+order by event_time;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetLatestLogEntries: %w", err)
+	}
+
+	var results []*GetLatestLogEntriesResult
+	rows, err := tx.Query(query, limit)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetLatestLogEntries: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(GetLatestLogEntriesResult)
+		if err := rows.Scan(&result.Username, &result.EventTime, &result.Action); err != nil {
+			rerr := fmt.Errorf("failed to scan row in GetLatestLogEntries: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+type ExportAuditLogResult struct {
+	Username  string    `json:"username"`
+	Action    string    `json:"action"`
+	EventTime time.Time `json:"eventTime"`
+	// OrgID is the organization the entry was recorded in, if any.
+	OrgID *uint64 `json:"orgID"`
+}
+
+// ExportAuditLogTx returns the whole audit log, oldest first, as part of a larger transaction.
+func ExportAuditLogTx(tx *sql.Tx) ([]*ExportAuditLogResult, error) {
+	const query = `
+select username, action, event_time, org_id from user_audit order by event_time;
+`
+	var results []*ExportAuditLogResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ExportAuditLog: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(ExportAuditLogResult)
+		if err := rows.Scan(&result.Username, &result.Action, &result.EventTime, &result.OrgID); err != nil {
+			return nil, fmt.Errorf("failed to scan row in ExportAuditLog: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ImportAuditEntryTx adds an entry to the audit log with its original time, as part of a larger transaction.
+// Nothing is added if the same entry is already in the log, so importing the same entries again doesn't duplicate them.
+// The entry belongs to the organization with orgID, or to none if it's nil.
+func ImportAuditEntryTx(tx *sql.Tx, username string, action string, eventTime time.Time, orgID *uint64) (sql.Result, error) {
+	const query = `
+insert into user_audit (username, action, event_time, org_id)
+select $1::text, $2::text, $3::timestamp, $4::bigint
+where not exists (
+    select 1 from user_audit where username = $1 and action = $2 and event_time = $3
+);
+`
+	result, err := tx.Exec(query, username, action, eventTime, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ImportAuditEntry: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,326 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (repo *UsersRepo) RedirectGrantAuth(delegate func(context.Context, *sql.DB, string, string) (sql.Result, error)) {
+	repo.grantAuth = delegate
+}
+
+func (repo *UsersRepo) GrantAuth(ctx context.Context, conn *sql.DB, userID string, authID string) (sql.Result, error) {
+	if repo.grantAuth != nil {
+		return repo.grantAuth(ctx, conn, userID, authID)
+	}
+	return GrantAuth(ctx, conn, userID, authID)
+}
+
+func (repo *UsersRepo) RedirectRevokeAuth(delegate func(context.Context, *sql.DB, string, string) (sql.Result, error)) {
+	repo.revokeAuth = delegate
+}
+
+func (repo *UsersRepo) RevokeAuth(ctx context.Context, conn *sql.DB, userID string, authID string) (sql.Result, error) {
+	if repo.revokeAuth != nil {
+		return repo.revokeAuth(ctx, conn, userID, authID)
+	}
+	return RevokeAuth(ctx, conn, userID, authID)
+}
+
+func (repo *UsersRepo) RedirectGetAuthorizations(delegate func(context.Context, *sql.DB) ([]*GetAuthorizationsResult, error)) {
+	repo.getAuthorizations = delegate
+}
+
+func (repo *UsersRepo) GetAuthorizations(ctx context.Context, conn *sql.DB) ([]*GetAuthorizationsResult, error) {
+	if repo.getAuthorizations != nil {
+		return repo.getAuthorizations(ctx, conn)
+	}
+	return GetAuthorizations(ctx, conn)
+}
+
+func (repo *UsersRepo) RedirectUserAuth(delegate func(context.Context, *sql.DB, uint64) ([]*UserAuthResult, error)) {
+	repo.userAuth = delegate
+}
+
+func (repo *UsersRepo) UserAuth(ctx context.Context, conn *sql.DB, userID uint64) ([]*UserAuthResult, error) {
+	if repo.userAuth != nil {
+		return repo.userAuth(ctx, conn, userID)
+	}
+	return UserAuth(ctx, conn, userID)
+}
+
+func (repo *UsersRepo) RedirectUserAuthNotGranted(delegate func(context.Context, *sql.DB, string) ([]*UserAuthNotGrantedResult, error)) {
+	repo.userAuthNotGranted = delegate
+}
+
+func (repo *UsersRepo) UserAuthNotGranted(ctx context.Context, conn *sql.DB, username string) ([]*UserAuthNotGrantedResult, error) {
+	if repo.userAuthNotGranted != nil {
+		return repo.userAuthNotGranted(ctx, conn, username)
+	}
+	return UserAuthNotGranted(ctx, conn, username)
+}
+
+func GrantAuth(ctx context.Context, conn *sql.DB, userID string, authID string) (sql.Result, error) {
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GrantAuth: %w", err)
+	}
+
+	result, err := GrantAuthTx(tx, userID, authID)
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// GrantAuthTx grants an authorization to a user as part of a larger transaction.
+func GrantAuthTx(tx *sql.Tx, userID string, authID string) (sql.Result, error) {
+	const query = `
+insert into user_authz (user_id, auth_id) values ($1, $2)
+on conflict (user_id, auth_id) do update set revoked = null, granted = current_timestamp
+;
+`
+	result, err := tx.Exec(query, userID, authID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run GrantAuth: %w", err)
+	}
+	return result, nil
+}
+
+func RevokeAuth(ctx context.Context, conn *sql.DB, userID string, authID string) (sql.Result, error) {
+	const query = `
+update user_authz set revoked = current_timestamp
+where
+    user_id = $1
+    and auth_id = $2
+;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in RevokeAuth: %w", err)
+	}
+
+	result, err := tx.Exec(query, userID, authID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run RevokeAuth: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+type GetAuthorizationsResult struct {
+	AuthID uint64 `json:"authID"`
+	Name   string `json:"name"`
+}
+
+func GetAuthorizations(ctx context.Context, conn *sql.DB) ([]*GetAuthorizationsResult, error) {
+	const query = `
+select id, auth from authorizations;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetAuthorizations: %w", err)
+	}
+
+	var results []*GetAuthorizationsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetAuthorizations: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(GetAuthorizationsResult)
+		if err := rows.Scan(&result.AuthID, &result.Name); err != nil {
+			rerr := fmt.Errorf("failed to scan row in GetAuthorizations: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+type UserAuthResult struct {
+	Id      uint64    `json:"id"`
+	Auth    string    `json:"auth"`
+	Granted time.Time `json:"granted"`
+}
+
+func UserAuth(ctx context.Context, conn *sql.DB, userID uint64) ([]*UserAuthResult, error) {
+	const query = `
+select auth_id, auth, granted from auth_grants where user_id = $1;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in UserAuth: %w", err)
+	}
+
+	var results []*UserAuthResult
+	rows, err := tx.Query(query, userID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run UserAuth: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(UserAuthResult)
+		if err := rows.Scan(&result.Id, &result.Auth, &result.Granted); err != nil {
+			rerr := fmt.Errorf("failed to scan row in UserAuth: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+type UserAuthNotGrantedResult struct {
+	Id   uint64 `json:"id"`
+	Auth string `json:"auth"`
+}
+
+func UserAuthNotGranted(ctx context.Context, conn *sql.DB, username string) ([]*UserAuthNotGrantedResult, error) {
+	const query = `
+select id, auth
+from authorizations
+where id not in (select auth_id from auth_grants where username = $1)
+;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in UserAuthNotGranted: %w", err)
+	}
+
+	var results []*UserAuthNotGrantedResult
+	rows, err := tx.Query(query, username)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run UserAuthNotGranted: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(UserAuthNotGrantedResult)
+		if err := rows.Scan(&result.Id, &result.Auth); err != nil {
+			rerr := fmt.Errorf("failed to scan row in UserAuthNotGranted: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+type ExportAuthorizationsResult struct {
+	AuthID      uint64 `json:"authID"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ExportAuthorizationsTx returns every authorization as part of a larger transaction.
+func ExportAuthorizationsTx(tx *sql.Tx) ([]*ExportAuthorizationsResult, error) {
+	const query = `
+select id, auth, description from authorizations order by id;
+`
+	var results []*ExportAuthorizationsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ExportAuthorizations: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(ExportAuthorizationsResult)
+		if err := rows.Scan(&result.AuthID, &result.Name, &result.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan row in ExportAuthorizations: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ImportAuthorizationTx creates an authorization as part of a larger transaction, returning its ID.
+// An authorization with the same name gets the new description instead.
+func ImportAuthorizationTx(tx *sql.Tx, name string, description string) (uint64, error) {
+	const query = `
+insert into authorizations (auth, description) values ($1, $2)
+on conflict (auth) do update set description = excluded.description
+returning id;
+`
+	var authID uint64
+	if err := tx.QueryRow(query, name, description).Scan(&authID); err != nil {
+		return 0, fmt.Errorf("failed to run ImportAuthorization: %w", err)
+	}
+	return authID, nil
+}
+
+type ExportGrantsResult struct {
+	UserID  uint64     `json:"userID"`
+	AuthID  uint64     `json:"authID"`
+	Granted time.Time  `json:"granted"`
+	Revoked *time.Time `json:"revoked"`
+}
+
+// ExportGrantsTx returns every grant, including revoked ones, as part of a larger transaction.
+func ExportGrantsTx(tx *sql.Tx) ([]*ExportGrantsResult, error) {
+	const query = `
+select ua.user_id, ua.auth_id, ua.granted, ua.revoked
+from user_authz ua
+join users u on ua.user_id = u.id
+where u.username is not null
+order by ua.user_id, ua.auth_id;
+`
+	var results []*ExportGrantsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ExportGrants: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(ExportGrantsResult)
+		if err := rows.Scan(&result.UserID, &result.AuthID, &result.Granted, &result.Revoked); err != nil {
+			return nil, fmt.Errorf("failed to scan row in ExportGrants: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ImportGrantTx records a grant with its original times as part of a larger transaction.
+// An existing grant of the same authorization to the same user is updated to match.
+func ImportGrantTx(tx *sql.Tx, userID uint64, authID uint64, granted time.Time, revoked *time.Time) (sql.Result, error) {
+	const query = `
+insert into user_authz (user_id, auth_id, granted, revoked) values ($1, $2, $3, $4)
+on conflict (user_id, auth_id) do update set granted = excluded.granted, revoked = excluded.revoked
+;
+`
+	result, err := tx.Exec(query, userID, authID, granted, revoked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ImportGrant: %w", err)
+	}
+	return result, nil
+}
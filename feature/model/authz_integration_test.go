@@ -0,0 +1,52 @@
+//go:build integration
+
+package model
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"yourapp/foundation/pgtest"
+)
+
+func TestGrantAuth(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	_, err := CreateUser(ctx, db, "alice", "correct horse")
+	require.NoError(t, err)
+	user, err := GetUser(ctx, db, "alice")
+	require.NoError(t, err)
+	var authID uint64
+	err = db.QueryRowContext(ctx, "insert into authorizations (auth) values ('reports') returning id").Scan(&authID)
+	require.NoError(t, err)
+	userIDParam, authIDParam := strconv.FormatUint(user.UserID, 10), strconv.FormatUint(authID, 10)
+
+	grants, err := UserAuth(ctx, db, user.UserID)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+
+	_, err = GrantAuth(ctx, db, userIDParam, authIDParam)
+	require.NoError(t, err)
+	grants, err = UserAuth(ctx, db, user.UserID)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "reports", grants[0].Auth)
+
+	_, err = RevokeAuth(ctx, db, userIDParam, authIDParam)
+	require.NoError(t, err)
+	grants, err = UserAuth(ctx, db, user.UserID)
+	require.NoError(t, err)
+	assert.Empty(t, grants, "A revoked authorization shouldn't be granted")
+	notGranted, err := UserAuthNotGranted(ctx, db, "alice")
+	require.NoError(t, err)
+	require.Len(t, notGranted, 1)
+	assert.Equal(t, "reports", notGranted[0].Auth)
+
+	_, err = GrantAuth(ctx, db, userIDParam, authIDParam)
+	require.NoError(t, err)
+	grants, err = UserAuth(ctx, db, user.UserID)
+	require.NoError(t, err)
+	assert.Len(t, grants, 1, "A revoked authorization can be granted again")
+}
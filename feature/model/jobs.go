@@ -0,0 +1,398 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobDead    = "dead"
+)
+
+type JobRepo struct {
+	claimJobs   func(context.Context, *sql.DB, int, time.Duration) ([]*ClaimJobsResult, error)
+	completeJob func(context.Context, *sql.DB, uint64, int) (sql.Result, error)
+	failJob     func(context.Context, *sql.DB, uint64, int, string, time.Duration) (sql.Result, error)
+	enqueueJob  func(context.Context, *sql.DB, EnqueueJobParams) (*EnqueueJobResult, error)
+	purgeJobs   func(context.Context, *sql.DB, time.Duration) (sql.Result, error)
+}
+
+func (repo *JobRepo) RedirectClaimJobs(delegate func(context.Context, *sql.DB, int, time.Duration) ([]*ClaimJobsResult, error)) {
+	repo.claimJobs = delegate
+}
+
+func (repo *JobRepo) ClaimJobs(ctx context.Context, conn *sql.DB, limit int, lease time.Duration) ([]*ClaimJobsResult, error) {
+	if repo.claimJobs != nil {
+		return repo.claimJobs(ctx, conn, limit, lease)
+	}
+	return ClaimJobs(ctx, conn, limit, lease)
+}
+
+func (repo *JobRepo) RedirectCompleteJob(delegate func(context.Context, *sql.DB, uint64, int) (sql.Result, error)) {
+	repo.completeJob = delegate
+}
+
+func (repo *JobRepo) CompleteJob(ctx context.Context, conn *sql.DB, jobID uint64, attempt int) (sql.Result, error) {
+	if repo.completeJob != nil {
+		return repo.completeJob(ctx, conn, jobID, attempt)
+	}
+	return CompleteJob(ctx, conn, jobID, attempt)
+}
+
+func (repo *JobRepo) RedirectFailJob(delegate func(context.Context, *sql.DB, uint64, int, string, time.Duration) (sql.Result, error)) {
+	repo.failJob = delegate
+}
+
+func (repo *JobRepo) FailJob(ctx context.Context, conn *sql.DB, jobID uint64, attempt int, reason string, retryAfter time.Duration) (sql.Result, error) {
+	if repo.failJob != nil {
+		return repo.failJob(ctx, conn, jobID, attempt, reason, retryAfter)
+	}
+	return FailJob(ctx, conn, jobID, attempt, reason, retryAfter)
+}
+
+func (repo *JobRepo) RedirectEnqueueJob(delegate func(context.Context, *sql.DB, EnqueueJobParams) (*EnqueueJobResult, error)) {
+	repo.enqueueJob = delegate
+}
+
+func (repo *JobRepo) EnqueueJob(ctx context.Context, conn *sql.DB, params EnqueueJobParams) (*EnqueueJobResult, error) {
+	if repo.enqueueJob != nil {
+		return repo.enqueueJob(ctx, conn, params)
+	}
+	return EnqueueJob(ctx, conn, params)
+}
+
+func (repo *JobRepo) RedirectPurgeFinishedJobs(delegate func(context.Context, *sql.DB, time.Duration) (sql.Result, error)) {
+	repo.purgeJobs = delegate
+}
+
+func (repo *JobRepo) PurgeFinishedJobs(ctx context.Context, conn *sql.DB, olderThan time.Duration) (sql.Result, error) {
+	if repo.purgeJobs != nil {
+		return repo.purgeJobs(ctx, conn, olderThan)
+	}
+	return PurgeFinishedJobs(ctx, conn, olderThan)
+}
+
+type EnqueueJobParams struct {
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+	// Delay is how long from now the job should wait before it runs.
+	Delay       time.Duration `json:"delay"`
+	MaxAttempts int           `json:"maxAttempts"`
+	// UniqueKey prevents the job from being enqueued more than once, if it's set.
+	UniqueKey string `json:"uniqueKey"`
+}
+
+type EnqueueJobResult struct {
+	JobID uint64 `json:"jobID"`
+	// Duplicate is true if a job with the same unique key already existed, in which case JobID is 0.
+	Duplicate bool `json:"duplicate"`
+}
+
+// EnqueueJob adds a job to the queue on its own.
+// Use [EnqueueJobTx] instead when the job is triggered by a change, so it only runs if the change is committed.
+func EnqueueJob(ctx context.Context, conn *sql.DB, params EnqueueJobParams) (*EnqueueJobResult, error) {
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in EnqueueJob: %w", err)
+	}
+
+	result, err := EnqueueJobTx(tx, params)
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// EnqueueJobTx adds a job to the queue as part of a larger transaction.
+func EnqueueJobTx(tx *sql.Tx, params EnqueueJobParams) (*EnqueueJobResult, error) {
+	const query = `
+insert into job (kind, payload, run_at, max_attempts, unique_key)
+values ($1, $2, current_timestamp + make_interval(secs => $3), $4, nullif($5, ''))
+on conflict (unique_key) do nothing
+returning id;
+`
+	payload := params.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	maxAttempts := params.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+	var result EnqueueJobResult
+	err := tx.QueryRow(query, params.Kind, []byte(payload), max(params.Delay, 0).Seconds(), maxAttempts, params.UniqueKey).Scan(&result.JobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		result.Duplicate = true
+		return &result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run EnqueueJob: %w", err)
+	}
+	return &result, nil
+}
+
+type ClaimJobsResult struct {
+	JobID   uint64          `json:"jobID"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+	// Attempts includes the attempt the job was just claimed for.
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"maxAttempts"`
+}
+
+// ClaimJobs claims up to limit jobs that are due to run, marking them as running until the lease has passed.
+// Jobs whose lease has passed without finishing are claimed again, so a worker that dies mid-job doesn't lose it,
+// unless that was their last attempt, in which case they're dead-lettered so a job that crashes its worker can't retry forever.
+// Rows locked by other workers are skipped so several app instances can work at once.
+func ClaimJobs(ctx context.Context, conn *sql.DB, limit int, lease time.Duration) ([]*ClaimJobsResult, error) {
+	const (
+		deadQuery = `
+update job
+set status = 'dead', locked_until = null, finished_at = current_timestamp,
+    last_error = 'the lease expired on the last attempt, so the worker may have crashed'
+where id in (
+    select id from job
+    where status = 'running' and locked_until < current_timestamp and attempts >= max_attempts
+    for update skip locked
+);
+`
+		query = `
+update job
+set status = 'running', attempts = attempts + 1, locked_until = current_timestamp + make_interval(secs => $2)
+where id in (
+    select id from job
+    where (status = 'queued' and run_at <= current_timestamp)
+       or (status = 'running' and locked_until < current_timestamp and attempts < max_attempts)
+    order by run_at
+    limit $1
+    for update skip locked
+)
+returning id, kind, payload, attempts, max_attempts;
+`
+	)
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in ClaimJobs: %w", err)
+	}
+
+	if _, err := tx.Exec(deadQuery); err != nil {
+		rerr := fmt.Errorf("failed to dead-letter expired jobs in ClaimJobs: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	var results []*ClaimJobsResult
+	rows, err := tx.Query(query, limit, lease.Seconds())
+	if err != nil {
+		rerr := fmt.Errorf("failed to run ClaimJobs: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var (
+			result  = new(ClaimJobsResult)
+			payload []byte
+		)
+		if err := rows.Scan(&result.JobID, &result.Kind, &payload, &result.Attempts, &result.MaxAttempts); err != nil {
+			rerr := fmt.Errorf("failed to scan row in ClaimJobs: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		result.Payload = payload
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		rerr := fmt.Errorf("failed to read rows in ClaimJobs: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return results, tx.Commit()
+}
+
+// CompleteJob marks the job as done, if it's still running for the given attempt.
+// Nothing is updated if the lease passed and the job was claimed again, so a stale worker can't overwrite a newer attempt.
+func CompleteJob(ctx context.Context, conn *sql.DB, jobID uint64, attempt int) (sql.Result, error) {
+	const query = `
+update job set status = 'done', finished_at = current_timestamp, locked_until = null, last_error = null
+where id = $1 and status = 'running' and attempts = $2;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in CompleteJob: %w", err)
+	}
+
+	result, err := tx.Exec(query, jobID, attempt)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run CompleteJob: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// FailJob records a failed attempt, which is retried after retryAfter.
+// If retryAfter isn't positive, then the job is dead-lettered, and stays that way until it's retried by an admin.
+// Like [CompleteJob], nothing is updated unless the job is still running for the given attempt.
+func FailJob(ctx context.Context, conn *sql.DB, jobID uint64, attempt int, reason string, retryAfter time.Duration) (sql.Result, error) {
+	const query = `
+update job
+set last_error = $3,
+    locked_until = null,
+    status = case when $4::float8 <= 0 then 'dead' else 'queued' end,
+    run_at = case when $4::float8 <= 0 then run_at else current_timestamp + make_interval(secs => $4::float8) end,
+    finished_at = case when $4::float8 <= 0 then current_timestamp end
+where id = $1 and status = 'running' and attempts = $2;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in FailJob: %w", err)
+	}
+
+	result, err := tx.Exec(query, jobID, attempt, reason, retryAfter.Seconds())
+	if err != nil {
+		rerr := fmt.Errorf("failed to run FailJob: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+type ListJobsResult struct {
+	JobID       uint64          `json:"jobID"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	RunAt       time.Time       `json:"runAt"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	LastError   *string         `json:"lastError"`
+	FinishedAt  *time.Time      `json:"finishedAt"`
+}
+
+// ListJobs lists up to limit jobs with the given status, soonest to run first, or most recently finished first for done and dead jobs.
+func ListJobs(ctx context.Context, conn *sql.DB, status string, limit int) ([]*ListJobsResult, error) {
+	const query = `
+select id, kind, payload, status, created_at, run_at, attempts, max_attempts, last_error, finished_at
+from job
+where status = $1
+order by finished_at desc nulls last, run_at, id
+limit $2;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in ListJobs: %w", err)
+	}
+
+	var results []*ListJobsResult
+	rows, err := tx.Query(query, status, limit)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run ListJobs: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var (
+			result  = new(ListJobsResult)
+			payload []byte
+		)
+		if err := rows.Scan(&result.JobID, &result.Kind, &payload, &result.Status, &result.CreatedAt, &result.RunAt,
+			&result.Attempts, &result.MaxAttempts, &result.LastError, &result.FinishedAt); err != nil {
+			rerr := fmt.Errorf("failed to scan row in ListJobs: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		result.Payload = payload
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		rerr := fmt.Errorf("failed to read rows in ListJobs: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return results, tx.Commit()
+}
+
+// RetryJob queues a dead job to run again right away, with a fresh set of attempts.
+func RetryJob(ctx context.Context, conn *sql.DB, jobID uint64) (sql.Result, error) {
+	const query = `
+update job
+set status = 'queued', run_at = current_timestamp, attempts = 0, finished_at = null
+where id = $1 and status = 'dead';
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in RetryJob: %w", err)
+	}
+
+	result, err := tx.Exec(query, jobID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run RetryJob: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// DeleteJob deletes a job that isn't running.
+func DeleteJob(ctx context.Context, conn *sql.DB, jobID uint64) (sql.Result, error) {
+	const query = `
+delete from job where id = $1 and status <> 'running';
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in DeleteJob: %w", err)
+	}
+
+	result, err := tx.Exec(query, jobID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run DeleteJob: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// PurgeFinishedJobs deletes jobs that finished successfully longer ago than olderThan.
+// Dead jobs are kept until an admin retries or deletes them.
+func PurgeFinishedJobs(ctx context.Context, conn *sql.DB, olderThan time.Duration) (sql.Result, error) {
+	const query = `
+delete from job where status = 'done' and finished_at < current_timestamp - make_interval(secs => $1);
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in PurgeFinishedJobs: %w", err)
+	}
+
+	result, err := tx.Exec(query, olderThan.Seconds())
+	if err != nil {
+		rerr := fmt.Errorf("failed to run PurgeFinishedJobs: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
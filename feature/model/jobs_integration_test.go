@@ -0,0 +1,76 @@
+//go:build integration
+
+package model
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"yourapp/foundation/pgtest"
+)
+
+func TestClaimJobs_expiredLease(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+
+	tests := map[string]struct {
+		maxAttempts    int
+		expectReclaim  bool
+		expectedStatus string
+	}{
+		"Attempts left": {
+			maxAttempts:    2,
+			expectReclaim:  true,
+			expectedStatus: JobRunning,
+		},
+		"Last attempt": {
+			maxAttempts:    1,
+			expectedStatus: JobDead,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			enqueued, err := EnqueueJob(ctx, db, EnqueueJobParams{Kind: "greet", MaxAttempts: tc.maxAttempts})
+			require.NoError(t, err)
+			claimed, err := ClaimJobs(ctx, db, 10, time.Minute)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+			expireLease(t, db, enqueued.JobID)
+
+			reclaimed, err := ClaimJobs(ctx, db, 10, time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectReclaim, len(reclaimed) == 1)
+			assert.Equal(t, tc.expectedStatus, jobStatus(t, db, enqueued.JobID))
+
+			// The first worker finishing late doesn't overwrite the attempt that replaced it, or the dead-lettered job.
+			result, err := CompleteJob(ctx, db, enqueued.JobID, claimed[0].Attempts)
+			require.NoError(t, err)
+			n, err := result.RowsAffected()
+			require.NoError(t, err)
+			assert.Zero(t, n)
+			assert.Equal(t, tc.expectedStatus, jobStatus(t, db, enqueued.JobID))
+			if tc.expectReclaim {
+				_, err = CompleteJob(ctx, db, enqueued.JobID, reclaimed[0].Attempts)
+				require.NoError(t, err)
+				assert.Equal(t, JobDone, jobStatus(t, db, enqueued.JobID))
+			}
+		})
+	}
+}
+
+// expireLease moves a running job's lease into the past, which stands in for its worker crashing.
+func expireLease(t *testing.T, db *sql.DB, jobID uint64) {
+	t.Helper()
+	_, err := db.ExecContext(context.Background(), `update job set locked_until = current_timestamp - interval '1 second' where id = $1;`, jobID)
+	require.NoError(t, err)
+}
+
+func jobStatus(t *testing.T, db *sql.DB, jobID uint64) string {
+	t.Helper()
+	var status string
+	require.NoError(t, db.QueryRowContext(context.Background(), `select status from job where id = $1;`, jobID).Scan(&status))
+	return status
+}
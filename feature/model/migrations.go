@@ -0,0 +1,75 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RequiredMigrations lists the scripts in infra/pg/sql that this version of the app depends on.
+// Each script records its name in the schema_migrations table when it's applied.
+var RequiredMigrations = []string{
+	"01_auth",
+	"02_tokens",
+	"03_signup",
+	"04_outbox", "05_jobs",
+	"06_tenants",
+}
+
+type AppliedMigrationsResult struct {
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+func AppliedMigrations(ctx context.Context, conn *sql.DB) ([]*AppliedMigrationsResult, error) {
+	const query = `
+select name, applied_at from schema_migrations order by name;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in AppliedMigrations: %w", err)
+	}
+
+	var results []*AppliedMigrationsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run AppliedMigrations: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(AppliedMigrationsResult)
+		if err := rows.Scan(&result.Name, &result.AppliedAt); err != nil {
+			rerr := fmt.Errorf("failed to scan row in AppliedMigrations: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+// PendingMigrations returns the [RequiredMigrations] that haven't been applied to the database.
+func PendingMigrations(ctx context.Context, conn *sql.DB) ([]string, error) {
+	applied, err := AppliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	done := map[string]bool{}
+	for _, migration := range applied {
+		done[migration.Name] = true
+	}
+	var pending []string
+	for _, name := range RequiredMigrations {
+		if !done[name] {
+			pending = append(pending, name)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,193 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (repo *OrgsRepo) RedirectMemberAuth(delegate func(context.Context, *sql.DB, uint64, uint64) ([]*UserAuthResult, error)) {
+	repo.memberAuth = delegate
+}
+
+func (repo *OrgsRepo) MemberAuth(ctx context.Context, conn *sql.DB, orgID uint64, userID uint64) ([]*UserAuthResult, error) {
+	if repo.memberAuth != nil {
+		return repo.memberAuth(ctx, conn, orgID, userID)
+	}
+	return MemberAuth(ctx, conn, orgID, userID)
+}
+
+func (repo *OrgsRepo) RedirectMemberTokenAuth(delegate func(context.Context, *sql.DB, uint64, uint64, uint64) ([]*UserAuthResult, error)) {
+	repo.memberTokenAuth = delegate
+}
+
+func (repo *OrgsRepo) MemberTokenAuth(ctx context.Context, conn *sql.DB, orgID uint64, tokenID uint64, userID uint64) ([]*UserAuthResult, error) {
+	if repo.memberTokenAuth != nil {
+		return repo.memberTokenAuth(ctx, conn, orgID, tokenID, userID)
+	}
+	return MemberTokenAuth(ctx, conn, orgID, tokenID, userID)
+}
+
+// GrantMemberAuth grants an authorization to a member, which only applies in their organization.
+func GrantMemberAuth(ctx context.Context, conn *sql.DB, orgID uint64, userID uint64, authID uint64) (sql.Result, error) {
+	const query = `
+insert into membership_authz (org_id, user_id, auth_id) values ($1, $2, $3)
+on conflict (org_id, user_id, auth_id) do update set revoked = null, granted = current_timestamp
+;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GrantMemberAuth: %w", err)
+	}
+
+	result, err := tx.Exec(query, orgID, userID, authID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GrantMemberAuth: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+func RevokeMemberAuth(ctx context.Context, conn *sql.DB, orgID uint64, userID uint64, authID uint64) (sql.Result, error) {
+	const query = `
+update membership_authz set revoked = current_timestamp
+where
+    org_id = $1
+    and user_id = $2
+    and auth_id = $3
+    and revoked is null
+;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in RevokeMemberAuth: %w", err)
+	}
+
+	result, err := tx.Exec(query, orgID, userID, authID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run RevokeMemberAuth: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// MemberAuth returns the authorizations granted to a member in their organization.
+func MemberAuth(ctx context.Context, conn *sql.DB, orgID uint64, userID uint64) ([]*UserAuthResult, error) {
+	const query = `
+select auth_id, auth, granted from member_grants where org_id = $1 and user_id = $2;
+`
+	return queryMemberAuth(ctx, conn, "MemberAuth", query, orgID, userID)
+}
+
+// MemberTokenAuth is like [TokenAuth], except that the token's scopes are limited to the member's authorizations in their organization.
+func MemberTokenAuth(ctx context.Context, conn *sql.DB, orgID uint64, tokenID uint64, userID uint64) ([]*UserAuthResult, error) {
+	const query = `
+select g.auth_id, g.auth, g.granted
+from api_token_scope s
+    join member_grants g on g.auth_id = s.auth_id
+where g.org_id = $1 and s.token_id = $2 and g.user_id = $3
+;
+`
+	return queryMemberAuth(ctx, conn, "MemberTokenAuth", query, orgID, tokenID, userID)
+}
+
+func queryMemberAuth(ctx context.Context, conn *sql.DB, name string, query string, args ...any) ([]*UserAuthResult, error) {
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in %s: %w", name, err)
+	}
+
+	var results []*UserAuthResult
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run %s: %w", name, err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(UserAuthResult)
+		if err := rows.Scan(&result.Id, &result.Auth, &result.Granted); err != nil {
+			rerr := fmt.Errorf("failed to scan row in %s: %w", name, err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+type ExportMemberGrantsResult struct {
+	OrgID   uint64     `json:"orgID"`
+	UserID  uint64     `json:"userID"`
+	AuthID  uint64     `json:"authID"`
+	Granted time.Time  `json:"granted"`
+	Revoked *time.Time `json:"revoked"`
+}
+
+// ExportMemberGrantsTx returns every member's grants in every organization, including revoked ones, as part of a larger transaction.
+func ExportMemberGrantsTx(tx *sql.Tx) ([]*ExportMemberGrantsResult, error) {
+	const query = `
+select ma.org_id, ma.user_id, ma.auth_id, ma.granted, ma.revoked
+from membership_authz ma
+join users u on ma.user_id = u.id
+where u.username is not null
+order by ma.org_id, ma.user_id, ma.auth_id;
+`
+	var results []*ExportMemberGrantsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ExportMemberGrants: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(ExportMemberGrantsResult)
+		if err := rows.Scan(&result.OrgID, &result.UserID, &result.AuthID, &result.Granted, &result.Revoked); err != nil {
+			return nil, fmt.Errorf("failed to scan row in ExportMemberGrants: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ImportMemberGrantTx records a member's grant with its original times as part of a larger transaction.
+// An existing grant of the same authorization to the same member is updated to match.
+func ImportMemberGrantTx(tx *sql.Tx, orgID uint64, userID uint64, authID uint64, granted time.Time, revoked *time.Time) (sql.Result, error) {
+	const query = `
+insert into membership_authz (org_id, user_id, auth_id, granted, revoked) values ($1, $2, $3, $4, $5)
+on conflict (org_id, user_id, auth_id) do update set granted = excluded.granted, revoked = excluded.revoked
+;
+`
+	result, err := tx.Exec(query, orgID, userID, authID, granted, revoked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ImportMemberGrant: %w", err)
+	}
+	return result, nil
+}
+
+// SeedMemberGrantTx grants an authorization to a member as part of a larger transaction.
+func SeedMemberGrantTx(tx *sql.Tx, orgID uint64, userID uint64, authID uint64) (sql.Result, error) {
+	const query = `
+insert into membership_authz (org_id, user_id, auth_id) values ($1, $2, $3)
+on conflict (org_id, user_id, auth_id) do update set revoked = null, granted = current_timestamp
+;
+`
+	result, err := tx.Exec(query, orgID, userID, authID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run SeedMemberGrant: %w", err)
+	}
+	return result, nil
+}
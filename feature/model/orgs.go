@@ -0,0 +1,357 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type OrgsRepo struct {
+	getOrganization func(context.Context, *sql.DB, string) (*GetOrganizationResult, error)
+	getMembership   func(context.Context, *sql.DB, uint64, uint64) (*GetMembershipResult, error)
+	memberAuth      func(context.Context, *sql.DB, uint64, uint64) ([]*UserAuthResult, error)         // feature:authz
+	memberTokenAuth func(context.Context, *sql.DB, uint64, uint64, uint64) ([]*UserAuthResult, error) // feature:authz
+}
+
+func (repo *OrgsRepo) RedirectGetOrganization(delegate func(context.Context, *sql.DB, string) (*GetOrganizationResult, error)) {
+	repo.getOrganization = delegate
+}
+
+func (repo *OrgsRepo) GetOrganization(ctx context.Context, conn *sql.DB, slug string) (*GetOrganizationResult, error) {
+	if repo.getOrganization != nil {
+		return repo.getOrganization(ctx, conn, slug)
+	}
+	return GetOrganization(ctx, conn, slug)
+}
+
+func (repo *OrgsRepo) RedirectGetMembership(delegate func(context.Context, *sql.DB, uint64, uint64) (*GetMembershipResult, error)) {
+	repo.getMembership = delegate
+}
+
+func (repo *OrgsRepo) GetMembership(ctx context.Context, conn *sql.DB, orgID uint64, userID uint64) (*GetMembershipResult, error) {
+	if repo.getMembership != nil {
+		return repo.getMembership(ctx, conn, orgID, userID)
+	}
+	return GetMembership(ctx, conn, orgID, userID)
+}
+
+// CreateOrganization adds an organization, returning its ID.
+func CreateOrganization(ctx context.Context, conn *sql.DB, slug string, name string) (uint64, error) {
+	const query = `
+insert into organization (slug, name) values ($1, $2) returning id;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction in CreateOrganization: %w", err)
+	}
+
+	var orgID uint64
+	if err := tx.QueryRow(query, slug, name).Scan(&orgID); err != nil {
+		rerr := fmt.Errorf("failed to run CreateOrganization: %w", err)
+		return 0, errors.Join(rerr, tx.Rollback())
+	}
+	return orgID, tx.Commit()
+}
+
+type GetOrganizationResult struct {
+	OrgID uint64 `json:"orgID"`
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+}
+
+// GetOrganization looks up an organization by its slug, returning [sql.ErrNoRows] if there isn't one.
+// This is how a request's tenant is found, so it's called before the request is scoped to one.
+func GetOrganization(ctx context.Context, conn *sql.DB, slug string) (*GetOrganizationResult, error) {
+	const query = `
+select id, slug, name from organization where slug = $1;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetOrganization: %w", err)
+	}
+
+	var result GetOrganizationResult
+	err = tx.QueryRow(query, slug).Scan(&result.OrgID, &result.Slug, &result.Name)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetOrganization: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+type GetAllOrganizationsResult struct {
+	OrgID   uint64 `json:"orgID"`
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	Members int    `json:"members"`
+}
+
+func GetAllOrganizations(ctx context.Context, conn *sql.DB) ([]*GetAllOrganizationsResult, error) {
+	const query = `
+select o.id, o.slug, o.name, count(m.user_id)
+from organization o
+left join membership m on m.org_id = o.id
+group by o.id, o.slug, o.name
+order by o.slug;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetAllOrganizations: %w", err)
+	}
+
+	var results []*GetAllOrganizationsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetAllOrganizations: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(GetAllOrganizationsResult)
+		if err := rows.Scan(&result.OrgID, &result.Slug, &result.Name, &result.Members); err != nil {
+			rerr := fmt.Errorf("failed to scan row in GetAllOrganizations: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+// AddMember makes a user a member of an organization, or changes whether they're one of its admins if they already are.
+// No rows are affected if the user doesn't exist.
+// The user is found with find_user_id, since a scoped context can only see users that are already members.
+func AddMember(ctx context.Context, conn *sql.DB, orgID uint64, username string, admin bool) (sql.Result, error) {
+	const query = `
+insert into membership (org_id, user_id, admin)
+select $1, u.id, $3 from (select find_user_id($2) as id) u where u.id is not null
+on conflict (org_id, user_id) do update set admin = excluded.admin
+;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in AddMember: %w", err)
+	}
+
+	result, err := tx.Exec(query, orgID, username, admin)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run AddMember: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// RemoveMember removes a user from an organization, along with the authorizations they were granted in it.
+func RemoveMember(ctx context.Context, conn *sql.DB, orgID uint64, userID uint64) (sql.Result, error) {
+	const query = `
+delete from membership where org_id = $1 and user_id = $2;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in RemoveMember: %w", err)
+	}
+
+	result, err := tx.Exec(query, orgID, userID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run RemoveMember: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+type GetMembershipResult struct {
+	Admin    bool      `json:"admin"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// GetMembership returns [sql.ErrNoRows] if the user isn't a member of the organization.
+func GetMembership(ctx context.Context, conn *sql.DB, orgID uint64, userID uint64) (*GetMembershipResult, error) {
+	const query = `
+select admin, joined_at from membership where org_id = $1 and user_id = $2;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetMembership: %w", err)
+	}
+
+	var result GetMembershipResult
+	err = tx.QueryRow(query, orgID, userID).Scan(&result.Admin, &result.JoinedAt)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetMembership: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+type GetMembersResult struct {
+	UserID   uint64    `json:"userID"`
+	Username string    `json:"username"`
+	Admin    bool      `json:"admin"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+func GetMembers(ctx context.Context, conn *sql.DB, orgID uint64) ([]*GetMembersResult, error) {
+	const query = `
+select u.id, u.username, m.admin, m.joined_at
+from membership m
+join users u on m.user_id = u.id
+where m.org_id = $1
+order by u.username;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetMembers: %w", err)
+	}
+
+	var results []*GetMembersResult
+	rows, err := tx.Query(query, orgID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetMembers: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(GetMembersResult)
+		if err := rows.Scan(&result.UserID, &result.Username, &result.Admin, &result.JoinedAt); err != nil {
+			rerr := fmt.Errorf("failed to scan row in GetMembers: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+type ExportOrganizationsResult struct {
+	OrgID     uint64    `json:"orgID"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ExportOrganizationsTx returns every organization as part of a larger transaction.
+func ExportOrganizationsTx(tx *sql.Tx) ([]*ExportOrganizationsResult, error) {
+	const query = `
+select id, slug, name, created_at from organization order by id;
+`
+	var results []*ExportOrganizationsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ExportOrganizations: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(ExportOrganizationsResult)
+		if err := rows.Scan(&result.OrgID, &result.Slug, &result.Name, &result.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row in ExportOrganizations: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ImportOrganizationTx creates an organization as part of a larger transaction, returning its ID.
+// An organization with the same slug gets the new name instead.
+func ImportOrganizationTx(tx *sql.Tx, slug string, name string) (uint64, error) {
+	const query = `
+insert into organization (slug, name) values ($1, $2)
+on conflict (slug) do update set name = excluded.name
+returning id;
+`
+	var orgID uint64
+	if err := tx.QueryRow(query, slug, name).Scan(&orgID); err != nil {
+		return 0, fmt.Errorf("failed to run ImportOrganization: %w", err)
+	}
+	return orgID, nil
+}
+
+type ExportMembershipsResult struct {
+	OrgID    uint64    `json:"orgID"`
+	UserID   uint64    `json:"userID"`
+	Admin    bool      `json:"admin"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// ExportMembershipsTx returns the members of every organization as part of a larger transaction.
+func ExportMembershipsTx(tx *sql.Tx) ([]*ExportMembershipsResult, error) {
+	const query = `
+select m.org_id, m.user_id, m.admin, m.joined_at
+from membership m
+join users u on m.user_id = u.id
+where u.username is not null
+order by m.org_id, m.user_id;
+`
+	var results []*ExportMembershipsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ExportMemberships: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(ExportMembershipsResult)
+		if err := rows.Scan(&result.OrgID, &result.UserID, &result.Admin, &result.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row in ExportMemberships: %w", err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ImportMembershipTx makes a user a member of an organization as part of a larger transaction.
+// An existing membership is updated to match.
+func ImportMembershipTx(tx *sql.Tx, orgID uint64, userID uint64, admin bool, joinedAt time.Time) (sql.Result, error) {
+	const query = `
+insert into membership (org_id, user_id, admin, joined_at) values ($1, $2, $3, $4)
+on conflict (org_id, user_id) do update set admin = excluded.admin, joined_at = excluded.joined_at
+;
+`
+	result, err := tx.Exec(query, orgID, userID, admin, joinedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run ImportMembership: %w", err)
+	}
+	return result, nil
+}
+
+// SeedMembershipTx makes a user a member of an organization as part of a larger transaction,
+// or changes whether they're one of its admins if they already are.
+func SeedMembershipTx(tx *sql.Tx, orgID uint64, userID uint64, admin bool) (sql.Result, error) {
+	const query = `
+insert into membership (org_id, user_id, admin) values ($1, $2, $3)
+on conflict (org_id, user_id) do update set admin = excluded.admin
+;
+`
+	result, err := tx.Exec(query, orgID, userID, admin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run SeedMembership: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,96 @@
+//go:build integration
+
+package model
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"yourapp/foundation/pgtest"
+)
+
+func TestWithTenant(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	acme, err := CreateOrganization(ctx, db, "acme", "Acme")
+	require.NoError(t, err)
+	globex, err := CreateOrganization(ctx, db, "globex", "Globex")
+	require.NoError(t, err)
+	for _, username := range []string{"alice", "bob"} {
+		_, err := CreateUser(ctx, db, username, "secret")
+		require.NoError(t, err)
+	}
+	_, err = AddMember(ctx, db, acme, "alice", true)
+	require.NoError(t, err)
+	_, err = AddMember(ctx, db, globex, "bob", false)
+	require.NoError(t, err)
+	acmeCtx := WithTenant(ctx, acme)
+
+	tests := map[string]struct {
+		ctx             context.Context
+		orgID           uint64
+		expectedMembers int
+	}{
+		"Unscoped": {
+			ctx:             ctx,
+			orgID:           globex,
+			expectedMembers: 1,
+		},
+		"Scoped to the organization": {
+			ctx:             acmeCtx,
+			orgID:           acme,
+			expectedMembers: 1,
+		},
+		"Scoped to another organization": {
+			ctx:   acmeCtx,
+			orgID: globex,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			members, err := GetMembers(tc.ctx, db, tc.orgID)
+			require.NoError(t, err)
+			assert.Len(t, members, tc.expectedMembers)
+		})
+	}
+
+	// Row level security applies even when a query doesn't filter by organization.
+	orgs, err := GetAllOrganizations(acmeCtx, db)
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	assert.Equal(t, "acme", orgs[0].Slug)
+	orgs, err = GetAllOrganizations(ctx, db)
+	require.NoError(t, err)
+	assert.Len(t, orgs, 2)
+
+	bob, err := GetUser(ctx, db, "bob")
+	require.NoError(t, err)
+	_, err = GetMembership(acmeCtx, db, globex, bob.UserID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = AddMember(acmeCtx, db, globex, "alice", true)
+	assert.Error(t, err, "Rows can't be added to another organization")
+
+	// Shared tables aren't granted to the tenant role, so they can't leak even without a filter.
+	_, err = CountActiveSessions(acmeCtx, db)
+	assert.Error(t, err, "Shared tables can't be queried within an organization")
+	_, err = GetAllUsers(acmeCtx, db)
+	assert.Error(t, err, "Only member names can be read within an organization")
+	_, err = AddMember(acmeCtx, db, acme, "bob", false)
+	require.NoError(t, err, "Users can be added by name before they're members")
+	members, err := GetMembers(acmeCtx, db, acme)
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+	// feature:audit begin
+
+	_, err = InsertAuditLog(acmeCtx, db, "alice", "Did something in acme")
+	require.NoError(t, err)
+	_, err = InsertAuditLog(ctx, db, "alice", "Did something")
+	require.NoError(t, err)
+	entries, err := GetLatestLogEntries(acmeCtx, db, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Did something in acme", entries[0].Action)
+	// feature:audit end
+}
@@ -0,0 +1,242 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"yourapp/foundation/mail"
+)
+
+type OutboxRepo struct {
+	enqueueMail    func(context.Context, *sql.DB, mail.Message) (*EnqueueMailResult, error)
+	claimMail      func(context.Context, *sql.DB, int, time.Duration) ([]*ClaimMailResult, error)
+	markMailSent   func(context.Context, *sql.DB, uint64) (sql.Result, error)
+	markMailFailed func(context.Context, *sql.DB, uint64, string, time.Duration) (sql.Result, error)
+}
+
+func (repo *OutboxRepo) RedirectEnqueueMail(delegate func(context.Context, *sql.DB, mail.Message) (*EnqueueMailResult, error)) {
+	repo.enqueueMail = delegate
+}
+
+func (repo *OutboxRepo) EnqueueMail(ctx context.Context, conn *sql.DB, msg mail.Message) (*EnqueueMailResult, error) {
+	if repo.enqueueMail != nil {
+		return repo.enqueueMail(ctx, conn, msg)
+	}
+	return EnqueueMail(ctx, conn, msg)
+}
+
+func (repo *OutboxRepo) RedirectClaimMail(delegate func(context.Context, *sql.DB, int, time.Duration) ([]*ClaimMailResult, error)) {
+	repo.claimMail = delegate
+}
+
+func (repo *OutboxRepo) ClaimMail(ctx context.Context, conn *sql.DB, limit int, lease time.Duration) ([]*ClaimMailResult, error) {
+	if repo.claimMail != nil {
+		return repo.claimMail(ctx, conn, limit, lease)
+	}
+	return ClaimMail(ctx, conn, limit, lease)
+}
+
+func (repo *OutboxRepo) RedirectMarkMailSent(delegate func(context.Context, *sql.DB, uint64) (sql.Result, error)) {
+	repo.markMailSent = delegate
+}
+
+func (repo *OutboxRepo) MarkMailSent(ctx context.Context, conn *sql.DB, messageID uint64) (sql.Result, error) {
+	if repo.markMailSent != nil {
+		return repo.markMailSent(ctx, conn, messageID)
+	}
+	return MarkMailSent(ctx, conn, messageID)
+}
+
+func (repo *OutboxRepo) RedirectMarkMailFailed(delegate func(context.Context, *sql.DB, uint64, string, time.Duration) (sql.Result, error)) {
+	repo.markMailFailed = delegate
+}
+
+func (repo *OutboxRepo) MarkMailFailed(ctx context.Context, conn *sql.DB, messageID uint64, reason string, retryAfter time.Duration) (sql.Result, error) {
+	if repo.markMailFailed != nil {
+		return repo.markMailFailed(ctx, conn, messageID, reason, retryAfter)
+	}
+	return MarkMailFailed(ctx, conn, messageID, reason, retryAfter)
+}
+
+type EnqueueMailResult struct {
+	MessageID uint64 `json:"messageID"`
+}
+
+// EnqueueMail adds a message to the outbox on its own.
+// Use [EnqueueMailTx] instead when the message is triggered by a change, so it's only sent if the change is committed.
+func EnqueueMail(ctx context.Context, conn *sql.DB, msg mail.Message) (*EnqueueMailResult, error) {
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in EnqueueMail: %w", err)
+	}
+
+	var result EnqueueMailResult
+	result.MessageID, err = EnqueueMailTx(tx, msg)
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+// EnqueueMailTx adds a message to the outbox as part of a larger transaction.
+// The message is validated first, so a bad address fails the transaction rather than the send.
+func EnqueueMailTx(tx *sql.Tx, msg mail.Message) (uint64, error) {
+	const query = `
+insert into mail_outbox (from_addr, recipients, subject, text_body, html_body) values ($1, $2, $3, $4, $5) returning id;
+`
+	if err := msg.Validate(); err != nil {
+		return 0, err
+	}
+	var id uint64
+	err := tx.QueryRow(query, msg.From, strings.Join(msg.To, "\n"), msg.Subject, msg.Text, msg.HTML).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run EnqueueMail: %w", err)
+	}
+	return id, nil
+}
+
+// ComposeMail creates the email for a newly inserted row, which is enqueued in the same transaction.
+// It's given the row's ID and expiry, since links in the email usually need them.
+type ComposeMail func(id uint64, expiresAt time.Time) (mail.Message, error)
+
+func enqueueComposed(tx *sql.Tx, compose ComposeMail, id uint64, expiresAt time.Time) error {
+	msg, err := compose(id, expiresAt)
+	if err != nil {
+		return err
+	}
+	_, err = EnqueueMailTx(tx, msg)
+	return err
+}
+
+type ClaimMailResult struct {
+	MessageID uint64 `json:"messageID"`
+	// Attempts includes the attempt the message was just claimed for.
+	Attempts int          `json:"attempts"`
+	Message  mail.Message `json:"message"`
+}
+
+// ClaimMail claims up to limit messages that are due to be sent.
+// Claimed messages aren't due again until the lease has passed, so a sender that dies mid-send doesn't lose them,
+// and rows locked by other senders are skipped so several app instances can send at once.
+func ClaimMail(ctx context.Context, conn *sql.DB, limit int, lease time.Duration) ([]*ClaimMailResult, error) {
+	const query = `
+update mail_outbox
+set attempts = attempts + 1, next_attempt_at = current_timestamp + make_interval(secs => $2)
+where id in (
+    select id from mail_outbox
+    where sent_at is null and failed_at is null and next_attempt_at <= current_timestamp
+    order by next_attempt_at
+    limit $1
+    for update skip locked
+)
+returning id, attempts, from_addr, recipients, subject, text_body, html_body;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in ClaimMail: %w", err)
+	}
+
+	var results []*ClaimMailResult
+	rows, err := tx.Query(query, limit, lease.Seconds())
+	if err != nil {
+		rerr := fmt.Errorf("failed to run ClaimMail: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var (
+			result     = new(ClaimMailResult)
+			recipients string
+		)
+		if err := rows.Scan(&result.MessageID, &result.Attempts, &result.Message.From, &recipients,
+			&result.Message.Subject, &result.Message.Text, &result.Message.HTML); err != nil {
+			rerr := fmt.Errorf("failed to scan row in ClaimMail: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		result.Message.To = strings.Split(recipients, "\n")
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		rerr := fmt.Errorf("failed to read rows in ClaimMail: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return results, tx.Commit()
+}
+
+func MarkMailSent(ctx context.Context, conn *sql.DB, messageID uint64) (sql.Result, error) {
+	const query = `
+update mail_outbox set sent_at = current_timestamp, last_error = null where id = $1;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in MarkMailSent: %w", err)
+	}
+
+	result, err := tx.Exec(query, messageID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run MarkMailSent: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// MarkMailFailed records a failed attempt, which is retried after retryAfter.
+// If retryAfter isn't positive, then the message is given up on.
+func MarkMailFailed(ctx context.Context, conn *sql.DB, messageID uint64, reason string, retryAfter time.Duration) (sql.Result, error) {
+	const query = `
+update mail_outbox
+set last_error = $2,
+    next_attempt_at = current_timestamp + make_interval(secs => greatest($3::float8, 0)),
+    failed_at = case when $3::float8 <= 0 then current_timestamp end
+where id = $1;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in MarkMailFailed: %w", err)
+	}
+
+	result, err := tx.Exec(query, messageID, reason, retryAfter.Seconds())
+	if err != nil {
+		rerr := fmt.Errorf("failed to run MarkMailFailed: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// PurgeSentMail deletes messages that were sent or given up on longer ago than olderThan.
+func PurgeSentMail(ctx context.Context, conn *sql.DB, olderThan time.Duration) (sql.Result, error) {
+	const query = `
+delete from mail_outbox where coalesce(sent_at, failed_at) < current_timestamp - make_interval(secs => $1);
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in PurgeSentMail: %w", err)
+	}
+
+	result, err := tx.Exec(query, olderThan.Seconds())
+	if err != nil {
+		rerr := fmt.Errorf("failed to run PurgeSentMail: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func CountActiveSessions(ctx context.Context, conn *sql.DB) (int64, error) {
+	const query = `
+select count(*) from session where revoked_at > current_timestamp and max_ttl > current_timestamp;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction in CountActiveSessions: %w", err)
+	}
+
+	var count int64
+	if err := tx.QueryRow(query).Scan(&count); err != nil {
+		rerr := fmt.Errorf("failed to run CountActiveSessions: %w", err)
+		return 0, errors.Join(rerr, tx.Rollback())
+	}
+	return count, tx.Commit()
+}
+
+func InvalidateUserSessions(ctx context.Context, conn *sql.DB, username string) (sql.Result, error) {
+	const query = `
+delete from session where user_id = (select id from users where username = $1);
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in InvalidateUserSessions: %w", err)
+	}
+
+	result, err := tx.Exec(query, username)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run InvalidateUserSessions: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+// PurgeExpiredSessions deletes sessions that have been revoked or outlived their max TTL, since they can't be used again.
+func PurgeExpiredSessions(ctx context.Context, conn *sql.DB) (sql.Result, error) {
+	const query = `
+delete from session where revoked_at <= current_timestamp or max_ttl <= current_timestamp;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in PurgeExpiredSessions: %w", err)
+	}
+
+	result, err := tx.Exec(query)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run PurgeExpiredSessions: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
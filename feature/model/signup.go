@@ -0,0 +1,411 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type SignupRepo struct {
+	userExists          func(context.Context, *sql.DB, string, string) (*UserExistsResult, error)
+	createPendingSignup func(context.Context, *sql.DB, string, string, string, ComposeMail) (*CreatePendingSignupResult, error)
+	completeSignup      func(context.Context, *sql.DB, uint64) (*CompleteSignupResult, error)
+	createInvitation    func(context.Context, *sql.DB, CreateInvitationParams, ComposeMail) (*CreateInvitationResult, error)
+	getInvitation       func(context.Context, *sql.DB, uint64) (*GetInvitationResult, error)
+	acceptInvitation    func(context.Context, *sql.DB, uint64, string, string) (*CompleteSignupResult, error)
+}
+
+func (repo *SignupRepo) RedirectUserExists(delegate func(context.Context, *sql.DB, string, string) (*UserExistsResult, error)) {
+	repo.userExists = delegate
+}
+
+func (repo *SignupRepo) UserExists(ctx context.Context, conn *sql.DB, username string, email string) (*UserExistsResult, error) {
+	if repo.userExists != nil {
+		return repo.userExists(ctx, conn, username, email)
+	}
+	return UserExists(ctx, conn, username, email)
+}
+
+func (repo *SignupRepo) RedirectCreatePendingSignup(delegate func(context.Context, *sql.DB, string, string, string, ComposeMail) (*CreatePendingSignupResult, error)) {
+	repo.createPendingSignup = delegate
+}
+
+func (repo *SignupRepo) CreatePendingSignup(ctx context.Context, conn *sql.DB, username string, email string, password string, compose ComposeMail) (*CreatePendingSignupResult, error) {
+	if repo.createPendingSignup != nil {
+		return repo.createPendingSignup(ctx, conn, username, email, password, compose)
+	}
+	return CreatePendingSignup(ctx, conn, username, email, password, compose)
+}
+
+func (repo *SignupRepo) RedirectCompleteSignup(delegate func(context.Context, *sql.DB, uint64) (*CompleteSignupResult, error)) {
+	repo.completeSignup = delegate
+}
+
+func (repo *SignupRepo) CompleteSignup(ctx context.Context, conn *sql.DB, signupID uint64) (*CompleteSignupResult, error) {
+	if repo.completeSignup != nil {
+		return repo.completeSignup(ctx, conn, signupID)
+	}
+	return CompleteSignup(ctx, conn, signupID)
+}
+
+func (repo *SignupRepo) RedirectCreateInvitation(delegate func(context.Context, *sql.DB, CreateInvitationParams, ComposeMail) (*CreateInvitationResult, error)) {
+	repo.createInvitation = delegate
+}
+
+func (repo *SignupRepo) CreateInvitation(ctx context.Context, conn *sql.DB, params CreateInvitationParams, compose ComposeMail) (*CreateInvitationResult, error) {
+	if repo.createInvitation != nil {
+		return repo.createInvitation(ctx, conn, params, compose)
+	}
+	return CreateInvitation(ctx, conn, params, compose)
+}
+
+func (repo *SignupRepo) RedirectGetInvitation(delegate func(context.Context, *sql.DB, uint64) (*GetInvitationResult, error)) {
+	repo.getInvitation = delegate
+}
+
+func (repo *SignupRepo) GetInvitation(ctx context.Context, conn *sql.DB, invitationID uint64) (*GetInvitationResult, error) {
+	if repo.getInvitation != nil {
+		return repo.getInvitation(ctx, conn, invitationID)
+	}
+	return GetInvitation(ctx, conn, invitationID)
+}
+
+func (repo *SignupRepo) RedirectAcceptInvitation(delegate func(context.Context, *sql.DB, uint64, string, string) (*CompleteSignupResult, error)) {
+	repo.acceptInvitation = delegate
+}
+
+func (repo *SignupRepo) AcceptInvitation(ctx context.Context, conn *sql.DB, invitationID uint64, username string, password string) (*CompleteSignupResult, error) {
+	if repo.acceptInvitation != nil {
+		return repo.acceptInvitation(ctx, conn, invitationID, username, password)
+	}
+	return AcceptInvitation(ctx, conn, invitationID, username, password)
+}
+
+type UserExistsResult struct {
+	UsernameTaken bool `json:"usernameTaken"`
+	EmailTaken    bool `json:"emailTaken"`
+}
+
+func UserExists(ctx context.Context, conn *sql.DB, username string, email string) (*UserExistsResult, error) {
+	const query = `
+select
+    exists(select 1 from users where username = $1),
+    exists(select 1 from users where lower(email) = lower($2))
+;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in UserExists: %w", err)
+	}
+
+	var result UserExistsResult
+	err = tx.QueryRow(query, username, email).Scan(&result.UsernameTaken, &result.EmailTaken)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run UserExists: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+type CreatePendingSignupResult struct {
+	SignupID uint64 `json:"signupID"`
+}
+
+// CreatePendingSignup stores a signup until its email address is verified, and enqueues the verification email.
+// Expired signups are deleted while we're here.
+func CreatePendingSignup(ctx context.Context, conn *sql.DB, username string, email string, password string, compose ComposeMail) (*CreatePendingSignupResult, error) {
+	const (
+		query = `
+insert into pending_signup (username, email, pass_hash) values ($1, $2, gen_passwd($3)) returning id, expires_at;
+`
+		cleanupQuery = `
+delete from pending_signup where expires_at < current_timestamp;
+`
+	)
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in CreatePendingSignup: %w", err)
+	}
+
+	var (
+		result    CreatePendingSignupResult
+		expiresAt time.Time
+	)
+	err = tx.QueryRow(query, username, email, password).Scan(&result.SignupID, &expiresAt)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run CreatePendingSignup: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	if err := enqueueComposed(tx, compose, result.SignupID, expiresAt); err != nil {
+		rerr := fmt.Errorf("failed to enqueue email in CreatePendingSignup: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	if _, err := tx.Exec(cleanupQuery); err != nil {
+		rerr := fmt.Errorf("failed to delete expired signups in CreatePendingSignup: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+type CompleteSignupResult struct {
+	UserID   uint64 `json:"userID"`
+	Username string `json:"username"`
+}
+
+// CompleteSignup creates the user for a pending signup once its email address is verified.
+// The pending signup is removed, so it can only be completed once. [sql.ErrNoRows] is returned if it doesn't exist or has expired.
+func CompleteSignup(ctx context.Context, conn *sql.DB, signupID uint64) (*CompleteSignupResult, error) {
+	const (
+		pendingQuery = `
+delete from pending_signup where id = $1 and expires_at > current_timestamp returning username, email, pass_hash;
+`
+		query = `
+insert into users (username, email, email_verified_at, pass_hash) values ($1, $2, current_timestamp, $3) returning id;
+`
+	)
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in CompleteSignup: %w", err)
+	}
+
+	var (
+		result          CompleteSignupResult
+		email, passHash string
+	)
+	err = tx.QueryRow(pendingQuery, signupID).Scan(&result.Username, &email, &passHash)
+	if err != nil {
+		rerr := fmt.Errorf("failed to get pending signup in CompleteSignup: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	err = tx.QueryRow(query, result.Username, email, passHash).Scan(&result.UserID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run CompleteSignup: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+type CreateInvitationParams struct {
+	Email     string
+	InvitedBy uint64
+	Admin     bool
+	AuthIDs   []uint64 // feature:authz
+}
+
+type CreateInvitationResult struct {
+	InvitationID uint64    `json:"invitationID"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// CreateInvitation stores an invitation with its authorizations, and enqueues the invitation email.
+func CreateInvitation(ctx context.Context, conn *sql.DB, params CreateInvitationParams, compose ComposeMail) (*CreateInvitationResult, error) {
+	const (
+		query = `
+insert into invitation (email, invited_by, admin) values ($1, $2, $3) returning id, expires_at;
+`
+		// feature:authz begin
+		authQuery = `
+insert into invitation_auth (invitation_id, auth_id) values ($1, $2);
+`
+		// feature:authz end
+	)
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in CreateInvitation: %w", err)
+	}
+
+	var result CreateInvitationResult
+	err = tx.QueryRow(query, params.Email, params.InvitedBy, params.Admin).Scan(&result.InvitationID, &result.ExpiresAt)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run CreateInvitation: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	// feature:authz begin
+	for _, authID := range params.AuthIDs {
+		if _, err := tx.Exec(authQuery, result.InvitationID, authID); err != nil {
+			rerr := fmt.Errorf("failed to add authorization in CreateInvitation: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+	}
+	// feature:authz end
+	if err := enqueueComposed(tx, compose, result.InvitationID, result.ExpiresAt); err != nil {
+		rerr := fmt.Errorf("failed to enqueue email in CreateInvitation: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+type GetInvitationResult struct {
+	InvitationID uint64    `json:"invitationID"`
+	Email        string    `json:"email"`
+	InvitedBy    string    `json:"invitedBy"`
+	Admin        bool      `json:"admin"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// GetInvitation returns an invitation that is still open, or [sql.ErrNoRows].
+func GetInvitation(ctx context.Context, conn *sql.DB, invitationID uint64) (*GetInvitationResult, error) {
+	const query = `
+select id, email, invited_by, admin, expires_at from open_invitations where id = $1;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetInvitation: %w", err)
+	}
+
+	var result GetInvitationResult
+	err = tx.QueryRow(query, invitationID).Scan(&result.InvitationID, &result.Email, &result.InvitedBy, &result.Admin, &result.ExpiresAt)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetInvitation: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+// AcceptInvitation creates the invited user with the invitation's authorizations, and closes the invitation.
+// [sql.ErrNoRows] is returned if the invitation isn't open.
+func AcceptInvitation(ctx context.Context, conn *sql.DB, invitationID uint64, username string, password string) (*CompleteSignupResult, error) {
+	const (
+		acceptQuery = `
+update invitation set accepted_at = current_timestamp
+where id in (select id from open_invitations where id = $1)
+returning email, admin;
+`
+		query = `
+insert into users (username, email, email_verified_at, admin, pass_hash) values ($1, $2, current_timestamp, $3, gen_passwd($4)) returning id;
+`
+		// feature:authz begin
+		grantQuery = `
+insert into user_authz (user_id, auth_id) select $1, auth_id from invitation_auth where invitation_id = $2;
+`
+		// feature:authz end
+	)
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in AcceptInvitation: %w", err)
+	}
+
+	var (
+		result = CompleteSignupResult{Username: username}
+		email  string
+		admin  bool
+	)
+	err = tx.QueryRow(acceptQuery, invitationID).Scan(&email, &admin)
+	if err != nil {
+		rerr := fmt.Errorf("failed to get open invitation in AcceptInvitation: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	err = tx.QueryRow(query, username, email, admin, password).Scan(&result.UserID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run AcceptInvitation: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	// feature:authz begin
+	if _, err := tx.Exec(grantQuery, result.UserID, invitationID); err != nil {
+		rerr := fmt.Errorf("failed to grant authorizations in AcceptInvitation: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	// feature:authz end
+	return &result, tx.Commit()
+}
+
+type ListInvitationsResult struct {
+	InvitationID   uint64    `json:"invitationID"`
+	Email          string    `json:"email"`
+	InvitedBy      string    `json:"invitedBy"`
+	Admin          bool      `json:"admin"`
+	Authorizations string    `json:"authorizations"` // feature:authz
+	CreatedAt      time.Time `json:"createdAt"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+// ListInvitations lists the invitations that are still open.
+func ListInvitations(ctx context.Context, conn *sql.DB) ([]*ListInvitationsResult, error) {
+	const query = `
+select
+    i.id,
+    i.email,
+    i.invited_by,
+    i.admin,
+    coalesce(string_agg(a.auth, ', ' order by a.auth), ''), -- feature:authz
+    i.created_at,
+    i.expires_at
+from open_invitations i
+    -- feature:authz begin
+    left join invitation_auth ia on ia.invitation_id = i.id
+    left join authorizations a on a.id = ia.auth_id
+    -- feature:authz end
+group by i.id, i.email, i.invited_by, i.admin, i.created_at, i.expires_at
+order by i.created_at desc
+;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in ListInvitations: %w", err)
+	}
+
+	var results []*ListInvitationsResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run ListInvitations: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(ListInvitationsResult)
+		// feature:!authz: if err := rows.Scan(&result.InvitationID, &result.Email, &result.InvitedBy, &result.Admin,
+		if err := rows.Scan(&result.InvitationID, &result.Email, &result.InvitedBy, &result.Admin, &result.Authorizations, // feature:authz
+			&result.CreatedAt, &result.ExpiresAt); err != nil {
+			rerr := fmt.Errorf("failed to scan row in ListInvitations: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+func RevokeInvitation(ctx context.Context, conn *sql.DB, invitationID uint64) (sql.Result, error) {
+	const query = `
+update invitation set revoked_at = current_timestamp
+where id = $1 and accepted_at is null and revoked_at is null;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in RevokeInvitation: %w", err)
+	}
+
+	result, err := tx.Exec(query, invitationID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run RevokeInvitation: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// tenantRole is the database role that transactions for an organization run as, see infra/pg/sql/06_tenants.sql.
+const tenantRole = "yourapp_tenant"
+
+type tenantKey string
+
+const tenantIDKey = tenantKey("tenantID")
+
+// WithTenant scopes the transactions begun with ctx to an organization.
+// Row level security then limits them to that organization's rows, even if a query doesn't filter by it.
+func WithTenant(ctx context.Context, orgID uint64) context.Context {
+	return context.WithValue(ctx, tenantIDKey, orgID)
+}
+
+// TenantID returns the organization that ctx is scoped to, if any.
+func TenantID(ctx context.Context) (uint64, bool) {
+	orgID, ok := ctx.Value(tenantIDKey).(uint64)
+	return orgID, ok && orgID != 0
+}
+
+// BeginTx begins a transaction like [sql.DB.BeginTx], which is scoped to the organization set with [WithTenant] if there is one.
+// Every query in this package begins its transaction with it, even single statements, so the scope applies to all of them.
+// New queries should do the same, rather than using conn directly.
+func BeginTx(ctx context.Context, conn *sql.DB, opts *sql.TxOptions) (*sql.Tx, error) {
+	const query = `
+select set_config('app.tenant_id', $1, true);
+`
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	orgID, ok := TenantID(ctx)
+	if !ok {
+		return tx, nil
+	}
+	if _, err := tx.Exec("set local role " + tenantRole); err != nil {
+		rerr := fmt.Errorf("failed to set tenant role: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	if _, err := tx.Exec(query, strconv.FormatUint(orgID, 10)); err != nil {
+		rerr := fmt.Errorf("failed to set tenant: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return tx, nil
+}
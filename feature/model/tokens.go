@@ -0,0 +1,347 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type TokensRepo struct {
+	createToken  func(context.Context, *sql.DB, CreateTokenParams) (*CreateTokenResult, error)
+	getTokenUser func(context.Context, *sql.DB, string) (*GetTokenUserResult, error)
+	tokenAuth    func(context.Context, *sql.DB, uint64, uint64) ([]*UserAuthResult, error) // feature:authz
+	touchToken   func(context.Context, *sql.DB, uint64) (sql.Result, error)
+}
+
+func (repo *TokensRepo) RedirectCreateToken(delegate func(context.Context, *sql.DB, CreateTokenParams) (*CreateTokenResult, error)) {
+	repo.createToken = delegate
+}
+
+func (repo *TokensRepo) CreateToken(ctx context.Context, conn *sql.DB, params CreateTokenParams) (*CreateTokenResult, error) {
+	if repo.createToken != nil {
+		return repo.createToken(ctx, conn, params)
+	}
+	return CreateToken(ctx, conn, params)
+}
+
+func (repo *TokensRepo) RedirectGetTokenUser(delegate func(context.Context, *sql.DB, string) (*GetTokenUserResult, error)) {
+	repo.getTokenUser = delegate
+}
+
+func (repo *TokensRepo) GetTokenUser(ctx context.Context, conn *sql.DB, tokenHash string) (*GetTokenUserResult, error) {
+	if repo.getTokenUser != nil {
+		return repo.getTokenUser(ctx, conn, tokenHash)
+	}
+	return GetTokenUser(ctx, conn, tokenHash)
+}
+
+// feature:authz begin
+
+func (repo *TokensRepo) RedirectTokenAuth(delegate func(context.Context, *sql.DB, uint64, uint64) ([]*UserAuthResult, error)) {
+	repo.tokenAuth = delegate
+}
+
+func (repo *TokensRepo) TokenAuth(ctx context.Context, conn *sql.DB, tokenID uint64, userID uint64) ([]*UserAuthResult, error) {
+	if repo.tokenAuth != nil {
+		return repo.tokenAuth(ctx, conn, tokenID, userID)
+	}
+	return TokenAuth(ctx, conn, tokenID, userID)
+}
+
+// feature:authz end
+
+func (repo *TokensRepo) RedirectTouchToken(delegate func(context.Context, *sql.DB, uint64) (sql.Result, error)) {
+	repo.touchToken = delegate
+}
+
+func (repo *TokensRepo) TouchToken(ctx context.Context, conn *sql.DB, tokenID uint64) (sql.Result, error) {
+	if repo.touchToken != nil {
+		return repo.touchToken(ctx, conn, tokenID)
+	}
+	return TouchToken(ctx, conn, tokenID)
+}
+
+type CreateTokenParams struct {
+	UserID    uint64
+	Name      string
+	TokenHash string
+	Hint      string
+	Admin     bool
+	ExpiresAt *time.Time
+	AuthIDs   []uint64 // feature:authz
+}
+
+type CreateTokenResult struct {
+	TokenID uint64 `json:"tokenID"`
+}
+
+func CreateToken(ctx context.Context, conn *sql.DB, params CreateTokenParams) (*CreateTokenResult, error) {
+	const (
+		query = `
+insert into api_token (user_id, name, token_hash, hint, admin, expires_at) values ($1, $2, $3, $4, $5, $6) returning id;
+`
+		// feature:authz begin
+		scopeQuery = `
+insert into api_token_scope (token_id, auth_id) values ($1, $2);
+`
+		// feature:authz end
+	)
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in CreateToken: %w", err)
+	}
+
+	var result CreateTokenResult
+	err = tx.QueryRow(query, params.UserID, params.Name, params.TokenHash, params.Hint, params.Admin, params.ExpiresAt).Scan(&result.TokenID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run CreateToken: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	// feature:authz begin
+	for _, authID := range params.AuthIDs {
+		if _, err := tx.Exec(scopeQuery, result.TokenID, authID); err != nil {
+			rerr := fmt.Errorf("failed to add scope in CreateToken: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+	}
+	// feature:authz end
+	return &result, tx.Commit()
+}
+
+type GetTokenUserResult struct {
+	TokenID        uint64 `json:"tokenID"`
+	UserID         uint64 `json:"userID"`
+	Username       string `json:"username"`
+	Admin          bool   `json:"admin"`
+	ServiceAccount bool   `json:"serviceAccount"`
+}
+
+func GetTokenUser(ctx context.Context, conn *sql.DB, tokenHash string) (*GetTokenUserResult, error) {
+	const query = `
+select id, user_id, username, admin, service_account from live_tokens where token_hash = $1;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetTokenUser: %w", err)
+	}
+
+	var result GetTokenUserResult
+	err = tx.QueryRow(query, tokenHash).Scan(&result.TokenID, &result.UserID, &result.Username, &result.Admin, &result.ServiceAccount)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetTokenUser: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return &result, tx.Commit()
+}
+
+// feature:authz begin
+
+// TokenAuth returns the token's scopes that the user is still granted.
+func TokenAuth(ctx context.Context, conn *sql.DB, tokenID uint64, userID uint64) ([]*UserAuthResult, error) {
+	const query = `
+select g.auth_id, g.auth, g.granted
+from api_token_scope s
+    join auth_grants g on g.auth_id = s.auth_id
+where s.token_id = $1 and g.user_id = $2
+;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in TokenAuth: %w", err)
+	}
+
+	var results []*UserAuthResult
+	rows, err := tx.Query(query, tokenID, userID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run TokenAuth: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(UserAuthResult)
+		if err := rows.Scan(&result.Id, &result.Auth, &result.Granted); err != nil {
+			rerr := fmt.Errorf("failed to scan row in TokenAuth: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+// feature:authz end
+
+// TouchToken records that a token was used.
+// This is only written once a minute per token, so busy clients don't cause a write on every request.
+func TouchToken(ctx context.Context, conn *sql.DB, tokenID uint64) (sql.Result, error) {
+	const query = `
+update api_token set last_used_at = current_timestamp
+where id = $1 and (last_used_at is null or last_used_at < current_timestamp - interval '1 minute');
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in TouchToken: %w", err)
+	}
+
+	result, err := tx.Exec(query, tokenID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run TouchToken: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+type ListTokensResult struct {
+	TokenID    uint64     `json:"tokenID"`
+	Username   string     `json:"username"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Admin      bool       `json:"admin"`
+	Scopes     string     `json:"scopes"` // feature:authz
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+const listTokensQuery = `
+select
+    t.id,
+    t.username,
+    t.name,
+    t.hint,
+    t.admin,
+    coalesce(string_agg(a.auth, ', ' order by a.auth), ''), -- feature:authz
+    t.created_at,
+    t.expires_at,
+    t.last_used_at
+from live_tokens t
+    -- feature:authz begin
+    left join api_token_scope s on s.token_id = t.id
+    left join authorizations a on a.id = s.auth_id
+    -- feature:authz end
+where $1 = 0 or t.user_id = $1
+group by t.id, t.username, t.name, t.hint, t.admin, t.created_at, t.expires_at, t.last_used_at
+order by t.username, t.created_at desc
+;
+`
+
+// ListTokens lists the live tokens for a user, or for all users if userID is 0.
+func ListTokens(ctx context.Context, conn *sql.DB, userID uint64) ([]*ListTokensResult, error) {
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in ListTokens: %w", err)
+	}
+
+	var results []*ListTokensResult
+	rows, err := tx.Query(listTokensQuery, userID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run ListTokens: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(ListTokensResult)
+		// feature:!authz: if err := rows.Scan(&result.TokenID, &result.Username, &result.Name, &result.Hint, &result.Admin,
+		if err := rows.Scan(&result.TokenID, &result.Username, &result.Name, &result.Hint, &result.Admin, &result.Scopes, // feature:authz
+			&result.CreatedAt, &result.ExpiresAt, &result.LastUsedAt); err != nil {
+			rerr := fmt.Errorf("failed to scan row in ListTokens: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
+
+// RevokeToken revokes a token owned by the user, or any token if userID is 0.
+func RevokeToken(ctx context.Context, conn *sql.DB, tokenID uint64, userID uint64) (sql.Result, error) {
+	const query = `
+update api_token set revoked_at = current_timestamp
+where id = $1 and ($2 = 0 or user_id = $2) and revoked_at is null;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in RevokeToken: %w", err)
+	}
+
+	result, err := tx.Exec(query, tokenID, userID)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run RevokeToken: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+func CreateServiceAccount(ctx context.Context, conn *sql.DB, username string) (sql.Result, error) {
+	const query = `
+insert into users (username, pass_hash, service_account) values ($1, 'locked', true);
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in CreateServiceAccount: %w", err)
+	}
+
+	result, err := tx.Exec(query, username)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run CreateServiceAccount: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
+}
+
+func GetServiceAccounts(ctx context.Context, conn *sql.DB) ([]*GetAllUsersResult, error) {
+	const query = `
+select id, username, admin from users where service_account order by username;
+`
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in GetServiceAccounts: %w", err)
+	}
+
+	var results []*GetAllUsersResult
+	rows, err := tx.Query(query)
+	if err != nil {
+		rerr := fmt.Errorf("failed to run GetServiceAccounts: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		result := new(GetAllUsersResult)
+		if err := rows.Scan(&result.UserID, &result.Username, &result.Admin); err != nil {
+			rerr := fmt.Errorf("failed to scan row in GetServiceAccounts: %w", err)
+			return nil, errors.Join(rerr, tx.Rollback())
+		}
+		results = append(results, result)
+	}
+	return results, tx.Commit()
+}
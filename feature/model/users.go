@@ -17,17 +17,17 @@ type UsersRepo struct {
 	lockUser              func(context.Context, *sql.DB, string) (sql.Result, error)
 	deleteUser            func(context.Context, *sql.DB, string) (sql.Result, error)
 	elevateToAdmin        func(context.Context, *sql.DB, string) (sql.Result, error)
-	insertAuditLog        func(context.Context, *sql.DB, string, string) (sql.Result, error)
+	insertAuditLog        func(context.Context, *sql.DB, string, string) (sql.Result, error) // feature:audit
 	createSession         func(context.Context, *sql.DB, string) (*CreateSessionResult, error)
 	updateSessionLiveness func(context.Context, *sql.DB, string) (sql.Result, error)
 	getSessionUser        func(context.Context, *sql.DB, string) (*GetSessionUserResult, error)
 	invalidateSession     func(context.Context, *sql.DB, string) (sql.Result, error)
-	getLatestLogEntries   func(context.Context, *sql.DB, int) ([]*GetLatestLogEntriesResult, error)
-	grantAuth             func(context.Context, *sql.DB, string, string) (sql.Result, error)
-	revokeAuth            func(context.Context, *sql.DB, string, string) (sql.Result, error)
-	getAuthorizations     func(context.Context, *sql.DB) ([]*GetAuthorizationsResult, error)
-	userAuth              func(context.Context, *sql.DB, uint64) ([]*UserAuthResult, error)
-	userAuthNotGranted    func(context.Context, *sql.DB, string) ([]*UserAuthNotGrantedResult, error)
+	getLatestLogEntries   func(context.Context, *sql.DB, int) ([]*GetLatestLogEntriesResult, error)   // feature:audit
+	grantAuth             func(context.Context, *sql.DB, string, string) (sql.Result, error)          // feature:authz
+	revokeAuth            func(context.Context, *sql.DB, string, string) (sql.Result, error)          // feature:authz
+	getAuthorizations     func(context.Context, *sql.DB) ([]*GetAuthorizationsResult, error)          // feature:authz
+	userAuth              func(context.Context, *sql.DB, uint64) ([]*UserAuthResult, error)           // feature:authz
+	userAuthNotGranted    func(context.Context, *sql.DB, string) ([]*UserAuthNotGrantedResult, error) // feature:authz
 }
 
 func (repo *UsersRepo) RedirectCreateUser(delegate func(context.Context, *sql.DB, string, string) (sql.Result, error)) {
@@ -118,17 +118,6 @@ func (repo *UsersRepo) ElevateToAdmin(ctx context.Context, conn *sql.DB, usernam
 	return ElevateToAdmin(ctx, conn, username)
 }
 
-func (repo *UsersRepo) RedirectInsertAuditLog(delegate func(context.Context, *sql.DB, string, string) (sql.Result, error)) {
-	repo.insertAuditLog = delegate
-}
-
-func (repo *UsersRepo) InsertAuditLog(ctx context.Context, conn *sql.DB, username string, message string) (sql.Result, error) {
-	if repo.insertAuditLog != nil {
-		return repo.insertAuditLog(ctx, conn, username, message)
-	}
-	return InsertAuditLog(ctx, conn, username, message)
-}
-
 func (repo *UsersRepo) RedirectCreateSession(delegate func(context.Context, *sql.DB, string) (*CreateSessionResult, error)) {
 	repo.createSession = delegate
 }
@@ -173,77 +162,11 @@ func (repo *UsersRepo) InvalidateSession(ctx context.Context, conn *sql.DB, sess
 	return InvalidateSession(ctx, conn, sessionKey)
 }
 
-func (repo *UsersRepo) RedirectGetLatestLogEntries(delegate func(context.Context, *sql.DB, int) ([]*GetLatestLogEntriesResult, error)) {
-	repo.getLatestLogEntries = delegate
-}
-
-func (repo *UsersRepo) GetLatestLogEntries(ctx context.Context, conn *sql.DB, limit int) ([]*GetLatestLogEntriesResult, error) {
-	if repo.getLatestLogEntries != nil {
-		return repo.getLatestLogEntries(ctx, conn, limit)
-	}
-	return GetLatestLogEntries(ctx, conn, limit)
-}
-
-func (repo *UsersRepo) RedirectGrantAuth(delegate func(context.Context, *sql.DB, string, string) (sql.Result, error)) {
-	repo.grantAuth = delegate
-}
-
-func (repo *UsersRepo) GrantAuth(ctx context.Context, conn *sql.DB, userID string, authID string) (sql.Result, error) {
-	if repo.grantAuth != nil {
-		return repo.grantAuth(ctx, conn, userID, authID)
-	}
-	return GrantAuth(ctx, conn, userID, authID)
-}
-
-func (repo *UsersRepo) RedirectRevokeAuth(delegate func(context.Context, *sql.DB, string, string) (sql.Result, error)) {
-	repo.revokeAuth = delegate
-}
-
-func (repo *UsersRepo) RevokeAuth(ctx context.Context, conn *sql.DB, userID string, authID string) (sql.Result, error) {
-	if repo.revokeAuth != nil {
-		return repo.revokeAuth(ctx, conn, userID, authID)
-	}
-	return RevokeAuth(ctx, conn, userID, authID)
-}
-
-func (repo *UsersRepo) RedirectGetAuthorizations(delegate func(context.Context, *sql.DB) ([]*GetAuthorizationsResult, error)) {
-	repo.getAuthorizations = delegate
-}
-
-func (repo *UsersRepo) GetAuthorizations(ctx context.Context, conn *sql.DB) ([]*GetAuthorizationsResult, error) {
-	if repo.getAuthorizations != nil {
-		return repo.getAuthorizations(ctx, conn)
-	}
-	return GetAuthorizations(ctx, conn)
-}
-
-func (repo *UsersRepo) RedirectUserAuth(delegate func(context.Context, *sql.DB, uint64) ([]*UserAuthResult, error)) {
-	repo.userAuth = delegate
-}
-
-func (repo *UsersRepo) UserAuth(ctx context.Context, conn *sql.DB, userID uint64) ([]*UserAuthResult, error) {
-	if repo.userAuth != nil {
-		return repo.userAuth(ctx, conn, userID)
-	}
-	return UserAuth(ctx, conn, userID)
-}
-
-func (repo *UsersRepo) RedirectUserAuthNotGranted(delegate func(context.Context, *sql.DB, string) ([]*UserAuthNotGrantedResult, error)) {
-	repo.userAuthNotGranted = delegate
-}
-
-func (repo *UsersRepo) UserAuthNotGranted(ctx context.Context, conn *sql.DB, username string) ([]*UserAuthNotGrantedResult, error) {
-	if repo.userAuthNotGranted != nil {
-		return repo.userAuthNotGranted(ctx, conn, username)
-	}
-	return UserAuthNotGranted(ctx, conn, username)
-}
-
 func CreateUser(ctx context.Context, conn *sql.DB, username string, password string) (sql.Result, error) {
 	const query = `
 insert into users (username, pass_hash) values ($1, gen_passwd($2));
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  false,
 	})
@@ -263,7 +186,7 @@ func UpdatePassword(ctx context.Context, conn *sql.DB, username string, password
 	const query = `
 update users set pass_hash = gen_passwd($2) where username = $1;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  false,
 	})
@@ -287,7 +210,7 @@ func CheckPassword(ctx context.Context, conn *sql.DB, username string, password
 	const query = `
 select check_passwd($1, $2);
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  true,
 	})
@@ -314,7 +237,7 @@ func GetUser(ctx context.Context, conn *sql.DB, username string) (*GetUserResult
 	const query = `
 select id, username, admin from users where username = $1;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  true,
 	})
@@ -341,7 +264,7 @@ func GetAllUsers(ctx context.Context, conn *sql.DB) ([]*GetAllUsersResult, error
 	const query = `
 select id, username, admin from users;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  true,
 	})
@@ -369,23 +292,43 @@ select id, username, admin from users;
 	return results, tx.Commit()
 }
 
+// LockUser replaces the user's password hash so that no password matches it, and revokes their API tokens in the same transaction.
+// The tokens are revoked explicitly, since live_tokens can't tell a locked user from a service account, which has no password either.
 func LockUser(ctx context.Context, conn *sql.DB, username string) (sql.Result, error) {
-	const query = `
---- @write-tx
+	const (
+		query = `
 update users set pass_hash = 'locked' where username = $1;
 `
-	result, err := conn.ExecContext(ctx, query, username)
+		revokeQuery = `
+update api_token set revoked_at = current_timestamp
+where user_id = (select id from users where username = $1) and revoked_at is null;
+`
+	)
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+		ReadOnly:  false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction in LockUser: %w", err)
+	}
+
+	result, err := tx.Exec(query, username)
 	if err != nil {
-		return nil, fmt.Errorf("failed to run LockUser: %w", err)
+		rerr := fmt.Errorf("failed to run LockUser: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
 	}
-	return result, nil
+	if _, err := tx.Exec(revokeQuery, username); err != nil {
+		rerr := fmt.Errorf("failed to revoke tokens in LockUser: %w", err)
+		return nil, errors.Join(rerr, tx.Rollback())
+	}
+	return result, tx.Commit()
 }
 
 func DeleteUser(ctx context.Context, conn *sql.DB, username string) (sql.Result, error) {
 	const query = `
 delete from users where username = $1;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  false,
 	})
@@ -405,7 +348,7 @@ func ElevateToAdmin(ctx context.Context, conn *sql.DB, username string) (sql.Res
 	const query = `
 update users set admin = true where username = $1;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  false,
 	})
@@ -421,17 +364,6 @@ update users set admin = true where username = $1;
 	return result, tx.Commit()
 }
 
-func InsertAuditLog(ctx context.Context, conn *sql.DB, username string, message string) (sql.Result, error) {
-	const query = `
-insert into user_audit (username, action) values ($1, $2);
-`
-	result, err := conn.ExecContext(ctx, query, username, message)
-	if err != nil {
-		return nil, fmt.Errorf("failed to run InsertAuditLog: %w", err)
-	}
-	return result, nil
-}
-
 type CreateSessionResult struct {
 	SessionKey string `json:"sessionKey"`
 }
@@ -440,7 +372,7 @@ func CreateSession(ctx context.Context, conn *sql.DB, username string) (*CreateS
 	const query = `
 select create_session($1);
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  false,
 	})
@@ -461,7 +393,7 @@ func UpdateSessionLiveness(ctx context.Context, conn *sql.DB, sessionKey string)
 	const query = `
 call update_session_ttl($1);
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  false,
 	})
@@ -491,7 +423,7 @@ from session s
 where s.session_key = $1
     and s.revoked_at > current_timestamp;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  true,
 	})
@@ -512,7 +444,7 @@ func InvalidateSession(ctx context.Context, conn *sql.DB, sessionKey string) (sq
 	const query = `
 delete from session where session_key = $1;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+	tx, err := BeginTx(ctx, conn, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 		ReadOnly:  false,
 	})
@@ -528,217 +460,83 @@ delete from session where session_key = $1;
 	return result, tx.Commit()
 }
 
-type GetLatestLogEntriesResult struct {
-	Username  string    `json:"username"`
-	EventTime time.Time `json:"eventTime"`
-	Action    string    `json:"action"`
-}
-
-func GetLatestLogEntries(ctx context.Context, conn *sql.DB, limit int) ([]*GetLatestLogEntriesResult, error) {
-	const query = `
-select
-    user_id,
-    username,
-    event_time,
-    action
-from (
-    select
-        u.id "user_id",
-        u.username,
-        event_time,
-        action
-    from user_audit ua
-    left join users u on ua.username = u.username
-    order by event_time desc
-    limit $1
-) segment
-order by event_time;
-`
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelDefault,
-		ReadOnly:  true,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction in GetLatestLogEntries: %w", err)
-	}
-
-	var results []*GetLatestLogEntriesResult
-	rows, err := tx.Query(query, limit)
-	if err != nil {
-		rerr := fmt.Errorf("failed to run GetLatestLogEntries: %w", err)
-		return nil, errors.Join(rerr, tx.Rollback())
-	}
-	defer func() {
-		_ = rows.Close()
-	}()
-	for rows.Next() {
-		result := new(GetLatestLogEntriesResult)
-		if err := rows.Scan(&result.Username, &result.EventTime, &result.Action); err != nil {
-			rerr := fmt.Errorf("failed to scan row in GetLatestLogEntries: %w", err)
-			return nil, errors.Join(rerr, tx.Rollback())
-		}
-		results = append(results, result)
-	}
-	return results, tx.Commit()
-}
-
-func GrantAuth(ctx context.Context, conn *sql.DB, userID string, authID string) (sql.Result, error) {
-	const query = `
-insert into user_authz (user_id, auth_id) values ($1, $2)
-on conflict (user_id, auth_id) do update set revoked = null, granted = current_timestamp
-;
-`
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelDefault,
-		ReadOnly:  false,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction in GrantAuth: %w", err)
-	}
-
-	result, err := tx.Exec(query, userID, authID)
-	if err != nil {
-		rerr := fmt.Errorf("failed to run GrantAuth: %w", err)
-		return nil, errors.Join(rerr, tx.Rollback())
-	}
-	return result, tx.Commit()
-}
-
-func RevokeAuth(ctx context.Context, conn *sql.DB, userID string, authID string) (sql.Result, error) {
-	const query = `
-update user_authz set revoked = current_timestamp
-where
-    user_id = $1
-    and auth_id = $2
-;
-`
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelDefault,
-		ReadOnly:  false,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction in RevokeAuth: %w", err)
-	}
-
-	result, err := tx.Exec(query, userID, authID)
-	if err != nil {
-		rerr := fmt.Errorf("failed to run RevokeAuth: %w", err)
-		return nil, errors.Join(rerr, tx.Rollback())
-	}
-	return result, tx.Commit()
-}
-
-type GetAuthorizationsResult struct {
-	AuthID uint64 `json:"authID"`
-	Name   string `json:"name"`
+type ExportUsersResult struct {
+	UserID          uint64     `json:"userID"`
+	Username        string     `json:"username"`
+	Admin           bool       `json:"admin"`
+	ServiceAccount  bool       `json:"serviceAccount"`
+	Email           *string    `json:"email"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	PassHash        string     `json:"passHash"`
 }
 
-func GetAuthorizations(ctx context.Context, conn *sql.DB) ([]*GetAuthorizationsResult, error) {
+// ExportUsersTx returns every user along with their password hash, as part of a larger transaction.
+func ExportUsersTx(tx *sql.Tx) ([]*ExportUsersResult, error) {
 	const query = `
-select id, auth from authorizations;
+select id, username, coalesce(admin, false), service_account, email, email_verified_at, pass_hash
+from users
+where username is not null
+order by id;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelDefault,
-		ReadOnly:  true,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction in GetAuthorizations: %w", err)
-	}
-
-	var results []*GetAuthorizationsResult
+	var results []*ExportUsersResult
 	rows, err := tx.Query(query)
 	if err != nil {
-		rerr := fmt.Errorf("failed to run GetAuthorizations: %w", err)
-		return nil, errors.Join(rerr, tx.Rollback())
+		return nil, fmt.Errorf("failed to run ExportUsers: %w", err)
 	}
 	defer func() {
 		_ = rows.Close()
 	}()
 	for rows.Next() {
-		result := new(GetAuthorizationsResult)
-		if err := rows.Scan(&result.AuthID, &result.Name); err != nil {
-			rerr := fmt.Errorf("failed to scan row in GetAuthorizations: %w", err)
-			return nil, errors.Join(rerr, tx.Rollback())
+		result := new(ExportUsersResult)
+		if err := rows.Scan(&result.UserID, &result.Username, &result.Admin, &result.ServiceAccount, &result.Email, &result.EmailVerifiedAt, &result.PassHash); err != nil {
+			return nil, fmt.Errorf("failed to scan row in ExportUsers: %w", err)
 		}
 		results = append(results, result)
 	}
-	return results, tx.Commit()
+	return results, rows.Err()
 }
 
-type UserAuthResult struct {
-	Id      uint64    `json:"id"`
-	Auth    string    `json:"auth"`
-	Granted time.Time `json:"granted"`
+type ImportUserParams struct {
+	Username        string     `json:"username"`
+	Admin           bool       `json:"admin"`
+	ServiceAccount  bool       `json:"serviceAccount"`
+	Email           *string    `json:"email"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	PassHash        string     `json:"passHash"`
 }
 
-func UserAuth(ctx context.Context, conn *sql.DB, userID uint64) ([]*UserAuthResult, error) {
+// ImportUserTx creates a user with an existing password hash as part of a larger transaction, returning their ID.
+// A user with the same username is updated to match instead.
+func ImportUserTx(tx *sql.Tx, params ImportUserParams) (uint64, error) {
 	const query = `
-select auth_id, auth, granted from auth_grants where user_id = $1;
+insert into users (username, admin, service_account, email, email_verified_at, pass_hash) values ($1, $2, $3, $4, $5, $6)
+on conflict (username) do update set
+    admin = excluded.admin,
+    service_account = excluded.service_account,
+    email = excluded.email,
+    email_verified_at = excluded.email_verified_at,
+    pass_hash = excluded.pass_hash
+returning id;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelDefault,
-		ReadOnly:  true,
-	})
+	var userID uint64
+	err := tx.QueryRow(query, params.Username, params.Admin, params.ServiceAccount, params.Email, params.EmailVerifiedAt, params.PassHash).Scan(&userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction in UserAuth: %w", err)
+		return 0, fmt.Errorf("failed to run ImportUser: %w", err)
 	}
-
-	var results []*UserAuthResult
-	rows, err := tx.Query(query, userID)
-	if err != nil {
-		rerr := fmt.Errorf("failed to run UserAuth: %w", err)
-		return nil, errors.Join(rerr, tx.Rollback())
-	}
-	defer func() {
-		_ = rows.Close()
-	}()
-	for rows.Next() {
-		result := new(UserAuthResult)
-		if err := rows.Scan(&result.Id, &result.Auth, &result.Granted); err != nil {
-			rerr := fmt.Errorf("failed to scan row in UserAuth: %w", err)
-			return nil, errors.Join(rerr, tx.Rollback())
-		}
-		results = append(results, result)
-	}
-	return results, tx.Commit()
+	return userID, nil
 }
 
-type UserAuthNotGrantedResult struct {
-	Id   uint64 `json:"id"`
-	Auth string `json:"auth"`
-}
-
-func UserAuthNotGranted(ctx context.Context, conn *sql.DB, username string) ([]*UserAuthNotGrantedResult, error) {
+// SeedUserTx creates a user with a password as part of a larger transaction, returning their ID.
+// A user with the same username gets the new password and admin flag instead.
+func SeedUserTx(tx *sql.Tx, username string, password string, admin bool) (uint64, error) {
 	const query = `
-select id, auth
-from authorizations
-where id not in (select auth_id from auth_grants where username = $1)
-;
+insert into users (username, admin, pass_hash) values ($1, $2, gen_passwd($3))
+on conflict (username) do update set admin = excluded.admin, pass_hash = excluded.pass_hash
+returning id;
 `
-	tx, err := conn.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelDefault,
-		ReadOnly:  true,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction in UserAuthNotGranted: %w", err)
-	}
-
-	var results []*UserAuthNotGrantedResult
-	rows, err := tx.Query(query, username)
-	if err != nil {
-		rerr := fmt.Errorf("failed to run UserAuthNotGranted: %w", err)
-		return nil, errors.Join(rerr, tx.Rollback())
+	var userID uint64
+	if err := tx.QueryRow(query, username, admin, password).Scan(&userID); err != nil {
+		return 0, fmt.Errorf("failed to run SeedUser: %w", err)
 	}
-	defer func() {
-		_ = rows.Close()
-	}()
-	for rows.Next() {
-		result := new(UserAuthNotGrantedResult)
-		if err := rows.Scan(&result.Id, &result.Auth); err != nil {
-			rerr := fmt.Errorf("failed to scan row in UserAuthNotGranted: %w", err)
-			return nil, errors.Join(rerr, tx.Rollback())
-		}
-		results = append(results, result)
-	}
-	return results, tx.Commit()
+	return userID, nil
 }
@@ -0,0 +1,168 @@
+//go:build integration
+
+package model
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"yourapp/foundation/pgtest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Main(m, filepath.Join("..", "..", "infra", "pg", "sql")))
+}
+
+func TestPendingMigrations(t *testing.T) {
+	pending, err := PendingMigrations(context.Background(), pgtest.DB(t))
+	require.NoError(t, err)
+	assert.Empty(t, pending, "Every script in RequiredMigrations should record itself when applied")
+}
+
+func TestCheckPassword(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	_, err := CreateUser(ctx, db, "alice", "correct horse")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		username string
+		password string
+		expected bool
+	}{
+		"Correct password": {
+			username: "alice",
+			password: "correct horse",
+			expected: true,
+		},
+		"Wrong password": {
+			username: "alice",
+			password: "battery staple",
+		},
+		"Unknown user": {
+			username: "bob",
+			password: "correct horse",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := CheckPassword(ctx, db, tc.username, tc.password)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result.Matches)
+		})
+	}
+}
+
+func TestCreateSession(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	_, err := CreateUser(ctx, db, "alice", "correct horse")
+	require.NoError(t, err)
+
+	first, err := CreateSession(ctx, db, "alice")
+	require.NoError(t, err)
+	user, err := GetSessionUser(ctx, db, first.SessionKey)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	second, err := CreateSession(ctx, db, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, first.SessionKey, second.SessionKey, "A live session should be reused")
+
+	_, err = CreateSession(ctx, db, "bob")
+	assert.ErrorContains(t, err, "No user with that username exists")
+}
+
+func TestUpdateSessionLiveness(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	_, err := CreateUser(ctx, db, "alice", "correct horse")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		revokedIn      time.Duration
+		maxTTLIn       time.Duration
+		expectedCapped bool
+		expectedErr    string
+	}{
+		"Extended": {
+			revokedIn: time.Minute,
+			maxTTLIn:  time.Hour,
+		},
+		"Capped at max_ttl": {
+			revokedIn:      time.Minute,
+			maxTTLIn:       10 * time.Minute,
+			expectedCapped: true,
+		},
+		"Idle too long": {
+			revokedIn:   -time.Second,
+			maxTTLIn:    time.Hour,
+			expectedErr: "No live sessions exist with this session key",
+		},
+		"Past max_ttl": {
+			revokedIn:   time.Minute,
+			maxTTLIn:    -time.Second,
+			expectedErr: "No live sessions exist with this session key",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			session, err := CreateSession(ctx, db, "alice")
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_, err := InvalidateSession(ctx, db, session.SessionKey)
+				assert.NoError(t, err)
+			})
+			moveSession(t, db, session.SessionKey, tc.revokedIn, tc.maxTTLIn)
+
+			_, err = UpdateSessionLiveness(ctx, db, session.SessionKey)
+			if len(tc.expectedErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			var capped, extended bool
+			err = db.QueryRowContext(ctx, `
+select revoked_at = max_ttl, revoked_at > current_timestamp + interval '29 minutes'
+from session where session_key = $1;
+`, session.SessionKey).Scan(&capped, &extended)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCapped, capped)
+			assert.Equal(t, !tc.expectedCapped, extended)
+		})
+	}
+}
+
+func TestGetSessionUser_expired(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.DB(t)
+	_, err := CreateUser(ctx, db, "alice", "correct horse")
+	require.NoError(t, err)
+	session, err := CreateSession(ctx, db, "alice")
+	require.NoError(t, err)
+	moveSession(t, db, session.SessionKey, -time.Second, time.Hour)
+
+	_, err = GetSessionUser(ctx, db, session.SessionKey)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	fresh, err := CreateSession(ctx, db, "alice")
+	require.NoError(t, err)
+	assert.NotEqual(t, session.SessionKey, fresh.SessionKey, "An expired session shouldn't be reused")
+}
+
+// moveSession sets when a session is revoked and its max_ttl relative to now, which stands in for waiting for time to pass.
+func moveSession(t *testing.T, db *sql.DB, sessionKey string, revokedIn, maxTTLIn time.Duration) {
+	t.Helper()
+	_, err := db.ExecContext(context.Background(), `
+update session
+set revoked_at = current_timestamp + make_interval(secs => $2),
+    max_ttl = current_timestamp + make_interval(secs => $3)
+where session_key = $1;
+`, sessionKey, revokedIn.Seconds(), maxTTLIn.Seconds())
+	require.NoError(t, err)
+}
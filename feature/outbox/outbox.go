@@ -0,0 +1,154 @@
+// Package outbox sends the email enqueued in the mail_outbox table, retrying failures with exponential backoff.
+// Enqueue mail with [model.EnqueueMailTx] in the same transaction as the change that triggers it.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"yourapp/feature/audit"
+	"yourapp/feature/model"
+	"yourapp/foundation/backoff"
+	"yourapp/foundation/mail"
+	"yourapp/foundation/metrics"
+)
+
+const (
+	ResultRetry = "retry"
+	ResultDead  = "dead"
+
+	DefaultInterval    = 5 * time.Second
+	DefaultBatchSize   = 20
+	DefaultMaxAttempts = 8
+	DefaultLease       = 2 * time.Minute
+	DefaultRetention   = 30 * 24 * time.Hour
+
+	backoffBase = 30 * time.Second
+	backoffMax  = time.Hour
+	purgeEvery  = time.Hour
+)
+
+var (
+	mailSent     = metrics.NewCounter("mail_sent_total", "Emails sent from the outbox")
+	mailFailures = metrics.NewCounter("mail_send_failures_total", "Failed attempts to send email from the outbox, by whether it will be retried", "result")
+)
+
+// Sender polls the outbox and sends due messages with a [mail.Mailer].
+// Several senders can run at once, e.g. one per app instance, since messages are claimed with a lease.
+type Sender struct {
+	// Interval is how often the outbox is polled when it's idle.
+	Interval time.Duration
+	// BatchSize is the most messages claimed at once.
+	BatchSize int
+	// MaxAttempts is how many times a message is tried before it's given up on.
+	MaxAttempts int
+	// Lease is how long a claimed message is reserved for this sender, which should be longer than a send can take.
+	Lease time.Duration
+	// Retention is how long sent and failed messages are kept before they're purged.
+	Retention time.Duration
+
+	pool   *sql.DB
+	mailer mail.Mailer
+	log    audit.LogDelegate
+	repo   model.OutboxRepo
+	wake   chan struct{}
+}
+
+func NewSender(pool *sql.DB, mailer mail.Mailer, log audit.LogDelegate) *Sender {
+	return &Sender{
+		Interval:    DefaultInterval,
+		BatchSize:   DefaultBatchSize,
+		MaxAttempts: DefaultMaxAttempts,
+		Lease:       DefaultLease,
+		Retention:   DefaultRetention,
+		pool:        pool,
+		mailer:      mailer,
+		log:         log,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Wake makes the sender poll right away, rather than waiting for the next interval.
+func (s *Sender) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due messages until the context is cancelled.
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		for {
+			sent, err := s.SendDue(ctx)
+			if err != nil {
+				s.log.Error("Failed to send email from the outbox: %v", err)
+				break
+			}
+			if sent < s.BatchSize {
+				break
+			}
+		}
+		if time.Since(lastPurge) >= purgeEvery {
+			if _, err := model.PurgeSentMail(ctx, s.pool, s.Retention); err != nil {
+				s.log.Error("Failed to purge old email from the outbox: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// SendDue claims one batch of due messages and tries to send them, returning how many were claimed.
+// Failures are recorded on each message rather than returned.
+// Once ctx is cancelled no more messages are sent, and the rest of the batch is sent again when its lease expires.
+func (s *Sender) SendDue(ctx context.Context) (int, error) {
+	claimed, err := s.repo.ClaimMail(ctx, s.pool, s.BatchSize, s.Lease)
+	if err != nil {
+		return 0, err
+	}
+	for _, claim := range claimed {
+		if ctx.Err() != nil {
+			break
+		}
+		s.send(ctx, claim)
+	}
+	return len(claimed), nil
+}
+
+// send sends a message and records the result.
+// A send in progress isn't cancelled with ctx, since a delivered message that isn't marked as sent would be delivered again.
+// The mailer's own timeout still applies.
+func (s *Sender) send(ctx context.Context, claim *model.ClaimMailResult) {
+	ctx = context.WithoutCancel(ctx)
+	sendErr := s.mailer.Send(ctx, claim.Message)
+	if sendErr == nil {
+		mailSent.Inc()
+		if _, err := s.repo.MarkMailSent(ctx, s.pool, claim.MessageID); err != nil {
+			// The lease will expire and the message will be sent again, which is better than not sending it at all.
+			s.log.Error("Failed to mark email %d as sent: %v", claim.MessageID, err)
+		}
+		return
+	}
+
+	var retryAfter time.Duration
+	if !mail.IsPermanent(sendErr) && claim.Attempts < s.MaxAttempts {
+		retryAfter = backoff.Delay(claim.Attempts, backoffBase, backoffMax)
+		mailFailures.Inc(ResultRetry)
+		s.log.Warn("Failed to send email %d on attempt %d, retrying in %s: %v", claim.MessageID, claim.Attempts, retryAfter.Round(time.Second), sendErr)
+	} else {
+		mailFailures.Inc(ResultDead)
+		s.log.Error("Giving up on email %d '%s' after %d attempts: %v", claim.MessageID, claim.Message.Subject, claim.Attempts, sendErr)
+	}
+	if _, err := s.repo.MarkMailFailed(ctx, s.pool, claim.MessageID, sendErr.Error(), retryAfter); err != nil {
+		s.log.Error("Failed to record email %d failure: %v", claim.MessageID, err)
+	}
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"net/textproto"
+	"testing"
+	"time"
+	"yourapp/feature/audit"
+	"yourapp/feature/model"
+	"yourapp/foundation/mail"
+	"yourapp/foundation/mail/mailtest"
+)
+
+type mailerFunc func(ctx context.Context, msg mail.Message) error
+
+func (f mailerFunc) Send(ctx context.Context, msg mail.Message) error {
+	return f(ctx, msg)
+}
+
+func TestSender_SendDue(t *testing.T) {
+	msg := mail.Message{To: []string{"bob@example.com"}, Subject: "Hi", Text: "Hello"}
+	tests := map[string]struct {
+		attempts      int
+		sendErr       error
+		expectSent    bool
+		expectRetry   bool
+		expectGiveUp  bool
+		expectedCause string
+	}{
+		"Sent": {
+			attempts:   1,
+			expectSent: true,
+		},
+		"Temporary failure": {
+			attempts:      1,
+			sendErr:       &textproto.Error{Code: 421, Msg: "Try again later"},
+			expectRetry:   true,
+			expectedCause: "Try again later",
+		},
+		"Permanent failure": {
+			attempts:      1,
+			sendErr:       &textproto.Error{Code: 550, Msg: "No such user"},
+			expectGiveUp:  true,
+			expectedCause: "No such user",
+		},
+		"Out of attempts": {
+			attempts:      DefaultMaxAttempts,
+			sendErr:       errors.New("connection refused"),
+			expectGiveUp:  true,
+			expectedCause: "connection refused",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var sent, retried, gaveUp bool
+			sender := NewSender(nil, mailerFunc(func(_ context.Context, m mail.Message) error {
+				assert.Equal(t, msg, m)
+				return tc.sendErr
+			}), audit.StdDelegate(log.Default(), true))
+			sender.repo.RedirectClaimMail(func(_ context.Context, _ *sql.DB, limit int, lease time.Duration) ([]*model.ClaimMailResult, error) {
+				assert.Equal(t, DefaultBatchSize, limit)
+				assert.Equal(t, DefaultLease, lease)
+				return []*model.ClaimMailResult{{MessageID: 3, Attempts: tc.attempts, Message: msg}}, nil
+			})
+			sender.repo.RedirectMarkMailSent(func(_ context.Context, _ *sql.DB, messageID uint64) (sql.Result, error) {
+				assert.Equal(t, uint64(3), messageID)
+				sent = true
+				return nil, nil
+			})
+			sender.repo.RedirectMarkMailFailed(func(_ context.Context, _ *sql.DB, messageID uint64, reason string, retryAfter time.Duration) (sql.Result, error) {
+				assert.Equal(t, uint64(3), messageID)
+				assert.Contains(t, reason, tc.expectedCause)
+				if retryAfter > 0 {
+					retried = true
+				} else {
+					gaveUp = true
+				}
+				return nil, nil
+			})
+			n, err := sender.SendDue(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 1, n)
+			assert.Equal(t, tc.expectSent, sent)
+			assert.Equal(t, tc.expectRetry, retried)
+			assert.Equal(t, tc.expectGiveUp, gaveUp)
+		})
+	}
+}
+
+func TestSender_SMTP(t *testing.T) {
+	server := &mailtest.Server{}
+	server.Start(t)
+	mailer := &mail.SMTPMailer{Addr: server.Addr(), From: "App <app@example.com>"}
+	sender := NewSender(nil, mailer, audit.StdDelegate(log.Default(), true))
+	var claims int
+	sender.repo.RedirectClaimMail(func(_ context.Context, _ *sql.DB, _ int, _ time.Duration) ([]*model.ClaimMailResult, error) {
+		claims++
+		if claims > 1 {
+			return nil, nil
+		}
+		return []*model.ClaimMailResult{
+			{MessageID: 1, Attempts: 1, Message: mail.Message{To: []string{"bob@example.com"}, Subject: "One", Text: "Hello"}},
+			{MessageID: 2, Attempts: 1, Message: mail.Message{To: []string{"carol@example.com"}, Subject: "Two", Text: "Hello"}},
+		}, nil
+	})
+	var sent []uint64
+	sender.repo.RedirectMarkMailSent(func(_ context.Context, _ *sql.DB, messageID uint64) (sql.Result, error) {
+		sent = append(sent, messageID)
+		return nil, nil
+	})
+
+	n, err := sender.SendDue(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []uint64{1, 2}, sent)
+	received := server.Messages()
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, []string{"bob@example.com"}, received[0].To)
+		assert.Equal(t, []string{"carol@example.com"}, received[1].To)
+	}
+}
@@ -0,0 +1,291 @@
+// Package signup lets people create their own accounts after verifying their email address, and lets admins invite people by email.
+// Both flows send signed, expiring links, so nothing in a link can be forged or used after it expires.
+package signup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/saylorsolutions/x/env"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"yourapp/feature/audit"
+	"yourapp/feature/model"
+	"yourapp/foundation/mail"
+	"yourapp/foundation/signed"
+	"yourapp/foundation/urlprefix" // feature:urlprefix
+)
+
+const (
+	EnvEnabled   = "SIGNUP_ENABLED" // EnvEnabled turns on self-signup, which is off by default. Invitations are always available to admins.
+	EnvPublicURL = "PUBLIC_URL"     // EnvPublicURL is the scheme and host that links in emails point to.
+
+	// MinPasswordLength applies to passwords chosen through signup and invitations.
+	MinPasswordLength = 10
+
+	verifyPurpose = "signup-verify"
+	invitePurpose = "invitation"
+	verifyPath    = "/signup/verify"
+	invitePath    = "/invite"
+)
+
+var (
+	ErrDisabled      = errors.New("self-signup is disabled")
+	ErrInvalidUser   = errors.New("username must be 3 to 32 letters, numbers, dots, dashes, or underscores")
+	ErrInvalidEmail  = errors.New("invalid email address")
+	ErrWeakPassword  = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	ErrUsernameTaken = errors.New("username is taken")
+	ErrEmailTaken    = errors.New("email address already has an account")
+	ErrInvalidLink   = errors.New("link is invalid or has already been used")
+	ErrExpiredLink   = errors.New("link has expired")
+
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{3,32}$`)
+)
+
+// Config holds the signup settings from the environment.
+type Config struct {
+	Enabled bool
+	// PublicURL is the base of links in emails, including the URL prefix.
+	PublicURL string
+}
+
+// LoadConfig reads the signup settings from the environment.
+func LoadConfig() (Config, error) {
+	base := env.Val(EnvPublicURL, "http://localhost:8080")
+	u, err := url.Parse(base)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return Config{}, fmt.Errorf("%s must be an absolute URL like https://example.com, got '%s'", EnvPublicURL, base)
+	}
+	return Config{
+		Enabled:   env.Bool(EnvEnabled, false),
+		PublicURL: strings.TrimRight(u.Scheme+"://"+u.Host, "/") + urlprefix.Get(), // feature:urlprefix
+		// feature:!urlprefix: PublicURL: strings.TrimRight(u.Scheme+"://"+u.Host, "/"),
+	}, nil
+}
+
+// Emails renders the bodies of the emails sent by the [Service], so they can live with the app's other templates.
+// Links are absolute, and expire at expiresAt.
+type Emails interface {
+	Verify(username, link string, expiresAt time.Time) templ.Component
+	AccountExists(loginLink string) templ.Component
+	Invitation(invitedBy, link string, expiresAt time.Time) templ.Component
+}
+
+// Service handles signups and invitations.
+// Emails are enqueued in the outbox in the same transaction as the signup or invitation, and sent in the background.
+type Service struct {
+	Config
+	// OnEnqueue is called after an email is committed to the outbox, e.g. to wake the sender so it's sent right away.
+	OnEnqueue func()
+
+	log    *audit.Logger
+	pool   *sql.DB
+	emails Emails
+	links  *signed.Signer
+	repo   model.SignupRepo
+	outbox model.OutboxRepo
+}
+
+func NewService(cfg Config, log *audit.Logger, pool *sql.DB, emails Emails, links *signed.Signer) *Service {
+	return &Service{Config: cfg, log: log, pool: pool, emails: emails, links: links}
+}
+
+// Registration is a self-signup request.
+type Registration struct {
+	Username string
+	Email    string
+	Password string
+}
+
+// Register stores a pending signup and emails a verification link.
+// If the email address already has an account, then the owner is told so by email instead, and nil is returned so the form doesn't reveal which addresses have accounts.
+func (s *Service) Register(ctx context.Context, reg Registration) error {
+	if !s.Enabled {
+		return ErrDisabled
+	}
+	email, err := validate(reg.Username, reg.Email, reg.Password)
+	if err != nil {
+		return err
+	}
+	exists, err := s.repo.UserExists(ctx, s.pool, reg.Username, email)
+	if err != nil {
+		return fmt.Errorf("failed to check for existing user: %w", err)
+	}
+	if exists.EmailTaken {
+		s.log.Postf(ctx, audit.AnonymousUser, "Signup attempted for an email address that already has an account")
+		msg, err := s.compose(ctx, email, "Your account already exists", s.emails.AccountExists(s.PublicURL+"/login"))
+		if err != nil {
+			return err
+		}
+		if _, err := s.outbox.EnqueueMail(ctx, s.pool, msg); err != nil {
+			return fmt.Errorf("failed to enqueue email: %w", err)
+		}
+		s.enqueued()
+		return nil
+	}
+	if exists.UsernameTaken {
+		return ErrUsernameTaken
+	}
+	_, err = s.repo.CreatePendingSignup(ctx, s.pool, reg.Username, email, reg.Password, func(signupID uint64, expiresAt time.Time) (mail.Message, error) {
+		link := s.signedLink(verifyPath, verifyPurpose, signupID, expiresAt)
+		return s.compose(ctx, email, "Verify your email address", s.emails.Verify(reg.Username, link, expiresAt))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create pending signup: %w", err)
+	}
+	s.enqueued()
+	s.log.Postf(ctx, reg.Username, "Signed up, waiting for email verification")
+	return nil
+}
+
+// CheckVerification returns an error if a verification link token isn't valid, without completing the signup.
+// This lets the link's page ask for confirmation first, so that mail scanners following the link don't activate the account.
+func (s *Service) CheckVerification(token string) error {
+	_, err := s.verifyLink(verifyPurpose, token)
+	return err
+}
+
+// Verify completes the signup for a verification link token.
+func (s *Service) Verify(ctx context.Context, token string) (*model.CompleteSignupResult, error) {
+	id, err := s.verifyLink(verifyPurpose, token)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.repo.CompleteSignup(ctx, s.pool, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidLink
+		}
+		return nil, fmt.Errorf("failed to complete signup: %w", err)
+	}
+	s.log.Postf(ctx, result.Username, "Verified email address and activated account")
+	return result, nil
+}
+
+// Invite creates an invitation and emails its link.
+// The invited user is granted params.AuthIDs, and admin if params.Admin is set, when they accept.
+func (s *Service) Invite(ctx context.Context, by string, params model.CreateInvitationParams) (*model.CreateInvitationResult, error) {
+	email, err := mail.ParseAddress(params.Email)
+	if err != nil {
+		return nil, ErrInvalidEmail
+	}
+	params.Email = email
+	exists, err := s.repo.UserExists(ctx, s.pool, "", params.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing user: %w", err)
+	}
+	if exists.EmailTaken {
+		return nil, ErrEmailTaken
+	}
+	result, err := s.repo.CreateInvitation(ctx, s.pool, params, func(invitationID uint64, expiresAt time.Time) (mail.Message, error) {
+		link := s.signedLink(invitePath, invitePurpose, invitationID, expiresAt)
+		return s.compose(ctx, params.Email, "You've been invited", s.emails.Invitation(by, link, expiresAt))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+	s.enqueued()
+	s.log.Postf(ctx, by, "Invited '%s' (invitation %d)", params.Email, result.InvitationID)
+	return result, nil
+}
+
+// Invitation returns the open invitation for an invitation link token.
+func (s *Service) Invitation(ctx context.Context, token string) (*model.GetInvitationResult, error) {
+	id, err := s.verifyLink(invitePurpose, token)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.repo.GetInvitation(ctx, s.pool, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidLink
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return result, nil
+}
+
+// Accept creates the invited user for an invitation link token.
+func (s *Service) Accept(ctx context.Context, token, username, password string) (*model.CompleteSignupResult, error) {
+	id, err := s.verifyLink(invitePurpose, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUser(username, password); err != nil {
+		return nil, err
+	}
+	exists, err := s.repo.UserExists(ctx, s.pool, username, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing user: %w", err)
+	}
+	if exists.UsernameTaken {
+		return nil, ErrUsernameTaken
+	}
+	result, err := s.repo.AcceptInvitation(ctx, s.pool, id, username, password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidLink
+		}
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	s.log.Postf(ctx, username, "Accepted invitation %d", id)
+	return result, nil
+}
+
+func (s *Service) verifyLink(purpose, token string) (uint64, error) {
+	val, err := s.links.Verify(purpose, token)
+	if err != nil {
+		if errors.Is(err, signed.ErrExpired) {
+			return 0, ErrExpiredLink
+		}
+		return 0, ErrInvalidLink
+	}
+	id, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidLink
+	}
+	return id, nil
+}
+
+// signedLink creates an absolute link to path, with a token for the ID that expires with the row it refers to.
+func (s *Service) signedLink(path, purpose string, id uint64, expiresAt time.Time) string {
+	token := s.links.Sign(purpose, strconv.FormatUint(id, 10), time.Until(expiresAt))
+	return s.PublicURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (s *Service) enqueued() {
+	if s.OnEnqueue != nil {
+		s.OnEnqueue()
+	}
+}
+
+func (s *Service) compose(ctx context.Context, to, subject string, body templ.Component) (mail.Message, error) {
+	return mail.Render(ctx, mail.Message{To: []string{to}, Subject: subject}, body)
+}
+
+// validate checks a registration, and returns the bare email address.
+func validate(username, email, password string) (string, error) {
+	if err := validateUser(username, password); err != nil {
+		return "", err
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return "", ErrInvalidEmail
+	}
+	return addr, nil
+}
+
+func validateUser(username, password string) error {
+	if !usernamePattern.MatchString(username) {
+		return ErrInvalidUser
+	}
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
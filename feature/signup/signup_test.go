@@ -0,0 +1,256 @@
+package signup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+	"yourapp/feature/audit"
+	"yourapp/feature/model"
+	"yourapp/foundation/mail"
+	"yourapp/foundation/signed"
+)
+
+var linkPattern = regexp.MustCompile(`https://app\.example\.com/\S+`)
+
+// testEmails renders each email as its subject-like heading and link.
+type testEmails struct{}
+
+func (testEmails) Verify(username, link string, _ time.Time) templ.Component {
+	return emailBody("Thanks for signing up as "+username, link)
+}
+
+func (testEmails) AccountExists(loginLink string) templ.Component {
+	return emailBody("Your account already exists", loginLink)
+}
+
+func (testEmails) Invitation(invitedBy, link string, _ time.Time) templ.Component {
+	return emailBody(invitedBy+" has invited you", link)
+}
+
+func emailBody(heading, link string) templ.Component {
+	return templ.ComponentFunc(func(_ context.Context, w io.Writer) error {
+		_, err := fmt.Fprintf(w, `<p>%s</p><p><a href="%s">%s</a></p>`, templ.EscapeString(heading), templ.EscapeString(link), templ.EscapeString(link))
+		return err
+	})
+}
+
+// sentMail records the messages a test service has enqueued.
+type sentMail []mail.Message
+
+func (s *sentMail) add(msg mail.Message, err error) error {
+	if err != nil {
+		return err
+	}
+	*s = append(*s, msg)
+	return nil
+}
+
+// testService creates a service whose emails are rendered by [testEmails] and recorded in sent.
+// Redirects for CreatePendingSignup and CreateInvitation should record the composed email with sent.add.
+func testService(t *testing.T, sent *sentMail) *Service {
+	auditLog := audit.NewLogger(nil, audit.StdDelegate(log.Default(), true))
+	// feature:audit begin
+	auditLog.UserRepo.RedirectInsertAuditLog(func(_ context.Context, _ *sql.DB, user string, msg string) (sql.Result, error) {
+		t.Log("[Audit Log]", user, msg)
+		return nil, nil
+	})
+	// feature:audit end
+	cfg := Config{Enabled: true, PublicURL: "https://app.example.com"}
+	svc := NewService(cfg, auditLog, nil, testEmails{}, signed.NewSigner([]byte("key")))
+	svc.repo.RedirectUserExists(func(_ context.Context, _ *sql.DB, username string, email string) (*model.UserExistsResult, error) {
+		return &model.UserExistsResult{UsernameTaken: username == "taken", EmailTaken: email == "taken@example.com"}, nil
+	})
+	svc.outbox.RedirectEnqueueMail(func(_ context.Context, _ *sql.DB, msg mail.Message) (*model.EnqueueMailResult, error) {
+		if err := sent.add(msg, msg.Validate()); err != nil {
+			return nil, err
+		}
+		return &model.EnqueueMailResult{MessageID: uint64(len(*sent))}, nil
+	})
+	return svc
+}
+
+// sentToken returns the token from the link in the last sent message.
+func sentToken(t *testing.T, sent sentMail) string {
+	if !assert.NotEmpty(t, sent, "A message should have been sent") {
+		return ""
+	}
+	msg := sent[len(sent)-1]
+	assert.NotEmpty(t, msg.HTML)
+	link := linkPattern.FindString(msg.Text)
+	if !assert.NotEmpty(t, link, "A link should have been sent") {
+		return ""
+	}
+	u, err := url.Parse(link)
+	assert.NoError(t, err)
+	return u.Query().Get("token")
+}
+
+func TestService_Register(t *testing.T) {
+	tests := map[string]struct {
+		reg          Registration
+		disabled     bool
+		expectedErr  error
+		expectedSent string
+	}{
+		"Valid": {
+			reg:          Registration{Username: "bob", Email: "Bob <bob@example.com>", Password: "correct horse"},
+			expectedSent: "Verify your email address",
+		},
+		"Disabled": {
+			reg:         Registration{Username: "bob", Email: "bob@example.com", Password: "correct horse"},
+			disabled:    true,
+			expectedErr: ErrDisabled,
+		},
+		"Invalid username": {
+			reg:         Registration{Username: "b b", Email: "bob@example.com", Password: "correct horse"},
+			expectedErr: ErrInvalidUser,
+		},
+		"Invalid email": {
+			reg:         Registration{Username: "bob", Email: "bob", Password: "correct horse"},
+			expectedErr: ErrInvalidEmail,
+		},
+		"Short password": {
+			reg:         Registration{Username: "bob", Email: "bob@example.com", Password: "hunter2"},
+			expectedErr: ErrWeakPassword,
+		},
+		"Username taken": {
+			reg:         Registration{Username: "taken", Email: "bob@example.com", Password: "correct horse"},
+			expectedErr: ErrUsernameTaken,
+		},
+		"Email taken": {
+			reg:          Registration{Username: "bob", Email: "taken@example.com", Password: "correct horse"},
+			expectedSent: "Your account already exists",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var sent sentMail
+			svc := testService(t, &sent)
+			svc.Enabled = !tc.disabled
+			var woken int
+			svc.OnEnqueue = func() {
+				woken++
+			}
+			var created bool
+			svc.repo.RedirectCreatePendingSignup(func(_ context.Context, _ *sql.DB, username string, email string, password string, compose model.ComposeMail) (*model.CreatePendingSignupResult, error) {
+				assert.Equal(t, "bob@example.com", email, "Only the bare address should be stored")
+				created = true
+				if err := sent.add(compose(7, time.Now().Add(24*time.Hour))); err != nil {
+					return nil, err
+				}
+				return &model.CreatePendingSignupResult{SignupID: 7}, nil
+			})
+			err := svc.Register(context.Background(), tc.reg)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, sent)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.Len(t, sent, 1) {
+				assert.Equal(t, tc.expectedSent, sent[0].Subject)
+				to, _ := mail.ParseAddress(tc.reg.Email)
+				assert.Equal(t, []string{to}, sent[0].To)
+			}
+			assert.Equal(t, tc.expectedSent == "Verify your email address", created)
+			assert.Equal(t, len(sent), woken, "The sender should be woken for each enqueued email")
+		})
+	}
+}
+
+func TestService_Verify(t *testing.T) {
+	var sent sentMail
+	svc := testService(t, &sent)
+	svc.repo.RedirectCreatePendingSignup(func(_ context.Context, _ *sql.DB, _ string, _ string, _ string, compose model.ComposeMail) (*model.CreatePendingSignupResult, error) {
+		if err := sent.add(compose(7, time.Now().Add(24*time.Hour))); err != nil {
+			return nil, err
+		}
+		return &model.CreatePendingSignupResult{SignupID: 7}, nil
+	})
+	var completed int
+	svc.repo.RedirectCompleteSignup(func(_ context.Context, _ *sql.DB, signupID uint64) (*model.CompleteSignupResult, error) {
+		assert.Equal(t, uint64(7), signupID)
+		completed++
+		if completed > 1 {
+			return nil, sql.ErrNoRows
+		}
+		return &model.CompleteSignupResult{UserID: 3, Username: "bob"}, nil
+	})
+	err := svc.Register(context.Background(), Registration{Username: "bob", Email: "bob@example.com", Password: "correct horse"})
+	assert.NoError(t, err)
+	token := sentToken(t, sent)
+
+	assert.NoError(t, svc.CheckVerification(token))
+	assert.ErrorIs(t, svc.CheckVerification(token+"x"), ErrInvalidLink)
+	assert.Zero(t, completed, "Checking a link should not complete the signup")
+
+	_, err = svc.Verify(context.Background(), token+"x")
+	assert.ErrorIs(t, err, ErrInvalidLink)
+	_, err = svc.Verify(context.Background(), svc.links.Sign(invitePurpose, "7", time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidLink, "Invitation links should not verify signups")
+	_, err = svc.Verify(context.Background(), svc.links.Sign(verifyPurpose, "7", -time.Second))
+	assert.ErrorIs(t, err, ErrExpiredLink)
+
+	result, err := svc.Verify(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", result.Username)
+	_, err = svc.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, ErrInvalidLink, "Links should only work once")
+}
+
+func TestService_Invite(t *testing.T) {
+	var sent sentMail
+	svc := testService(t, &sent)
+	svc.Enabled = false
+	svc.repo.RedirectCreateInvitation(func(_ context.Context, _ *sql.DB, params model.CreateInvitationParams, compose model.ComposeMail) (*model.CreateInvitationResult, error) {
+		assert.Equal(t, "carol@example.com", params.Email)
+		assert.Equal(t, []uint64{1, 2}, params.AuthIDs) // feature:authz
+		expiresAt := time.Now().Add(time.Hour)
+		if err := sent.add(compose(9, expiresAt)); err != nil {
+			return nil, err
+		}
+		return &model.CreateInvitationResult{InvitationID: 9, ExpiresAt: expiresAt}, nil
+	})
+	svc.repo.RedirectGetInvitation(func(_ context.Context, _ *sql.DB, invitationID uint64) (*model.GetInvitationResult, error) {
+		assert.Equal(t, uint64(9), invitationID)
+		return &model.GetInvitationResult{InvitationID: 9, Email: "carol@example.com", InvitedBy: "admin"}, nil
+	})
+	svc.repo.RedirectAcceptInvitation(func(_ context.Context, _ *sql.DB, invitationID uint64, username string, password string) (*model.CompleteSignupResult, error) {
+		assert.Equal(t, uint64(9), invitationID)
+		return &model.CompleteSignupResult{UserID: 4, Username: username}, nil
+	})
+
+	_, err := svc.Invite(context.Background(), "admin", model.CreateInvitationParams{Email: "taken@example.com"})
+	assert.ErrorIs(t, err, ErrEmailTaken)
+	_, err = svc.Invite(context.Background(), "admin", model.CreateInvitationParams{Email: "carol", AuthIDs: []uint64{1, 2}}) // feature:authz
+	// feature:!authz: _, err = svc.Invite(context.Background(), "admin", model.CreateInvitationParams{Email: "carol"})
+	assert.ErrorIs(t, err, ErrInvalidEmail)
+
+	_, err = svc.Invite(context.Background(), "admin", model.CreateInvitationParams{Email: "carol@example.com", AuthIDs: []uint64{1, 2}}) // feature:authz
+	// feature:!authz: _, err = svc.Invite(context.Background(), "admin", model.CreateInvitationParams{Email: "carol@example.com"})
+	assert.NoError(t, err, "Invitations should work with self-signup disabled")
+	if assert.Len(t, sent, 1) {
+		assert.Contains(t, sent[0].Text, "admin has invited you")
+	}
+	token := sentToken(t, sent)
+
+	invitation, err := svc.Invitation(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "carol@example.com", invitation.Email)
+
+	_, err = svc.Accept(context.Background(), token, "taken", "correct horse")
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+	_, err = svc.Accept(context.Background(), token, "carol", "short")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	result, err := svc.Accept(context.Background(), token, "carol", "correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), result.UserID)
+}
@@ -0,0 +1,171 @@
+// Package tenant resolves the organization that a request is for, from either its subdomain or a path prefix.
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/saylorsolutions/x/env"
+	"github.com/saylorsolutions/x/httpx"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"yourapp/feature/model"
+	"yourapp/foundation/httperr"
+)
+
+const (
+	// EnvDomain is the parent domain of organization subdomains, like "example.com" for "acme.example.com".
+	// Organizations are resolved from the PathPrefix instead if it isn't set.
+	EnvDomain = "TENANT_DOMAIN"
+	// PathPrefix is followed by the organization's slug in the path of requests for it, like "/org/acme/tokens".
+	PathPrefix = "/org/"
+)
+
+// slugPattern matches a DNS label, so that slugs can be used as subdomains, see infra/pg/sql/06_tenants.sql.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidSlug reports whether slug can identify an organization in both modes.
+// "www" isn't valid, since that subdomain is served without an organization.
+func ValidSlug(slug string) bool {
+	return slug != "www" && slugPattern.MatchString(slug)
+}
+
+// Org is the organization that a request is for.
+type Org struct {
+	ID   uint64
+	Slug string
+	Name string
+}
+
+type tenantKey string
+
+const orgKey = tenantKey("org")
+
+type resolved struct {
+	org Org
+	// prefix is added to paths by [Path], which is empty when organizations are resolved from the subdomain.
+	prefix string
+}
+
+// Get returns the organization that the request is for, if any.
+func Get(ctx context.Context) (Org, bool) {
+	res, ok := ctx.Value(orgKey).(resolved)
+	if !ok {
+		return Org{}, false
+	}
+	return res.org, true
+}
+
+// Path adds the organization's path prefix to an app path, so links and redirects stay within the organization.
+// The path is unchanged if the request isn't for an organization, or organizations are resolved from the subdomain.
+func Path(ctx context.Context, path string) string {
+	res, ok := ctx.Value(orgKey).(resolved)
+	if !ok || len(res.prefix) == 0 {
+		return path
+	}
+	return res.prefix + "/" + strings.TrimLeft(path, "/")
+}
+
+// Resolver looks up the organization that requests are for, see [Resolver.Middleware].
+type Resolver struct {
+	domain  string
+	pool    *sql.DB
+	repo    model.OrgsRepo
+	failure func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewResolver creates a resolver with the domain set in TENANT_DOMAIN, see [EnvDomain].
+func NewResolver(pool *sql.DB) *Resolver {
+	return &Resolver{
+		domain: strings.ToLower(strings.Trim(env.Val(EnvDomain, ""), ".")),
+		pool:   pool,
+	}
+}
+
+// OrgsRepo returns the repo that organizations are looked up with, so tests can redirect its queries.
+func (res *Resolver) OrgsRepo() *model.OrgsRepo {
+	return &res.repo
+}
+
+// SetErrorHandler sets the function used to respond when the request's organization doesn't exist or can't be looked up.
+// The error given to the handler is an [*httperr.Error].
+// The default handler responds with a bare status.
+func (res *Resolver) SetErrorHandler(handler func(w http.ResponseWriter, r *http.Request, err error)) {
+	res.failure = handler
+}
+
+func (res *Resolver) fail(w http.ResponseWriter, r *http.Request, err *httperr.Error) {
+	if res.failure != nil {
+		res.failure(w, r, err)
+		return
+	}
+	http.Error(w, http.StatusText(err.Status), err.Status)
+}
+
+// Middleware resolves the organization that each request is for, which is then returned by [Get].
+// In path mode the organization's prefix is removed from the request's path, so the same routes serve every organization.
+// Requests that aren't for an organization continue unchanged, and those for an organization that doesn't exist get a 404.
+func (res *Resolver) Middleware() httpx.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slug, rest, ok := res.slug(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			result, err := res.repo.GetOrganization(r.Context(), res.pool, slug)
+			if errors.Is(err, sql.ErrNoRows) {
+				res.fail(w, r, httperr.NotFound("No such organization"))
+				return
+			}
+			if err != nil {
+				res.fail(w, r, httperr.Internal(err))
+				return
+			}
+			org := resolved{org: Org{ID: result.OrgID, Slug: result.Slug, Name: result.Name}}
+			if len(res.domain) == 0 {
+				org.prefix = PathPrefix + result.Slug
+				r = stripPath(r, rest)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), orgKey, org)))
+		})
+	}
+}
+
+// slug returns the organization slug that the request is for, and the rest of its path in path mode.
+func (res *Resolver) slug(r *http.Request) (string, string, bool) {
+	if len(res.domain) > 0 {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		label, ok := strings.CutSuffix(strings.ToLower(host), "."+res.domain)
+		if !ok || len(label) == 0 || strings.Contains(label, ".") || label == "www" {
+			return "", "", false
+		}
+		return label, "", true
+	}
+	trimmed, ok := strings.CutPrefix(r.URL.Path, PathPrefix)
+	if !ok {
+		return "", "", false
+	}
+	slug, rest, _ := strings.Cut(trimmed, "/")
+	if len(slug) == 0 {
+		return "", "", false
+	}
+	return slug, "/" + rest, true
+}
+
+// stripPath returns a shallow copy of the request with a new path, like [http.StripPrefix].
+func stripPath(r *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
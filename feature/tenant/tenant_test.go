@@ -0,0 +1,152 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"yourapp/feature/model"
+)
+
+func testResolver(domain string) *Resolver {
+	res := &Resolver{domain: domain}
+	res.repo.RedirectGetOrganization(func(_ context.Context, _ *sql.DB, slug string) (*model.GetOrganizationResult, error) {
+		if slug != "acme" {
+			return nil, sql.ErrNoRows
+		}
+		return &model.GetOrganizationResult{OrgID: 3, Slug: "acme", Name: "Acme"}, nil
+	})
+	return res
+}
+
+func TestValidSlug(t *testing.T) {
+	tests := map[string]struct {
+		slug     string
+		expected bool
+	}{
+		"Simple":         {slug: "acme", expected: true},
+		"Hyphenated":     {slug: "acme-2", expected: true},
+		"Single":         {slug: "a", expected: true},
+		"Empty":          {slug: "", expected: false},
+		"Upper case":     {slug: "Acme", expected: false},
+		"Leading hyphen": {slug: "-acme", expected: false},
+		"Dotted":         {slug: "acme.corp", expected: false},
+		"Too long":       {slug: strings.Repeat("a", 64), expected: false},
+		"Reserved":       {slug: "www", expected: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ValidSlug(tc.slug))
+		})
+	}
+}
+
+func TestResolver_Middleware(t *testing.T) {
+	tests := map[string]struct {
+		domain         string
+		host           string
+		path           string
+		expectedStatus int
+		expectedOrg    uint64
+		expectedPath   string
+		expectedLink   string
+	}{
+		"No organization": {
+			path:           "/tokens",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/tokens",
+			expectedLink:   "/tokens",
+		},
+		"Path": {
+			path:           "/org/acme/tokens",
+			expectedStatus: http.StatusOK,
+			expectedOrg:    3,
+			expectedPath:   "/tokens",
+			expectedLink:   "/org/acme/tokens",
+		},
+		"Path root": {
+			path:           "/org/acme",
+			expectedStatus: http.StatusOK,
+			expectedOrg:    3,
+			expectedPath:   "/",
+			expectedLink:   "/org/acme/tokens",
+		},
+		"Unknown path": {
+			path:           "/org/other/tokens",
+			expectedStatus: http.StatusNotFound,
+		},
+		"Subdomain": {
+			domain:         "example.com",
+			host:           "ACME.example.com:8080",
+			path:           "/tokens",
+			expectedStatus: http.StatusOK,
+			expectedOrg:    3,
+			expectedPath:   "/tokens",
+			expectedLink:   "/tokens",
+		},
+		"Parent domain": {
+			domain:         "example.com",
+			host:           "example.com",
+			path:           "/org/acme/tokens",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/org/acme/tokens",
+			expectedLink:   "/tokens",
+		},
+		"www subdomain": {
+			domain:         "example.com",
+			host:           "www.example.com",
+			path:           "/tokens",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/tokens",
+			expectedLink:   "/tokens",
+		},
+		"Unknown subdomain": {
+			domain:         "example.com",
+			host:           "other.example.com",
+			path:           "/tokens",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var (
+				org  Org
+				path string
+				link string
+			)
+			handler := testResolver(tc.domain).Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				org, _ = Get(r.Context())
+				path = r.URL.Path
+				link = Path(r.Context(), "/tokens")
+			}))
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if len(tc.host) > 0 {
+				req.Host = tc.host
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedOrg, org.ID)
+			assert.Equal(t, tc.expectedPath, path)
+			assert.Equal(t, tc.expectedLink, link)
+		})
+	}
+}
+
+func TestResolver_SetErrorHandler(t *testing.T) {
+	res := testResolver("")
+	var handled error
+	res.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := res.Middleware()(http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/org/other", nil))
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+	assert.EqualError(t, handled, "404 No such organization")
+}
@@ -0,0 +1,130 @@
+// Package api provides a versioned group of JSON routes that can describe themselves with an OpenAPI document.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"mime"
+	"net/http"
+	"strings"
+	"yourapp/foundation/httperr"
+)
+
+const (
+	ContentType = "application/json"
+	// MaxBodySize limits the size of request bodies read by [Decode].
+	MaxBodySize = 1 << 20
+)
+
+// Operation describes a single route in a [Group].
+type Operation struct {
+	Method string
+	// Path is relative to the group prefix, and may include [http.ServeMux] wildcards like "/tokens/{id}".
+	Path        string
+	Summary     string
+	Description string
+	Tags        []string
+	// Auth documents that the operation requires an authenticated session or bearer token.
+	Auth bool
+	// Request is a value of the request body type, or nil if the operation doesn't take a body.
+	Request any
+	// Response is a value of the response body type, or nil if the operation doesn't respond with a body.
+	Response any
+	// Status is the status of a successful response, which defaults to 200.
+	Status  int
+	Handler httpx.ErrHandlerFunc
+	// Middleware is applied to this operation only, after the group's middleware.
+	Middleware []httpx.Middleware
+}
+
+func (op Operation) status() int {
+	if op.Status == 0 {
+		return http.StatusOK
+	}
+	return op.Status
+}
+
+// Group is a set of operations under a common prefix, like "/api/v1".
+type Group struct {
+	Prefix  string
+	Title   string
+	Version string
+	// ServerURL is the group's URL as seen by clients, if it differs from the Prefix.
+	ServerURL string
+	ops       []Operation
+}
+
+func NewGroup(prefix, title, version string) *Group {
+	return &Group{Prefix: strings.TrimSuffix(prefix, "/"), Title: title, Version: version}
+}
+
+// Add adds operations to the group.
+func (g *Group) Add(ops ...Operation) {
+	g.ops = append(g.ops, ops...)
+}
+
+// Operations returns the operations in the order they were added.
+func (g *Group) Operations() []Operation {
+	return append([]Operation(nil), g.ops...)
+}
+
+// Mount registers each operation with the mux.
+// The adapt function turns an operation's handler into a [http.Handler], which is where errors should be rendered.
+// The group middleware is applied to every operation, and also answers OPTIONS requests for any path in the group, which is needed for CORS preflight requests.
+func (g *Group) Mount(mux *http.ServeMux, adapt func(httpx.ErrHandlerFunc) http.Handler, middleware ...httpx.Middleware) {
+	for _, op := range g.ops {
+		handler := adapt(op.Handler)
+		for i := len(op.Middleware) - 1; i >= 0; i-- {
+			handler = op.Middleware[i](handler)
+		}
+		mux.Handle(op.Method+" "+g.Prefix+op.Path, wrap(handler, middleware))
+	}
+	mux.Handle("OPTIONS "+g.Prefix+"/", wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), middleware))
+}
+
+func wrap(handler http.Handler, middleware []httpx.Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Decode reads a JSON request body into target.
+// Unknown fields are rejected, so clients find out about typos right away.
+// Errors are returned as [*httperr.Error] that are safe to show to the client.
+func Decode(r *http.Request, target any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != ContentType {
+		return httperr.New(http.StatusUnsupportedMediaType, "Request body must be "+ContentType)
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return httperr.New(http.StatusRequestEntityTooLarge, "Request body is too large")
+		}
+		return httperr.Wrap(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err), err)
+	}
+	if dec.More() {
+		return httperr.BadRequest("Request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// Write responds with a JSON body.
+func Write(w http.ResponseWriter, status int, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return httperr.Internal(fmt.Errorf("failed to encode response: %w", err))
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(data, '\n'))
+	return nil
+}
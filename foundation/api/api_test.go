@@ -0,0 +1,169 @@
+package api
+
+import (
+	"errors"
+	"github.com/saylorsolutions/x/httpx"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"yourapp/foundation/httperr"
+)
+
+type widget struct {
+	ID      uint64     `json:"id"`
+	Name    string     `json:"name"`
+	Tags    []string   `json:"tags,omitempty"`
+	Created time.Time  `json:"created"`
+	Parent  *widget    `json:"parent"`
+	Deleted *time.Time `json:"deleted"`
+	secret  string
+}
+
+func TestDecode(t *testing.T) {
+	tests := map[string]struct {
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		"Valid": {
+			contentType: "application/json; charset=utf-8",
+			body:        `{"id": 1, "name": "gear"}`,
+		},
+		"Wrong content type": {
+			contentType:    "application/x-www-form-urlencoded",
+			body:           `name=gear`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		"Unknown field": {
+			contentType:    ContentType,
+			body:           `{"nmae": "gear"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"Trailing data": {
+			contentType:    ContentType,
+			body:           `{"name": "gear"} {"name": "cog"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"Too large": {
+			contentType:    ContentType,
+			body:           `{"name": "` + strings.Repeat("a", MaxBodySize) + `"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/widgets", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			var w widget
+			err := Decode(req, &w)
+			if tc.expectedStatus == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, "gear", w.Name)
+				return
+			}
+			var httpErr *httperr.Error
+			assert.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tc.expectedStatus, httpErr.Status)
+		})
+	}
+}
+
+func testGroup() *Group {
+	group := NewGroup("/api/v1/", "Test", "1")
+	group.Add(
+		Operation{
+			Method:   http.MethodGet,
+			Path:     "/widgets/{id}",
+			Auth:     true,
+			Response: widget{},
+			Handler: func(w http.ResponseWriter, r *http.Request) error {
+				return Write(w, http.StatusOK, widget{Name: r.PathValue("id")})
+			},
+		},
+		Operation{
+			Method:   http.MethodPost,
+			Path:     "/widgets",
+			Request:  widget{},
+			Response: []widget{},
+			Status:   http.StatusCreated,
+			Handler: func(w http.ResponseWriter, r *http.Request) error {
+				return httperr.BadRequest("nope")
+			},
+		},
+	)
+	return group
+}
+
+func TestGroup_Mount(t *testing.T) {
+	var groupCalls, opCalls int
+	countGroup := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			groupCalls++
+			next.ServeHTTP(w, r)
+		})
+	}
+	group := testGroup()
+	group.ops[0].Middleware = []httpx.Middleware{func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, 1, groupCalls, "Group middleware should run first")
+			opCalls++
+			next.ServeHTTP(w, r)
+		})
+	}}
+	mux := http.NewServeMux()
+	group.Mount(mux, func(handler httpx.ErrHandlerFunc) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := handler(w, r); err != nil {
+				httperr.WriteJSON(w, httperr.From(err), "")
+			}
+		})
+	}, countGroup)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/widgets/gear", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"gear"`)
+	assert.Equal(t, 1, opCalls)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/widgets", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	groupCalls = 0
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/api/v1/widgets", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, 1, groupCalls, "OPTIONS requests should go through the group middleware")
+}
+
+func TestGroup_OpenAPI(t *testing.T) {
+	doc := testGroup().OpenAPI(map[string]SecurityScheme{"token": {Type: "http", Scheme: "bearer"}})
+	assert.Equal(t, "/api/v1", doc.Servers[0].URL)
+
+	get := doc.Paths["/widgets/{id}"]["get"]
+	if assert.NotNil(t, get) {
+		assert.Equal(t, "getWidgetsId", get.OperationID)
+		assert.Equal(t, []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}}, get.Parameters)
+		assert.Equal(t, "#/components/schemas/widget", get.Responses["200"].Content[ContentType].Schema.Ref)
+		assert.Equal(t, "#/components/schemas/Error", get.Responses["default"].Content[ContentType].Schema.Ref)
+		assert.Equal(t, []map[string][]string{{"token": {}}}, get.Security)
+	}
+	post := doc.Paths["/widgets"]["post"]
+	if assert.NotNil(t, post) {
+		assert.NotNil(t, post.RequestBody)
+		assert.Contains(t, post.Responses, "201")
+		assert.Equal(t, "array", post.Responses["201"].Content[ContentType].Schema.Type)
+		assert.Empty(t, post.Security)
+	}
+
+	schema := doc.Components.Schemas["widget"]
+	if assert.NotNil(t, schema) {
+		assert.Equal(t, []string{"id", "name", "created"}, schema.Required)
+		assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, schema.Properties["created"])
+		assert.Equal(t, "#/components/schemas/widget", schema.Properties["parent"].Ref, "Recursive types should refer to themselves")
+		assert.NotContains(t, schema.Properties, "secret")
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"yourapp/foundation/httperr"
+)
+
+const openAPIVersion = "3.1.0"
+
+// Document is an OpenAPI document, with only the parts that a [Group] can describe.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]*PathItem `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type PathItem struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how an operation with [Operation.Auth] set may be authenticated.
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Schema is a JSON schema, as used in OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var wildcardPattern = regexp.MustCompile(`\{([^}]+)}`)
+
+// OpenAPI describes the group's operations.
+// Each scheme in security is accepted as an alternative for operations that require auth.
+func (g *Group) OpenAPI(security map[string]SecurityScheme) *Document {
+	serverURL := g.ServerURL
+	if len(serverURL) == 0 {
+		serverURL = g.Prefix
+	}
+	doc := &Document{
+		OpenAPI: openAPIVersion,
+		Info:    Info{Title: g.Title, Version: g.Version},
+		Servers: []Server{{URL: serverURL}},
+		Paths:   map[string]map[string]*PathItem{},
+		Components: Components{
+			Schemas:         map[string]*Schema{},
+			SecuritySchemes: security,
+		},
+	}
+	gen := &schemaGen{schemas: doc.Components.Schemas, names: map[reflect.Type]string{reflect.TypeOf(httperr.Body{}): "Error"}}
+	errorSchema := gen.schema(reflect.TypeOf(httperr.Body{}))
+	var securityNames []string
+	for name := range security {
+		securityNames = append(securityNames, name)
+	}
+	sort.Strings(securityNames)
+
+	for _, op := range g.ops {
+		path := strings.TrimSuffix(op.Path, "{$}")
+		item := &PathItem{
+			OperationID: operationID(op.Method, path),
+			Summary:     op.Summary,
+			Description: op.Description,
+			Tags:        op.Tags,
+			Responses:   map[string]Response{},
+		}
+		path = wildcardPattern.ReplaceAllStringFunc(path, func(wildcard string) string {
+			name := strings.TrimSuffix(strings.Trim(wildcard, "{}"), "...")
+			item.Parameters = append(item.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+			return "{" + name + "}"
+		})
+		if op.Request != nil {
+			item.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{ContentType: {Schema: gen.schema(reflect.TypeOf(op.Request))}},
+			}
+		}
+		success := Response{Description: http.StatusText(op.status())}
+		if op.Response != nil {
+			success.Content = map[string]MediaType{ContentType: {Schema: gen.schema(reflect.TypeOf(op.Response))}}
+		}
+		item.Responses[strconv.Itoa(op.status())] = success
+		item.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{ContentType: {Schema: errorSchema}},
+		}
+		if op.Auth {
+			for _, name := range securityNames {
+				item.Security = append(item.Security, map[string][]string{name: {}})
+			}
+		}
+		if _, ok := doc.Paths[path]; !ok {
+			doc.Paths[path] = map[string]*PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(op.Method)] = item
+	}
+	return doc
+}
+
+// Handler serves the group's OpenAPI document as JSON.
+func (g *Group) Handler(security map[string]SecurityScheme) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(g.OpenAPI(security))
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		_, _ = w.Write(data)
+	})
+}
+
+// operationID derives a stable ID like "getTokensId" from the method and path.
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaGen reflects Go types into schemas, adding named structs to the document components.
+type schemaGen struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func (g *schemaGen) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return g.object(t)
+		}
+		name := g.name(t)
+		if _, ok := g.schemas[name]; !ok {
+			// Reserve the name first, so recursive types refer to themselves.
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (g *schemaGen) name(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	for _, other := range g.names {
+		if other == name {
+			pkg := t.PkgPath()
+			name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+			break
+		}
+	}
+	g.names[t] = name
+	return name
+}
+
+func (g *schemaGen) object(t reflect.Type) *Schema {
+	obj := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(t, obj)
+	return obj
+}
+
+func (g *schemaGen) fields(t reflect.Type, obj *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && len(name) == 0 {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, obj)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		obj.Properties[name] = g.schema(field.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") && field.Type.Kind() != reflect.Pointer {
+			obj.Required = append(obj.Required, name)
+		}
+	}
+}
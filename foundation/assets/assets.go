@@ -0,0 +1,202 @@
+// Package assets serves static assets with content-hash fingerprinted names, precompressed variants, and cache headers.
+//
+// Fingerprints and compressed variants are produced ahead of time with [Generate], which writes a manifest alongside the assets.
+// If no manifest exists, then assets are still served by their logical names, just without the caching benefits.
+package assets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ManifestFile    = "manifest.json"
+	manifestVersion = 1
+
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+
+	immutableCache = "public, max-age=31536000, immutable"
+	revalidate     = "no-cache"
+)
+
+var (
+	// encodingExt maps supported encodings to the file extension of their precompressed variant, in order of preference.
+	encodingExt = []struct {
+		encoding string
+		ext      string
+	}{
+		{EncodingBrotli, ".br"},
+		{EncodingGzip, ".gz"},
+	}
+)
+
+// Asset describes a single asset in the [Manifest].
+type Asset struct {
+	// Path is the fingerprinted path of the asset, relative to the asset root.
+	Path string `json:"path"`
+	// Hash is the hex encoded content hash of the uncompressed asset.
+	Hash string `json:"hash"`
+	// Encodings lists the precompressed variants that exist for this asset.
+	Encodings []string `json:"encodings,omitempty"`
+}
+
+// Manifest maps logical asset names to their fingerprinted form.
+type Manifest struct {
+	Version int              `json:"version"`
+	Assets  map[string]Asset `json:"assets"`
+}
+
+// Set is a collection of assets that can be resolved to fingerprinted paths, and served over HTTP.
+type Set struct {
+	fsys        fs.FS
+	logical     map[string]Asset
+	fingerprint map[string]string
+}
+
+// Load creates a Set from the given file system, reading the [ManifestFile] at its root if it exists.
+func Load(fsys fs.FS) (*Set, error) {
+	set := &Set{
+		fsys:        fsys,
+		logical:     map[string]Asset{},
+		fingerprint: map[string]string{},
+	}
+	data, err := fs.ReadFile(fsys, ManifestFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return set, nil
+		}
+		return nil, fmt.Errorf("failed to read asset manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse asset manifest: %w", err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported asset manifest version %d", manifest.Version)
+	}
+	for name, asset := range manifest.Assets {
+		set.logical[name] = asset
+		set.fingerprint[asset.Path] = name
+	}
+	return set, nil
+}
+
+// LoadLive creates a Set that serves assets from fsys by their logical names, ignoring any [ManifestFile].
+// This is meant for development, so edits are served as soon as they're saved without running [Generate] again.
+func LoadLive(fsys fs.FS) *Set {
+	return &Set{
+		fsys:        fsys,
+		logical:     map[string]Asset{},
+		fingerprint: map[string]string{},
+	}
+}
+
+// Path resolves a logical asset name to its fingerprinted path, relative to the asset root.
+// If the asset is not in the manifest, then the logical name is returned unchanged.
+func (s *Set) Path(logical string) string {
+	logical = strings.TrimPrefix(logical, "/")
+	if asset, ok := s.logical[logical]; ok {
+		return asset.Path
+	}
+	return logical
+}
+
+// Handler serves assets relative to the asset root, so it should be used with [http.StripPrefix].
+//
+// Fingerprinted paths are served with an immutable Cache-Control header, and logical names must be revalidated with the ETag.
+// Precompressed variants are served when the client accepts them.
+func (s *Set) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if len(name) == 0 || name == ManifestFile || strings.HasPrefix(path.Base(name), ".") {
+			http.NotFound(w, r)
+			return
+		}
+		var (
+			logical   = name
+			cacheCtl  = revalidate
+			asset, ok = s.logical[name]
+		)
+		if fpLogical, isFingerprint := s.fingerprint[name]; isFingerprint {
+			logical = fpLogical
+			asset, ok = s.logical[fpLogical]
+			cacheCtl = immutableCache
+		}
+
+		h := w.Header()
+		h.Set("Cache-Control", cacheCtl)
+		if ctype := mime.TypeByExtension(path.Ext(logical)); len(ctype) > 0 {
+			h.Set("Content-Type", ctype)
+		}
+		filename := logical
+		if ok {
+			etag := asset.Hash
+			if len(asset.Encodings) > 0 {
+				h.Add("Vary", "Accept-Encoding")
+				if encoding, ext := negotiate(r.Header.Get("Accept-Encoding"), asset.Encodings); len(encoding) > 0 {
+					h.Set("Content-Encoding", encoding)
+					filename += ext
+					etag += "-" + encoding
+				}
+			}
+			h.Set("ETag", `"`+etag+`"`)
+		}
+		f, err := s.fsys.Open(filename)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, "Failed to open asset", http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		if fi, err := f.Stat(); err != nil || fi.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		content, ok := f.(io.ReadSeeker)
+		if !ok {
+			http.Error(w, "Asset is not seekable", http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, logical, time.Time{}, content)
+	})
+}
+
+// negotiate picks the most preferred precompressed encoding that is both available and accepted by the client.
+func negotiate(acceptEncoding string, available []string) (encoding string, ext string) {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if qval, isQ := strings.CutPrefix(strings.TrimSpace(params), "q="); isQ {
+			if q, err := strconv.ParseFloat(qval, 64); err == nil && q <= 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, candidate := range encodingExt {
+		if !accepted[candidate.encoding] {
+			continue
+		}
+		for _, have := range available {
+			if have == candidate.encoding {
+				return candidate.encoding, candidate.ext
+			}
+		}
+	}
+	return "", ""
+}
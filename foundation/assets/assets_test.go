@@ -0,0 +1,121 @@
+package assets
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_Handler(t *testing.T) {
+	dir := t.TempDir()
+	css := strings.Repeat("body { color: red; }\n", 50)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "vendor"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.css"), []byte(css), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "vendor", "lib.js"), []byte(strings.Repeat("let a = 1;\n", 50)), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "image.png"), []byte{0x89, 'P', 'N', 'G'}, 0644))
+
+	manifest, err := Generate(dir)
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Assets, 3)
+	assert.Equal(t, []string{EncodingBrotli, EncodingGzip}, manifest.Assets["main.css"].Encodings)
+	assert.Empty(t, manifest.Assets["image.png"].Encodings, "Images should not be precompressed")
+
+	// Running again should not pick up generated files.
+	manifest, err = Generate(dir)
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Assets, 3)
+
+	set, err := Load(os.DirFS(dir))
+	assert.NoError(t, err)
+	cssPath := set.Path("main.css")
+	assert.NotEqual(t, "main.css", cssPath)
+	assert.True(t, strings.HasPrefix(cssPath, "main."))
+	assert.True(t, strings.HasSuffix(set.Path("/vendor/lib.js"), ".js"))
+	assert.Equal(t, "unknown.css", set.Path("unknown.css"))
+
+	handler := set.Handler()
+	serve := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/"+path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Fingerprinted", func(t *testing.T) {
+		rec := serve(cssPath, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, immutableCache, rec.Header().Get("Cache-Control"))
+		assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css"))
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, css, rec.Body.String())
+	})
+
+	t.Run("Logical", func(t *testing.T) {
+		rec := serve("main.css", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, revalidate, rec.Header().Get("Cache-Control"))
+	})
+
+	t.Run("Precompressed", func(t *testing.T) {
+		rec := serve(cssPath, map[string]string{"Accept-Encoding": "gzip, deflate, br"})
+		assert.Equal(t, EncodingBrotli, rec.Header().Get("Content-Encoding"))
+		assert.Contains(t, rec.Header().Get("Vary"), "Accept-Encoding")
+		rec = serve(cssPath, map[string]string{"Accept-Encoding": "gzip, br;q=0"})
+		assert.Equal(t, EncodingGzip, rec.Header().Get("Content-Encoding"))
+		assert.NotEqual(t, css, rec.Body.String())
+	})
+
+	t.Run("ETag", func(t *testing.T) {
+		etag := serve(cssPath, nil).Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+		rec := serve(cssPath, map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		gzipTag := serve(cssPath, map[string]string{"Accept-Encoding": "gzip"}).Header().Get("ETag")
+		assert.NotEqual(t, etag, gzipTag, "Each encoding should have a distinct ETag")
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("missing.css", nil).Code)
+		assert.Equal(t, http.StatusNotFound, serve(ManifestFile, nil).Code)
+		assert.Equal(t, http.StatusNotFound, serve("vendor", nil).Code)
+	})
+}
+
+func TestLoad_NoManifest(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.css"), []byte("body {}"), 0644))
+	set, err := Load(os.DirFS(dir))
+	assert.NoError(t, err)
+	assert.Equal(t, "main.css", set.Path("main.css"))
+	req := httptest.NewRequest(http.MethodGet, "/main.css", nil)
+	rec := httptest.NewRecorder()
+	set.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "body {}", rec.Body.String())
+}
+
+func TestLoadLive(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.css"), []byte("body {}"), 0644))
+	_, err := Generate(dir)
+	assert.NoError(t, err)
+	set := LoadLive(os.DirFS(dir))
+	assert.Equal(t, "main.css", set.Path("main.css"), "The manifest should be ignored")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.css"), []byte("body { color: red; }"), 0644))
+	req := httptest.NewRequest(http.MethodGet, "/main.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	set.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, revalidate, rec.Header().Get("Cache-Control"))
+	assert.Empty(t, rec.Header().Get("Content-Encoding"), "Stale precompressed variants shouldn't be served")
+	assert.Equal(t, "body { color: red; }", rec.Body.String())
+}
@@ -0,0 +1,159 @@
+package assets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	hashLen = 10
+)
+
+var (
+	// CompressibleExts lists the file extensions that will have precompressed variants generated.
+	CompressibleExts = []string{".css", ".js", ".mjs", ".svg", ".html", ".json", ".txt", ".map"}
+)
+
+// Generate fingerprints every asset under dir, writes precompressed variants for compressible assets, and writes the [ManifestFile].
+// Previously generated variants are replaced, so this is safe to run repeatedly.
+func Generate(dir string) (*Manifest, error) {
+	manifest := &Manifest{Version: manifestVersion, Assets: map[string]Asset{}}
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isGenerated(d.Name()) || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk asset directory: %w", err)
+	}
+	for _, file := range files {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return nil, err
+		}
+		logical := filepath.ToSlash(rel)
+		asset, err := generateAsset(file, logical)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate asset '%s': %w", logical, err)
+		}
+		manifest.Assets[logical] = asset
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write asset manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func generateAsset(file, logical string) (Asset, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Asset{}, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	asset := Asset{
+		Path: fingerprintPath(logical, hash[:hashLen]),
+		Hash: hash,
+	}
+	for _, candidate := range encodingExt {
+		variant := file + candidate.ext
+		_ = os.Remove(variant)
+		if !isCompressible(logical) {
+			continue
+		}
+		compressed, err := compress(candidate.encoding, data)
+		if err != nil {
+			return Asset{}, err
+		}
+		if len(compressed) >= len(data) {
+			continue
+		}
+		if err := os.WriteFile(variant, compressed, 0644); err != nil {
+			return Asset{}, err
+		}
+		asset.Encodings = append(asset.Encodings, candidate.encoding)
+	}
+	return asset, nil
+}
+
+// fingerprintPath inserts the hash before the final extension, so "vendor/htmx.min.js" becomes "vendor/htmx.min.<hash>.js".
+func fingerprintPath(logical, hash string) string {
+	ext := path.Ext(logical)
+	return strings.TrimSuffix(logical, ext) + "." + hash + ext
+}
+
+func compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch encoding {
+	case EncodingBrotli:
+		w := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case EncodingGzip:
+		w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding '%s'", encoding)
+	}
+	return buf.Bytes(), nil
+}
+
+func isCompressible(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, compressible := range CompressibleExts {
+		if ext == compressible {
+			return true
+		}
+	}
+	return false
+}
+
+func isGenerated(name string) bool {
+	if name == ManifestFile {
+		return true
+	}
+	for _, candidate := range encodingExt {
+		if strings.HasSuffix(name, candidate.ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+// Package backoff computes exponential delays between retries of work done in the background, like sending email and running jobs.
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Delay returns how long to wait after the given failed attempt, starting at base and doubling up to limit.
+// Up to 10% jitter is added, so work that failed together doesn't all retry together.
+func Delay(attempt int, base, limit time.Duration) time.Duration {
+	delay := limit
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt <= 20 {
+		delay = min(base<<(attempt-1), limit)
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay/10)+1))
+}
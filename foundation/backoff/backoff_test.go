@@ -0,0 +1,30 @@
+package backoff
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	tests := map[string]struct {
+		attempt int
+		base    time.Duration
+		min     time.Duration
+	}{
+		"First":       {attempt: 1, base: 30 * time.Second, min: 30 * time.Second},
+		"Second":      {attempt: 2, base: 30 * time.Second, min: time.Minute},
+		"Fifth":       {attempt: 5, base: 10 * time.Second, min: 160 * time.Second},
+		"Capped":      {attempt: 12, base: 10 * time.Second, min: time.Hour},
+		"Huge":        {attempt: 100, base: 30 * time.Second, min: time.Hour},
+		"Invalid":     {attempt: 0, base: 10 * time.Second, min: 10 * time.Second},
+		"Base at max": {attempt: 1, base: 2 * time.Hour, min: time.Hour},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			delay := Delay(tc.attempt, tc.base, time.Hour)
+			assert.GreaterOrEqual(t, delay, tc.min)
+			assert.LessOrEqual(t, delay, tc.min+tc.min/10)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package cors provides middleware that applies a per-origin Cross-Origin Resource Sharing policy.
+package cors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderOrigin           = "Origin"
+	HeaderRequestMethod    = "Access-Control-Request-Method"
+	HeaderRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderMaxAge           = "Access-Control-Max-Age"
+)
+
+var (
+	ErrInvalidPolicy = errors.New("invalid CORS policy")
+)
+
+// Origin configures what a single origin is allowed to do.
+type Origin struct {
+	// Credentials allows the origin to send cookies and read responses to credentialed requests.
+	Credentials bool `json:"credentials"`
+	// Methods are allowed in addition to the CORS safelisted methods, GET, HEAD, and POST.
+	Methods []string `json:"methods"`
+	// Headers are request headers the origin may send, in addition to the CORS safelisted headers.
+	Headers []string `json:"headers"`
+	// Expose are response headers that the origin's scripts may read.
+	Expose []string `json:"expose"`
+	// MaxAge is how long a preflight response may be cached.
+	MaxAge time.Duration `json:"-"`
+}
+
+// Policy maps exact origins, like "https://app.example.com", to what they're allowed to do.
+// Origins that aren't in the policy get no CORS headers, so browsers block their cross-origin requests.
+// There is deliberately no wildcard, since the API is usually used with credentials.
+type Policy map[string]Origin
+
+// Parse reads a policy from JSON, which is an object mapping origins to their [Origin] settings.
+// A "maxAge" in seconds may be given for each origin.
+//
+//	{"https://app.example.com": {"credentials": true, "methods": ["PUT", "DELETE"], "headers": ["Content-Type", "X-CSRF-Token"], "maxAge": 600}}
+func Parse(data string) (Policy, error) {
+	if len(strings.TrimSpace(data)) == 0 {
+		return Policy{}, nil
+	}
+	var raw map[string]struct {
+		Origin
+		MaxAge int `json:"maxAge"`
+	}
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+	}
+	policy := Policy{}
+	for origin, settings := range raw {
+		o := settings.Origin
+		o.MaxAge = time.Duration(settings.MaxAge) * time.Second
+		policy[origin] = o
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate checks that each origin is a bare scheme, host, and optional port.
+func (p Policy) Validate() error {
+	for origin := range p {
+		u, err := url.Parse(origin)
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 || len(u.Path) > 0 || len(u.RawQuery) > 0 || len(u.Fragment) > 0 {
+			return fmt.Errorf("%w: '%s' is not an origin, like https://app.example.com", ErrInvalidPolicy, origin)
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return fmt.Errorf("%w: origin '%s' must use http or https", ErrInvalidPolicy, origin)
+		}
+	}
+	return nil
+}
+
+// Middleware sets CORS headers for allowed origins, and answers preflight requests.
+// Preflight requests from origins that aren't allowed get a 403.
+func (p Policy) Middleware() httpx.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get(HeaderOrigin)
+			if len(origin) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", HeaderOrigin)
+			allowed, ok := p[origin]
+			preflight := r.Method == http.MethodOptions && len(r.Header.Get(HeaderRequestMethod)) > 0
+			if preflight {
+				h.Add("Vary", HeaderRequestMethod)
+				h.Add("Vary", HeaderRequestHeaders)
+				if !ok {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				allowed.writeCommon(h, origin)
+				methods := append([]string{http.MethodGet, http.MethodHead, http.MethodPost}, allowed.Methods...)
+				h.Set(HeaderAllowMethods, strings.Join(methods, ", "))
+				if len(allowed.Headers) > 0 {
+					h.Set(HeaderAllowHeaders, strings.Join(allowed.Headers, ", "))
+				}
+				if allowed.MaxAge > 0 {
+					h.Set(HeaderMaxAge, strconv.Itoa(int(allowed.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if ok {
+				allowed.writeCommon(h, origin)
+				if len(allowed.Expose) > 0 {
+					h.Set(HeaderExposeHeaders, strings.Join(allowed.Expose, ", "))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (o Origin) writeCommon(h http.Header, origin string) {
+	h.Set(HeaderAllowOrigin, origin)
+	if o.Credentials {
+		h.Set(HeaderAllowCredentials, "true")
+	}
+}
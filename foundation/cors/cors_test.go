@@ -0,0 +1,135 @@
+package cors
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		data        string
+		expected    Policy
+		expectedErr bool
+	}{
+		"Empty": {
+			expected: Policy{},
+		},
+		"Origin settings": {
+			data: `{"https://app.example.com": {"credentials": true, "methods": ["PUT"], "headers": ["X-CSRF-Token"], "maxAge": 600}}`,
+			expected: Policy{
+				"https://app.example.com": {Credentials: true, Methods: []string{"PUT"}, Headers: []string{"X-CSRF-Token"}, MaxAge: 10 * time.Minute},
+			},
+		},
+		"Invalid JSON": {
+			data:        `["https://app.example.com"]`,
+			expectedErr: true,
+		},
+		"Wildcard": {
+			data:        `{"*": {}}`,
+			expectedErr: true,
+		},
+		"Path": {
+			data:        `{"https://app.example.com/": {}}`,
+			expectedErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			policy, err := Parse(tc.data)
+			if tc.expectedErr {
+				assert.ErrorIs(t, err, ErrInvalidPolicy)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, policy)
+		})
+	}
+}
+
+func TestPolicy_Middleware(t *testing.T) {
+	policy := Policy{
+		"https://app.example.com":   {Credentials: true, Methods: []string{http.MethodDelete}, Headers: []string{"Content-Type"}, Expose: []string{"X-Request-ID"}, MaxAge: time.Minute},
+		"https://other.example.com": {},
+	}
+	tests := map[string]struct {
+		method         string
+		headers        map[string]string
+		expectedStatus int
+		expectedCalled bool
+		expected       map[string]string
+	}{
+		"Same origin": {
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			expectedCalled: true,
+			expected:       map[string]string{HeaderAllowOrigin: ""},
+		},
+		"Allowed origin": {
+			method:         http.MethodGet,
+			headers:        map[string]string{HeaderOrigin: "https://app.example.com"},
+			expectedStatus: http.StatusOK,
+			expectedCalled: true,
+			expected: map[string]string{
+				HeaderAllowOrigin:      "https://app.example.com",
+				HeaderAllowCredentials: "true",
+				HeaderExposeHeaders:    "X-Request-ID",
+			},
+		},
+		"Allowed origin without credentials": {
+			method:         http.MethodGet,
+			headers:        map[string]string{HeaderOrigin: "https://other.example.com"},
+			expectedStatus: http.StatusOK,
+			expectedCalled: true,
+			expected: map[string]string{
+				HeaderAllowOrigin:      "https://other.example.com",
+				HeaderAllowCredentials: "",
+			},
+		},
+		"Unknown origin": {
+			method:         http.MethodGet,
+			headers:        map[string]string{HeaderOrigin: "https://evil.example.com"},
+			expectedStatus: http.StatusOK,
+			expectedCalled: true,
+			expected:       map[string]string{HeaderAllowOrigin: ""},
+		},
+		"Preflight": {
+			method:         http.MethodOptions,
+			headers:        map[string]string{HeaderOrigin: "https://app.example.com", HeaderRequestMethod: http.MethodDelete},
+			expectedStatus: http.StatusNoContent,
+			expected: map[string]string{
+				HeaderAllowOrigin:  "https://app.example.com",
+				HeaderAllowMethods: "GET, HEAD, POST, DELETE",
+				HeaderAllowHeaders: "Content-Type",
+				HeaderMaxAge:       "60",
+			},
+		},
+		"Preflight unknown origin": {
+			method:         http.MethodOptions,
+			headers:        map[string]string{HeaderOrigin: "https://evil.example.com", HeaderRequestMethod: http.MethodDelete},
+			expectedStatus: http.StatusForbidden,
+			expected:       map[string]string{HeaderAllowOrigin: ""},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var called bool
+			handler := policy.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+			req := httptest.NewRequest(tc.method, "/api/v1/session", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedCalled, called)
+			for k, v := range tc.expected {
+				assert.Equal(t, v, rec.Header().Get(k), k)
+			}
+		})
+	}
+}
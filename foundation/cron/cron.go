@@ -0,0 +1,204 @@
+// Package cron parses cron schedules, and finds when they next fire.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalid = errors.New("invalid cron schedule")
+
+// maxSearch bounds how far ahead [Schedule.Next] looks, so a schedule like "0 0 30 2 *" that never fires doesn't loop forever.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday may be given as 0 or 7.
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// Schedule is a parsed cron schedule.
+type Schedule struct {
+	spec                         string
+	minute, hour, dom, month     uint64
+	dow                          uint64
+	domRestricted, dowRestricted bool
+}
+
+// Parse parses a standard 5 field cron schedule: minute, hour, day of month, month, and day of week.
+// Fields may be *, a value, a range like 1-5, a list like 1,15, and may have a step like */15 or 0-30/10.
+// Months and days of week may be given by their 3 letter names.
+// The descriptors @yearly, @monthly, @weekly, @daily, and @hourly are accepted too.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	expanded := spec
+	if strings.HasPrefix(spec, "@") {
+		var ok bool
+		if expanded, ok = descriptors[strings.ToLower(spec)]; !ok {
+			return Schedule{}, fmt.Errorf("%w: unknown descriptor '%s'", ErrInvalid, spec)
+		}
+	}
+	parts := strings.Fields(expanded)
+	if len(parts) != 5 {
+		return Schedule{}, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalid, len(parts))
+	}
+	s := Schedule{spec: spec}
+	var err error
+	if s.minute, err = minuteField.parse(parts[0]); err != nil {
+		return Schedule{}, err
+	}
+	if s.hour, err = hourField.parse(parts[1]); err != nil {
+		return Schedule{}, err
+	}
+	if s.dom, err = domField.parse(parts[2]); err != nil {
+		return Schedule{}, err
+	}
+	if s.month, err = monthField.parse(parts[3]); err != nil {
+		return Schedule{}, err
+	}
+	if s.dow, err = dowField.parse(parts[4]); err != nil {
+		return Schedule{}, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = parts[2] != "*"
+	s.dowRestricted = parts[4] != "*"
+	return s, nil
+}
+
+// MustParse is like [Parse], but panics if the schedule is invalid, which is useful for schedules declared in code.
+func MustParse(spec string) Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time after the given time that the schedule fires, in the given time's location.
+// The zero time is returned if the schedule doesn't fire within the next 5 years.
+func (s Schedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		switch {
+		case !has(s.month, int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches follows the usual cron rule, where a day matches either field if both are restricted.
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func has(set uint64, n int) bool {
+	return set&(1<<n) != 0
+}
+
+func (f field) parse(expr string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(expr, ",") {
+		partSet, err := f.parsePart(part)
+		if err != nil {
+			return 0, err
+		}
+		set |= partSet
+	}
+	return set, nil
+}
+
+func (f field) parsePart(part string) (uint64, error) {
+	rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepExpr)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("%w: invalid step '%s' in %s", ErrInvalid, stepExpr, f.name)
+		}
+		step = n
+	}
+	lo, hi := f.min, f.max
+	if rangeExpr != "*" {
+		loExpr, hiExpr, isRange := strings.Cut(rangeExpr, "-")
+		var err error
+		if lo, err = f.value(loExpr); err != nil {
+			return 0, err
+		}
+		hi = lo
+		if isRange {
+			if hi, err = f.value(hiExpr); err != nil {
+				return 0, err
+			}
+		} else if hasStep {
+			hi = f.max
+		}
+		if hi < lo {
+			return 0, fmt.Errorf("%w: range '%s' in %s is backwards", ErrInvalid, rangeExpr, f.name)
+		}
+	}
+	var set uint64
+	for n := lo; n <= hi; n += step {
+		set |= 1 << n
+	}
+	return set, nil
+}
+
+func (f field) value(expr string) (int, error) {
+	if n, ok := f.names[strings.ToLower(expr)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(expr)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%w: '%s' is not a valid %s", ErrInvalid, expr, f.name)
+	}
+	return n, nil
+}
@@ -0,0 +1,70 @@
+package cron
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		spec      string
+		expectErr bool
+	}{
+		"Every minute":       {spec: "* * * * *"},
+		"Lists and ranges":   {spec: "0,30 9-17 * * mon-fri"},
+		"Steps":              {spec: "*/15 0-12/3 * * *"},
+		"Named months":       {spec: "0 0 1 jan,jul *"},
+		"Sunday as 7":        {spec: "0 0 * * 7"},
+		"Descriptor":         {spec: "@daily"},
+		"Too few fields":     {spec: "* * * *", expectErr: true},
+		"Out of range":       {spec: "60 * * * *", expectErr: true},
+		"Backwards range":    {spec: "* 17-9 * * *", expectErr: true},
+		"Zero step":          {spec: "*/0 * * * *", expectErr: true},
+		"Unknown name":       {spec: "* * * foo *", expectErr: true},
+		"Unknown descriptor": {spec: "@sometimes", expectErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tc.spec)
+			if tc.expectErr {
+				assert.ErrorIs(t, err, ErrInvalid)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// This is a Wednesday.
+	start := time.Date(2024, time.January, 10, 10, 17, 42, 0, time.UTC)
+	tests := map[string]struct {
+		spec     string
+		expected time.Time
+	}{
+		"Every minute":         {spec: "* * * * *", expected: time.Date(2024, 1, 10, 10, 18, 0, 0, time.UTC)},
+		"Every 15 minutes":     {spec: "*/15 * * * *", expected: time.Date(2024, 1, 10, 10, 30, 0, 0, time.UTC)},
+		"Hourly":               {spec: "@hourly", expected: time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		"Daily":                {spec: "@daily", expected: time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		"Later today":          {spec: "30 14 * * *", expected: time.Date(2024, 1, 10, 14, 30, 0, 0, time.UTC)},
+		"Weekdays only":        {spec: "0 9 * * mon-fri", expected: time.Date(2024, 1, 11, 9, 0, 0, 0, time.UTC)},
+		"Next Sunday":          {spec: "0 0 * * 7", expected: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		"Next month":           {spec: "0 0 1 * *", expected: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		"Leap day":             {spec: "0 0 29 2 *", expected: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		"Day of month or week": {spec: "0 0 15 * fri", expected: time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+		"Never":                {spec: "0 0 30 2 *", expected: time.Time{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := MustParse(tc.spec)
+			assert.Equal(t, tc.expected, s.Next(start))
+		})
+	}
+}
+
+func TestSchedule_Next_Exact(t *testing.T) {
+	s := MustParse("0 * * * *")
+	on := time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, on.Add(time.Hour), s.Next(on), "The next time should be strictly after the given time")
+}
@@ -0,0 +1,173 @@
+// Package health provides liveness, readiness, and build info endpoints for orchestrators and load balancers.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+
+	defaultTimeout = 2 * time.Second
+)
+
+// Check reports whether a dependency is ready, returning a non-nil error if it's not.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Probe tracks readiness checks and shutdown state.
+type Probe struct {
+	// Timeout limits how long all checks may take for a single readiness request. Defaults to 2 seconds.
+	Timeout time.Duration
+	// ErrorLog is used to log failed checks, since their errors are not included in responses.
+	// If nil, then the standard logger is used.
+	ErrorLog *log.Logger
+
+	mux      sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// Report is the response body of the readiness endpoint.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// AddCheck adds a named check that must pass for the app to be ready.
+func (p *Probe) AddCheck(name string, check Check) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.checks = append(p.checks, namedCheck{name: name, check: check})
+}
+
+// Drain marks the app as shutting down, which makes readiness fail so new traffic is routed elsewhere.
+func (p *Probe) Drain() {
+	p.draining.Store(true)
+}
+
+// Draining returns true if [Probe.Drain] has been called.
+func (p *Probe) Draining() bool {
+	return p.draining.Load()
+}
+
+// DrainCtx returns a context that is cancelled delay after ctx is done.
+// The Probe is marked as draining as soon as ctx is done, so load balancers have time to notice before the server stops accepting connections.
+func (p *Probe) DrainCtx(ctx context.Context, delay time.Duration) context.Context {
+	drainCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		<-ctx.Done()
+		p.Drain()
+		if delay <= 0 {
+			return
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		<-timer.C
+	}()
+	return drainCtx
+}
+
+// Ready runs all checks and reports the result.
+func (p *Probe) Ready(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: map[string]string{},
+	}
+	if p.Draining() {
+		report.Status = StatusDraining
+		report.Checks["shutdown"] = StatusDraining
+		return report
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	for _, check := range p.checks {
+		if err := check.check(ctx); err != nil {
+			p.logf("[ERR] Readiness check '%s' failed: %v\n", check.name, err)
+			report.Status = StatusFail
+			report.Checks[check.name] = StatusFail
+			continue
+		}
+		report.Checks[check.name] = StatusOK
+	}
+	return report
+}
+
+// Liveness responds with 200 as long as the server is able to handle requests.
+func (p *Probe) Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		noCache(w)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(StatusOK))
+	})
+}
+
+// Readiness responds with a JSON [Report], and a 503 if any check failed or the app is draining.
+func (p *Probe) Readiness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := p.Ready(r.Context())
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+func (p *Probe) logf(format string, args ...any) {
+	if p.ErrorLog != nil {
+		p.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// BuildInfo describes the running build.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitHash   string `json:"gitHash"`
+	GoVersion string `json:"goVersion"`
+}
+
+// VersionHandler responds with the given [BuildInfo] as JSON.
+// GoVersion is filled in from the runtime if it's empty.
+func VersionHandler(info BuildInfo) http.Handler {
+	if len(info.GoVersion) == 0 {
+		info.GoVersion = runtime.Version()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, info)
+	})
+}
+
+func noCache(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func writeJSON(w http.ResponseWriter, status int, val any) {
+	noCache(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(val)
+}
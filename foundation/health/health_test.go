@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbe_Readiness(t *testing.T) {
+	tests := map[string]struct {
+		checks         map[string]Check
+		drain          bool
+		expectedStatus int
+		expectedReport Report
+	}{
+		"No checks": {
+			expectedStatus: http.StatusOK,
+			expectedReport: Report{Status: StatusOK, Checks: map[string]string{}},
+		},
+		"Passing checks": {
+			checks: map[string]Check{
+				"db": func(ctx context.Context) error { return nil },
+			},
+			expectedStatus: http.StatusOK,
+			expectedReport: Report{Status: StatusOK, Checks: map[string]string{"db": StatusOK}},
+		},
+		"Failing check": {
+			checks: map[string]Check{
+				"db":         func(ctx context.Context) error { return nil },
+				"migrations": func(ctx context.Context) error { return errors.New("missing 01_auth") },
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: Report{Status: StatusFail, Checks: map[string]string{"db": StatusOK, "migrations": StatusFail}},
+		},
+		"Draining": {
+			checks: map[string]Check{
+				"db": func(ctx context.Context) error { return nil },
+			},
+			drain:          true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: Report{Status: StatusDraining, Checks: map[string]string{"shutdown": StatusDraining}},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			probe := &Probe{ErrorLog: log.New(io.Discard, "", 0)}
+			for checkName, check := range tc.checks {
+				probe.AddCheck(checkName, check)
+			}
+			if tc.drain {
+				probe.Drain()
+			}
+			rec := httptest.NewRecorder()
+			probe.Readiness().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			var report Report
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			assert.Equal(t, tc.expectedReport, report)
+			assert.NotContains(t, rec.Body.String(), "01_auth", "Check errors should not be exposed")
+		})
+	}
+}
+
+func TestProbe_DrainCtx(t *testing.T) {
+	probe := new(Probe)
+	ctx, cancel := context.WithCancel(context.Background())
+	drainCtx := probe.DrainCtx(ctx, 50*time.Millisecond)
+	assert.False(t, probe.Draining())
+
+	cancel()
+	assert.Eventually(t, probe.Draining, time.Second, time.Millisecond)
+	assert.NoError(t, drainCtx.Err(), "Server context should stay open during the drain delay")
+	select {
+	case <-drainCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Drain context was not cancelled after the delay")
+	}
+}
@@ -0,0 +1,72 @@
+// Package htmx provides helpers for detecting htmx requests, and responding in a way that htmx understands.
+package htmx
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	HeaderRequest        = "HX-Request"
+	HeaderBoosted        = "HX-Boosted"
+	HeaderHistoryRestore = "HX-History-Restore-Request"
+	HeaderTarget         = "HX-Target"
+	HeaderRedirect       = "HX-Redirect"
+	HeaderLocation       = "HX-Location"
+	HeaderRefresh        = "HX-Refresh"
+	HeaderRetarget       = "HX-Retarget"
+	HeaderReswap         = "HX-Reswap"
+)
+
+// IsRequest returns true if the request was made by htmx.
+func IsRequest(r *http.Request) bool {
+	return r.Header.Get(HeaderRequest) == "true"
+}
+
+// IsBoosted returns true if the request was made by an element using hx-boost.
+func IsBoosted(r *http.Request) bool {
+	return r.Header.Get(HeaderBoosted) == "true"
+}
+
+// IsHistoryRestore returns true if htmx is requesting a full page to restore browser history after a cache miss.
+func IsHistoryRestore(r *http.Request) bool {
+	return r.Header.Get(HeaderHistoryRestore) == "true"
+}
+
+// IsFragmentRequest returns true if the request expects a page fragment rather than a full page.
+// Boosted and history restore requests expect a full page, even though they're made by htmx.
+func IsFragmentRequest(r *http.Request) bool {
+	return IsRequest(r) && !IsBoosted(r) && !IsHistoryRestore(r)
+}
+
+// Redirect redirects the client to the given URL.
+// For htmx requests, this responds with an HX-Redirect header, which makes the browser load the full page at the URL.
+// Otherwise, a standard redirect with the given status is used.
+func Redirect(w http.ResponseWriter, r *http.Request, url string, status int) {
+	if IsRequest(r) {
+		w.Header().Set(HeaderRedirect, url)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, url, status)
+}
+
+// Location is like [Redirect], except that htmx requests are answered with an HX-Location header.
+// This makes htmx fetch the URL and swap it into the target, like a boosted link, instead of loading a full page.
+func Location(w http.ResponseWriter, r *http.Request, url string, target string, status int) {
+	if IsRequest(r) {
+		location := map[string]string{"path": url}
+		if len(target) > 0 {
+			location["target"] = target
+		}
+		val, err := json.Marshal(location)
+		if err != nil {
+			w.Header().Set(HeaderRedirect, url)
+		} else {
+			w.Header().Set(HeaderLocation, string(val))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Redirect(w, r, url, status)
+}
@@ -0,0 +1,67 @@
+package htmx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	tests := map[string]struct {
+		headers        map[string]string
+		expectedStatus int
+		hxRedirect     bool
+	}{
+		"Standard request": {
+			expectedStatus: http.StatusFound,
+		},
+		"htmx request": {
+			headers:        map[string]string{HeaderRequest: "true"},
+			expectedStatus: http.StatusNoContent,
+			hxRedirect:     true,
+		},
+		"Boosted request": {
+			headers:        map[string]string{HeaderRequest: "true", HeaderBoosted: "true"},
+			expectedStatus: http.StatusNoContent,
+			hxRedirect:     true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			Redirect(rec, req, "/login", http.StatusFound)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.hxRedirect {
+				assert.Equal(t, "/login", rec.Header().Get(HeaderRedirect))
+				assert.Empty(t, rec.Header().Get("Location"))
+			} else {
+				assert.Empty(t, rec.Header().Get(HeaderRedirect))
+				assert.Equal(t, "/login", rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestLocation(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set(HeaderRequest, "true")
+	rec := httptest.NewRecorder()
+	Location(rec, req, "/unauthorized", "#app-content", http.StatusFound)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.JSONEq(t, `{"path":"/unauthorized","target":"#app-content"}`, rec.Header().Get(HeaderLocation))
+}
+
+func TestIsFragmentRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.False(t, IsFragmentRequest(req))
+	req.Header.Set(HeaderRequest, "true")
+	assert.True(t, IsFragmentRequest(req))
+	req.Header.Set(HeaderHistoryRestore, "true")
+	assert.False(t, IsFragmentRequest(req))
+}
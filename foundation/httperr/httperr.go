@@ -0,0 +1,145 @@
+// Package httperr provides typed HTTP errors that carry a status code and a message that is safe to show to clients.
+//
+// The underlying cause of an error is kept for logging, but is never included in a response.
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Error is an error that should be reported to the client with a specific status code.
+type Error struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// Message is shown to the client, so it must not include internal details.
+	// If empty, the standard status text is used.
+	Message string
+	// Err is the underlying cause, if any. This is only used for logging.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %v", e.Status, e.PublicMessage(), e.Err)
+	}
+	return fmt.Sprintf("%d %s", e.Status, e.PublicMessage())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// PublicMessage returns the message that may be shown to the client.
+func (e *Error) PublicMessage() string {
+	if len(e.Message) > 0 {
+		return e.Message
+	}
+	return http.StatusText(e.Status)
+}
+
+// New creates an Error with the given status and public message.
+func New(status int, message string) *Error {
+	return &Error{Status: status, Message: message}
+}
+
+// Wrap creates an Error with the given status and public message, keeping err as the cause.
+func Wrap(status int, message string, err error) *Error {
+	return &Error{Status: status, Message: message, Err: err}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, message)
+}
+
+// Internal wraps an unexpected error as a 500 with a generic public message.
+func Internal(err error) *Error {
+	return Wrap(http.StatusInternalServerError, "", err)
+}
+
+// From converts any error to an *Error.
+// The sentinel errors from httpx are mapped to their matching status codes, and anything else is treated as [Internal].
+func From(err error) *Error {
+	var httpErr *Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	switch {
+	case errors.Is(err, httpx.ErrClientError):
+		return Wrap(http.StatusBadRequest, "", err)
+	case errors.Is(err, httpx.ErrAuthentication):
+		return Wrap(http.StatusUnauthorized, "", err)
+	case errors.Is(err, httpx.ErrAuthorization):
+		return Wrap(http.StatusForbidden, "", err)
+	default:
+		return Internal(err)
+	}
+}
+
+// Body is the JSON representation of an error response.
+type Body struct {
+	Error BodyError `json:"error"`
+}
+
+type BodyError struct {
+	Status    int    `json:"status"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestID,omitempty"`
+}
+
+// WriteJSON writes err to the client as a JSON [Body].
+func WriteJSON(w http.ResponseWriter, err *Error, requestID string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(err.Status)
+	_ = json.NewEncoder(w).Encode(Body{
+		Error: BodyError{
+			Status:    err.Status,
+			Message:   err.PublicMessage(),
+			RequestID: requestID,
+		},
+	})
+}
+
+// WantsJSON returns true if the client prefers a JSON response over HTML, based on the Accept header.
+// Requests that don't express a preference get HTML.
+func WantsJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qval, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qval, 64); err == nil {
+				q = parsed
+			}
+		}
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQ = max(jsonQ, q)
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
@@ -0,0 +1,85 @@
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/x/httpx"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	tests := map[string]struct {
+		err            error
+		expectedStatus int
+		expectedMsg    string
+	}{
+		"Typed error": {
+			err:            NotFound("No such widget"),
+			expectedStatus: http.StatusNotFound,
+			expectedMsg:    "No such widget",
+		},
+		"Wrapped typed error": {
+			err:            fmt.Errorf("loading widget: %w", Forbidden("")),
+			expectedStatus: http.StatusForbidden,
+			expectedMsg:    "Forbidden",
+		},
+		"httpx sentinel": {
+			err:            fmt.Errorf("bad input: %w", httpx.ErrClientError),
+			expectedStatus: http.StatusBadRequest,
+			expectedMsg:    "Bad Request",
+		},
+		"Unknown error": {
+			err:            errors.New("connection refused to 10.0.0.5"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedMsg:    "Internal Server Error",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			httpErr := From(tc.err)
+			assert.Equal(t, tc.expectedStatus, httpErr.Status)
+			assert.Equal(t, tc.expectedMsg, httpErr.PublicMessage())
+		})
+	}
+}
+
+func TestWantsJSON(t *testing.T) {
+	tests := map[string]struct {
+		accept   string
+		expected bool
+	}{
+		"No header":       {accept: "", expected: false},
+		"Browser":         {accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: false},
+		"JSON":            {accept: "application/json", expected: true},
+		"Problem JSON":    {accept: "application/problem+json", expected: true},
+		"Prefers HTML":    {accept: "application/json;q=0.5, text/html", expected: false},
+		"Prefers JSON":    {accept: "text/html;q=0.5, application/json", expected: true},
+		"JSON not wanted": {accept: "application/json;q=0", expected: false},
+		"Wildcard":        {accept: "*/*", expected: false},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tc.accept)
+			assert.Equal(t, tc.expected, WantsJSON(req))
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteJSON(rec, Internal(errors.New("secret details")), "abc123")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.NotContains(t, rec.Body.String(), "secret details")
+	var body Body
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusInternalServerError, body.Error.Status)
+	assert.Equal(t, "abc123", body.Error.RequestID)
+}
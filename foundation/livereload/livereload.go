@@ -0,0 +1,35 @@
+// Package livereload reloads pages in the browser when a development server restarts.
+//
+// The [Handler] streams server-sent events with an ID that's unique to the process.
+// A page keeps the first ID it sees, and reloads when its EventSource reconnects to a restarted server and gets a different one.
+package livereload
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Path is where the [Handler] is usually served.
+const Path = "/dev/reload"
+
+// retry is how soon browsers reconnect once the server goes away, which is sooner than their default of a few seconds.
+const retry = 250 * time.Millisecond
+
+var bootID = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// Handler sends a "boot" event with the process's ID, then holds the connection open until the client or server goes away.
+// Middleware that wraps the [http.ResponseWriter] without an Unwrap method keeps the event from being flushed, so it should be served outside of it.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-store")
+		_, _ = fmt.Fprintf(w, "retry: %d\nevent: boot\ndata: %s\n\n", retry.Milliseconds(), bootID)
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			return
+		}
+		<-r.Context().Done()
+	})
+}
@@ -0,0 +1,29 @@
+package livereload
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	// The event must arrive while the connection is still open.
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for scanner.Scan() && len(scanner.Text()) > 0 {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Equal(t, []string{"retry: 250", "event: boot", "data: " + bootID}, lines)
+}
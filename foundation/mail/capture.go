@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultCaptureSize is the number of messages kept by a [CaptureMailer] if no size is given.
+const DefaultCaptureSize = 100
+
+// Captured is a message kept by a [CaptureMailer].
+type Captured struct {
+	ID         uint64
+	CapturedAt time.Time
+	Message
+}
+
+// CaptureMailer keeps the most recent messages in memory instead of sending them.
+// This is for development, where captured messages can be browsed in the app rather than read from the log.
+type CaptureMailer struct {
+	From     string
+	mux      sync.RWMutex
+	size     int
+	nextID   uint64
+	messages []Captured
+	// OnCapture is called after each message is captured, e.g. to log a pointer to it.
+	OnCapture func(Captured)
+}
+
+func NewCaptureMailer(from string, size int) *CaptureMailer {
+	if size <= 0 {
+		size = DefaultCaptureSize
+	}
+	return &CaptureMailer{From: from, size: size}
+}
+
+func (m *CaptureMailer) Send(_ context.Context, msg Message) error {
+	msg = msg.withFrom(m.From)
+	if _, err := msg.Bytes(); err != nil {
+		return err
+	}
+	m.mux.Lock()
+	m.nextID++
+	captured := Captured{ID: m.nextID, CapturedAt: time.Now(), Message: msg}
+	m.messages = append(m.messages, captured)
+	if len(m.messages) > m.size {
+		m.messages = m.messages[len(m.messages)-m.size:]
+	}
+	m.mux.Unlock()
+	if m.OnCapture != nil {
+		m.OnCapture(captured)
+	}
+	return nil
+}
+
+// Messages returns the captured messages, newest first.
+func (m *CaptureMailer) Messages() []Captured {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	messages := make([]Captured, len(m.messages))
+	for i, msg := range m.messages {
+		messages[len(messages)-1-i] = msg
+	}
+	return messages
+}
+
+// Message returns the captured message with the given ID, if it's still kept.
+func (m *CaptureMailer) Message(id uint64) (Captured, bool) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	for _, msg := range m.messages {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+	return Captured{}, false
+}
+
+// Clear removes all captured messages.
+func (m *CaptureMailer) Clear() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.messages = nil
+}
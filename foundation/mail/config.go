@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"fmt"
+	"github.com/saylorsolutions/x/env"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+const (
+	EnvTransport    = "MAIL_TRANSPORT" // EnvTransport may be one of "stdout", "file", "capture", or "smtp".
+	EnvFrom         = "MAIL_FROM"      // EnvFrom is the default sender address.
+	EnvFile         = "MAIL_FILE"      // EnvFile is the file that messages are appended to with the "file" transport.
+	EnvSMTPAddr     = "SMTP_ADDR"      // EnvSMTPAddr is the SMTP server's host:port.
+	EnvSMTPUsername = "SMTP_USERNAME"
+	EnvSMTPPassword = "SMTP_PASSWORD"
+	EnvSMTPInsecure = "SMTP_INSECURE" // EnvSMTPInsecure allows sending credentials without TLS, for local development only.
+
+	defaultFrom = "Your App <noreply@localhost>"
+)
+
+// Load creates a Mailer from the environment.
+// Messages are written to stdout by default, so no mail server is needed in development.
+func Load() (Mailer, error) {
+	from := env.Val(EnvFrom, defaultFrom)
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid %s '%s': %w", EnvFrom, from, err)
+	}
+	switch transport := strings.ToLower(strings.TrimSpace(env.Val(EnvTransport, "stdout"))); transport {
+	case "stdout":
+		return NewWriterMailer(from, os.Stdout), nil
+	case "capture":
+		return NewCaptureMailer(from, DefaultCaptureSize), nil
+	case "file":
+		path := env.Val(EnvFile, "")
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%s must be set for the file mail transport", EnvFile)
+		}
+		return NewFileMailer(from, path)
+	case "smtp":
+		addr := env.Val(EnvSMTPAddr, "")
+		if len(addr) == 0 {
+			return nil, fmt.Errorf("%s must be set for the smtp mail transport", EnvSMTPAddr)
+		}
+		return &SMTPMailer{
+			Addr:     addr,
+			From:     from,
+			Username: env.Val(EnvSMTPUsername, ""),
+			Password: env.Val(EnvSMTPPassword, ""),
+			Insecure: env.Bool(EnvSMTPInsecure, false),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown %s '%s'", EnvTransport, transport)
+	}
+}
@@ -0,0 +1,204 @@
+// Package mail sends email through a [Mailer], which may be an SMTP server or a file for development and tests.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidMessage = errors.New("invalid message")
+)
+
+// Message is an email with a plain text body, and an optional HTML alternative.
+type Message struct {
+	// From defaults to the Mailer's sender address if empty.
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends email messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Validate checks that the message has parseable addresses, a subject, and a body.
+// An empty From is allowed, since the Mailer fills it in.
+// Header injection is prevented by rejecting line breaks in the subject.
+func (m Message) Validate() error {
+	if len(m.To) == 0 {
+		return fmt.Errorf("%w: no recipients", ErrInvalidMessage)
+	}
+	addrs := m.To
+	if len(m.From) > 0 {
+		addrs = append([]string{m.From}, m.To...)
+	}
+	for _, addr := range addrs {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("%w: address '%s': %v", ErrInvalidMessage, addr, err)
+		}
+	}
+	if len(strings.TrimSpace(m.Subject)) == 0 || strings.ContainsAny(m.Subject, "\r\n") {
+		return fmt.Errorf("%w: subject must be a single non-empty line", ErrInvalidMessage)
+	}
+	if len(m.Text) == 0 && len(m.HTML) == 0 {
+		return fmt.Errorf("%w: no body", ErrInvalidMessage)
+	}
+	return nil
+}
+
+// ParseAddress parses a single address like "Name <user@example.com>", and returns just the "user@example.com" part.
+func ParseAddress(addr string) (string, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(addr))
+	if err != nil {
+		return "", fmt.Errorf("%w: address '%s': %v", ErrInvalidMessage, addr, err)
+	}
+	return parsed.Address, nil
+}
+
+// withFrom returns the message with the default sender if it doesn't have one.
+func (m Message) withFrom(from string) Message {
+	if len(m.From) == 0 {
+		m.From = from
+	}
+	return m
+}
+
+// Bytes renders the message in the RFC 5322 format, with a multipart/alternative body if there is an HTML part.
+func (m Message) Bytes() ([]byte, error) {
+	if len(m.From) == 0 {
+		return nil, fmt.Errorf("%w: no sender", ErrInvalidMessage)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	header := textproto.MIMEHeader{}
+	header.Set("From", m.From)
+	header.Set("To", strings.Join(m.To, ", "))
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("Message-ID", messageID(m.From))
+	header.Set("MIME-Version", "1.0")
+
+	if len(m.HTML) == 0 {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, header)
+		if err := writeQP(&buf, m.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		if len(part.content) == 0 {
+			continue
+		}
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQP(pw, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	header.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	writeHeader(&buf, header)
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+func writeHeader(w io.Writer, header textproto.MIMEHeader) {
+	// A stable order makes messages easier to read and compare.
+	for _, key := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if val := header.Get(key); len(val) > 0 {
+			_, _ = fmt.Fprintf(w, "%s: %s\r\n", key, val)
+		}
+	}
+	_, _ = io.WriteString(w, "\r\n")
+}
+
+func writeQP(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, content); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, host, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = host
+		}
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(buf), domain)
+}
+
+// WriterMailer writes each message to an io.Writer instead of sending it.
+// This is useful in development, where messages can be read from stdout or a file, and in tests.
+type WriterMailer struct {
+	From string
+	mux  sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterMailer(from string, w io.Writer) *WriterMailer {
+	return &WriterMailer{From: from, w: w}
+}
+
+// NewFileMailer appends messages to the file at path, creating it if needed.
+// If path is "-", then messages are written to stdout.
+func NewFileMailer(from, path string) (*WriterMailer, error) {
+	if path == "-" {
+		return NewWriterMailer(from, os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mail file: %w", err)
+	}
+	return NewWriterMailer(from, f), nil
+}
+
+func (m *WriterMailer) Send(_ context.Context, msg Message) error {
+	data, err := msg.withFrom(m.From).Bytes()
+	if err != nil {
+		return err
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	_, err = fmt.Fprintf(m.w, "----- BEGIN MESSAGE -----\r\n%s\r\n----- END MESSAGE -----\r\n", data)
+	return err
+}
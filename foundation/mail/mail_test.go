@@ -0,0 +1,165 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestMessage_Validate(t *testing.T) {
+	valid := Message{From: "App <app@example.com>", To: []string{"bob@example.com"}, Subject: "Hi", Text: "Hello"}
+	tests := map[string]struct {
+		modify func(m *Message)
+		valid  bool
+	}{
+		"Valid": {
+			modify: func(m *Message) {},
+			valid:  true,
+		},
+		"No recipients": {
+			modify: func(m *Message) { m.To = nil },
+		},
+		"Bad recipient": {
+			modify: func(m *Message) { m.To = []string{"not an address"} },
+		},
+		"Header injection": {
+			modify: func(m *Message) { m.Subject = "Hi\r\nBcc: eve@example.com" },
+		},
+		"No body": {
+			modify: func(m *Message) { m.Text = "" },
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg := valid
+			tc.modify(&msg)
+			err := msg.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidMessage)
+			}
+		})
+	}
+}
+
+func TestMessage_Bytes(t *testing.T) {
+	msg := Message{
+		From:    "App <app@example.com>",
+		To:      []string{"bob@example.com"},
+		Subject: "Café",
+		Text:    "Open https://example.com/verify?token=abc",
+		HTML:    `<a href="https://example.com/verify?token=abc">Verify</a>`,
+	}
+	data, err := msg.Bytes()
+	assert.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if !assert.NoError(t, err) {
+		return
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Café", subject)
+	assert.Contains(t, parsed.Header.Get("Message-ID"), "@example.com>")
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		body, err := io.ReadAll(part)
+		assert.NoError(t, err)
+		bodies = append(bodies, string(body))
+	}
+	assert.Equal(t, []string{msg.Text, msg.HTML}, bodies, "Parts should be decoded from quoted-printable")
+}
+
+func TestWriterMailer_Send(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewWriterMailer("App <app@example.com>", &buf)
+	err := mailer.Send(context.Background(), Message{To: []string{"bob@example.com"}, Subject: "Hi", Text: "Hello"})
+	assert.NoError(t, err)
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "----- BEGIN MESSAGE -----"))
+	assert.Contains(t, out, "From: App <app@example.com>")
+	assert.Contains(t, out, "\r\n\r\nHello")
+
+	err = mailer.Send(context.Background(), Message{Subject: "Hi", Text: "Hello"})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
+
+func TestPlainText(t *testing.T) {
+	tests := map[string]struct {
+		html     string
+		expected string
+	}{
+		"Paragraphs": {
+			html:     "<p>Hello   Bob,</p>\n<p>Welcome &amp; enjoy.</p>",
+			expected: "Hello Bob,\n\nWelcome & enjoy.\n",
+		},
+		"Links": {
+			html:     `<p><a href="https://example.com/verify?a=1&amp;b=2">Verify</a> or <a href="https://example.com">https://example.com</a></p>`,
+			expected: "Verify (https://example.com/verify?a=1&b=2) or https://example.com\n",
+		},
+		"Lists and breaks": {
+			html:     "<ul><li>One</li><li>Two</li></ul>Line<br/>Break",
+			expected: "- One\n- Two\n\nLine\nBreak\n",
+		},
+		"Hidden content": {
+			html:     "<html><head><title>Hi</title><style>p { color: red; }</style></head><body><p>Hi</p></body></html>",
+			expected: "Hi\n",
+		},
+		"Table cells": {
+			html:     "<table><tr><td>Name</td><td>Bob</td></tr></table>",
+			expected: "Name Bob\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, PlainText(tc.html))
+		})
+	}
+}
+
+func TestCaptureMailer(t *testing.T) {
+	mailer := NewCaptureMailer("App <app@example.com>", 2)
+	var captured []uint64
+	mailer.OnCapture = func(msg Captured) {
+		captured = append(captured, msg.ID)
+	}
+	for _, subject := range []string{"One", "Two", "Three"} {
+		assert.NoError(t, mailer.Send(context.Background(), Message{To: []string{"bob@example.com"}, Subject: subject, Text: "Hi"}))
+	}
+	assert.ErrorIs(t, mailer.Send(context.Background(), Message{Subject: "Nobody", Text: "Hi"}), ErrInvalidMessage)
+	assert.Equal(t, []uint64{1, 2, 3}, captured)
+
+	messages := mailer.Messages()
+	if assert.Len(t, messages, 2, "Only the newest messages should be kept") {
+		assert.Equal(t, "Three", messages[0].Subject)
+		assert.Equal(t, "Two", messages[1].Subject)
+		assert.Equal(t, "App <app@example.com>", messages[0].From)
+	}
+	_, ok := mailer.Message(1)
+	assert.False(t, ok)
+	msg, ok := mailer.Message(2)
+	assert.True(t, ok)
+	assert.Equal(t, "Two", msg.Subject)
+
+	mailer.Clear()
+	assert.Empty(t, mailer.Messages())
+}
@@ -0,0 +1,282 @@
+// Package mailtest provides an in-process SMTP server for testing code that sends email, so tests don't need a live mail server.
+package mailtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Received is a message accepted by the [Server].
+type Received struct {
+	From string
+	To   []string
+	Data []byte
+	// Username is the authenticated user, if any.
+	Username string
+	// TLS is true if the message was sent after STARTTLS.
+	TLS bool
+}
+
+// Message parses the received data.
+func (r Received) Message() (*mail.Message, error) {
+	return mail.ReadMessage(strings.NewReader(string(r.Data)))
+}
+
+// Server is a minimal SMTP server that records the messages it accepts.
+// It supports STARTTLS and AUTH PLAIN, which are enough to exercise a real SMTP client.
+type Server struct {
+	// Username and Password, if set, must be given with AUTH PLAIN before sending.
+	Username string
+	Password string
+	// TLSConfig enables STARTTLS if set. See [TLSConfig] for a self-signed pair.
+	TLSConfig *tls.Config
+	// Reply, if set, is called with each command line from the client.
+	// A non-empty result is sent as the reply instead of handling the command, which can simulate failures like "550 No such user".
+	Reply func(cmd string) string
+
+	mux      sync.Mutex
+	ln       net.Listener
+	received []Received
+	wg       sync.WaitGroup
+}
+
+// Start listens on a random local port, and stops the server when the test is done.
+func (s *Server) Start(t testing.TB) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen for SMTP: %v", err)
+	}
+	s.ln = ln
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+}
+
+// Addr is the host:port to send to.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops accepting connections, and waits for open sessions to end.
+func (s *Server) Close() {
+	_ = s.ln.Close()
+	s.wg.Wait()
+}
+
+// Messages returns the messages accepted so far.
+func (s *Server) Messages() []Received {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]Received(nil), s.received...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				_ = conn.Close()
+			}()
+			_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+			s.session(conn)
+		}()
+	}
+}
+
+type session struct {
+	conn     net.Conn
+	tp       *textproto.Conn
+	tls      bool
+	username string
+	from     string
+	to       []string
+}
+
+func (s *Server) session(conn net.Conn) {
+	sess := &session{conn: conn, tp: textproto.NewConn(conn)}
+	_ = sess.tp.PrintfLine("220 localhost ESMTP mailtest")
+	for {
+		line, err := sess.tp.ReadLine()
+		if err != nil {
+			return
+		}
+		if s.Reply != nil {
+			if reply := s.Reply(line); len(reply) > 0 {
+				_ = sess.tp.PrintfLine("%s", reply)
+				continue
+			}
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"localhost"}
+			if s.TLSConfig != nil && !sess.tls {
+				lines = append(lines, "STARTTLS")
+			}
+			if len(s.Username) > 0 {
+				lines = append(lines, "AUTH PLAIN")
+			}
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				_ = sess.tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			if s.TLSConfig == nil || sess.tls {
+				_ = sess.tp.PrintfLine("502 STARTTLS not available")
+				continue
+			}
+			_ = sess.tp.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(sess.conn, s.TLSConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			*sess = session{conn: tlsConn, tp: textproto.NewConn(tlsConn), tls: true}
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			if !strings.EqualFold(mech, "PLAIN") {
+				_ = sess.tp.PrintfLine("504 Unrecognized authentication type")
+				continue
+			}
+			if len(initial) == 0 {
+				_ = sess.tp.PrintfLine("334 ")
+				if initial, err = sess.tp.ReadLine(); err != nil {
+					return
+				}
+			}
+			username, err := s.checkPlain(initial)
+			if err != nil {
+				_ = sess.tp.PrintfLine("535 Authentication failed")
+				continue
+			}
+			sess.username = username
+			_ = sess.tp.PrintfLine("235 Authenticated")
+		case "MAIL":
+			if len(s.Username) > 0 && len(sess.username) == 0 {
+				_ = sess.tp.PrintfLine("530 Authentication required")
+				continue
+			}
+			sess.from = parsePath(arg, "FROM:")
+			sess.to = nil
+			_ = sess.tp.PrintfLine("250 OK")
+		case "RCPT":
+			if len(sess.from) == 0 {
+				_ = sess.tp.PrintfLine("503 Need MAIL first")
+				continue
+			}
+			sess.to = append(sess.to, parsePath(arg, "TO:"))
+			_ = sess.tp.PrintfLine("250 OK")
+		case "DATA":
+			if len(sess.to) == 0 {
+				_ = sess.tp.PrintfLine("503 Need RCPT first")
+				continue
+			}
+			_ = sess.tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := sess.tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mux.Lock()
+			s.received = append(s.received, Received{
+				From:     sess.from,
+				To:       sess.to,
+				Data:     data,
+				Username: sess.username,
+				TLS:      sess.tls,
+			})
+			s.mux.Unlock()
+			sess.from, sess.to = "", nil
+			_ = sess.tp.PrintfLine("250 OK queued")
+		case "RSET":
+			sess.from, sess.to = "", nil
+			_ = sess.tp.PrintfLine("250 OK")
+		case "NOOP":
+			_ = sess.tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = sess.tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = sess.tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *Server) checkPlain(initial string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(initial)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(string(decoded), "\x00")
+	if len(parts) != 3 || parts[1] != s.Username || parts[2] != s.Password {
+		return "", errors.New("invalid credentials")
+	}
+	return parts[1], nil
+}
+
+// parsePath gets the address from a MAIL or RCPT argument like "FROM:<bob@example.com> BODY=8BITMIME".
+func parsePath(arg, prefix string) string {
+	if len(arg) >= len(prefix) && strings.EqualFold(arg[:len(prefix)], prefix) {
+		arg = arg[len(prefix):]
+	}
+	arg, _, _ = strings.Cut(strings.TrimSpace(arg), " ")
+	return strings.Trim(arg, "<>")
+}
+
+// TLSConfig creates a self-signed certificate for 127.0.0.1 and localhost.
+// The server config is meant for [Server.TLSConfig], and the client config trusts the certificate.
+func TLSConfig(t testing.TB) (server *tls.Config, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mailtest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+	return server, client
+}
@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/a-h/templ"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	linkPattern     = regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	blockPattern    = regexp.MustCompile(`(?i)</?(p|div|h[1-6]|table|tr|ul|ol|blockquote)(\s[^>]*)?>`)
+	breakPattern    = regexp.MustCompile(`(?i)<(br|hr)\s*/?>`)
+	itemPattern     = regexp.MustCompile(`(?i)<li(\s[^>]*)?>`)
+	cellPattern     = regexp.MustCompile(`(?i)</t[dh]>`)
+	hiddenPattern   = regexp.MustCompile(`(?is)<(head|style|script)[^>]*>.*?</(head|style|script)>`)
+	tagPattern      = regexp.MustCompile(`<[^>]*>`)
+	spacePattern    = regexp.MustCompile(`[ \t]+`)
+	newlinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// Render renders an HTML email body from a templ component into the message.
+// The plain text alternative is derived from the same HTML with [PlainText], so there's only one template to maintain for each email.
+func Render(ctx context.Context, msg Message, body templ.Component) (Message, error) {
+	var buf bytes.Buffer
+	if err := body.Render(ctx, &buf); err != nil {
+		return msg, fmt.Errorf("failed to render email '%s': %w", msg.Subject, err)
+	}
+	msg.HTML = buf.String()
+	msg.Text = PlainText(msg.HTML)
+	return msg, nil
+}
+
+// PlainText converts simple email HTML to readable plain text.
+// Block elements become line breaks, list items become bullets, and links are written out as "text (url)" so they can still be followed.
+// This is meant for the app's own email templates rather than arbitrary HTML.
+func PlainText(body string) string {
+	text := strings.ReplaceAll(body, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n", " ")
+	text = hiddenPattern.ReplaceAllString(text, "")
+	text = linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		match := linkPattern.FindStringSubmatch(link)
+		href, label := match[1], strings.TrimSpace(tagPattern.ReplaceAllString(match[2], ""))
+		if len(label) == 0 || html.UnescapeString(label) == html.UnescapeString(href) {
+			return href
+		}
+		return label + " (" + href + ")"
+	})
+	text = blockPattern.ReplaceAllString(text, "\n\n")
+	text = breakPattern.ReplaceAllString(text, "\n")
+	text = itemPattern.ReplaceAllString(text, "\n- ")
+	text = cellPattern.ReplaceAllString(text, " ")
+	text = tagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spacePattern.ReplaceAllString(line, " "))
+	}
+	text = newlinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text) + "\n"
+}
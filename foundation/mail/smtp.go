@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// SMTPMailer sends messages through an SMTP server.
+// STARTTLS is used whenever the server offers it, and is required before authenticating unless Insecure is set.
+type SMTPMailer struct {
+	// Addr is the server's host:port.
+	Addr     string
+	From     string
+	Username string
+	Password string
+	// Insecure allows authenticating without TLS, which should only be used with a local development server.
+	Insecure bool
+	// TLSConfig is used for STARTTLS. If nil, then the host from Addr is verified with the system roots.
+	TLSConfig *tls.Config
+	// Timeout limits the whole exchange if the context doesn't have an earlier deadline.
+	Timeout time.Duration
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	msg = msg.withFrom(m.From)
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address '%s': %w", m.Addr, err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if err := client.Hello("localhost"); err != nil {
+		return fmt.Errorf("failed to greet SMTP server: %w", err)
+	}
+	var secure bool
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig := m.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: host}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+		secure = true
+	}
+	if len(m.Username) > 0 {
+		if !secure && !m.Insecure {
+			return errors.New("refusing to send SMTP credentials without TLS")
+		}
+		if err := client.Auth(plainAuth{username: m.Username, password: m.Password}); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+	from, _ := mail.ParseAddress(msg.From)
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP server rejected sender: %w", err)
+	}
+	for _, to := range msg.To {
+		addr, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("SMTP server rejected recipient '%s': %w", addr.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start sending message: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected message: %w", err)
+	}
+	return client.Quit()
+}
+
+// plainAuth is PLAIN authentication without the TLS check in [smtp.PlainAuth], which is enforced by SMTPMailer.Send instead.
+// This allows Insecure to work with development servers that aren't on localhost, like a docker compose service.
+type plainAuth struct {
+	username, password string
+}
+
+func (a plainAuth) Start(_ *smtp.ServerInfo) (string, []byte, error) {
+	return "PLAIN", []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+func (a plainAuth) Next(_ []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("unexpected server challenge")
+	}
+	return nil, nil
+}
+
+// IsPermanent reports whether sending failed in a way that retrying won't fix.
+// This is the case for invalid messages, and for SMTP 5xx replies like an unknown recipient.
+// Anything else, like a connection failure or a 4xx reply, may succeed later.
+func IsPermanent(err error) bool {
+	if errors.Is(err, ErrInvalidMessage) {
+		return true
+	}
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 500
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package mail
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"yourapp/foundation/mail/mailtest"
+)
+
+func TestSMTPMailer_Send(t *testing.T) {
+	serverTLS, clientTLS := mailtest.TLSConfig(t)
+	msg := Message{To: []string{"Bob <bob@example.com>", "carol@example.com"}, Subject: "Hi", Text: "Hello"}
+
+	tests := map[string]struct {
+		server      *mailtest.Server
+		mailer      SMTPMailer
+		expectedErr string
+		permanent   bool
+		expectTLS   bool
+	}{
+		"No auth": {
+			server: &mailtest.Server{},
+		},
+		"STARTTLS and auth": {
+			server:    &mailtest.Server{Username: "app", Password: "secret", TLSConfig: serverTLS},
+			mailer:    SMTPMailer{Username: "app", Password: "secret", TLSConfig: clientTLS},
+			expectTLS: true,
+		},
+		"Untrusted certificate": {
+			server:      &mailtest.Server{TLSConfig: serverTLS},
+			expectedErr: "failed to start TLS",
+		},
+		"Auth without TLS": {
+			server:      &mailtest.Server{Username: "app", Password: "secret"},
+			mailer:      SMTPMailer{Username: "app", Password: "secret"},
+			expectedErr: "refusing to send SMTP credentials without TLS",
+		},
+		"Insecure auth": {
+			server: &mailtest.Server{Username: "app", Password: "secret"},
+			mailer: SMTPMailer{Username: "app", Password: "secret", Insecure: true},
+		},
+		"Wrong password": {
+			server:      &mailtest.Server{Username: "app", Password: "secret"},
+			mailer:      SMTPMailer{Username: "app", Password: "hunter2", Insecure: true},
+			expectedErr: "failed to authenticate",
+			permanent:   true,
+		},
+		"Unknown recipient": {
+			server: &mailtest.Server{Reply: func(cmd string) string {
+				if strings.Contains(cmd, "carol@") {
+					return "550 No such user"
+				}
+				return ""
+			}},
+			expectedErr: "rejected recipient 'carol@example.com'",
+			permanent:   true,
+		},
+		"Temporary failure": {
+			server: &mailtest.Server{Reply: func(cmd string) string {
+				if strings.HasPrefix(cmd, "MAIL") {
+					return "421 Try again later"
+				}
+				return ""
+			}},
+			expectedErr: "rejected sender",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.server.Start(t)
+			mailer := tc.mailer
+			mailer.Addr = tc.server.Addr()
+			mailer.From = "App <app@example.com>"
+			mailer.Timeout = 5 * time.Second
+			err := mailer.Send(context.Background(), msg)
+			if len(tc.expectedErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				assert.Equal(t, tc.permanent, IsPermanent(err))
+				assert.Empty(t, tc.server.Messages())
+				return
+			}
+			assert.NoError(t, err)
+			received := tc.server.Messages()
+			if !assert.Len(t, received, 1) {
+				return
+			}
+			assert.Equal(t, "app@example.com", received[0].From)
+			assert.Equal(t, []string{"bob@example.com", "carol@example.com"}, received[0].To)
+			assert.Equal(t, tc.expectTLS, received[0].TLS)
+			assert.Equal(t, tc.mailer.Username, received[0].Username)
+			parsed, err := received[0].Message()
+			if assert.NoError(t, err) {
+				assert.Equal(t, "Hi", parsed.Header.Get("Subject"))
+				body, _ := io.ReadAll(parsed.Body)
+				assert.Equal(t, "Hello", strings.TrimSpace(string(body)))
+			}
+		})
+	}
+}
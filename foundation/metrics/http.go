@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnmatchedRoute is the route label used for requests that didn't match a [http.ServeMux] pattern.
+const UnmatchedRoute = "unmatched"
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(data []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(data)
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// HTTPMetrics records request counts and latency for each route.
+type HTTPMetrics struct {
+	requests *Counter
+	latency  *Histogram
+}
+
+// NewHTTPMetrics registers HTTP request metrics with the registry.
+func (r *Registry) NewHTTPMetrics() *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: r.NewCounter("http_requests_total", "HTTP requests by method, route, and status code", "method", "route", "code"),
+		latency:  r.NewHistogram("http_request_duration_seconds", "HTTP request latency by method and route", nil, "method", "route"),
+	}
+}
+
+// Middleware records metrics for each request.
+//
+// Routes are labeled with the matched [http.ServeMux] pattern rather than the raw path, so the number of series stays bounded.
+// The pattern is only visible if this middleware directly wraps the ServeMux, since the mux sets it on the request it's given.
+func (m *HTTPMetrics) Middleware() httpx.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			start := time.Now()
+			defer func() {
+				route := r.Pattern
+				if _, path, hasMethod := strings.Cut(route, " "); hasMethod {
+					route = path
+				}
+				if len(route) == 0 {
+					route = UnmatchedRoute
+				}
+				status := sw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				method := methodLabel(r.Method)
+				m.requests.Inc(method, route, strconv.Itoa(status))
+				m.latency.Observe(time.Since(start).Seconds(), method, route)
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// methodLabel limits methods to the standard set, since clients can send anything.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,367 @@
+// Package metrics provides counters, gauges, and histograms that are exposed in the Prometheus text format.
+//
+// This covers the small subset of the Prometheus client that the app needs, without pulling in its dependencies.
+// Metrics are usually declared as package variables with the New* functions, which register them with [Default].
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+
+	labelSep = "\xff"
+)
+
+// Default is the registry used by the package level New* functions.
+var Default = NewRegistry()
+
+// DefaultBuckets are histogram buckets suitable for HTTP request latency in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	describe() (name, help, typ string)
+	write(w io.Writer, name string)
+}
+
+// Registry holds a set of uniquely named metrics.
+type Registry struct {
+	mux     sync.RWMutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+func (r *Registry) register(m metric) {
+	name, _, _ := m.describe()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metric '%s' is already registered", name))
+	}
+	r.metrics[name] = m
+}
+
+// WriteText writes all metrics in the Prometheus text format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mux.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mux.RUnlock()
+
+	for _, m := range metrics {
+		name, help, typ := m.describe()
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+		m.write(w, name)
+	}
+}
+
+// Handler serves the registry's metrics in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		r.WriteText(w)
+	})
+}
+
+type desc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func (d desc) describe() (string, string, string) {
+	return d.name, d.help, d.typ
+}
+
+func (d desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labelNames) {
+		panic(fmt.Sprintf("metric '%s' expects %d label values, got %d", d.name, len(d.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, labelSep)
+}
+
+// labels formats label pairs, with optional extra pairs appended.
+func (d desc) labels(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labelNames) > 0 {
+		for i, val := range strings.Split(key, labelSep) {
+			pairs = append(pairs, d.labelNames[i]+`="`+escapeLabel(val)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// series holds a float value for each combination of label values.
+type series struct {
+	desc
+	mux  sync.Mutex
+	vals map[string]float64
+}
+
+func (s *series) add(delta float64, labelValues []string) {
+	key := s.key(labelValues)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.vals[key] += delta
+}
+
+func (s *series) set(val float64, labelValues []string) {
+	key := s.key(labelValues)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.vals[key] = val
+}
+
+func (s *series) get(labelValues []string) float64 {
+	key := s.key(labelValues)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.vals[key]
+}
+
+func (s *series) write(w io.Writer, name string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	keys := make([]string, 0, len(s.vals))
+	for key := range s.vals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", name, s.labels(key), formatFloat(s.vals[key]))
+	}
+}
+
+// Counter is a value that only increases, optionally partitioned by labels.
+type Counter struct {
+	series
+}
+
+// NewCounter creates a Counter registered with [Default].
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return Default.NewCounter(name, help, labelNames...)
+}
+
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{series{desc: desc{name: name, help: help, typ: typeCounter, labelNames: labelNames}, vals: map[string]float64{}}}
+	if len(labelNames) == 0 {
+		c.vals[""] = 0
+	}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter for the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add adds a non-negative delta to the counter for the given label values.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("counters cannot decrease")
+	}
+	c.add(delta, labelValues)
+}
+
+// Value returns the current value for the given label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.get(labelValues)
+}
+
+// Gauge is a value that can go up and down, optionally partitioned by labels.
+type Gauge struct {
+	series
+}
+
+// NewGauge creates a Gauge registered with [Default].
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return Default.NewGauge(name, help, labelNames...)
+}
+
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{series{desc: desc{name: name, help: help, typ: typeGauge, labelNames: labelNames}, vals: map[string]float64{}}}
+	if len(labelNames) == 0 {
+		g.vals[""] = 0
+	}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Set(val float64, labelValues ...string) {
+	g.set(val, labelValues)
+}
+
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.get(labelValues)
+}
+
+// funcMetric reads its value when metrics are collected.
+type funcMetric struct {
+	desc
+	fn func() float64
+}
+
+func (f *funcMetric) write(w io.Writer, name string) {
+	_, _ = fmt.Fprintf(w, "%s %s\n", name, formatFloat(f.fn()))
+}
+
+// NewGaugeFunc registers a gauge with [Default] that calls fn each time metrics are collected.
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.NewGaugeFunc(name, help, fn)
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{desc: desc{name: name, help: help, typ: typeGauge}, fn: fn})
+}
+
+// NewCounterFunc registers a counter with [Default] that calls fn each time metrics are collected.
+// This is useful for exposing totals that are already tracked elsewhere, like [sql.DBStats].
+func NewCounterFunc(name, help string, fn func() float64) {
+	Default.NewCounterFunc(name, help, fn)
+}
+
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{desc: desc{name: name, help: help, typ: typeCounter}, fn: fn})
+}
+
+// Histogram counts observations in cumulative buckets, optionally partitioned by labels.
+type Histogram struct {
+	desc
+	buckets []float64
+	mux     sync.Mutex
+	vals    map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a Histogram registered with [Default].
+// If buckets is nil, then [DefaultBuckets] is used.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labelNames...)
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{
+		desc:    desc{name: name, help: help, typ: typeHistogram, labelNames: labelNames},
+		buckets: buckets,
+		vals:    map[string]*histogramValue{},
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records a value for the given label values.
+func (h *Histogram) Observe(val float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	hv, ok := h.vals[key]
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.vals[key] = hv
+	}
+	for i, upper := range h.buckets {
+		if val <= upper {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += val
+}
+
+// Count returns the number of observations for the given label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if hv, ok := h.vals[key]; ok {
+		return hv.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	keys := make([]string, 0, len(h.vals))
+	for key := range h.vals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		hv := h.vals[key]
+		for i, upper := range h.buckets {
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.labels(key, "le", formatFloat(upper)), hv.counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", name, h.labels(key, "le", "+Inf"), hv.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", name, h.labels(key), formatFloat(hv.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", name, h.labels(key), hv.count)
+	}
+}
+
+func formatFloat(val float64) string {
+	switch {
+	case math.IsInf(val, 1):
+		return "+Inf"
+	case math.IsInf(val, -1):
+		return "-Inf"
+	case math.IsNaN(val):
+		return "NaN"
+	}
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(val string) string {
+	return labelEscaper.Replace(val)
+}
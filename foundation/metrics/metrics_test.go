@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	reg := NewRegistry()
+	logins := reg.NewCounter("logins_total", "Login attempts by result", "result")
+	pending := reg.NewGauge("pending", "Pending writes")
+	latency := reg.NewHistogram("latency_seconds", "Request latency", []float64{0.5, 0.1}, "route")
+	reg.NewGaugeFunc("pool_open", "Open connections", func() float64 { return 3 })
+
+	logins.Inc("success")
+	logins.Inc("success")
+	logins.Inc(`fail"ure`)
+	pending.Add(2)
+	pending.Add(-1)
+	latency.Observe(0.05, "/")
+	latency.Observe(0.3, "/")
+
+	var buf strings.Builder
+	reg.WriteText(&buf)
+	expected := `# HELP latency_seconds Request latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/",le="0.1"} 1
+latency_seconds_bucket{route="/",le="0.5"} 2
+latency_seconds_bucket{route="/",le="+Inf"} 2
+latency_seconds_sum{route="/"} 0.35
+latency_seconds_count{route="/"} 2
+# HELP logins_total Login attempts by result
+# TYPE logins_total counter
+logins_total{result="fail\"ure"} 1
+logins_total{result="success"} 2
+# HELP pending Pending writes
+# TYPE pending gauge
+pending 1
+# HELP pool_open Open connections
+# TYPE pool_open gauge
+pool_open 3
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestRegistry_DuplicateName(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("dupe", "")
+	assert.Panics(t, func() {
+		reg.NewGauge("dupe", "")
+	})
+}
+
+func TestCounter_LabelMismatch(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounter("labeled", "", "a", "b")
+	assert.Panics(t, func() {
+		c.Inc("only-one")
+	})
+}
+
+func TestHTTPMetrics_Middleware(t *testing.T) {
+	reg := NewRegistry()
+	m := reg.NewHTTPMetrics()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	})
+	handler := m.Middleware()(mux)
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/users/1", nil))
+
+	assert.Equal(t, float64(2), m.requests.Value(http.MethodGet, "/users/{id}", "200"))
+	assert.Equal(t, float64(1), m.requests.Value(http.MethodGet, UnmatchedRoute, "404"))
+	assert.Equal(t, float64(1), m.requests.Value("OTHER", UnmatchedRoute, "405"))
+	assert.Equal(t, uint64(2), m.latency.Count(http.MethodGet, "/users/{id}"))
+}
@@ -0,0 +1,281 @@
+// Package pgtest runs a throwaway Postgres server for integration tests, so the SQL in infra/pg/sql runs against a real database.
+//
+// A test package calls [Main] from TestMain to start the server and apply the schema once,
+// and each test calls [DB] to get its own database, copied from the schema.
+// Tests that use it are built with the integration tag, and run with 'go run ./modmake integration'.
+package pgtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// EnvBinDir names the directory with the initdb and postgres binaries, if they can't be found otherwise.
+const EnvBinDir = "PG_BIN"
+
+const (
+	templateDB   = "pgtest_template"
+	startTimeout = 30 * time.Second
+	stopTimeout  = 10 * time.Second
+	// objectInUse is the SQLSTATE for a template database that still has a connection, which may be a moment after it's closed.
+	objectInUse = "55006"
+)
+
+var current *server
+
+type server struct {
+	dir    string
+	cmd    *exec.Cmd
+	exited chan error
+	admin  *sql.DB
+	next   atomic.Int64
+}
+
+// Main starts a Postgres server with the *.sql scripts in schemaDir applied in name order, runs the tests, and stops the server.
+// It's meant to be called from TestMain, and returns the exit code to pass to [os.Exit].
+func Main(m *testing.M, schemaDir string) int {
+	srv, err := start(schemaDir)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Unable to start Postgres for integration tests:", err)
+		return 1
+	}
+	current = srv
+	code := m.Run()
+	current = nil
+	if err := srv.stop(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Unable to stop Postgres:", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// DB creates a database with the schema applied, which no other test can see, and drops it when the test is done.
+func DB(t testing.TB) *sql.DB {
+	t.Helper()
+	srv := current
+	if srv == nil {
+		t.Fatal("pgtest.DB requires pgtest.Main to be called from TestMain")
+	}
+	ctx := context.Background()
+	name := fmt.Sprintf("test_%d", srv.next.Add(1))
+	if err := srv.createDatabase(ctx, name, templateDB); err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	db, err := sql.Open("pgx", srv.url(name))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		if _, err := srv.admin.ExecContext(ctx, "drop database "+name+" with (force)"); err != nil {
+			t.Errorf("Failed to drop test database: %v", err)
+		}
+	})
+	return db
+}
+
+func start(schemaDir string) (_ *server, err error) {
+	if os.Geteuid() == 0 {
+		return nil, errors.New("postgres refuses to run as root, run the integration tests as another user")
+	}
+	scripts, err := filepath.Glob(filepath.Join(schemaDir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(scripts) == 0 {
+		return nil, fmt.Errorf("no SQL scripts found in '%s'", schemaDir)
+	}
+	slices.Sort(scripts)
+	bin, err := binDir()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "pgtest-")
+	if err != nil {
+		return nil, err
+	}
+	srv := &server{dir: dir}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, srv.stop())
+		}
+	}()
+
+	data := filepath.Join(dir, "data")
+	initdb := exec.Command(filepath.Join(bin, "initdb"), "-D", data, "-U", "postgres", "--auth=trust", "-E", "UTF8", "--no-sync")
+	if out, err := initdb.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("initdb failed: %w\n%s", err, out)
+	}
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = logFile.Close()
+	}()
+	// Only listen on a socket in the temp dir, so the server can't clash with another on the same machine.
+	// Durability doesn't matter for a database that's thrown away.
+	srv.cmd = exec.Command(filepath.Join(bin, "postgres"), "-D", data, "-k", dir,
+		"-c", "listen_addresses=",
+		"-c", "fsync=off",
+		"-c", "synchronous_commit=off",
+		"-c", "full_page_writes=off",
+	)
+	srv.cmd.Stdout = logFile
+	srv.cmd.Stderr = logFile
+	if err := srv.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start postgres: %w", err)
+	}
+	srv.exited = make(chan error, 1)
+	go func() {
+		srv.exited <- srv.cmd.Wait()
+	}()
+
+	srv.admin, err = sql.Open("pgx", srv.url("postgres"))
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.waitReady(); err != nil {
+		return nil, err
+	}
+	if err := srv.applySchema(scripts); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+// binDir finds the directory with initdb, which is often not on the PATH when installed from a distribution's packages.
+func binDir() (string, error) {
+	if dir := os.Getenv(EnvBinDir); len(dir) > 0 {
+		return dir, nil
+	}
+	if initdb, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(initdb), nil
+	}
+	if out, err := exec.Command("pg_config", "--bindir").Output(); err == nil {
+		dir := strings.TrimSpace(string(out))
+		if _, err := os.Stat(filepath.Join(dir, "initdb")); err == nil {
+			return dir, nil
+		}
+	}
+	// Debian and Ubuntu install each major version side by side, so prefer the newest.
+	matches, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+	if len(matches) > 0 {
+		version := func(initdb string) int {
+			n, _ := strconv.Atoi(filepath.Base(filepath.Dir(filepath.Dir(initdb))))
+			return n
+		}
+		return filepath.Dir(slices.MaxFunc(matches, func(a, b string) int { return version(a) - version(b) })), nil
+	}
+	return "", fmt.Errorf("unable to find initdb, install Postgres or set %s to the directory with its binaries", EnvBinDir)
+}
+
+func (srv *server) waitReady() error {
+	deadline := time.Now().Add(startTimeout)
+	for {
+		err := srv.admin.Ping()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("postgres wasn't ready after %s: %w\n%s", startTimeout, err, srv.log())
+		}
+		select {
+		case err := <-srv.exited:
+			srv.exited <- err
+			return fmt.Errorf("postgres exited: %v\n%s", err, srv.log())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// applySchema runs each script in the template database that [DB] copies.
+func (srv *server) applySchema(scripts []string) error {
+	ctx := context.Background()
+	if _, err := srv.admin.ExecContext(ctx, "create database "+templateDB); err != nil {
+		return fmt.Errorf("failed to create template database: %w", err)
+	}
+	db, err := sql.Open("pgx", srv.url(templateDB))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	for _, script := range scripts {
+		content, err := os.ReadFile(script)
+		if err != nil {
+			return err
+		}
+		// Without arguments, pgx uses the simple protocol, which allows several statements at once.
+		if _, err := db.ExecContext(ctx, string(content)); err != nil {
+			return fmt.Errorf("failed to apply '%s': %w", filepath.Base(script), err)
+		}
+	}
+	return nil
+}
+
+func (srv *server) createDatabase(ctx context.Context, name, template string) error {
+	deadline := time.Now().Add(startTimeout)
+	for {
+		_, err := srv.admin.ExecContext(ctx, "create database "+name+" template "+template)
+		var pgErr *pgconn.PgError
+		if err == nil || !errors.As(err, &pgErr) || pgErr.Code != objectInUse || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (srv *server) url(database string) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.User("postgres"),
+		Path:     "/" + database,
+		RawQuery: url.Values{"host": {srv.dir}, "sslmode": {"disable"}}.Encode(),
+	}
+	return u.String()
+}
+
+func (srv *server) log() string {
+	content, _ := os.ReadFile(filepath.Join(srv.dir, "postgres.log"))
+	return string(content)
+}
+
+// stop shuts the server down and removes its files.
+func (srv *server) stop() error {
+	var errs []error
+	if srv.admin != nil {
+		errs = append(errs, srv.admin.Close())
+	}
+	if srv.exited != nil {
+		// An interrupt is a fast shutdown, which doesn't wait for clients to disconnect.
+		if err := srv.cmd.Process.Signal(os.Interrupt); err != nil {
+			_ = srv.cmd.Process.Kill()
+		}
+		select {
+		case <-srv.exited:
+		case <-time.After(stopTimeout):
+			_ = srv.cmd.Process.Kill()
+			<-srv.exited
+		}
+	}
+	errs = append(errs, os.RemoveAll(srv.dir))
+	return errors.Join(errs...)
+}
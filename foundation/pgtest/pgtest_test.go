@@ -0,0 +1,29 @@
+//go:build integration
+
+package pgtest
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(Main(m, filepath.Join("..", "..", "infra", "pg", "sql")))
+}
+
+func TestDB(t *testing.T) {
+	ctx := context.Background()
+	first, second := DB(t), DB(t)
+	_, err := first.ExecContext(ctx, "insert into users (username, pass_hash) values ('alice', 'x')")
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, first.QueryRowContext(ctx, "select count(*) from users").Scan(&count))
+	assert.Equal(t, 1, count)
+	require.NoError(t, second.QueryRowContext(ctx, "select count(*) from users").Scan(&count))
+	assert.Equal(t, 0, count, "Each test database should start from the schema")
+}
@@ -0,0 +1,193 @@
+// Package release packages built binaries for distribution, so that building a release again from the same commit writes the same bytes.
+// Timestamps come from a given time instead of the clock or the file system, and entries are always written in the same order.
+package release
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// File is a file to add to an archive.
+type File struct {
+	// Source is where the file is read from.
+	Source string
+	// Name is the file's path in the archive, with forward slashes.
+	Name string
+	// Mode is the file's permissions in the archive, which default to 0644.
+	// The mode on disk isn't used, since it depends on the umask of whoever built it.
+	Mode fs.FileMode
+	// content is used instead of reading Source, for files that are generated in memory.
+	content []byte
+}
+
+// TarGz writes a gzipped tar archive of files to dest, with each entry's modification time set to modTime.
+func TarGz(dest string, modTime time.Time, files ...File) error {
+	return writeFile(dest, func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		if err := writeTar(gz, modTime, false, files); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+}
+
+// Zip writes a zip archive of files to dest, with each entry's modification time set to modTime.
+func Zip(dest string, modTime time.Time, files ...File) error {
+	return writeFile(dest, func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		for _, file := range sortedFiles(files) {
+			header := &zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: modTime.UTC()}
+			header.SetMode(file.mode())
+			fw, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			if err := file.copyTo(fw); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	})
+}
+
+// WriteChecksums writes the SHA-256 sum of each file to dest in the format of sha256sum, so downloads can be checked with 'sha256sum -c'.
+// Files are listed by their base name, since they're expected to be downloaded to the same directory as dest.
+func WriteChecksums(dest string, files ...string) error {
+	var buf strings.Builder
+	for _, file := range slices.Sorted(slices.Values(files)) {
+		sum, err := fileSum(file)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(&buf, "%s  %s\n", sum, filepath.Base(file))
+	}
+	return os.WriteFile(dest, []byte(buf.String()), 0644)
+}
+
+func (f File) mode() fs.FileMode {
+	if f.Mode == 0 {
+		return 0644
+	}
+	return f.Mode
+}
+
+func (f File) size() (int64, error) {
+	if f.content != nil {
+		return int64(len(f.content)), nil
+	}
+	info, err := os.Stat(f.Source)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (f File) copyTo(w io.Writer) error {
+	if f.content != nil {
+		_, err := w.Write(f.content)
+		return err
+	}
+	return copyFile(w, f.Source)
+}
+
+// writeTar writes files to w as a tar archive owned by root.
+// If withDirs is set, entries are added for the directories that files are in, as container image layers need.
+func writeTar(w io.Writer, modTime time.Time, withDirs bool, files []File) error {
+	tw := tar.NewWriter(w)
+	// Sub-second times would need PAX headers, which aren't worth the bytes.
+	modTime = modTime.UTC().Truncate(time.Second)
+	written := map[string]bool{}
+	for _, file := range sortedFiles(files) {
+		if withDirs {
+			for _, dir := range parentDirs(file.Name) {
+				if written[dir] {
+					continue
+				}
+				written[dir] = true
+				if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755, ModTime: modTime}); err != nil {
+					return err
+				}
+			}
+		}
+		size, err := file.size()
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Name,
+			Mode:     int64(file.mode()),
+			Size:     size,
+			ModTime:  modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err := file.copyTo(tw); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// parentDirs returns the directories above name, from the top down.
+func parentDirs(name string) []string {
+	var dirs []string
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	slices.Reverse(dirs)
+	return dirs
+}
+
+func sortedFiles(files []File) []File {
+	return slices.SortedFunc(slices.Values(files), func(a, b File) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+func copyFile(w io.Writer, source string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func fileSum(file string) (string, error) {
+	h := sha256.New()
+	if err := copyFile(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeFile writes dest with write, and removes it if write fails so a partial file isn't mistaken for a release.
+func writeFile(dest string, write func(w io.Writer) error) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(dest)
+		return fmt.Errorf("failed to write '%s': %w", dest, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,112 @@
+package release
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var releaseTime = time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+
+func TestTarGz(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir)
+	first := filepath.Join(dir, "first.tar.gz")
+	require.NoError(t, TarGz(first, releaseTime, files...))
+
+	// Only the content and the given time matter, not when or in what order files were written.
+	touch(t, files[0].Source, time.Now())
+	second := filepath.Join(dir, "second.tar.gz")
+	require.NoError(t, TarGz(second, releaseTime, files[1], files[0]))
+	assert.Equal(t, readFile(t, first), readFile(t, second))
+
+	f, err := os.Open(first)
+	require.NoError(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+		assert.True(t, releaseTime.Equal(header.ModTime))
+		assert.Equal(t, 0, header.Uid)
+		if header.Name == "yourapp" {
+			assert.Equal(t, int64(0755), header.Mode)
+		} else {
+			assert.Equal(t, int64(0644), header.Mode)
+		}
+	}
+	assert.Equal(t, []string{"LICENSE", "yourapp"}, names)
+}
+
+func TestZip(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir)
+	first := filepath.Join(dir, "first.zip")
+	require.NoError(t, Zip(first, releaseTime, files...))
+	touch(t, files[0].Source, time.Now())
+	second := filepath.Join(dir, "second.zip")
+	require.NoError(t, Zip(second, releaseTime, files...))
+	assert.Equal(t, readFile(t, first), readFile(t, second))
+
+	zr, err := zip.OpenReader(first)
+	require.NoError(t, err)
+	defer func() {
+		_ = zr.Close()
+	}()
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "LICENSE", zr.File[0].Name)
+	assert.Equal(t, "yourapp", zr.File[1].Name)
+	assert.True(t, releaseTime.Equal(zr.File[1].Modified))
+}
+
+func TestWriteChecksums(t *testing.T) {
+	dir := t.TempDir()
+	files := writeFiles(t, dir)
+	dest := filepath.Join(dir, "checksums.txt")
+	require.NoError(t, WriteChecksums(dest, files[1].Source, files[0].Source))
+	// Listed in the same order and format as 'sha256sum LICENSE yourapp'.
+	expected := "cc1d3b0234846714b0aeda6cc34b057b4305bb83dd447fb88f816efeb59a4e96  LICENSE\n" +
+		"9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd  yourapp\n"
+	assert.Equal(t, expected, string(readFile(t, dest)))
+}
+
+// writeFiles writes a binary and a license to dir, with the mode and time they'd have after a build.
+func writeFiles(t *testing.T, dir string) []File {
+	t.Helper()
+	binary := filepath.Join(dir, "yourapp")
+	require.NoError(t, os.WriteFile(binary, []byte("binary"), 0700))
+	license := filepath.Join(dir, "LICENSE")
+	require.NoError(t, os.WriteFile(license, []byte("license"), 0600))
+	return []File{
+		{Source: binary, Name: "yourapp", Mode: 0755},
+		{Source: license, Name: "LICENSE"},
+	}
+}
+
+func touch(t *testing.T, file string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+}
+
+func readFile(t *testing.T, file string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	return content
+}
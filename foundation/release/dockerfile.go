@@ -0,0 +1,348 @@
+package release
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stage is the last stage of a Dockerfile, which describes the image that's shipped.
+// Earlier stages only build what the last one copies, so they're skipped.
+type Stage struct {
+	// From is the reference to the base image.
+	From string
+	// Env has a KEY=value entry for each variable that's set, in order.
+	Env          []string
+	Labels       map[string]string
+	User         string
+	WorkingDir   string
+	ExposedPorts []string
+	Copies       []Copy
+	// Entrypoint and Cmd are nil if they aren't set, so the base image's are kept.
+	Entrypoint  []string
+	Cmd         []string
+	Healthcheck *Healthcheck
+}
+
+// Copy is a COPY instruction that copies a file from an earlier stage.
+type Copy struct {
+	Source string
+	Dest   string
+	// Instruction is the instruction as written, which is recorded in the image's history.
+	Instruction string
+}
+
+// Healthcheck is the health check run by the container runtime, in the form that Docker keeps in an image's config.
+type Healthcheck struct {
+	Test        []string      `json:"Test"`
+	Interval    time.Duration `json:"Interval,omitempty"`
+	Timeout     time.Duration `json:"Timeout,omitempty"`
+	StartPeriod time.Duration `json:"StartPeriod,omitempty"`
+	Retries     int           `json:"Retries,omitempty"`
+}
+
+// ParseDockerfile parses the last stage of a Dockerfile.
+// Only instructions that set the image's config or copy files from an earlier stage are allowed in it, since the image is assembled without running anything.
+// Variables aren't expanded.
+func ParseDockerfile(r io.Reader) (*Stage, error) {
+	lines, err := readInstructions(r)
+	if err != nil {
+		return nil, err
+	}
+	last := -1
+	for i, line := range lines {
+		if keyword, _ := splitInstruction(line); keyword == "FROM" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil, errors.New("no FROM instruction found")
+	}
+	_, from := splitInstruction(lines[last])
+	stage := &Stage{From: fromImage(from)}
+	if len(stage.From) == 0 {
+		return nil, errors.New("FROM requires an image")
+	}
+	for _, line := range lines[last+1:] {
+		if err := stage.apply(line); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, line)
+		}
+	}
+	return stage, nil
+}
+
+func (s *Stage) apply(line string) error {
+	keyword, args := splitInstruction(line)
+	switch keyword {
+	case "ENV":
+		pairs, err := keyValues(args)
+		if err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			s.Env = append(s.Env, pair[0]+"="+pair[1])
+		}
+	case "LABEL":
+		pairs, err := keyValues(args)
+		if err != nil {
+			return err
+		}
+		if s.Labels == nil {
+			s.Labels = map[string]string{}
+		}
+		for _, pair := range pairs {
+			s.Labels[pair[0]] = pair[1]
+		}
+	case "USER":
+		s.User = args
+	case "WORKDIR":
+		s.WorkingDir = path.Join(s.WorkingDir, args)
+		if !path.IsAbs(s.WorkingDir) {
+			s.WorkingDir = "/" + s.WorkingDir
+		}
+	case "EXPOSE":
+		for _, port := range strings.Fields(args) {
+			if !strings.Contains(port, "/") {
+				port += "/tcp"
+			}
+			s.ExposedPorts = append(s.ExposedPorts, port)
+		}
+	case "COPY":
+		return s.applyCopy(line, args)
+	case "ENTRYPOINT":
+		entrypoint, err := command(args)
+		if err != nil {
+			return err
+		}
+		s.Entrypoint = entrypoint
+	case "CMD":
+		cmd, err := command(args)
+		if err != nil {
+			return err
+		}
+		s.Cmd = cmd
+	case "HEALTHCHECK":
+		check, err := healthcheck(args)
+		if err != nil {
+			return err
+		}
+		s.Healthcheck = check
+	case "ARG":
+		// Variables aren't expanded, so there's nothing to keep.
+	default:
+		return fmt.Errorf("%s can't be used in the last stage, since the image is assembled without running anything", keyword)
+	}
+	return nil
+}
+
+func (s *Stage) applyCopy(line, args string) error {
+	words, err := splitWords(args)
+	if err != nil {
+		return err
+	}
+	var (
+		paths    []string
+		fromPrev bool
+	)
+	for _, word := range words {
+		if strings.HasPrefix(word, "--from=") {
+			fromPrev = true
+		} else if strings.HasPrefix(word, "--") {
+			return fmt.Errorf("COPY option %s isn't supported", word)
+		} else {
+			paths = append(paths, word)
+		}
+	}
+	if !fromPrev {
+		return errors.New("COPY must copy from an earlier stage with --from")
+	}
+	if len(paths) != 2 {
+		return errors.New("COPY must have exactly one source and a destination")
+	}
+	dest := paths[1]
+	if strings.HasSuffix(dest, "/") {
+		dest += path.Base(paths[0])
+	}
+	if !path.IsAbs(dest) {
+		dest = path.Join("/", s.WorkingDir, dest)
+	}
+	s.Copies = append(s.Copies, Copy{Source: paths[0], Dest: dest, Instruction: line})
+	return nil
+}
+
+// readInstructions returns each instruction on its own line, with comments removed and continued lines joined.
+func readInstructions(r io.Reader) ([]string, error) {
+	var (
+		lines   []string
+		current strings.Builder
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if continued, ok := strings.CutSuffix(line, `\`); ok {
+			current.WriteString(continued)
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+		lines = append(lines, current.String())
+		current.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current.Len() > 0 {
+		lines = append(lines, strings.TrimSpace(current.String()))
+	}
+	return lines, nil
+}
+
+func splitInstruction(line string) (keyword, args string) {
+	keyword, args, _ = strings.Cut(line, " ")
+	return strings.ToUpper(keyword), strings.TrimSpace(args)
+}
+
+// fromImage returns the image from a FROM instruction's arguments, which may have a --platform option and a stage name.
+func fromImage(args string) string {
+	for _, field := range strings.Fields(args) {
+		if !strings.HasPrefix(field, "--") {
+			return field
+		}
+	}
+	return ""
+}
+
+// keyValues parses the arguments to ENV or LABEL, which are either KEY=value pairs or a single key and value separated by a space.
+func keyValues(args string) ([][2]string, error) {
+	words, err := splitWords(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, errors.New("missing key and value")
+	}
+	if !strings.Contains(words[0], "=") {
+		key, value, _ := strings.Cut(args, " ")
+		return [][2]string{{key, strings.TrimSpace(value)}}, nil
+	}
+	var pairs [][2]string
+	for _, word := range words {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf("expected KEY=value, got '%s'", word)
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, nil
+}
+
+// splitWords splits s on spaces outside of quotes, and removes the quotes and escapes.
+func splitWords(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, c := range s {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// command parses the exec form of ENTRYPOINT or CMD, or wraps the shell form in a call to the shell as Docker does.
+func command(args string) ([]string, error) {
+	if !strings.HasPrefix(args, "[") {
+		return []string{"/bin/sh", "-c", args}, nil
+	}
+	var cmd []string
+	if err := json.Unmarshal([]byte(args), &cmd); err != nil {
+		return nil, fmt.Errorf("invalid exec form: %w", err)
+	}
+	return cmd, nil
+}
+
+func healthcheck(args string) (*Healthcheck, error) {
+	check := &Healthcheck{}
+	for {
+		option, rest, _ := strings.Cut(args, " ")
+		name, value, ok := strings.Cut(option, "=")
+		if !ok || !strings.HasPrefix(name, "--") {
+			break
+		}
+		args = strings.TrimSpace(rest)
+		var err error
+		switch name {
+		case "--interval":
+			check.Interval, err = time.ParseDuration(value)
+		case "--timeout":
+			check.Timeout, err = time.ParseDuration(value)
+		case "--start-period":
+			check.StartPeriod, err = time.ParseDuration(value)
+		case "--retries":
+			check.Retries, err = strconv.Atoi(value)
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid HEALTHCHECK option %s: %w", option, err)
+		}
+	}
+	keyword, cmd := splitInstruction(args)
+	switch keyword {
+	case "NONE":
+		check.Test = []string{"NONE"}
+	case "CMD":
+		if !strings.HasPrefix(cmd, "[") {
+			check.Test = []string{"CMD-SHELL", cmd}
+			break
+		}
+		test, err := command(cmd)
+		if err != nil {
+			return nil, err
+		}
+		check.Test = append([]string{"CMD"}, test...)
+	default:
+		return nil, errors.New("HEALTHCHECK must be followed by CMD or NONE")
+	}
+	return check, nil
+}
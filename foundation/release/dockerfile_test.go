@@ -0,0 +1,102 @@
+package release
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDockerfile_app(t *testing.T) {
+	f, err := os.Open("../../infra/app/Dockerfile")
+	require.NoError(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+	stage, err := ParseDockerfile(f)
+	require.NoError(t, err)
+	assert.Equal(t, "gcr.io/distroless/static-debian12:latest", stage.From)
+	assert.Equal(t, []string{"DBURL=", "SESSION_HASHKEY=", "SESSION_BLOCKKEY="}, stage.Env)
+	require.Len(t, stage.Copies, 1)
+	assert.Equal(t, "/yourapp", stage.Copies[0].Dest)
+	assert.Equal(t, []string{"/yourapp"}, stage.Entrypoint)
+	assert.Nil(t, stage.Cmd)
+	assert.Equal(t, &Healthcheck{
+		Test:        []string{"CMD", "/yourapp", "healthcheck"},
+		Interval:    10 * time.Second,
+		Timeout:     5 * time.Second,
+		StartPeriod: 10 * time.Second,
+		Retries:     3,
+	}, stage.Healthcheck)
+}
+
+func TestParseDockerfile(t *testing.T) {
+	tests := map[string]struct {
+		dockerfile string
+		expected   *Stage
+		expectErr  string
+	}{
+		"Earlier stages are skipped": {
+			dockerfile: "FROM golang AS builder\nRUN go build\nFROM --platform=$BUILDPLATFORM scratch\nCMD [\"/app\"]",
+			expected:   &Stage{From: "scratch", Cmd: []string{"/app"}},
+		},
+		"Key value forms": {
+			dockerfile: "FROM scratch\nENV A=1 B=\"two words\" C='$literal'\nENV D three words\nLABEL org.example.title=\"App\"",
+			expected: &Stage{
+				From:   "scratch",
+				Env:    []string{"A=1", "B=two words", "C=$literal", "D=three words"},
+				Labels: map[string]string{"org.example.title": "App"},
+			},
+		},
+		"Continued lines": {
+			dockerfile: "FROM scratch\nENTRYPOINT [\"/app\", \\\n  \"serve\"]\nEXPOSE 8080 9090/udp",
+			expected:   &Stage{From: "scratch", Entrypoint: []string{"/app", "serve"}, ExposedPorts: []string{"8080/tcp", "9090/udp"}},
+		},
+		"Copy into workdir": {
+			dockerfile: "FROM scratch\nWORKDIR /opt\nCOPY --from=builder /src/app bin/\nUSER nonroot",
+			expected: &Stage{
+				From:       "scratch",
+				WorkingDir: "/opt",
+				User:       "nonroot",
+				Copies:     []Copy{{Source: "/src/app", Dest: "/opt/bin/app", Instruction: "COPY --from=builder /src/app bin/"}},
+			},
+		},
+		"Shell form": {
+			dockerfile: "FROM alpine\nCMD exec /app\nHEALTHCHECK CMD wget -q localhost",
+			expected: &Stage{
+				From:        "alpine",
+				Cmd:         []string{"/bin/sh", "-c", "exec /app"},
+				Healthcheck: &Healthcheck{Test: []string{"CMD-SHELL", "wget -q localhost"}},
+			},
+		},
+		"No FROM": {
+			dockerfile: "# syntax=docker/dockerfile:1\nENV A=1",
+			expectErr:  "no FROM instruction found",
+		},
+		"RUN in last stage": {
+			dockerfile: "FROM alpine\nRUN apk add curl",
+			expectErr:  "RUN can't be used in the last stage",
+		},
+		"COPY from context": {
+			dockerfile: "FROM scratch\nCOPY app /app",
+			expectErr:  "COPY must copy from an earlier stage",
+		},
+		"Invalid healthcheck": {
+			dockerfile: "FROM scratch\nHEALTHCHECK --interval=soon CMD [\"/app\"]",
+			expectErr:  "invalid HEALTHCHECK option --interval=soon",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			stage, err := ParseDockerfile(strings.NewReader(tc.dockerfile))
+			if len(tc.expectErr) > 0 {
+				assert.ErrorContains(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, stage)
+		})
+	}
+}
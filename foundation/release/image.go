@@ -0,0 +1,410 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	mediaTypeIndex          = "application/vnd.oci.image.index.v1+json"
+	mediaTypeManifest       = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeConfig         = "application/vnd.oci.image.config.v1+json"
+	mediaTypeLayer          = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// Image is a container image for one or more platforms, assembled by adding the files copied by a Dockerfile [Stage] to its base image.
+// Nothing is run or downloaded to build it, so the same inputs always make the same image.
+type Image struct {
+	Stage *Stage
+	// Base is an OCI image layout archive with the stage's base image for each platform, like 'skopeo copy --all' writes to an oci-archive.
+	Base string
+	// Binaries maps platforms like "linux/amd64" to the file that the stage's COPY instructions copy, which is matched by file name.
+	Binaries map[string]string
+	// Name is the name and tag that the image is loaded as, like "yourapp:1.0.0".
+	Name    string
+	Created time.Time
+}
+
+// Write writes the image to dest as an OCI image layout archive, with a manifest for each platform in Binaries.
+func (img *Image) Write(dest string) error {
+	base, err := readLayout(img.Base)
+	if err != nil {
+		return fmt.Errorf("failed to read base image: %w", err)
+	}
+	images, err := base.images()
+	if err != nil {
+		return fmt.Errorf("failed to read base image: %w", err)
+	}
+	out := &layout{blobs: map[string][]byte{}}
+	var manifests []descriptor
+	for _, platform := range slices.Sorted(maps.Keys(img.Binaries)) {
+		i := slices.IndexFunc(images, func(image baseImage) bool {
+			return image.platform.matches(platform)
+		})
+		if i < 0 {
+			return fmt.Errorf("base image '%s' has no image for %s", img.Stage.From, platform)
+		}
+		desc, err := img.build(out, base, images[i], img.Binaries[platform])
+		if err != nil {
+			return fmt.Errorf("failed to build image for %s: %w", platform, err)
+		}
+		manifests = append(manifests, desc)
+	}
+	list, err := out.addJSON(mediaTypeIndex, index{SchemaVersion: 2, MediaType: mediaTypeIndex, Manifests: manifests})
+	if err != nil {
+		return err
+	}
+	list.Annotations = map[string]string{
+		"io.containerd.image.name":          img.Name,
+		"org.opencontainers.image.ref.name": imageTag(img.Name),
+	}
+	top, err := json.Marshal(index{SchemaVersion: 2, MediaType: mediaTypeIndex, Manifests: []descriptor{list}})
+	if err != nil {
+		return err
+	}
+	files := []File{
+		{Name: "oci-layout", content: []byte(`{"imageLayoutVersion":"1.0.0"}`)},
+		{Name: "index.json", content: top},
+	}
+	for digest, blob := range out.blobs {
+		files = append(files, File{Name: blobPath(digest), content: blob})
+	}
+	return writeFile(dest, func(w io.Writer) error {
+		return writeTar(w, img.Created, true, files)
+	})
+}
+
+// build adds a layer for each COPY instruction to the base image, and returns the descriptor of the new manifest.
+func (img *Image) build(out, base *layout, image baseImage, binary string) (descriptor, error) {
+	created := img.Created.UTC().Format(time.RFC3339)
+	config := image.config
+	config.Created = created
+	img.Stage.applyTo(&config.Config)
+	layers := slices.Clone(image.manifest.Layers)
+	for _, layer := range layers {
+		blob, err := base.blob(layer.Digest)
+		if err != nil {
+			return descriptor{}, err
+		}
+		out.blobs[layer.Digest] = blob
+	}
+	for _, c := range img.Stage.Copies {
+		if path.Base(c.Source) != filepath.Base(binary) {
+			return descriptor{}, fmt.Errorf("'%s' isn't the release binary, so it can't be copied", c.Source)
+		}
+		var layer bytes.Buffer
+		file := File{Source: binary, Name: strings.TrimPrefix(c.Dest, "/"), Mode: 0755}
+		if err := writeTar(&layer, img.Created, true, []File{file}); err != nil {
+			return descriptor{}, err
+		}
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(layer.Bytes()); err != nil {
+			return descriptor{}, err
+		}
+		if err := gz.Close(); err != nil {
+			return descriptor{}, err
+		}
+		layers = append(layers, out.add(mediaTypeLayer, compressed.Bytes()))
+		config.RootFS.DiffIDs = append(slices.Clip(config.RootFS.DiffIDs), digest(layer.Bytes()))
+		config.History = append(slices.Clip(config.History), history{Created: created, CreatedBy: c.Instruction})
+	}
+	configDesc, err := out.addJSON(mediaTypeConfig, config)
+	if err != nil {
+		return descriptor{}, err
+	}
+	desc, err := out.addJSON(mediaTypeManifest, manifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeManifest,
+		Config:        configDesc,
+		Layers:        layers,
+		Annotations: map[string]string{
+			"org.opencontainers.image.base.name":   img.Stage.From,
+			"org.opencontainers.image.base.digest": image.digest,
+			"org.opencontainers.image.created":     created,
+		},
+	})
+	if err != nil {
+		return descriptor{}, err
+	}
+	desc.Platform = &image.platform
+	return desc, nil
+}
+
+// applyTo sets what the stage configures, the way Docker would when building it.
+func (s *Stage) applyTo(c *containerConfig) {
+	for _, env := range s.Env {
+		key, _, _ := strings.Cut(env, "=")
+		c.Env = slices.DeleteFunc(slices.Clone(c.Env), func(existing string) bool {
+			return strings.HasPrefix(existing, key+"=")
+		})
+		c.Env = append(c.Env, env)
+	}
+	if len(s.Labels) > 0 {
+		c.Labels = maps.Clone(c.Labels)
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		maps.Copy(c.Labels, s.Labels)
+	}
+	if len(s.User) > 0 {
+		c.User = s.User
+	}
+	if len(s.WorkingDir) > 0 {
+		c.WorkingDir = s.WorkingDir
+	}
+	if len(s.ExposedPorts) > 0 {
+		c.ExposedPorts = maps.Clone(c.ExposedPorts)
+		if c.ExposedPorts == nil {
+			c.ExposedPorts = map[string]struct{}{}
+		}
+		for _, port := range s.ExposedPorts {
+			c.ExposedPorts[port] = struct{}{}
+		}
+	}
+	if s.Entrypoint != nil {
+		c.Entrypoint = s.Entrypoint
+		// Docker doesn't keep the base image's CMD when the ENTRYPOINT changes.
+		c.Cmd = nil
+	}
+	if s.Cmd != nil {
+		c.Cmd = s.Cmd
+	}
+	if s.Healthcheck != nil {
+		c.Healthcheck = s.Healthcheck
+	}
+}
+
+func imageTag(name string) string {
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name[i+1:]
+	}
+	return "latest"
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// matches reports whether p is the platform named like "linux/arm64", or "linux/arm/v7" if the variant matters.
+func (p platform) matches(name string) bool {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 2 || parts[0] != p.OS || parts[1] != p.Architecture {
+		return false
+	}
+	return len(parts) < 3 || parts[2] == p.Variant
+}
+
+type index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []descriptor `json:"manifests"`
+}
+
+type manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        descriptor        `json:"config"`
+	Layers        []descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+type imageConfig struct {
+	Created      string          `json:"created,omitempty"`
+	Architecture string          `json:"architecture"`
+	OS           string          `json:"os"`
+	Variant      string          `json:"variant,omitempty"`
+	Config       containerConfig `json:"config"`
+	RootFS       rootFS          `json:"rootfs"`
+	History      []history       `json:"history,omitempty"`
+}
+
+type containerConfig struct {
+	User         string              `json:"User,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	StopSignal   string              `json:"StopSignal,omitempty"`
+	Healthcheck  *Healthcheck        `json:"Healthcheck,omitempty"`
+}
+
+type rootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+type history struct {
+	Created    string `json:"created,omitempty"`
+	CreatedBy  string `json:"created_by,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	EmptyLayer bool   `json:"empty_layer,omitempty"`
+}
+
+// layout is the content of an OCI image layout, which is small enough to keep in memory for the images that are shipped here.
+type layout struct {
+	index []byte
+	blobs map[string][]byte
+}
+
+// baseImage is an image for a single platform in a base image's layout.
+type baseImage struct {
+	platform platform
+	digest   string
+	manifest manifest
+	config   imageConfig
+}
+
+func readLayout(file string) (*layout, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	l := &layout{blobs: map[string][]byte{}}
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if header.Typeflag != tar.TypeReg || (name != "index.json" && !strings.HasPrefix(name, "blobs/")) {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if name == "index.json" {
+			l.index = content
+			continue
+		}
+		algorithm, hash, _ := strings.Cut(strings.TrimPrefix(name, "blobs/"), "/")
+		l.blobs[algorithm+":"+hash] = content
+	}
+	if l.index == nil {
+		return nil, fmt.Errorf("'%s' isn't an OCI image layout archive, it has no index.json", file)
+	}
+	return l, nil
+}
+
+// images finds the image for each platform, looking through nested indexes.
+func (l *layout) images() ([]baseImage, error) {
+	var idx index
+	if err := json.Unmarshal(l.index, &idx); err != nil {
+		return nil, fmt.Errorf("invalid index.json: %w", err)
+	}
+	return l.resolve(idx.Manifests)
+}
+
+func (l *layout) resolve(descs []descriptor) ([]baseImage, error) {
+	var images []baseImage
+	for _, desc := range descs {
+		switch desc.MediaType {
+		case mediaTypeIndex, mediaTypeDockerList:
+			var idx index
+			if err := l.readJSON(desc.Digest, &idx); err != nil {
+				return nil, err
+			}
+			nested, err := l.resolve(idx.Manifests)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, nested...)
+		case mediaTypeManifest, mediaTypeDockerManifest:
+			if desc.Platform != nil && desc.Platform.OS == "unknown" {
+				// Build attestations are stored as images for an unknown platform, and often aren't copied with the image.
+				continue
+			}
+			image := baseImage{digest: desc.Digest}
+			if err := l.readJSON(desc.Digest, &image.manifest); err != nil {
+				return nil, err
+			}
+			if err := l.readJSON(image.manifest.Config.Digest, &image.config); err != nil {
+				return nil, err
+			}
+			image.platform = platform{OS: image.config.OS, Architecture: image.config.Architecture, Variant: image.config.Variant}
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+func (l *layout) readJSON(digest string, v any) error {
+	blob, err := l.blob(digest)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(blob, v)
+}
+
+// blob returns the blob with the digest, after checking that its content matches.
+func (l *layout) blob(d string) ([]byte, error) {
+	blob, ok := l.blobs[d]
+	if !ok {
+		return nil, fmt.Errorf("blob %s is missing", d)
+	}
+	if actual := digest(blob); actual != d {
+		return nil, fmt.Errorf("blob %s has the digest %s", d, actual)
+	}
+	return blob, nil
+}
+
+func (l *layout) add(mediaType string, blob []byte) descriptor {
+	d := digest(blob)
+	l.blobs[d] = blob
+	return descriptor{MediaType: mediaType, Digest: d, Size: int64(len(blob))}
+}
+
+func (l *layout) addJSON(mediaType string, v any) (descriptor, error) {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		return descriptor{}, err
+	}
+	return l.add(mediaType, blob), nil
+}
+
+func digest(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func blobPath(digest string) string {
+	algorithm, hash, _ := strings.Cut(digest, ":")
+	return "blobs/" + algorithm + "/" + hash
+}
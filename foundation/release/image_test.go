@@ -0,0 +1,141 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const imageDockerfile = `FROM golang:1.23 AS builder
+RUN go build -o /app/build/yourapp ./cmd/yourapp
+
+FROM example.com/base:latest
+ENV DBURL=""
+COPY --from=builder /app/build/yourapp /yourapp
+HEALTHCHECK --interval=10s CMD ["/yourapp", "healthcheck"]
+ENTRYPOINT ["/yourapp"]
+`
+
+func TestImage_Write(t *testing.T) {
+	dir := t.TempDir()
+	base := writeBaseImage(t, dir)
+	stage, err := ParseDockerfile(strings.NewReader(imageDockerfile))
+	require.NoError(t, err)
+	binaries := map[string]string{}
+	for _, platform := range []string{"linux/amd64", "linux/arm64"} {
+		binary := filepath.Join(dir, strings.ReplaceAll(platform, "/", "_"), "yourapp")
+		require.NoError(t, os.MkdirAll(filepath.Dir(binary), 0755))
+		require.NoError(t, os.WriteFile(binary, []byte("binary for "+platform), 0700))
+		binaries[platform] = binary
+	}
+	img := &Image{Stage: stage, Base: base, Binaries: binaries, Name: "yourapp:1.2.0", Created: releaseTime}
+
+	first := filepath.Join(dir, "first.tar")
+	require.NoError(t, img.Write(first))
+	second := filepath.Join(dir, "second.tar")
+	require.NoError(t, img.Write(second))
+	assert.Equal(t, readFile(t, first), readFile(t, second))
+
+	out, err := readLayout(first)
+	require.NoError(t, err)
+	var top index
+	require.NoError(t, json.Unmarshal(out.index, &top))
+	require.Len(t, top.Manifests, 1)
+	assert.Equal(t, "yourapp:1.2.0", top.Manifests[0].Annotations["io.containerd.image.name"])
+	assert.Equal(t, "1.2.0", top.Manifests[0].Annotations["org.opencontainers.image.ref.name"])
+
+	images, err := out.images()
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+	for i, platform := range []string{"linux/amd64", "linux/arm64"} {
+		image := images[i]
+		assert.True(t, image.platform.matches(platform))
+		assert.Equal(t, "example.com/base:latest", image.manifest.Annotations["org.opencontainers.image.base.name"])
+		assert.Equal(t, "2025-02-01T12:00:00Z", image.config.Created)
+
+		config := image.config.Config
+		assert.Equal(t, []string{"PATH=/bin", "DBURL="}, config.Env)
+		assert.Equal(t, []string{"/yourapp"}, config.Entrypoint)
+		assert.Nil(t, config.Cmd, "The base image's CMD isn't kept when the ENTRYPOINT changes")
+		assert.Equal(t, []string{"CMD", "/yourapp", "healthcheck"}, config.Healthcheck.Test)
+
+		require.Len(t, image.manifest.Layers, 2)
+		require.Len(t, image.config.RootFS.DiffIDs, 2)
+		assert.Equal(t, "COPY --from=builder /app/build/yourapp /yourapp", image.config.History[1].CreatedBy)
+		layer, err := out.blob(image.manifest.Layers[1].Digest)
+		require.NoError(t, err)
+		header, content := readLayer(t, layer)
+		assert.Equal(t, "yourapp", header.Name)
+		assert.Equal(t, int64(0755), header.Mode)
+		assert.Equal(t, "binary for "+platform, content)
+	}
+
+	img.Binaries = map[string]string{"linux/s390x": binaries["linux/amd64"]}
+	assert.ErrorContains(t, img.Write(filepath.Join(dir, "missing.tar")), "has no image for linux/s390x")
+}
+
+// writeBaseImage writes an OCI layout archive with an image for linux/amd64 and linux/arm64/v8, like 'skopeo copy --all' would.
+func writeBaseImage(t *testing.T, dir string) string {
+	t.Helper()
+	base := &layout{blobs: map[string][]byte{}}
+	var manifests []descriptor
+	for _, p := range []platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64", Variant: "v8"}} {
+		var layer bytes.Buffer
+		gz := gzip.NewWriter(&layer)
+		require.NoError(t, writeTar(gz, releaseTime, true, []File{{Name: "etc/os-release", content: []byte(p.Architecture)}}))
+		require.NoError(t, gz.Close())
+		layerDesc := base.add(mediaTypeLayer, layer.Bytes())
+		configDesc, err := base.addJSON(mediaTypeConfig, imageConfig{
+			Architecture: p.Architecture,
+			OS:           p.OS,
+			Variant:      p.Variant,
+			Config:       containerConfig{Env: []string{"PATH=/bin"}, Cmd: []string{"/bin/sh"}},
+			RootFS:       rootFS{Type: "layers", DiffIDs: []string{"sha256:base"}},
+			History:      []history{{CreatedBy: "base"}},
+		})
+		require.NoError(t, err)
+		desc, err := base.addJSON(mediaTypeManifest, manifest{SchemaVersion: 2, MediaType: mediaTypeManifest, Config: configDesc, Layers: []descriptor{layerDesc}})
+		require.NoError(t, err)
+		desc.Platform = &p
+		manifests = append(manifests, desc)
+	}
+	list, err := base.addJSON(mediaTypeIndex, index{SchemaVersion: 2, MediaType: mediaTypeIndex, Manifests: manifests})
+	require.NoError(t, err)
+	top, err := json.Marshal(index{SchemaVersion: 2, Manifests: []descriptor{list}})
+	require.NoError(t, err)
+	files := []File{{Name: "oci-layout", content: []byte(`{"imageLayoutVersion":"1.0.0"}`)}, {Name: "index.json", content: top}}
+	for digest, blob := range base.blobs {
+		files = append(files, File{Name: blobPath(digest), content: blob})
+	}
+	file := filepath.Join(dir, "base.tar")
+	require.NoError(t, writeFile(file, func(w io.Writer) error {
+		return writeTar(w, releaseTime, true, files)
+	}))
+	return file
+}
+
+// readLayer returns the header and content of the only file in a layer.
+func readLayer(t *testing.T, layer []byte) (*tar.Header, string) {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(layer))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		require.NoError(t, err)
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		return header, string(content)
+	}
+}
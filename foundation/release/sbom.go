@@ -0,0 +1,124 @@
+package release
+
+import (
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+const cycloneDXVersion = "1.5"
+
+// SBOM describes the modules compiled into binary as a CycloneDX JSON document.
+// The dependencies between modules are read from graph, which is the output of 'go mod graph'.
+// Modules in the graph that aren't compiled into the binary, like those only needed for tests, are left out.
+func SBOM(binary, version string, graph []byte, timestamp time.Time) ([]byte, error) {
+	info, err := buildinfo.ReadFile(binary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build info: %w", err)
+	}
+	main := info.Main.Path
+	mainRef := purl(main, "v"+strings.TrimPrefix(version, "v"))
+	doc := cycloneDX{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: cycloneDXVersion,
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: timestamp.UTC().Format(time.RFC3339),
+			Component: cycloneDXComponent{
+				Type:    "application",
+				BOMRef:  mainRef,
+				Name:    main,
+				Version: version,
+				PURL:    mainRef,
+			},
+		},
+		Components: []cycloneDXComponent{},
+	}
+	// selected maps the path of each compiled module to its ref, and nodes maps the graph's name for the selected version to the same ref.
+	selected := map[string]string{main: mainRef}
+	nodes := map[string]string{main: mainRef}
+	for _, dep := range info.Deps {
+		ref := purl(dep.Path, dep.Version)
+		component := cycloneDXComponent{Type: "library", BOMRef: ref, Name: dep.Path, Version: dep.Version, PURL: ref}
+		if dep.Replace != nil {
+			component.Properties = []cycloneDXProperty{{Name: "go:replace", Value: dep.Replace.Path + "@" + dep.Replace.Version}}
+		}
+		doc.Components = append(doc.Components, component)
+		selected[dep.Path] = ref
+		nodes[dep.Path+"@"+dep.Version] = ref
+	}
+
+	dependsOn := map[string]map[string]bool{}
+	for ref := range maps.Values(selected) {
+		dependsOn[ref] = map[string]bool{}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(graph))
+	for scanner.Scan() {
+		from, to, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			continue
+		}
+		// Only the requirements of selected versions count, and they resolve to whichever version was selected.
+		fromRef, ok := nodes[from]
+		if !ok {
+			continue
+		}
+		toPath, _, _ := strings.Cut(to, "@")
+		if toRef, ok := selected[toPath]; ok && toRef != fromRef {
+			dependsOn[fromRef][toRef] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, ref := range slices.Sorted(maps.Keys(dependsOn)) {
+		doc.Dependencies = append(doc.Dependencies, cycloneDXDependency{
+			Ref:       ref,
+			DependsOn: slices.Sorted(maps.Keys(dependsOn[ref])),
+		})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func purl(path, version string) string {
+	return "pkg:golang/" + path + "@" + version
+}
+
+type cycloneDX struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version"`
+	PURL       string              `json:"purl"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
@@ -0,0 +1,55 @@
+package release
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestSBOM(t *testing.T) {
+	// The test binary has build info like any other, with testify and its dependencies compiled in.
+	binary, err := os.Executable()
+	require.NoError(t, err)
+	info, err := buildinfo.ReadFile(binary)
+	require.NoError(t, err)
+	main := info.Main.Path
+	graph := []byte(main + ` github.com/stretchr/testify@v1.10.0
+` + main + ` github.com/jackc/pgx/v5@v5.7.2
+github.com/stretchr/testify@v1.10.0 github.com/davecgh/go-spew@v1.1.1
+github.com/stretchr/testify@v1.10.0 github.com/stretchr/objx@v0.5.2
+github.com/stretchr/testify@v1.9.0 github.com/pmezard/go-difflib@v1.0.0
+github.com/davecgh/go-spew@v1.1.1 github.com/stretchr/testify@v1.8.0
+`)
+	content, err := SBOM(binary, "1.2.0", graph, releaseTime)
+	require.NoError(t, err)
+
+	var doc cycloneDX
+	require.NoError(t, json.Unmarshal(content, &doc))
+	assert.Equal(t, "CycloneDX", doc.BOMFormat)
+	assert.Equal(t, "2025-02-01T12:00:00Z", doc.Metadata.Timestamp)
+	mainRef := "pkg:golang/" + main + "@v1.2.0"
+	assert.Equal(t, mainRef, doc.Metadata.Component.BOMRef)
+	assert.Contains(t, doc.Components, cycloneDXComponent{
+		Type:    "library",
+		BOMRef:  "pkg:golang/github.com/stretchr/testify@v1.10.0",
+		Name:    "github.com/stretchr/testify",
+		Version: "v1.10.0",
+		PURL:    "pkg:golang/github.com/stretchr/testify@v1.10.0",
+	})
+
+	dependsOn := map[string][]string{}
+	for _, dep := range doc.Dependencies {
+		dependsOn[dep.Ref] = dep.DependsOn
+	}
+	// pgx and objx aren't compiled into the test, and older versions of testify don't count.
+	assert.Equal(t, []string{"pkg:golang/github.com/stretchr/testify@v1.10.0"}, dependsOn[mainRef])
+	assert.Equal(t, []string{"pkg:golang/github.com/davecgh/go-spew@v1.1.1"}, dependsOn["pkg:golang/github.com/stretchr/testify@v1.10.0"])
+	assert.Equal(t, []string{"pkg:golang/github.com/stretchr/testify@v1.10.0"}, dependsOn["pkg:golang/github.com/davecgh/go-spew@v1.1.1"])
+
+	again, err := SBOM(binary, "1.2.0", graph, releaseTime)
+	require.NoError(t, err)
+	assert.Equal(t, content, again)
+}
@@ -0,0 +1,63 @@
+// Package requestid assigns an ID to each request, so log messages and error pages can be correlated.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/saylorsolutions/x/httpx"
+	"net/http"
+)
+
+const (
+	Header = "X-Request-ID"
+
+	maxLength = 64
+)
+
+type ctxKey struct{}
+
+// Middleware assigns a request ID to the request context, and echoes it in the [Header] response header.
+// A request ID given by the client (or a proxy in front of the app) is reused if it's reasonably formed.
+func Middleware() httpx.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(Header)
+			if !valid(id) {
+				id = New()
+			}
+			w.Header().Set(Header, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, id)))
+		})
+	}
+}
+
+// Get returns the request ID set by [Middleware], or an empty string if there isn't one.
+func Get(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// New generates a random request ID.
+func New() string {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		panic("failed to read random bytes for request ID: " + err.Error())
+	}
+	return hex.EncodeToString(id)
+}
+
+// valid limits the characters and length of client supplied IDs, since they end up in logs and pages.
+func valid(id string) bool {
+	if len(id) == 0 || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package requestid
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		given    string
+		expectID string
+	}{
+		"Generated":     {},
+		"From client":   {given: "edge-1234.abc", expectID: "edge-1234.abc"},
+		"Invalid chars": {given: "abc\ndef"},
+		"Too long":      {given: "a123456789012345678901234567890123456789012345678901234567890123456789"},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var seen string
+			handler := Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = Get(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(tc.given) > 0 {
+				req.Header.Set(Header, tc.given)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.NotEmpty(t, seen)
+			assert.Equal(t, seen, rec.Header().Get(Header))
+			if len(tc.expectID) > 0 {
+				assert.Equal(t, tc.expectID, seen)
+			} else {
+				assert.NotEqual(t, tc.given, seen)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package secheaders
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	maxReportSize = 64 * 1024
+	// maxFieldLength caps each text field of a report, since anyone can send one and they end up in the logs.
+	maxFieldLength = 256
+	// maxReportsPerMinute limits how many reports are handled, so that a flood of reports can't flood the logs.
+	maxReportsPerMinute = 60
+)
+
+// Report is a CSP violation report sent by a browser.
+// Both the legacy report-uri format and the Reporting API format are normalized to this type.
+type Report struct {
+	DocumentURL        string `json:"documentURL"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	Disposition        string `json:"disposition"`
+	Sample             string `json:"sample"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	StatusCode         int    `json:"statusCode"`
+}
+
+type legacyReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		BlockedURI         string `json:"blocked-uri"`
+		EffectiveDirective string `json:"effective-directive"`
+		ViolatedDirective  string `json:"violated-directive"`
+		Disposition        string `json:"disposition"`
+		ScriptSample       string `json:"script-sample"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		StatusCode         int    `json:"status-code"`
+	} `json:"csp-report"`
+}
+
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body Report `json:"body"`
+}
+
+// ReportHandler accepts CSP violation reports, and passes each one to the given function.
+// This endpoint must be reachable without authentication, so request bodies are limited in size,
+// control characters are removed from each field and long fields are truncated,
+// and reports beyond a limit per minute are accepted but not passed on.
+func ReportHandler(handle func(r *http.Request, report Report)) http.Handler {
+	limit := &reportLimiter{max: maxReportsPerMinute}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			_ = r.Body.Close()
+		}()
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReportSize))
+		if err != nil {
+			http.Error(w, "Invalid CSP report", http.StatusRequestEntityTooLarge)
+			return
+		}
+		reports, err := parseReports(r.Header.Get("Content-Type"), data)
+		if err != nil {
+			http.Error(w, "Invalid CSP report", http.StatusBadRequest)
+			return
+		}
+		for _, report := range reports {
+			if !limit.allow(time.Now()) {
+				break
+			}
+			handle(r, report.sanitized())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func parseReports(contentType string, data []byte) ([]Report, error) {
+	if strings.HasPrefix(contentType, "application/reports+json") {
+		var batch []reportingAPIReport
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, err
+		}
+		var reports []Report
+		for _, report := range batch {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, report.Body)
+		}
+		return reports, nil
+	}
+	var legacy legacyReport
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+	directive := legacy.Report.EffectiveDirective
+	if len(directive) == 0 {
+		directive = legacy.Report.ViolatedDirective
+	}
+	return []Report{{
+		DocumentURL:        legacy.Report.DocumentURI,
+		BlockedURL:         legacy.Report.BlockedURI,
+		EffectiveDirective: directive,
+		Disposition:        legacy.Report.Disposition,
+		Sample:             legacy.Report.ScriptSample,
+		SourceFile:         legacy.Report.SourceFile,
+		LineNumber:         legacy.Report.LineNumber,
+		StatusCode:         legacy.Report.StatusCode,
+	}}, nil
+}
+
+func (r Report) sanitized() Report {
+	r.DocumentURL = sanitizeField(r.DocumentURL)
+	r.BlockedURL = sanitizeField(r.BlockedURL)
+	r.EffectiveDirective = sanitizeField(r.EffectiveDirective)
+	r.Disposition = sanitizeField(r.Disposition)
+	r.Sample = sanitizeField(r.Sample)
+	r.SourceFile = sanitizeField(r.SourceFile)
+	return r
+}
+
+// sanitizeField removes control and formatting characters, which could forge or disguise log lines,
+// and truncates the field to maxFieldLength characters.
+func sanitizeField(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range s {
+		if unicode.IsControl(c) || unicode.Is(unicode.Cf, c) {
+			continue
+		}
+		if n == maxFieldLength {
+			b.WriteString("...")
+			break
+		}
+		b.WriteRune(c)
+		n++
+	}
+	return b.String()
+}
+
+// reportLimiter allows up to max reports in each minute.
+type reportLimiter struct {
+	mu     sync.Mutex
+	max    int
+	window time.Time
+	count  int
+}
+
+func (l *reportLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.window) >= time.Minute {
+		l.window = now
+		l.count = 0
+	}
+	if l.count >= l.max {
+		return false
+	}
+	l.count++
+	return true
+}
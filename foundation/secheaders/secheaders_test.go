@@ -0,0 +1,123 @@
+package secheaders
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPolicy_Middleware(t *testing.T) {
+	tests := map[string]struct {
+		policy    Policy
+		cspHeader string
+		hsts      bool
+	}{
+		"Default": {
+			policy:    DefaultPolicy(),
+			cspHeader: HeaderCSP,
+			hsts:      true,
+		},
+		"Report only": {
+			policy: func() Policy {
+				p := DefaultPolicy()
+				p.ReportOnly = true
+				p.ReportURI = "/csp-report"
+				p.HSTSMaxAge = 0
+				return p
+			}(),
+			cspHeader: HeaderCSPReportOnly,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var nonces []string
+			handler := tc.policy.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nonces = append(nonces, Nonce(r.Context()))
+			}))
+			var headers []http.Header
+			for i := 0; i < 2; i++ {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				headers = append(headers, rec.Header())
+			}
+			assert.Len(t, nonces, 2)
+			assert.NotEmpty(t, nonces[0])
+			assert.NotEqual(t, nonces[0], nonces[1], "Nonce must be unique per request")
+			for i, h := range headers {
+				csp := h.Get(tc.cspHeader)
+				assert.Contains(t, csp, "'nonce-"+nonces[i]+"'")
+				assert.Contains(t, csp, "frame-ancestors 'none'")
+				assert.Equal(t, "DENY", h.Get("X-Frame-Options"))
+				assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+				assert.NotEmpty(t, h.Get("Referrer-Policy"))
+				assert.NotEmpty(t, h.Get("Permissions-Policy"))
+				assert.Equal(t, tc.hsts, len(h.Get("Strict-Transport-Security")) > 0)
+				if len(tc.policy.ReportURI) > 0 {
+					assert.Contains(t, csp, "report-uri "+tc.policy.ReportURI)
+					assert.NotEmpty(t, h.Get("Reporting-Endpoints"))
+				}
+			}
+		})
+	}
+}
+
+func TestReportHandler(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		body        string
+		expected    []Report
+		status      int
+	}{
+		"Legacy": {
+			contentType: "application/csp-report",
+			body:        `{"csp-report":{"document-uri":"https://example.com/","blocked-uri":"inline","violated-directive":"script-src"}}`,
+			expected:    []Report{{DocumentURL: "https://example.com/", BlockedURL: "inline", EffectiveDirective: "script-src"}},
+			status:      http.StatusNoContent,
+		},
+		"Reporting API": {
+			contentType: "application/reports+json",
+			body:        `[{"type":"csp-violation","body":{"documentURL":"https://example.com/","blockedURL":"eval","effectiveDirective":"script-src"}},{"type":"deprecation","body":{}}]`,
+			expected:    []Report{{DocumentURL: "https://example.com/", BlockedURL: "eval", EffectiveDirective: "script-src"}},
+			status:      http.StatusNoContent,
+		},
+		"Invalid": {
+			contentType: "application/csp-report",
+			body:        `not json`,
+			status:      http.StatusBadRequest,
+		},
+		"Sanitized": {
+			contentType: "application/csp-report",
+			body:        `{"csp-report":{"document-uri":"https://example.com/\n[ERR] forged\u202e","script-sample":"` + strings.Repeat("a", 300) + `"}}`,
+			expected:    []Report{{DocumentURL: "https://example.com/[ERR] forged", Sample: strings.Repeat("a", 256) + "..."}},
+			status:      http.StatusNoContent,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var reports []Report
+			handler := ReportHandler(func(_ *http.Request, report Report) {
+				reports = append(reports, report)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.status, rec.Code)
+			assert.Equal(t, tc.expected, reports)
+		})
+	}
+}
+
+func TestReportLimiter(t *testing.T) {
+	limit := &reportLimiter{max: 2}
+	now := time.Now()
+	assert.True(t, limit.allow(now))
+	assert.True(t, limit.allow(now.Add(time.Second)))
+	assert.False(t, limit.allow(now.Add(2*time.Second)), "Reports beyond the limit should be dropped")
+	assert.True(t, limit.allow(now.Add(time.Minute)), "The limit should reset each minute")
+}
@@ -0,0 +1,72 @@
+package signed
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("new key"), []byte("old key"))
+	signer.now = func() time.Time { return now }
+	oldSigner := NewSigner([]byte("old key"))
+	oldSigner.now = signer.now
+	token := signer.Sign("verify", "42", time.Hour)
+
+	tests := map[string]struct {
+		purpose     string
+		token       string
+		elapsed     time.Duration
+		expectedErr error
+	}{
+		"Valid": {
+			purpose: "verify",
+			token:   token,
+		},
+		"Signed with a rotated key": {
+			purpose: "verify",
+			token:   oldSigner.Sign("verify", "42", time.Hour),
+		},
+		"Expired": {
+			purpose:     "verify",
+			token:       token,
+			elapsed:     time.Hour,
+			expectedErr: ErrExpired,
+		},
+		"Wrong purpose": {
+			purpose:     "invite",
+			token:       token,
+			expectedErr: ErrInvalid,
+		},
+		"Tampered value": {
+			purpose:     "verify",
+			token:       "NDM" + token[strings.IndexByte(token, '.'):],
+			expectedErr: ErrInvalid,
+		},
+		"Extended expiry": {
+			purpose:     "verify",
+			token:       strings.Replace(token, ".17", ".27", 1),
+			expectedErr: ErrInvalid,
+		},
+		"Malformed": {
+			purpose:     "verify",
+			token:       "garbage",
+			expectedErr: ErrInvalid,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			signer.now = func() time.Time { return now.Add(tc.elapsed) }
+			val, err := signer.Verify(tc.purpose, tc.token)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, val)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "42", val)
+		})
+	}
+}
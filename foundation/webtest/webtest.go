@@ -0,0 +1,312 @@
+// Package webtest is an HTTP client for end-to-end tests, which keeps cookies and follows redirects like a browser.
+// Forms are submitted from rendered pages with the values they already have, so hidden fields like CSRF tokens don't need to be assembled by hand.
+package webtest
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Client sends requests to a test server as a single browser would.
+type Client struct {
+	t      testing.TB
+	base   *url.URL
+	client *http.Client
+}
+
+// NewClient creates a client for srv with its own cookie jar.
+// Paths given to the client are relative to prefix, which should be the server's URL prefix if it has one.
+func NewClient(t testing.TB, srv *httptest.Server, prefix string) *Client {
+	t.Helper()
+	base, err := url.Parse(srv.URL + strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		t.Fatalf("Invalid server URL: %v", err)
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("Failed to create cookie jar: %v", err)
+	}
+	// The server's client trusts its certificate, which is copied so each Client has its own cookies.
+	client := *srv.Client()
+	client.Jar = jar
+	return &Client{t: t, base: base, client: &client}
+}
+
+// Get requests the path, and follows redirects.
+func (c *Client) Get(path string) *Response {
+	c.t.Helper()
+	return c.send(http.MethodGet, c.resolve(path), nil)
+}
+
+// PostForm posts values to the path, and follows redirects.
+func (c *Client) PostForm(path string, values url.Values) *Response {
+	c.t.Helper()
+	return c.send(http.MethodPost, c.resolve(path), values)
+}
+
+// Submit submits the form on the page with the given action, after setting values over the ones it already has.
+func (c *Client) Submit(page *Response, action string, values url.Values) *Response {
+	c.t.Helper()
+	form, ok := page.Form(action)
+	if !ok {
+		c.t.Fatalf("No form on %s is submitted to %s", page.Path, action)
+	}
+	for name, value := range values {
+		form.Values[name] = value
+	}
+	return c.send(form.Method, form.URL, form.Values)
+}
+
+// Cookie returns the cookie with the name that would be sent to the server, or nil if there isn't one.
+func (c *Client) Cookie(name string) *http.Cookie {
+	for _, cookie := range c.client.Jar.Cookies(c.base) {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func (c *Client) resolve(path string) *url.URL {
+	u, err := url.Parse(path)
+	if err != nil {
+		c.t.Fatalf("Invalid path '%s': %v", path, err)
+	}
+	u.Path = c.base.Path + u.Path
+	return c.base.ResolveReference(u)
+}
+
+func (c *Client) send(method string, target *url.URL, values url.Values) *Response {
+	c.t.Helper()
+	var body io.Reader
+	if method == http.MethodGet && values != nil {
+		u := *target
+		u.RawQuery = values.Encode()
+		target = &u
+	} else if values != nil {
+		body = strings.NewReader(values.Encode())
+	}
+	req, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		c.t.Fatalf("Failed to create request for %s %s: %v", method, target, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatalf("Failed to send %s %s: %v", method, target, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatalf("Failed to read response to %s %s: %v", method, target, err)
+	}
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       string(content),
+		URL:        resp.Request.URL,
+		Path:       strings.TrimPrefix(resp.Request.URL.Path, c.base.Path),
+		prefix:     c.base.Path,
+	}
+}
+
+// Response is the response at the end of any redirects.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+	// URL is where the response came from, after following redirects.
+	URL *url.URL
+	// Path is the path of URL, without the client's prefix.
+	Path   string
+	prefix string
+}
+
+// Form is a form on a page, with the values it would submit as-is.
+type Form struct {
+	Method string
+	// URL is the form's action, resolved against the page's URL.
+	URL    *url.URL
+	Values url.Values
+}
+
+// Forms parses the forms on the page.
+// Forms submitted by htmx are included too, with their hx-post or hx-get attribute as the action.
+func (r *Response) Forms() []Form {
+	doc, err := html.Parse(strings.NewReader(r.Body))
+	if err != nil {
+		return nil
+	}
+	var forms []Form
+	for n := range doc.Descendants() {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Form {
+			forms = append(forms, r.parseForm(n))
+		}
+	}
+	return forms
+}
+
+// Form returns the form submitted to action, which is relative to the client's prefix like other paths.
+func (r *Response) Form(action string) (Form, bool) {
+	for _, form := range r.Forms() {
+		if form.URL.Path == r.prefix+action {
+			return form, true
+		}
+	}
+	return Form{}, false
+}
+
+func (r *Response) parseForm(n *html.Node) Form {
+	form := Form{Method: http.MethodGet, Values: url.Values{}}
+	action := attr(n, "action")
+	if method, ok := lookupAttr(n, "method"); ok {
+		form.Method = strings.ToUpper(method)
+	}
+	if hxPost, ok := lookupAttr(n, "hx-post"); ok {
+		action, form.Method = hxPost, http.MethodPost
+	} else if hxGet, ok := lookupAttr(n, "hx-get"); ok {
+		action, form.Method = hxGet, http.MethodGet
+	}
+	form.URL = r.URL
+	if u, err := r.URL.Parse(action); err == nil {
+		form.URL = u
+	}
+	for field := range n.Descendants() {
+		if field.Type != html.ElementNode {
+			continue
+		}
+		name, ok := lookupAttr(field, "name")
+		if !ok {
+			continue
+		}
+		switch field.DataAtom {
+		case atom.Input:
+			switch strings.ToLower(attr(field, "type")) {
+			case "submit", "button", "image", "reset", "file":
+			case "checkbox", "radio":
+				if _, checked := lookupAttr(field, "checked"); checked {
+					value, ok := lookupAttr(field, "value")
+					if !ok {
+						value = "on"
+					}
+					form.Values.Add(name, value)
+				}
+			default:
+				form.Values.Add(name, attr(field, "value"))
+			}
+		case atom.Textarea:
+			form.Values.Add(name, text(field))
+		case atom.Select:
+			if value, ok := selected(field); ok {
+				form.Values.Add(name, value)
+			}
+		}
+	}
+	return form
+}
+
+// selected returns the value of the selected option, or the first one if none are.
+func selected(n *html.Node) (string, bool) {
+	var (
+		first    string
+		hasFirst bool
+	)
+	for option := range n.Descendants() {
+		if option.Type != html.ElementNode || option.DataAtom != atom.Option {
+			continue
+		}
+		value, ok := lookupAttr(option, "value")
+		if !ok {
+			value = strings.TrimSpace(text(option))
+		}
+		if _, ok := lookupAttr(option, "selected"); ok {
+			return value, true
+		}
+		if !hasFirst {
+			first, hasFirst = value, true
+		}
+	}
+	return first, hasFirst
+}
+
+func lookupAttr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func attr(n *html.Node, key string) string {
+	val, _ := lookupAttr(n, key)
+	return val
+}
+
+func text(n *html.Node) string {
+	var buf strings.Builder
+	for child := range n.Descendants() {
+		if child.Type == html.TextNode {
+			buf.WriteString(child.Data)
+		}
+	}
+	return buf.String()
+}
+
+// Log records what's logged through it, and can be used as an audit.LogDelegate to assert on audit events.
+type Log struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *Log) Info(msg string, args ...any) {
+	l.record("[INF] ", msg, args)
+}
+
+func (l *Log) Warn(msg string, args ...any) {
+	l.record("[WRN] ", msg, args)
+}
+
+func (l *Log) Error(msg string, args ...any) {
+	l.record("[ERR] ", msg, args)
+}
+
+func (l *Log) Debug(msg string, args ...any) {
+	l.record("[DBG] ", msg, args)
+}
+
+func (l *Log) record(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+fmt.Sprintf(msg, args...))
+}
+
+// Messages returns what's been logged so far, each with a level prefix like "[INF] ".
+func (l *Log) Messages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.messages...)
+}
+
+// Contains reports whether any message logged so far contains substr.
+func (l *Log) Contains(substr string) bool {
+	for _, msg := range l.Messages() {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
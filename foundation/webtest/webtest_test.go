@@ -0,0 +1,106 @@
+package webtest
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const formPage = `<html><body>
+<form action="/app/save" method="post">
+	<input type="hidden" name="csrf" value="token" />
+	<input type="text" name="name" value="Widget" />
+	<input type="checkbox" name="active" value="true" checked />
+	<input type="checkbox" name="archived" value="true" />
+	<textarea name="notes">Some notes</textarea>
+	<select name="size"><option value="s">Small</option><option value="m" selected>Medium</option></select>
+	<button>Save</button>
+</form>
+<form hx-post="/app/delete"><input type="hidden" name="csrf" value="token" /></form>
+</body></html>`
+
+func TestResponse_Forms(t *testing.T) {
+	page := &Response{URL: &url.URL{Scheme: "https", Host: "example.com", Path: "/app/edit"}, Body: formPage, prefix: "/app"}
+	tests := map[string]struct {
+		action         string
+		expectedMethod string
+		expected       url.Values
+	}{
+		"Action": {
+			action:         "/save",
+			expectedMethod: http.MethodPost,
+			expected: url.Values{
+				"csrf":   {"token"},
+				"name":   {"Widget"},
+				"active": {"true"},
+				"notes":  {"Some notes"},
+				"size":   {"m"},
+			},
+		},
+		"htmx": {
+			action:         "/delete",
+			expectedMethod: http.MethodPost,
+			expected:       url.Values{"csrf": {"token"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			form, ok := page.Form(tc.action)
+			require.True(t, ok)
+			assert.Equal(t, tc.expectedMethod, form.Method)
+			assert.Equal(t, "https://example.com/app"+tc.action, form.URL.String())
+			assert.Equal(t, tc.expected, form.Values)
+		})
+	}
+
+	_, ok := page.Form("/missing")
+	assert.False(t, ok)
+}
+
+func TestClient_Submit(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /app/edit", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", Secure: true})
+		_, _ = fmt.Fprint(w, formPage)
+	})
+	mux.HandleFunc("POST /app/save", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || r.FormValue("csrf") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.Redirect(w, r, "/app/saved?name="+url.QueryEscape(r.FormValue("name"))+"&session="+cookie.Value, http.StatusFound)
+	})
+	mux.HandleFunc("GET /app/saved", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "Saved %s in %s", r.FormValue("name"), r.FormValue("session"))
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	client := NewClient(t, srv, "/app")
+	page := client.Get("/edit")
+	assert.Equal(t, http.StatusOK, page.StatusCode)
+	assert.Equal(t, "/edit", page.Path)
+	assert.Equal(t, "abc", client.Cookie("session").Value)
+
+	saved := client.Submit(page, "/save", url.Values{"name": {"Gadget"}})
+	assert.Equal(t, http.StatusOK, saved.StatusCode)
+	assert.Equal(t, "/saved", saved.Path)
+	assert.Equal(t, "Saved Gadget in abc", saved.Body)
+
+	rejected := client.PostForm("/save", url.Values{"name": {"Gadget"}})
+	assert.Equal(t, http.StatusForbidden, rejected.StatusCode)
+}
+
+func TestLog(t *testing.T) {
+	var log Log
+	log.Info("%s: %s", "bob", "Authenticated")
+	log.Debug("failed authentication")
+	assert.Equal(t, []string{"[INF] bob: Authenticated", "[DBG] failed authentication"}, log.Messages())
+	assert.True(t, log.Contains("Authenticated"))
+	assert.False(t, log.Contains("Logged out"))
+}
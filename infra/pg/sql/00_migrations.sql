@@ -0,0 +1,5 @@
+-- Each script records itself here once it has been applied, so the app can tell whether the schema it needs is in place.
+create table if not exists schema_migrations (
+    name text primary key,
+    applied_at timestamptz not null default current_timestamp
+);
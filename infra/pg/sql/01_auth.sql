@@ -8,6 +8,7 @@ create table users
     pass_hash text not null
 );
 
+-- feature:authz begin
 create table authorizations
 (
     id bigserial not null primary key,
@@ -42,6 +43,7 @@ where
     (ua.revoked is null or ua.revoked > current_timestamp)
 ;
 
+-- feature:authz end
 create or replace function gen_passwd(p_plaint_text text) returns text as $$
 begin
     return crypt(p_plaint_text, gen_salt('bf', 15));
@@ -62,6 +64,7 @@ begin
 end;
 $$ language plpgsql;
 
+-- feature:audit begin
 create table user_audit
 (
     username text not null,
@@ -69,6 +72,7 @@ create table user_audit
     event_time timestamp not null default current_timestamp
 );
 
+-- feature:audit end
 create table session
 (
     id bigserial not null primary key,
@@ -136,3 +140,5 @@ create or replace function create_session(p_username text) returns text as $$
         return r_session_key;
     end;
 $$ language plpgsql;
+
+insert into schema_migrations (name) values ('01_auth') on conflict do nothing;
@@ -0,0 +1,55 @@
+-- Service accounts are users that can't log in with a password, and only authenticate with API tokens.
+alter table users add column service_account bool not null default false;
+
+create table api_token
+(
+    id bigserial not null primary key,
+    user_id bigint not null,
+    name text not null,
+    -- Only a SHA-256 hash of the token is stored, the token itself is shown once when it's created.
+    token_hash text not null unique,
+    -- The first few characters of the token, so users can tell their tokens apart.
+    hint text not null,
+    admin bool not null default false,
+    created_at timestamp not null default current_timestamp,
+    expires_at timestamp null,
+    last_used_at timestamp null,
+    revoked_at timestamp null,
+    foreign key (user_id) references users(id) on delete cascade
+);
+
+-- feature:authz begin
+-- Tokens are scoped to a subset of their user's authorizations.
+-- A scope only applies while the user is still granted the authorization.
+create table api_token_scope
+(
+    token_id bigint not null,
+    auth_id bigint not null,
+    foreign key (token_id) references api_token(id) on delete cascade,
+    foreign key (auth_id) references authorizations(id) on delete cascade,
+    primary key (token_id, auth_id)
+);
+
+-- feature:authz end
+create view live_tokens as
+select
+    t.id,
+    t.user_id,
+    u.username,
+    u.service_account,
+    t.name,
+    t.token_hash,
+    t.hint,
+    t.admin and u.admin as admin,
+    t.created_at,
+    t.expires_at,
+    t.last_used_at
+from
+    api_token t
+    join users u on t.user_id = u.id
+where
+    t.revoked_at is null
+    and (t.expires_at is null or t.expires_at > current_timestamp)
+;
+
+insert into schema_migrations (name) values ('02_tokens') on conflict do nothing;
@@ -0,0 +1,61 @@
+-- Users created through signup or an invitation have a verified email address.
+-- Existing users and service accounts don't need one.
+alter table users add column email text null;
+alter table users add column email_verified_at timestamp null;
+-- Addresses are compared case-insensitively, so Bob@example.com can't get a second account.
+create unique index users_email on users (lower(email));
+
+-- Self-signups wait here until the email address is verified, so unverified addresses never become users.
+create table pending_signup
+(
+    id bigserial not null primary key,
+    username text not null,
+    email text not null,
+    pass_hash text not null,
+    created_at timestamp not null default current_timestamp,
+    expires_at timestamp not null default current_timestamp + interval '24 hours'
+);
+
+create table invitation
+(
+    id bigserial not null primary key,
+    email text not null,
+    invited_by bigint null,
+    admin bool not null default false,
+    created_at timestamp not null default current_timestamp,
+    expires_at timestamp not null default current_timestamp + interval '7 days',
+    accepted_at timestamp null,
+    revoked_at timestamp null,
+    foreign key (invited_by) references users(id) on delete set null
+);
+
+-- feature:authz begin
+-- Authorizations granted to the invited user when the invitation is accepted.
+create table invitation_auth
+(
+    invitation_id bigint not null,
+    auth_id bigint not null,
+    foreign key (invitation_id) references invitation(id) on delete cascade,
+    foreign key (auth_id) references authorizations(id) on delete cascade,
+    primary key (invitation_id, auth_id)
+);
+
+-- feature:authz end
+create view open_invitations as
+select
+    i.id,
+    i.email,
+    coalesce(u.username, '') as invited_by,
+    i.admin,
+    i.created_at,
+    i.expires_at
+from
+    invitation i
+    left join users u on i.invited_by = u.id
+where
+    i.accepted_at is null
+    and i.revoked_at is null
+    and i.expires_at > current_timestamp
+;
+
+insert into schema_migrations (name) values ('03_signup') on conflict do nothing;
@@ -0,0 +1,24 @@
+-- Outgoing email is written here in the same transaction as the change that triggers it, and sent by a background sender.
+-- This way mail is never sent for a change that was rolled back, and isn't lost if the mail server is down.
+create table mail_outbox
+(
+    id bigserial not null primary key,
+    -- An empty sender means the app's default MAIL_FROM.
+    from_addr text not null default '',
+    -- Recipients are separated by newlines, since addresses can't contain them but may contain commas.
+    recipients text not null,
+    subject text not null,
+    text_body text not null default '',
+    html_body text not null default '',
+    created_at timestamp not null default current_timestamp,
+    attempts int not null default 0,
+    next_attempt_at timestamp not null default current_timestamp,
+    last_error text null,
+    sent_at timestamp null,
+    -- Set when the sender gives up, either after too many attempts or a permanent failure.
+    failed_at timestamp null
+);
+
+create index mail_outbox_due on mail_outbox (next_attempt_at) where sent_at is null and failed_at is null;
+
+insert into schema_migrations (name) values ('04_outbox') on conflict do nothing;
@@ -0,0 +1,24 @@
+-- Background jobs, claimed by workers with for update skip locked so several app instances can share the queue.
+create table job
+(
+    id bigserial not null primary key,
+    -- Kind selects the handler registered in Go, and the payload is decoded into the type it expects.
+    kind text not null,
+    payload jsonb not null default '{}',
+    -- One of queued, running, done, or dead.
+    -- A running job whose lease has passed is assumed to have lost its worker, and is claimed again.
+    status text not null default 'queued' check (status in ('queued', 'running', 'done', 'dead')),
+    -- Set for jobs that must only be enqueued once, like each run of a recurring job.
+    unique_key text null unique,
+    created_at timestamp not null default current_timestamp,
+    run_at timestamp not null default current_timestamp,
+    attempts int not null default 0,
+    max_attempts int not null default 10,
+    locked_until timestamp null,
+    last_error text null,
+    finished_at timestamp null
+);
+
+create index job_due on job (run_at) where status in ('queued', 'running');
+
+insert into schema_migrations (name) values ('05_jobs') on conflict do nothing;
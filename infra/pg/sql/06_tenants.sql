@@ -0,0 +1,131 @@
+-- Organizations are the tenants that share this deployment.
+-- The slug identifies the organization in URLs, either as a path segment or a subdomain, so it must be a valid DNS label.
+create table organization
+(
+    id bigserial not null primary key,
+    slug text not null unique check (slug ~ '^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$'),
+    name text not null,
+    created_at timestamp not null default current_timestamp
+);
+
+-- Users are shared by every organization, and belong to the ones they're members of.
+-- An organization's admins manage it, without being admins of the whole app.
+create table membership
+(
+    org_id bigint not null,
+    user_id bigint not null,
+    admin bool not null default false,
+    joined_at timestamp not null default current_timestamp,
+    foreign key (org_id) references organization(id) on delete cascade,
+    foreign key (user_id) references users(id) on delete cascade,
+    primary key (org_id, user_id)
+);
+
+-- feature:authz begin
+-- Authorizations granted to a member apply only in their organization, unlike those in user_authz.
+create table membership_authz
+(
+    org_id bigint not null,
+    user_id bigint not null,
+    auth_id bigint not null,
+    granted timestamp not null default current_timestamp,
+    revoked timestamp null,
+    foreign key (org_id, user_id) references membership(org_id, user_id) on delete cascade,
+    foreign key (auth_id) references authorizations(id) on delete cascade,
+    primary key (org_id, user_id, auth_id)
+);
+
+-- This checks row level security as the querying role, so the tenant role only sees its own organization's grants.
+create view member_grants with (security_invoker = true) as
+select
+    ma.org_id,
+    ma.user_id,
+    ma.auth_id,
+    a.auth,
+    ma.granted
+from
+    membership_authz ma
+    join authorizations a on ma.auth_id = a.id
+where
+    (ma.revoked is null or ma.revoked > current_timestamp)
+;
+
+-- feature:authz end
+-- feature:audit begin
+-- Entries recorded while a transaction is scoped to an organization belong to it, and the rest have no organization.
+alter table user_audit add column org_id bigint null references organization(id) on delete cascade;
+
+-- feature:audit end
+-- current_tenant is the organization that the transaction is scoped to, or null if it isn't.
+-- It's set with set_config('app.tenant_id', ..., true), so it only lasts until the end of the transaction.
+create or replace function current_tenant() returns bigint as $$
+    select nullif(current_setting('app.tenant_id', true), '')::bigint;
+$$ language sql stable;
+
+-- Transactions for an organization switch to this role with "set local role", along with setting app.tenant_id.
+-- It's only granted the tenant tables, where the policies below only let it see and change its organization's rows.
+-- Shared tables, like sessions, jobs, and the outbox, aren't granted at all, so a scoped query can't reach them.
+-- The app's own role owns the tables, so it isn't limited by any of this outside of an organization.
+-- Roles are shared by every database in the cluster, so it may already exist, or be created concurrently by another database's migrations.
+do $$
+begin
+    create role yourapp_tenant nologin;
+exception when duplicate_object or unique_violation then
+    null;
+end
+$$;
+grant yourapp_tenant to current_user;
+grant usage on schema public to yourapp_tenant;
+
+alter table organization enable row level security;
+grant select on organization to yourapp_tenant;
+create policy organization_tenant on organization to yourapp_tenant
+    using (id = current_tenant());
+
+alter table membership enable row level security;
+grant select, insert, update, delete on membership to yourapp_tenant;
+create policy membership_tenant on membership to yourapp_tenant
+    using (org_id = current_tenant())
+    with check (org_id = current_tenant());
+
+-- Users are shared, so the tenant role can only read the names of its organization's members.
+alter table users enable row level security;
+grant select (id, username) on users to yourapp_tenant;
+create policy users_tenant on users for select to yourapp_tenant
+    using (id in (select user_id from membership));
+
+-- A user has to be found by name before they're a member, so this looks them up as the app's role, and only returns their ID.
+create or replace function find_user_id(p_username text) returns bigint as $$
+    select id from users where username = p_username;
+$$ language sql stable security definer set search_path = public;
+
+-- feature:authz begin
+alter table membership_authz enable row level security;
+grant select, insert, update, delete on membership_authz to yourapp_tenant;
+create policy membership_authz_tenant on membership_authz to yourapp_tenant
+    using (org_id = current_tenant())
+    with check (org_id = current_tenant());
+grant select on member_grants to yourapp_tenant;
+grant select on authorizations to yourapp_tenant;
+
+-- Member authorizations are limited by a token's scopes, so the tenant role can read the scopes of its members' tokens, but nothing else about them.
+alter table api_token enable row level security;
+grant select (id, user_id) on api_token to yourapp_tenant;
+create policy api_token_tenant on api_token for select to yourapp_tenant
+    using (user_id in (select user_id from membership));
+
+alter table api_token_scope enable row level security;
+grant select on api_token_scope to yourapp_tenant;
+create policy api_token_scope_tenant on api_token_scope for select to yourapp_tenant
+    using (token_id in (select id from api_token));
+
+-- feature:authz end
+-- feature:audit begin
+alter table user_audit enable row level security;
+grant select, insert on user_audit to yourapp_tenant;
+create policy user_audit_tenant on user_audit to yourapp_tenant
+    using (org_id = current_tenant())
+    with check (org_id = current_tenant());
+
+-- feature:audit end
+insert into schema_migrations (name) values ('06_tenants') on conflict do nothing;
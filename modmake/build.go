@@ -3,11 +3,14 @@ package main
 import (
 	. "github.com/saylorsolutions/modmake"
 	"github.com/saylorsolutions/modmake/pkg/git"
+	"os"
 	"runtime"
+	"yourapp/foundation/assets"
 )
 
 const (
 	appMainPath = "./cmd/yourapp"
+	staticPath  = "./cmd/yourapp/static"
 	version     = "0.1.0"
 )
 
@@ -26,7 +29,14 @@ func init() {
 }
 
 func main() {
-	Go().PinLatestV1(23)
+	if releasing(os.Args[1:]) {
+		// Pinning downloads a toolchain from go.dev, and a release is only built from what's already on disk.
+		if err := os.Setenv("GOTOOLCHAIN", "local"); err != nil {
+			panic(err)
+		}
+	} else {
+		Go().PinLatestV1(23)
+	}
 	b := NewBuild()
 	b.Tools().DependsOnRunner("install-templ", "", Go().Install(F("github.com/a-h/templ/cmd/templ@${templVersion}", versions)))
 	b.Generate().DependsOnRunner("gen-templ", "",
@@ -36,6 +46,13 @@ func main() {
 			Go().VetAll(),
 		),
 	)
+	b.Generate().DependsOnRunner("gen-assets", "Fingerprints and precompresses static assets", WithoutContext(func() error {
+		_, err := assets.Generate(staticPath)
+		return err
+	}))
+	b.AddNewStep("integration", "Runs the integration tests against a temporary Postgres server",
+		Go().Test("-tags", "integration", "./..."),
+	).DependsOn(b.Generate())
 	b.Build().DependsOnRunner("build-app", "", Script(
 		RemoveDir(appBuildPath),
 		MkdirAll(appBuildPath, 0755),
@@ -48,5 +65,6 @@ func main() {
 	))
 
 	localBuild(b)
+	releaseBuild(b)
 	b.Execute()
 }
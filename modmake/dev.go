@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	. "github.com/saylorsolutions/modmake"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const pollInterval = 500 * time.Millisecond
+
+var (
+	devBuildPath = Path("./build/dev")
+	// watchDirs are searched for changes to files with one of the watchExts.
+	watchDirs = []string{"cmd", "feature", "foundation", "infra"}
+	watchExts = []string{".go", ".templ", ".css", ".sql"}
+)
+
+// devServer runs a dev build of the app, and rebuilds and restarts it whenever a watched file changes, until ctx is cancelled.
+// A build that fails is reported, and the last good build keeps running until the next change.
+// Pages reload on their own once the app restarts, see foundation/livereload.
+func devServer(ctx context.Context) error {
+	seen, err := watchedFiles()
+	if err != nil {
+		return err
+	}
+	var app *devApp
+	if err := devBuild(ctx, false); err != nil {
+		log.Println("Build failed, waiting for changes:", err)
+	} else {
+		app = startDevApp(ctx)
+	}
+	defer func() {
+		app.stop()
+	}()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current, err := watchedFiles()
+		if err != nil {
+			return err
+		}
+		changed := changedFiles(seen, current)
+		seen = current
+		if len(changed) == 0 {
+			continue
+		}
+		log.Println("Changed:", strings.Join(changed, ", "))
+		regenerate := slices.ContainsFunc(changed, func(file string) bool {
+			return filepath.Ext(file) == ".templ"
+		})
+		if err := devBuild(ctx, regenerate); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Println("Build failed, the last build is still running:", err)
+			continue
+		}
+		app.stop()
+		app = startDevApp(ctx)
+	}
+}
+
+// devBuild builds the app with the dev tag, after generating templ code if needed.
+func devBuild(ctx context.Context, regenerate bool) error {
+	if regenerate {
+		if err := Exec("templ", "generate", "./cmd/yourapp/internal/templates").Run(ctx); err != nil {
+			return err
+		}
+	}
+	return Go().Build(appMainPath).
+		Tags("dev").
+		OutputFilename(devBuildPath.Join(appName)).
+		Run(ctx)
+}
+
+// devApp is a running dev build.
+type devApp struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startDevApp(ctx context.Context) *devApp {
+	appCtx, cancel := context.WithCancel(ctx)
+	app := &devApp{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(app.done)
+		if err := localEnv(Exec(devBuildPath.Join(appName).String())).Run(appCtx); err != nil && appCtx.Err() == nil {
+			log.Println("The app exited, waiting for changes:", err)
+		}
+	}()
+	return app
+}
+
+// stop stops the app and waits for it to exit, so the next one can listen on the same port.
+func (app *devApp) stop() {
+	if app == nil {
+		return
+	}
+	app.cancel()
+	<-app.done
+}
+
+// watchedFiles returns the modification time of each watched file.
+// Generated templ code is skipped, since it changes whenever templates are generated.
+func watchedFiles() (map[string]time.Time, error) {
+	files := map[string]time.Time{}
+	for _, dir := range watchDirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Files can be removed while walking, which is picked up next time.
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				if path != dir && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !slices.Contains(watchExts, filepath.Ext(path)) || strings.HasSuffix(path, "_templ.go") {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			files[path] = info.ModTime()
+			return nil
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// changedFiles returns the files that were added, removed, or modified, in sorted order.
+func changedFiles(before, after map[string]time.Time) []string {
+	var changed []string
+	for file, modTime := range after {
+		if prev, ok := before[file]; !ok || !prev.Equal(modTime) {
+			changed = append(changed, file)
+		}
+	}
+	for file := range before {
+		if _, ok := after[file]; !ok {
+			changed = append(changed, file)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
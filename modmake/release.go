@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	. "github.com/saylorsolutions/modmake"
+	"github.com/saylorsolutions/modmake/pkg/git"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"yourapp/foundation/assets"
+	"yourapp/foundation/release"
+)
+
+const releaseName = "yourapp"
+
+var (
+	// releasePlatforms are the GOOS/GOARCH pairs that a release has binaries for.
+	// The linux ones are also built into the container image.
+	releasePlatforms = []string{"linux/amd64", "linux/arm64", "darwin/amd64", "darwin/arm64", "windows/amd64"}
+	releaseDistPath  = Path("./dist", releaseName)
+	dockerfilePath   = Path("./infra/app/Dockerfile")
+	// baseImagePath is an OCI image layout archive of the image that the Dockerfile's last stage is built FROM.
+	// It's read from disk so that building the image doesn't need a registry, see the README for how to export it.
+	baseImagePath = Path("./build/base-image.tar")
+)
+
+func releaseBuild(base *Build) {
+	rel := NewBuild()
+	sources := rel.AddNewStep("sources", "Generates templates and static assets without using the network", Task(generateRelease))
+	binaries := rel.AddNewStep("binaries", "Cross-compiles the application for each release platform", Task(buildReleaseBinaries)).DependsOn(sources)
+	archives := rel.AddNewStep("archives", "Packages each release binary in an archive", WithoutContext(writeReleaseArchives)).DependsOn(binaries)
+	sbom := rel.AddNewStep("sbom", "Writes a CycloneDX SBOM of the modules compiled into the application", Task(writeReleaseSBOM)).DependsOn(binaries)
+	image := rel.AddNewStep("image", "Builds the container image from infra/app/Dockerfile as an OCI image archive", WithoutContext(writeReleaseImage)).DependsOn(binaries)
+
+	base.ImportAndLink("release", rel)
+	base.AddNewStep("release", "Builds everything for a release in dist, and writes their checksums", WithoutContext(writeReleaseChecksums)).
+		DependsOn(archives).
+		DependsOn(sbom).
+		DependsOn(image)
+}
+
+// generateRelease does the same as the generate step, except templ is run from the module cache instead of being fetched with 'go get'.
+func generateRelease(ctx context.Context) error {
+	err := offline(Go().Command("run", "github.com/a-h/templ/cmd/templ", "generate", "-path", "./cmd/yourapp/internal/templates")).Run(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to generate templates: %w", err)
+	}
+	_, err = assets.Generate(staticPath)
+	return err
+}
+
+func buildReleaseBinaries(ctx context.Context) error {
+	if err := RemoveDir(releaseDistPath).Run(ctx); err != nil {
+		return err
+	}
+	if err := releaseDistPath.MkdirAll(0755); err != nil {
+		return err
+	}
+	// The linker flags are passed as one, since modmake doesn't keep them in order, and they're recorded in the binary.
+	ldflags := fmt.Sprintf("-s -w -X 'main.version=%s' -X 'main.gitHash=%s'", version, git.CommitHash())
+	for _, platform := range releasePlatforms {
+		goos, goarch, _ := strings.Cut(platform, "/")
+		binary := releaseBinary(platform)
+		if err := RemoveDir(binary.Dir()).Run(ctx); err != nil {
+			return err
+		}
+		err := offline(Go().Build(appMainPath).
+			OS(goos).
+			Arch(goarch).
+			Env("CGO_ENABLED", "0").
+			TrimPath().
+			LinkerFlags(ldflags).
+			OutputFilename(binary)).
+			Run(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to build for %s: %w", platform, err)
+		}
+	}
+	return nil
+}
+
+func writeReleaseArchives() error {
+	modTime, err := releaseTime()
+	if err != nil {
+		return err
+	}
+	for _, platform := range releasePlatforms {
+		binary := releaseBinary(platform)
+		file := release.File{Source: binary.String(), Name: binary.Base().String(), Mode: 0755}
+		archive := releaseArtifact(strings.ReplaceAll(platform, "/", "_"))
+		if strings.HasPrefix(platform, "windows/") {
+			err = release.Zip(archive+".zip", modTime, file)
+		} else {
+			err = release.TarGz(archive+".tar.gz", modTime, file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeReleaseSBOM(ctx context.Context) error {
+	modTime, err := releaseTime()
+	if err != nil {
+		return err
+	}
+	var graph bytes.Buffer
+	if err := offline(Go().Command("mod", "graph")).Stdout(&graph).Run(ctx); err != nil {
+		return fmt.Errorf("failed to read the module graph: %w", err)
+	}
+	// Every platform has the same modules, since none of them are only built for some.
+	sbom, err := release.SBOM(releaseBinary(releasePlatforms[0]).String(), version, graph.Bytes(), modTime)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(releaseArtifact("sbom")+".cdx.json", sbom, 0644)
+}
+
+func writeReleaseImage() error {
+	modTime, err := releaseTime()
+	if err != nil {
+		return err
+	}
+	if !baseImagePath.Exists() {
+		return fmt.Errorf("the base image isn't at '%s', see the README for how to export it", baseImagePath)
+	}
+	f, err := dockerfilePath.Open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	stage, err := release.ParseDockerfile(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse '%s': %w", dockerfilePath, err)
+	}
+	img := &release.Image{
+		Stage:    stage,
+		Base:     baseImagePath.String(),
+		Binaries: map[string]string{},
+		Name:     releaseName + ":" + version,
+		Created:  modTime,
+	}
+	for _, platform := range releasePlatforms {
+		if strings.HasPrefix(platform, "linux/") {
+			img.Binaries[platform] = releaseBinary(platform).String()
+		}
+	}
+	return img.Write(releaseArtifact("image") + ".tar")
+}
+
+func writeReleaseChecksums() error {
+	entries, err := os.ReadDir(releaseDistPath.String())
+	if err != nil {
+		return err
+	}
+	checksums := releaseArtifact("checksums") + ".txt"
+	var files []string
+	for _, entry := range entries {
+		if file := releaseDistPath.Join(entry.Name()).String(); file != checksums {
+			files = append(files, file)
+		}
+	}
+	return release.WriteChecksums(checksums, files...)
+}
+
+// releaseBinary is where the binary for a platform like "linux/amd64" is built, following modmake's layout for app variants.
+func releaseBinary(platform string) PathString {
+	variant := strings.ReplaceAll(platform, "/", "_")
+	name := releaseName
+	if strings.HasPrefix(platform, "windows/") {
+		name += ".exe"
+	}
+	return Path("./build", releaseName+"_"+variant, name)
+}
+
+// releaseArtifact returns the path in dist for an artifact, without an extension.
+func releaseArtifact(kind string) string {
+	return releaseDistPath.Join(fmt.Sprintf("%s_%s_%s", releaseName, version, kind)).String()
+}
+
+// releaseTime is the time recorded in everything built for a release, so building the same commit again gives the same bytes.
+// It's the commit time, unless SOURCE_DATE_EPOCH is set.
+func releaseTime() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if len(epoch) == 0 {
+		var err error
+		epoch, err = git.ExecOutput("log", "-1", "--format=%ct")
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to get the commit time: %w", err)
+		}
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(epoch), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid source date epoch '%s': %w", epoch, err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// releasing reports whether the args ask for a release step, which must be built without the network.
+func releasing(args []string) bool {
+	for _, arg := range args {
+		if arg == "release" || strings.HasPrefix(arg, "release:") {
+			return true
+		}
+	}
+	return false
+}
+
+// offline keeps the go command from using the network, so a release is only built from modules that are already in the module cache.
+func offline[T interface{ Env(key, value string) T }](cmd T) T {
+	return cmd.
+		Env("GOPROXY", "off").
+		Env("GOFLAGS", "-mod=readonly").
+		Env("GOTOOLCHAIN", "local")
+}
@@ -0,0 +1,8 @@
+//go:build tools
+
+package main
+
+// The templ command is required by go.mod, so that a release can generate templates from the module cache.
+import (
+	_ "github.com/a-h/templ/cmd/templ"
+)